@@ -20,6 +20,7 @@ import (
     `io`
 
     `github.com/bytedance/sonic/ast`
+    `github.com/bytedance/sonic/encoder`
     `github.com/bytedance/sonic/internal/rt`
 )
 
@@ -94,6 +95,55 @@ type Config struct {
 
     // Encode Infinity or Nan float into `null`, instead of returning an error.
     EncodeNullForInfOrNan bool
+
+    // Canonical indicates encoder to output the canonical form of RFC 8785 (JCS), which has
+    // no whitespace, sorted object keys, minimal string escapes and ES6 number serialization.
+    // WARNING: This hurts performance A LOT, USE WITH CARE.
+    Canonical bool
+
+    // RedactSensitive indicates encoder to replace the struct fields tagged with `json:",sensitive"`
+    // by `"***"`, or by the output of Redactor if it is set.
+    RedactSensitive bool
+
+    // Redactor returns the JSON text replacing the value of the sensitive field name of struct vt,
+    // or nil to omit the field. It is called only once for each field and the results are cached.
+    Redactor encoder.Redactor
+
+    // EscapeASCII indicates encoder to escape all the non-ASCII characters in the strings
+    // as \uXXXX, so that the output JSON is pure ASCII.
+    EscapeASCII bool
+
+    // EscapeSlash indicates encoder to escape the '/' in the strings as `\/`.
+    EscapeSlash bool
+
+    // FloatFormat is the format of the floats emitted by encoder, which is replaced by
+    // the float format options in the json tags of struct fields, see encoder.FloatFormat.
+    // If it is invalid, the encoding fails with the error of encoder.SetFloatFormat.
+    FloatFormat encoder.FloatFormat
+
+    // EscapeChars is the extra characters to be escaped as \uXXXX in the strings by encoder.
+    EscapeChars string
+
+    // Int64AsString indicates encoder to emit the 64-bit integers as JSON strings, for the
+    // JavaScript consumers which lose the precision above 2^53, and decoder to accept both
+    // the strings and the numbers for them.
+    Int64AsString bool
+
+    // LargeInt64AsString is like Int64AsString, but encoder only emits the 64-bit integers
+    // out of the safe range of JavaScript, [-(2^53-1), 2^53-1], as strings.
+    LargeInt64AsString bool
+
+    // DetectCycles indicates encoder to fail with an error naming the types on the cycle
+    // if a value contains itself, see encoder.DetectCycles.
+    DetectCycles bool
+
+    // EncoderMaxDepth limits the nesting depth of the arrays and objects emitted by encoder,
+    // 0 for unlimited, see encoder.Encoder.SetMaxDepth.
+    EncoderMaxDepth int
+
+    // EncoderMaxSize limits the length in bytes of the output of encoder, 0 for unlimited,
+    // see encoder.Encoder.SetMaxSize.
+    EncoderMaxSize int
 }
  
 var (
@@ -154,6 +204,20 @@ type Encoder interface {
     SetIndent(prefix, indent string)
 }
 
+// invalidEncoder is the Encoder of the Config with the invalid encoder options,
+// which fails to encode any value.
+type invalidEncoder struct {
+    err error
+}
+
+func (self invalidEncoder) Encode(val interface{}) error {
+    return self.err
+}
+
+func (self invalidEncoder) SetEscapeHTML(on bool) {}
+
+func (self invalidEncoder) SetIndent(prefix, indent string) {}
+
 // Decoder decodes JSON from io.Read
 type Decoder interface {
     // Decode reads the next JSON-encoded value from its input and stores it in the value pointed to by v.
@@ -236,6 +300,58 @@ func GetCopyFromString(src string, path ...interface{}) (ast.Node, error) {
     return ast.NewSearcher(src).GetByPathCopy(path...)
 }
 
+// GetByPointer searches and locates the given JSON Pointer (RFC 6901) from src json,
+// like `/a/b~1c/0`, and returns a ast.Node representing the partially json.
+//
+// Considering memory safety, the returned JSON is **Copied** from the input
+func GetByPointer(src []byte, ptr string) (ast.Node, error) {
+    s := ast.NewSearcher(rt.Mem2Str(src))
+    s.CopyReturn = true
+    return s.GetByPointer(ptr)
+}
+
+// SetByPath sets the value at the given path of src json, and returns the new json.
+// The value is marshaled and spliced into src directly, the untouched parts of src keep their formatting.
+//
+// If the value doesn't exist, it is added to the parent object,
+// or appended to the parent array if the last path arg equals to the length of the array.
+//
+// Notice: It expects the src json is **Well-formed**, and doesn't modify it.
+func SetByPath(src []byte, value interface{}, path ...interface{}) ([]byte, error) {
+    return SetByPathWithOptions(src, value, ast.EditOptions{}, path...)
+}
+
+// SetByPathWithOptions is same with SetByPath, with specific options of editing,
+// like creating the missing intermediate objects.
+func SetByPathWithOptions(src []byte, value interface{}, opts ast.EditOptions, path ...interface{}) ([]byte, error) {
+    raw, err := Marshal(value)
+    if err != nil {
+        return nil, err
+    }
+    return ast.SetRawByPath(rt.Mem2Str(src), rt.Mem2Str(raw), opts, path...)
+}
+
+// DeleteByPath deletes the value at the given path of src json, and returns the new json.
+// The untouched parts of src keep their formatting.
+//
+// Notice: It expects the src json is **Well-formed**, and doesn't modify it.
+func DeleteByPath(src []byte, path ...interface{}) ([]byte, error) {
+    return ast.DeleteRawByPath(rt.Mem2Str(src), path...)
+}
+
+// InsertByPath inserts the value into the array at the given path of src json, and returns the new json.
+// The last path arg must be an index, the value is inserted before the element at the index,
+// or appended to the array if the index equals to the length of the array.
+//
+// Notice: It expects the src json is **Well-formed**, and doesn't modify it.
+func InsertByPath(src []byte, value interface{}, path ...interface{}) ([]byte, error) {
+    raw, err := Marshal(value)
+    if err != nil {
+        return nil, err
+    }
+    return ast.InsertRawByPath(rt.Mem2Str(src), rt.Mem2Str(raw), path...)
+}
+
 // Valid reports whether data is a valid JSON encoding.
 func Valid(data []byte) bool {
     return ConfigDefault.Valid(data)
@@ -23,6 +23,7 @@ import (
     `runtime`
     `unsafe`
 
+    `github.com/bytedance/sonic/decoder`
     `github.com/bytedance/sonic/encoder`
     `github.com/bytedance/sonic/internal/native`
     `github.com/bytedance/sonic/internal/native/types`
@@ -101,9 +102,21 @@ func (self *Parser) skip() (int, types.ParsingError) {
     return start, 0
 }
 
-func (self *Node) encodeInterface(buf *[]byte) error {
+func (self *Node) encodeInterface(buf *[]byte, ind *indenter) error {
     //WARN: NOT compatible with json.Encoder
-    return encoder.EncodeInto(buf, self.packAny(), encoder.NoEncoderNewline)
+    if ind == nil {
+        return encoder.EncodeInto(buf, self.packAny(), encoder.NoEncoderNewline)
+    }
+    out, err := encoder.EncodeIndented(self.packAny(), ind.linePrefix(), ind.indent, encoder.NoEncoderNewline)
+    if err != nil {
+        return err
+    }
+    *buf = append(*buf, out...)
+    return nil
+}
+
+func decodeRaw(src string, val interface{}) error {
+    return decoder.NewDecoder(src).Decode(val)
 }
 
 func (self *Parser) skipFast() (int, types.ParsingError) {
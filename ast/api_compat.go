@@ -72,8 +72,14 @@ func (self *Parser) skipFast() (int, types.ParsingError) {
     return s, 0
 }
 
-func (self *Node) encodeInterface(buf *[]byte) error {
-    out, err := json.Marshal(self.packAny())
+func (self *Node) encodeInterface(buf *[]byte, ind *indenter) error {
+    var out []byte
+    var err error
+    if ind == nil {
+        out, err = json.Marshal(self.packAny())
+    } else {
+        out, err = json.MarshalIndent(self.packAny(), ind.linePrefix(), ind.indent)
+    }
     if err != nil {
         return err
     }
@@ -81,6 +87,10 @@ func (self *Node) encodeInterface(buf *[]byte) error {
     return nil
 }
 
+func decodeRaw(src string, val interface{}) error {
+    return json.Unmarshal([]byte(src), val)
+}
+
 func (self *Parser) getByPath(validate bool, path ...interface{}) (int, types.ParsingError) {
     for _, p := range path {
         if idx, ok := p.(int); ok && idx >= 0 {
@@ -18,6 +18,7 @@ package ast
 
 import (
 	"sort"
+	"sync"
 	"unsafe"
 
 	"github.com/bytedance/sonic/internal/caching"
@@ -29,6 +30,8 @@ type linkedNodes struct {
     head   nodeChunk
     tail   []*nodeChunk
     size   int
+    frozen bool // shared by snapshots, must not be written
+    mu     *sync.RWMutex // the lock of the container in SharedNode
 }
 
 func (self *linkedNodes) Cap() int {
@@ -121,6 +124,21 @@ func (self *linkedNodes) Set(i int, v Node) {
     }
 }
 
+// copy returns a writable shallow copy of self.
+func (self *linkedNodes) copy() *linkedNodes {
+    ret := &linkedNodes{head: self.head, size: self.size}
+    if len(self.tail) > 0 {
+        ret.tail = make([]*nodeChunk, len(self.tail))
+        for i, c := range self.tail {
+            if c != nil {
+                n := *c
+                ret.tail[i] = &n
+            }
+        }
+    }
+    return ret
+}
+
 func (self *linkedNodes) growTailLength(l int) {
     if l <= len(self.tail) {
         return
@@ -194,6 +212,8 @@ type linkedPairs struct {
     head pairChunk
     tail []*pairChunk
     size int
+    frozen bool // shared by snapshots, must not be written
+    mu *sync.RWMutex // the lock of the container in SharedNode
 }
 
 func (self *linkedPairs) BuildIndex() {
@@ -287,6 +307,27 @@ func (self *linkedPairs) set(i int, v Pair) {
     }
 }
 
+// copy returns a writable shallow copy of self.
+func (self *linkedPairs) copy() *linkedPairs {
+    ret := &linkedPairs{head: self.head, size: self.size}
+    if len(self.tail) > 0 {
+        ret.tail = make([]*pairChunk, len(self.tail))
+        for i, c := range self.tail {
+            if c != nil {
+                n := *c
+                ret.tail[i] = &n
+            }
+        }
+    }
+    if self.index != nil {
+        ret.index = make(map[uint64]int, len(self.index))
+        for k, v := range self.index {
+            ret.index[k] = v
+        }
+    }
+    return ret
+}
+
 func (self *linkedPairs) growTailLength(l int) {
     if l <= len(self.tail) {
         return
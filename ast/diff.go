@@ -0,0 +1,381 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `strconv`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// Change is a change between two JSON documents, in the form of a JSON Patch operation.
+type Change struct {
+    // Op is the operation of the change, can be "add", "remove" or "replace"
+    Op   string
+
+    // Path is the JSON Pointer of the changed value
+    Path string
+
+    // Old is the value before the change, V_NONE for "add"
+    Old  Node
+
+    // New is the value after the change, V_NONE for "remove"
+    New  Node
+}
+
+// DiffOptions controls how Diff compares the values.
+type DiffOptions struct {
+    // CompareNumbersByValue compares the numbers by their exact decimal values, so that 1.0 equals to 1.
+    // Otherwise, the numbers are compared by their literals.
+    CompareNumbersByValue bool
+}
+
+// Diff compares the JSON documents a and b semantically, and returns the changes from a to b.
+// The keys order of objects is ignored, and the numbers are compared by their literals.
+//
+// The changes are ordered, and applying them as a JSON Patch (see NewPatch) to a produces b.
+// The identical raw subtrees are skipped without being parsed.
+func Diff(a, b *Node) ([]Change, error) {
+    return DiffWithOptions(a, b, DiffOptions{})
+}
+
+// DiffWithOptions is same as Diff, except it compares the values with specific options.
+//
+// Arrays are aligned by their longest common subsequence, so inserting or removing elements
+// anywhere produces only those changes, and the unaligned elements at the same place are diffed.
+// The arrays differing in more than 1024 elements are compared index-by-index.
+func DiffWithOptions(a, b *Node, opts DiffOptions) ([]Change, error) {
+    d := differ{opts: opts}
+    if err := d.diff(a, b); err != nil {
+        return nil, err
+    }
+    return d.changes, nil
+}
+
+// NewPatch creates a JSON Patch document of the changes, which can be applied by ApplyPatch.
+func NewPatch(changes []Change) Node {
+    ops := make([]Node, 0, len(changes))
+    for _, c := range changes {
+        op := []Pair{NewPair("op", NewString(c.Op)), NewPair("path", NewString(c.Path))}
+        if c.Op != "remove" {
+            op = append(op, NewPair("value", c.New))
+        }
+        ops = append(ops, NewObject(op))
+    }
+    return NewArray(ops)
+}
+
+type differ struct {
+    opts    DiffOptions
+    path    []byte
+    changes []Change
+}
+
+func (self *differ) change(op string, a *Node, b *Node) error {
+    c := Change{Op: op, Path: string(self.path)}
+    if a != nil {
+        if err := loadLazy(a); err != nil {
+            return err
+        }
+        c.Old = *a
+    }
+    if b != nil {
+        if err := loadLazy(b); err != nil {
+            return err
+        }
+        c.New = *b
+    }
+    self.changes = append(self.changes, c)
+    return nil
+}
+
+// prepareDiff parses the node, and converts the V_ANY node to a raw node without modifying it.
+func prepareDiff(n *Node) (*Node, error) {
+    if err := n.checkRaw(); err != nil {
+        return nil, err
+    }
+    if n.itype() != _V_ANY {
+        return n, nil
+    }
+    buf, err := n.MarshalJSON()
+    if err != nil {
+        return nil, err
+    }
+    ret := NewRaw(string(buf))
+    return &ret, ret.checkRaw()
+}
+
+func isRawEqual(a, b *Node) bool {
+    return a.isRaw() && b.isRaw() && a.toString() == b.toString()
+}
+
+func (self *differ) diff(a, b *Node) error {
+    if isRawEqual(a, b) {
+        return nil
+    }
+    if err := a.Check(); err != nil {
+        return err
+    }
+    if err := b.Check(); err != nil {
+        return err
+    }
+
+    /* the raw nodes have known their types */
+    if ta, tb := a.itype(), b.itype(); ta != tb && ta != _V_ANY && tb != _V_ANY {
+        return self.change("replace", a, b)
+    }
+    pa, err := prepareDiff(a)
+    if err != nil {
+        return err
+    }
+    pb, err := prepareDiff(b)
+    if err != nil {
+        return err
+    }
+
+    switch ta := pa.itype(); {
+        case ta != pb.itype():
+            return self.change("replace", a, b)
+        case ta == types.V_OBJECT:
+            return self.diffObject(pa, pb)
+        case ta == types.V_ARRAY:
+            return self.diffArray(pa, pb)
+    }
+    if ok, err := self.equal(pa, pb); err != nil {
+        return err
+    } else if !ok {
+        return self.change("replace", a, b)
+    }
+    return nil
+}
+
+func (self *differ) push(key string) int {
+    n := len(self.path)
+    self.path = appendPointerToken(append(self.path, '/'), key)
+    return n
+}
+
+func (self *differ) pushIndex(i int) int {
+    n := len(self.path)
+    self.path = strconv.AppendInt(append(self.path, '/'), int64(i), 10)
+    return n
+}
+
+func (self *differ) diffObject(a, b *Node) error {
+    if err := a.skipAllKey(); err != nil {
+        return err
+    }
+    if err := b.skipAllKey(); err != nil {
+        return err
+    }
+
+    /* the removed or changed members */
+    var err error
+    it := a.properties()
+    for p := it.next(); p != nil; p = it.next() {
+        v := b.Get(p.Key)
+        if err = v.Check(); err != nil && err != ErrNotExist {
+            return err
+        }
+        n := self.push(p.Key)
+        if !v.Exists() {
+            err = self.change("remove", &p.Value, nil)
+        } else {
+            err = self.diff(&p.Value, v)
+        }
+        if err != nil {
+            return err
+        }
+        self.path = self.path[:n]
+    }
+
+    /* the added members */
+    it = b.properties()
+    for p := it.next(); p != nil; p = it.next() {
+        if v := a.Get(p.Key); v.Exists() {
+            continue
+        }
+        n := self.push(p.Key)
+        if err := self.change("add", nil, &p.Value); err != nil {
+            return err
+        }
+        self.path = self.path[:n]
+    }
+    return nil
+}
+
+func (self *differ) diffArray(a, b *Node) error {
+    if err := a.skipAllIndex(); err != nil {
+        return err
+    }
+    if err := b.skipAllIndex(); err != nil {
+        return err
+    }
+
+    /* trim the common prefix and suffix */
+    la, lb := a.len(), b.len()
+    s := 0
+    for ; s < la && s < lb; s++ {
+        if ok, err := self.equal(a.nodeAt(s), b.nodeAt(s)); err != nil {
+            return err
+        } else if !ok {
+            break
+        }
+    }
+    for ; la > s && lb > s; la, lb = la - 1, lb - 1 {
+        if ok, err := self.equal(a.nodeAt(la - 1), b.nodeAt(lb - 1)); err != nil {
+            return err
+        } else if !ok {
+            break
+        }
+    }
+
+    /* align the rest by their longest common subsequence */
+    matches, err := self.align(a, b, s, la, s, lb)
+    if err != nil {
+        return err
+    }
+
+    /* the elements before i of a are kept or changed to the elements before k of b */
+    i, k := s, s
+    for _, m := range append(matches, [2]int{la, lb}) {
+        if err := self.diffHunk(a, b, i, m[0], k, m[1]); err != nil {
+            return err
+        }
+        i, k = m[0] + 1, m[1] + 1
+    }
+    return nil
+}
+
+// diffHunk produces the changes from a[i:ie] to b[k:ke], where i is also the index of a[i] after
+// the former changes. The elements at the same place are diffed, then the rest are removed or added.
+func (self *differ) diffHunk(a, b *Node, i, ie, k, ke int) error {
+    for ; i < ie && k < ke; i, k = i + 1, k + 1 {
+        n := self.pushIndex(k)
+        if err := self.diff(a.nodeAt(i), b.nodeAt(k)); err != nil {
+            return err
+        }
+        self.path = self.path[:n]
+    }
+
+    /* the removed elements, from the last one to keep the indexes of the others */
+    for j := ie - 1; j >= i; j-- {
+        n := self.pushIndex(k + j - i)
+        if err := self.change("remove", a.nodeAt(j), nil); err != nil {
+            return err
+        }
+        self.path = self.path[:n]
+    }
+
+    /* the inserted elements */
+    for ; k < ke; k++ {
+        n := self.pushIndex(k)
+        if err := self.change("add", nil, b.nodeAt(k)); err != nil {
+            return err
+        }
+        self.path = self.path[:n]
+    }
+    return nil
+}
+
+// _MAX_ARRAY_EDITS limits the edit distance searched by align,
+// which takes O(D^2) memory for the D edits.
+const _MAX_ARRAY_EDITS = 1024
+
+// align returns the index pairs of the longest common subsequence of a[sa:la] and b[sb:lb]
+// in order, by the Myers' algorithm. If the arrays differ too much, no element is aligned.
+func (self *differ) align(a, b *Node, sa, la, sb, lb int) ([][2]int, error) {
+    n, m := la - sa, lb - sb
+    if n == 0 || m == 0 {
+        return nil, nil
+    }
+
+    /* v[max + 1 + k] is the furthest x on the diagonal k = x - y,
+     * and trace[d] is the diagonals [-d-1, d+1] of v before searching with d edits */
+    max := n + m
+    if max > _MAX_ARRAY_EDITS {
+        max = _MAX_ARRAY_EDITS
+    }
+    v := make([]int, 2 * max + 3)
+    trace := make([][]int, 0, 16)
+    for d := 0; d <= max; d++ {
+        trace = append(trace, append([]int(nil), v[max - d : max + d + 3]...))
+        for k := -d; k <= d; k += 2 {
+            var x int
+            if k == -d || (k != d && v[max + 1 + k - 1] < v[max + 1 + k + 1]) {
+                x = v[max + 1 + k + 1]
+            } else {
+                x = v[max + 1 + k - 1] + 1
+            }
+            for y := x - k; x < n && y < m; x, y = x + 1, y + 1 {
+                if ok, err := self.equal(a.nodeAt(sa + x), b.nodeAt(sb + y)); err != nil {
+                    return nil, err
+                } else if !ok {
+                    break
+                }
+            }
+            if v[max + 1 + k] = x; x >= n && x - k >= m {
+                return backtrackLCS(trace, n, m, sa, sb), nil
+            }
+        }
+    }
+    return nil, nil
+}
+
+// backtrackLCS collects the diagonal moves of the shortest edit path, which are the common elements.
+func backtrackLCS(trace [][]int, x int, y int, sa int, sb int) [][2]int {
+    var ret [][2]int
+    for d := len(trace) - 1; d >= 0; d-- {
+        v, k, off := trace[d], x - y, d + 1
+        pk := k - 1
+        if k == -d || (k != d && v[off + k - 1] < v[off + k + 1]) {
+            pk = k + 1
+        }
+        px := v[off + pk]
+        py := px - pk
+        for x > px && y > py {
+            x, y = x - 1, y - 1
+            ret = append(ret, [2]int{sa + x, sb + y})
+        }
+        x, y = px, py
+    }
+    for i, j := 0, len(ret) - 1; i < j; i, j = i + 1, j - 1 {
+        ret[i], ret[j] = ret[j], ret[i]
+    }
+    return ret
+}
+
+// equal reports whether a and b are semantically equal.
+func (self *differ) equal(a, b *Node) (bool, error) {
+    opts := EqualOptions{Numbers: numbersByLiteral}
+    if self.opts.CompareNumbersByValue {
+        opts.Numbers = NumbersByValue
+    }
+    return equalNodes(a, b, opts)
+}
+
+// appendPointerToken appends the key to the JSON Pointer, escaping '~' and '/'.
+func appendPointerToken(buf []byte, key string) []byte {
+    for i := 0; i < len(key); i++ {
+        switch key[i] {
+            case '~' : buf = append(buf, '~', '0')
+            case '/' : buf = append(buf, '~', '1')
+            default  : buf = append(buf, key[i])
+        }
+    }
+    return buf
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `math/rand`
+    `strconv`
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestDiff(t *testing.T) {
+    tests := []struct {
+        a     string
+        b     string
+        patch string
+    }{
+        {`{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, `[]`},
+        {`{"a":1}`, `{"a":2}`, `[{"op":"replace","path":"/a","value":2}]`},
+        {`{"a":1,"b":2}`, `{"b":2,"c":3}`, `[{"op":"remove","path":"/a"},{"op":"add","path":"/c","value":3}]`},
+        {`{"a/b":{"c~d":"x"}}`, `{"a/b":{"c~d":"y"}}`, `[{"op":"replace","path":"/a~1b/c~0d","value":"y"}]`},
+        {`{"a":{"b":1}}`, `{"a":[1]}`, `[{"op":"replace","path":"/a","value":[1]}]`},
+        {`[1,2,3,4]`, `[1,4]`, `[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`},
+        {`[1,4]`, `[1,2,3,4]`, `[{"op":"add","path":"/1","value":2},{"op":"add","path":"/2","value":3}]`},
+        {`[1,{"x":1},3]`, `[1,{"x":2},3,4]`, `[{"op":"replace","path":"/1/x","value":2},{"op":"add","path":"/3","value":4}]`},
+        {`[1,2,3]`, `[0,2,3]`, `[{"op":"replace","path":"/0","value":0}]`},
+        {`[1,2,3]`, `[0,1,2,3,4]`, `[{"op":"add","path":"/0","value":0},{"op":"add","path":"/4","value":4}]`},
+        {`[1,2,3,4,5]`, `[2,4]`, `[{"op":"remove","path":"/0"},{"op":"remove","path":"/1"},{"op":"remove","path":"/2"}]`},
+        {`[1,{"x":1},3]`, `[0,1,{"x":2},3,4]`, `[{"op":"add","path":"/0","value":0},{"op":"replace","path":"/2/x","value":2},{"op":"add","path":"/4","value":4}]`},
+        {`[1,2,{"x":1},3]`, `[1,{"x":2},3]`, `[{"op":"replace","path":"/1","value":{"x":2}},{"op":"remove","path":"/2"}]`},
+        {`[1,{"x":1},2]`, `[{"x":1},0,2,{"y":1}]`, `[{"op":"remove","path":"/0"},{"op":"add","path":"/1","value":0},{"op":"add","path":"/3","value":{"y":1}}]`},
+        {`["a\u0041"]`, `["aA"]`, `[]`},
+        {`1.0`, `1`, `[{"op":"replace","path":"","value":1}]`},
+        {`{"a":null,"b":true}`, `{"a":false,"b":true}`, `[{"op":"replace","path":"/a","value":false}]`},
+    }
+    for _, tt := range tests {
+        a, b := NewRaw(tt.a), NewRaw(tt.b)
+        changes, err := Diff(&a, &b)
+        require.NoError(t, err, tt.a)
+        patch := NewPatch(changes)
+        out, err := patch.MarshalJSON()
+        require.NoError(t, err, tt.a)
+        assert.Equal(t, tt.patch, string(out), tt.a)
+
+        /* applying the patch produces b */
+        doc := NewRaw(tt.a)
+        require.NoError(t, ApplyPatch(&doc, patch), tt.a)
+        changes, err = Diff(&doc, &b)
+        require.NoError(t, err, tt.a)
+        assert.Empty(t, changes, tt.a)
+    }
+}
+
+func TestDiff_Options(t *testing.T) {
+    a, b := NewRaw(`{"a":1.0,"b":[1e2]}`), NewRaw(`{"a":1,"b":[100]}`)
+    changes, err := Diff(&a, &b)
+    require.NoError(t, err)
+    assert.Len(t, changes, 2)
+    changes, err = DiffWithOptions(&a, &b, DiffOptions{CompareNumbersByValue: true})
+    require.NoError(t, err)
+    assert.Empty(t, changes)
+
+    /* the numbers are compared exactly */
+    a, b = NewRaw(`[9007199254740993,0.1]`), NewRaw(`[9007199254740992,0.10000000000000001]`)
+    changes, err = DiffWithOptions(&a, &b, DiffOptions{CompareNumbersByValue: true})
+    require.NoError(t, err)
+    assert.Len(t, changes, 2)
+}
+
+func TestDiff_Arrays(t *testing.T) {
+    r := rand.New(rand.NewSource(0))
+    for n := 0; n < 200; n++ {
+        var xs, ys []Node
+        for i := r.Intn(20); i > 0; i-- {
+            xs = append(xs, NewNumber(strconv.Itoa(r.Intn(5))))
+        }
+        for i := r.Intn(20); i > 0; i-- {
+            ys = append(ys, NewNumber(strconv.Itoa(r.Intn(5))))
+        }
+        a, b := NewArray(xs), NewArray(ys)
+        changes, err := Diff(&a, &b)
+        require.NoError(t, err)
+
+        /* the changes are no more than the elements out of the longest common subsequence */
+        lcs := make([][]int, len(xs) + 1)
+        for i := range lcs {
+            lcs[i] = make([]int, len(ys) + 1)
+        }
+        for i := len(xs) - 1; i >= 0; i-- {
+            for j := len(ys) - 1; j >= 0; j-- {
+                if xs[i].toString() == ys[j].toString() {
+                    lcs[i][j] = lcs[i + 1][j + 1] + 1
+                } else if lcs[i + 1][j] > lcs[i][j + 1] {
+                    lcs[i][j] = lcs[i + 1][j]
+                } else {
+                    lcs[i][j] = lcs[i][j + 1]
+                }
+            }
+        }
+        assert.LessOrEqual(t, len(changes), len(xs) + len(ys) - 2 * lcs[0][0])
+
+        /* applying the patch produces b */
+        doc := NewArray(append([]Node(nil), xs...))
+        require.NoError(t, ApplyPatch(&doc, NewPatch(changes)))
+        ok, err := doc.Equal(&b)
+        require.NoError(t, err)
+        assert.True(t, ok)
+    }
+
+    /* the arrays differing too much are compared index-by-index */
+    var xs, ys []Node
+    for i := 0; i < 1000; i++ {
+        xs = append(xs, NewNumber(strconv.Itoa(i)))
+        ys = append(ys, NewNumber(strconv.Itoa(-i - 1)))
+    }
+    a, b := NewArray(xs), NewArray(append(ys, NewNull()))
+    changes, err := Diff(&a, &b)
+    require.NoError(t, err)
+    require.Len(t, changes, 1001)
+    assert.Equal(t, "replace", changes[0].Op)
+    assert.Equal(t, "add", changes[1000].Op)
+}
+
+func TestDiff_Changes(t *testing.T) {
+    a := NewRaw(`{"a":{"x":[1,2]},"b":{"y":1},"c":"s"}`)
+    b := NewObject([]Pair{
+        NewPair("a", NewRaw(`{"x":[1,2]}`)),
+        NewPair("b", NewAny(map[string]int{"y": 2})),
+    })
+    changes, err := Diff(&a, &b)
+    require.NoError(t, err)
+    require.Len(t, changes, 2)
+    assert.Equal(t, "replace", changes[0].Op)
+    assert.Equal(t, "/b/y", changes[0].Path)
+    assert.Equal(t, "remove", changes[1].Op)
+    assert.Equal(t, "/c", changes[1].Path)
+    s, err := changes[1].Old.String()
+    require.NoError(t, err)
+    assert.Equal(t, "s", s)
+    assert.False(t, changes[1].New.Exists())
+
+    /* the identical raw subtrees are not parsed */
+    assert.True(t, a.Get("a").isRaw())
+    assert.True(t, b.Get("a").isRaw())
+
+    /* the errors */
+    a, b = NewRaw(`{"a":[1,}`), NewRaw(`{"a":[1]}`)
+    _, err = Diff(&a, &b)
+    assert.Error(t, err)
+}
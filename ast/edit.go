@@ -0,0 +1,357 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// EditOptions controls how SetRawByPath edits the raw JSON.
+type EditOptions struct {
+    // CreateMissing creates the missing intermediate objects on the path,
+    // otherwise ErrNotExist is returned if the parent of the value doesn't exist.
+    CreateMissing bool
+}
+
+// GetSpanByPath searches and locates the given path from src json,
+// and returns the span src[start:end] of the found value and its type (V_*).
+//
+// Each path arg must be integer or string, like Searcher.GetByPath.
+func GetSpanByPath(src string, path ...interface{}) (start int, end int, typ int, err error) {
+    return _GetByPath(src, path...)
+}
+
+// SetRawByPath sets the raw JSON value at the given path of src json,
+// and returns the new json spliced from src, while the untouched parts keep their formatting.
+//
+// If the value doesn't exist, it is added to the parent object,
+// or appended to the parent array if the last path arg equals to the length of the array.
+//
+// Notice: It expects src and raw are **Well-formed** JSON, and doesn't validate them.
+func SetRawByPath(src string, raw string, opts EditOptions, path ...interface{}) ([]byte, error) {
+    if len(path) == 0 {
+        return []byte(raw), nil
+    }
+    p := NewParserObj(src)
+    m, depth, found, err := p.scanPath(path)
+    if err != nil {
+        return nil, err
+    }
+    if found {
+        return splice(src, m.vstart, m.vend, raw), nil
+    }
+    if depth < len(path) - 1 && !opts.CreateMissing {
+        return nil, ErrNotExist
+    }
+    member, err := newRawMember(&m, path[depth], path[depth + 1:], raw)
+    if err != nil {
+        return nil, err
+    }
+    return m.append(src, member), nil
+}
+
+// DeleteRawByPath deletes the value at the given path of src json, with the key of it if any,
+// and returns the new json spliced from src, while the untouched parts keep their formatting.
+//
+// Notice: It expects src is **Well-formed** JSON, and doesn't validate it.
+func DeleteRawByPath(src string, path ...interface{}) ([]byte, error) {
+    if len(path) == 0 {
+        return nil, ErrUnsupportType
+    }
+    p := NewParserObj(src)
+    m, _, found, err := p.scanPath(path)
+    if err != nil {
+        return nil, err
+    }
+    if !found {
+        return nil, ErrNotExist
+    }
+
+    /* remove the value with the separator after it, or the one before it for the last value */
+    switch {
+        case m.next >= 0 : return splice(src, m.start, m.next), nil
+        case m.prev >= 0 : return splice(src, m.prev, m.vend), nil
+        default          : return splice(src, m.start, m.vend), nil
+    }
+}
+
+// InsertRawByPath inserts the raw JSON value into the array at the given path of src json,
+// and returns the new json spliced from src, while the untouched parts keep their formatting.
+//
+// The last path arg must be an index, the value is inserted before the element at the index,
+// or appended to the array if the index equals to the length of the array.
+//
+// Notice: It expects src and raw are **Well-formed** JSON, and doesn't validate them.
+func InsertRawByPath(src string, raw string, path ...interface{}) ([]byte, error) {
+    if len(path) == 0 {
+        return nil, ErrUnsupportType
+    }
+    if _, ok := path[len(path) - 1].(int); !ok {
+        return nil, ErrUnsupportType
+    }
+    p := NewParserObj(src)
+    m, depth, found, err := p.scanPath(path)
+    if err != nil {
+        return nil, err
+    }
+    if found {
+        return splice(src, m.start, m.start, raw, m.sep), nil
+    }
+    if depth < len(path) - 1 || path[depth].(int) != m.size {
+        return nil, ErrNotExist
+    }
+    return m.append(src, raw), nil
+}
+
+// rawMember is the location of a member in a raw JSON container.
+type rawMember struct {
+    start  int      // start of the member, including the key of pair
+    vstart int      // start of the value
+    vend   int      // end of the value
+    prev   int      // end of the previous value, or -1 if it is the first member
+    next   int      // start of the next member, or -1 if it is the last member
+    size   int      // number of the members before it, only for the missing member
+    object bool     // the container is an object
+    sep    string   // separator between the members, including the blanks
+}
+
+// scanPath locates the member on the path by searching natively,
+// and falls back to scanMembers if it is missing.
+func (self *Parser) scanPath(path []interface{}) (rawMember, int, bool, error) {
+    if s, err := self.getByPath(false, path...); err == 0 {
+        return self.locateMember(s, 1 + backward(self.s, self.p - 1)), len(path) - 1, true, nil
+    }
+    self.p = 0
+    return self.scanMembers(path)
+}
+
+// scanMembers scans the members on the path, until the last one or the first missing one.
+// It returns the location of the last scanned member, and the index of its path arg.
+func (self *Parser) scanMembers(path []interface{}) (rawMember, int, bool, error) {
+    var m rawMember
+    for i, key := range path {
+        found, err := self.scanMember(&m, key)
+        if err == types.ERR_UNSUPPORT_TYPE {
+            return m, i, false, ErrUnsupportType
+        } else if err != 0 {
+            return m, i, false, self.syntaxError(err)
+        }
+        if !found || i == len(path) - 1 {
+            return m, i, found, nil
+        }
+        self.p = m.vstart
+    }
+    return m, len(path) - 1, false, nil
+}
+
+// locateMember locates the member around its value src[vs:ve].
+func (self *Parser) locateMember(vs int, ve int) rawMember {
+    m := rawMember{start: vs, vstart: vs, vend: ve, prev: -1, next: -1, sep: ","}
+
+    /* the key of pair starts at the unescaped quote before the colon */
+    if i := backward(self.s, vs - 1); self.s[i] == ':' {
+        m.object = true
+        for m.start = backward(self.s, i - 1) - 1; ; m.start-- {
+            if self.s[m.start] != '"' {
+                continue
+            }
+            n := 0
+            for ; self.s[m.start - n - 1] == '\\'; n++ {}
+            if n % 2 == 0 {
+                break
+            }
+        }
+    }
+
+    /* the separators around the member */
+    if i := backward(self.s, m.start - 1); self.s[i] == ',' {
+        m.prev = 1 + backward(self.s, i - 1)
+        m.sep = self.s[m.prev:m.start]
+    }
+    if i := self.lspace(ve); i < len(self.s) && self.s[i] == ',' {
+        m.next = self.lspace(i + 1)
+        if m.prev < 0 {
+            m.sep = self.s[ve:m.next]
+        }
+    }
+    return m
+}
+
+// scanMember scans the container at the current position for the member of key.
+// If the member is not found, start is the position of the closing bracket,
+// and prev is the end of the last value.
+func (self *Parser) scanMember(m *rawMember, key interface{}) (bool, types.ParsingError) {
+    ns := len(self.s)
+    if self.p = self.lspace(self.p); self.p >= ns {
+        return false, types.ERR_EOF
+    }
+
+    /* check the container and the key */
+    var end byte
+    var idx int
+    var str string
+    switch v := key.(type) {
+        case string : str = v
+        case int    : idx = v
+        default     : panic("path must be either int(>=0) or string")
+    }
+    switch self.s[self.p] {
+        case '{' : end = '}'
+        case '[' : end = ']'
+        default  : return false, types.ERR_UNSUPPORT_TYPE
+    }
+    if idx < 0 {
+        panic("path must be either int(>=0) or string")
+    }
+    *m = rawMember{prev: -1, next: -1, object: end == '}', sep: ","}
+    if _, ok := key.(string); ok != m.object {
+        return false, types.ERR_UNSUPPORT_TYPE
+    }
+
+    /* check for empty container */
+    if self.p = self.lspace(self.p + 1); self.p >= ns {
+        return false, types.ERR_EOF
+    } else if self.s[self.p] == end {
+        m.start = self.p
+        return false, 0
+    }
+
+    for ; ; m.size++ {
+        m.start = self.p
+        match := m.size == idx
+        if m.object {
+            k, err := self.decodeKey()
+            if err != 0 {
+                return false, err
+            }
+            if err = self.delim(); err != 0 {
+                return false, err
+            }
+            match = k == str
+        }
+
+        /* skip the value, the numbers may be followed by blanks */
+        s, err := self.skipFast()
+        if err != 0 {
+            return false, err
+        }
+        m.vstart = s
+        m.vend = 1 + backward(self.s, self.p - 1)
+
+        /* check for the next character */
+        if self.p = self.lspace(self.p); self.p >= ns {
+            return false, types.ERR_EOF
+        }
+        switch self.s[self.p] {
+            case ',':
+                self.p = self.lspace(self.p + 1)
+                if match {
+                    m.next = self.p
+                    if m.prev < 0 {
+                        m.sep = self.s[m.vend:m.next]
+                    }
+                    return true, 0
+                }
+                m.prev = m.vend
+                m.sep = self.s[m.vend:self.p]
+            case end:
+                if match {
+                    return true, 0
+                }
+                m.prev = m.vend
+                m.start = self.p
+                m.size++
+                return false, 0
+            default:
+                return false, types.ERR_INVALID_CHAR
+        }
+    }
+}
+
+// append appends the member to the container of the missing member m.
+func (self *rawMember) append(src string, member string) []byte {
+    if self.prev < 0 {
+        return splice(src, self.start, self.start, member)
+    }
+    return splice(src, self.prev, self.prev, self.sep, member)
+}
+
+// newRawMember creates the member of key for the container of m,
+// the rest keys on the path are created as nested objects.
+func newRawMember(m *rawMember, key interface{}, rest []interface{}, raw string) (string, error) {
+    var buf []byte
+    if m.object {
+        quote(&buf, key.(string))
+        buf = append(buf, ':')
+    } else if key.(int) != m.size {
+        return "", ErrNotExist
+    }
+    for _, k := range rest {
+        s, ok := k.(string)
+        if !ok {
+            return "", ErrNotExist
+        }
+        buf = append(buf, '{')
+        quote(&buf, s)
+        buf = append(buf, ':')
+    }
+    buf = append(buf, raw...)
+    for range rest {
+        buf = append(buf, '}')
+    }
+    return string(buf), nil
+}
+
+// splice replaces src[start:end] with strs.
+func splice(src string, start int, end int, strs ...string) []byte {
+    n := len(src) - (end - start)
+    for _, s := range strs {
+        n += len(s)
+    }
+    buf := make([]byte, 0, n)
+    buf = append(buf, src[:start]...)
+    for _, s := range strs {
+        buf = append(buf, s...)
+    }
+    return append(buf, src[end:]...)
+}
+
+// decodeKey decodes the key of a pair, the keys without escaping are sliced directly.
+func (self *Parser) decodeKey() (string, types.ParsingError) {
+    if self.p = self.lspace(self.p); self.p < len(self.s) && self.s[self.p] == '"' {
+        s := self.p + 1
+        for e := s; e < len(self.s); e++ {
+            if c := self.s[e]; c == '"' {
+                self.p = e + 1
+                return self.s[s:e], 0
+            } else if c == '\\' || c < 0x20 {
+                break
+            }
+        }
+    }
+
+    /* fallback to the native decoding */
+    njs := self.decodeValue()
+    if njs.Vt != types.V_STRING {
+        return "", types.ERR_INVALID_CHAR
+    }
+    key := self.s[njs.Iv:self.p - 1]
+    if njs.Ep != -1 {
+        return unquote(key)
+    }
+    return key, 0
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestGetSpanByPath(t *testing.T) {
+    src := `{"a": [1, {"b": "c"} ], "d": 2 }`
+    start, end, typ, err := GetSpanByPath(src, "a", 1)
+    require.NoError(t, err)
+    assert.Equal(t, `{"b": "c"}`, src[start:end])
+    assert.Equal(t, V_OBJECT, typ)
+    start, end, typ, err = GetSpanByPath(src, "d")
+    require.NoError(t, err)
+    assert.Equal(t, `2`, src[start:end])
+    assert.Equal(t, V_NUMBER, typ)
+    _, _, _, err = GetSpanByPath(src, "x")
+    assert.Equal(t, ErrNotExist, err)
+}
+
+func TestScanPath(t *testing.T) {
+    src := `{"a" : [ 1 , {"b\\":2,"\\\"c":[]} ,3 ], "": "x\"" ,"d":{"e":null}}`
+    for _, path := range [][]interface{}{
+        {"a"}, {"a", 0}, {"a", 1}, {"a", 2}, {"a", 1, "b\\"}, {"a", 1, "\\\"c"}, {""}, {"d"}, {"d", "e"},
+    } {
+        p := NewParserObj(src)
+        m, depth, found, err := p.scanPath(path)
+        require.NoError(t, err, path)
+        require.True(t, found, path)
+        q := NewParserObj(src)
+        exp, _, _, err := q.scanMembers(path)
+        require.NoError(t, err, path)
+        exp.size = 0
+        assert.Equal(t, exp, m, path)
+        assert.Equal(t, len(path) - 1, depth, path)
+    }
+}
+
+func TestSetRawByPath(t *testing.T) {
+    tests := []struct {
+        src    string
+        path   []interface{}
+        create bool
+        exp    string
+        err    error
+    }{
+        {`{"a": 1, "b": [1, 2]}`, []interface{}{"a"}, false, `{"a": "x", "b": [1, 2]}`, nil},
+        {`{"a": 1 , "b": [1, 2]}`, []interface{}{"a"}, false, `{"a": "x" , "b": [1, 2]}`, nil},
+        {`{"a": 1, "b": [1, 2]}`, []interface{}{"b", 1}, false, `{"a": 1, "b": [1, "x"]}`, nil},
+        {`{"a": 1, "b": [1, 2]}`, []interface{}{"b", 2}, false, `{"a": 1, "b": [1, 2, "x"]}`, nil},
+        {`{"a": 1, "b": [1, 2]}`, []interface{}{"c"}, false, `{"a": 1, "b": [1, 2], "c":"x"}`, nil},
+        {"{\n  \"a\": 1\n}", []interface{}{"b"}, false, "{\n  \"a\": 1,\"b\":\"x\"\n}", nil},
+        {`{ }`, []interface{}{"a"}, false, `{ "a":"x"}`, nil},
+        {`[]`, []interface{}{0}, false, `["x"]`, nil},
+        {`{"ab": 1}`, []interface{}{"ab"}, false, `{"ab": "x"}`, nil},
+        {`{"a": {}}`, []interface{}{"a", "b", "c"}, true, `{"a": {"b":{"c":"x"}}}`, nil},
+        {`{"a": []}`, []interface{}{"a", 0, "c"}, true, `{"a": [{"c":"x"}]}`, nil},
+        {`{"a": {}}`, []interface{}{"a", "b", "c"}, false, ``, ErrNotExist},
+        {`{"a": []}`, []interface{}{"a", 1}, false, ``, ErrNotExist},
+        {`{"a": {}}`, []interface{}{"a", "b", 0}, true, ``, ErrNotExist},
+        {`{"a": 1}`, []interface{}{"a", "b"}, true, ``, ErrUnsupportType},
+        {`{"a": 1}`, []interface{}{0}, false, ``, ErrUnsupportType},
+        {`{"a": 1}`, []interface{}{}, false, `"x"`, nil},
+    }
+    for _, tt := range tests {
+        out, err := SetRawByPath(tt.src, `"x"`, EditOptions{CreateMissing: tt.create}, tt.path...)
+        if tt.err != nil {
+            assert.Equal(t, tt.err, err, tt.src)
+            continue
+        }
+        require.NoError(t, err, tt.src)
+        assert.Equal(t, tt.exp, string(out), tt.src)
+    }
+
+    _, err := SetRawByPath(`{"a": [1, }`, `1`, EditOptions{}, "a", 2)
+    assert.IsType(t, SyntaxError{}, err)
+    assert.Panics(t, func() { _, _ = SetRawByPath(`[]`, `1`, EditOptions{}, -1) })
+}
+
+func TestDeleteRawByPath(t *testing.T) {
+    tests := []struct {
+        src  string
+        path []interface{}
+        exp  string
+    }{
+        {`{"a": 1, "b": 2, "c": 3}`, []interface{}{"a"}, `{"b": 2, "c": 3}`},
+        {`{"a": 1, "b": 2, "c": 3}`, []interface{}{"b"}, `{"a": 1, "c": 3}`},
+        {`{"a": 1, "b": 2, "c": 3 }`, []interface{}{"c"}, `{"a": 1, "b": 2 }`},
+        {`{"a": [1]}`, []interface{}{"a", 0}, `{"a": []}`},
+        {`{ "a" : {"b":1} }`, []interface{}{"a"}, `{  }`},
+        {"[\n  1,\n  2\n]", []interface{}{1}, "[\n  1\n]"},
+    }
+    for _, tt := range tests {
+        out, err := DeleteRawByPath(tt.src, tt.path...)
+        require.NoError(t, err, tt.src)
+        assert.Equal(t, tt.exp, string(out), tt.src)
+    }
+
+    _, err := DeleteRawByPath(`{"a": 1}`, "b")
+    assert.Equal(t, ErrNotExist, err)
+    _, err = DeleteRawByPath(`{"a": 1}`)
+    assert.Equal(t, ErrUnsupportType, err)
+}
+
+func TestInsertRawByPath(t *testing.T) {
+    tests := []struct {
+        src  string
+        path []interface{}
+        exp  string
+    }{
+        {`[1, 2]`, []interface{}{0}, `["x", 1, 2]`},
+        {`[1, 2]`, []interface{}{1}, `[1, "x", 2]`},
+        {`[1, 2]`, []interface{}{2}, `[1, 2, "x"]`},
+        {`[1]`, []interface{}{0}, `["x",1]`},
+        {`[ ]`, []interface{}{0}, `[ "x"]`},
+        {`{"a": [{"b": []}]}`, []interface{}{"a", 0, "b", 0}, `{"a": [{"b": ["x"]}]}`},
+    }
+    for _, tt := range tests {
+        out, err := InsertRawByPath(tt.src, `"x"`, tt.path...)
+        require.NoError(t, err, tt.src)
+        assert.Equal(t, tt.exp, string(out), tt.src)
+    }
+
+    _, err := InsertRawByPath(`[1, 2]`, `"x"`, 3)
+    assert.Equal(t, ErrNotExist, err)
+    _, err = InsertRawByPath(`{"a": 1}`, `"x"`, "b")
+    assert.Equal(t, ErrUnsupportType, err)
+    _, err = InsertRawByPath(`{"a": 1}`, `"x"`, 0)
+    assert.Equal(t, ErrUnsupportType, err)
+}
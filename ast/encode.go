@@ -20,6 +20,7 @@ import (
 	"sync"
 	"unicode/utf8"
 
+	"github.com/bytedance/sonic/internal/encoder/alg"
 	"github.com/bytedance/sonic/internal/rt"
     "github.com/bytedance/sonic/option"
 )
@@ -95,8 +96,23 @@ func (self *Node) MarshalJSON() ([]byte, error) {
 		return bytesNull, nil
 	}
 
+    return self.marshal(nil)
+}
+
+// MarshalIndent is like MarshalJSON but indents the output in a single pass, as
+// json.MarshalIndent does. Each JSON element begins on a new line beginning with
+// prefix followed by one or more copies of indent according to the nesting.
+func (self *Node) MarshalIndent(prefix, indent string) ([]byte, error) {
+	if self == nil {
+		return bytesNull, nil
+	}
+
+    return self.marshal(&indenter{prefix: prefix, indent: indent})
+}
+
+func (self *Node) marshal(ind *indenter) ([]byte, error) {
     buf := newBuffer()
-    err := self.encode(buf)
+    err := self.encode(buf, ind)
     if err != nil {
         freeBuffer(buf)
         return nil, err
@@ -129,9 +145,47 @@ func freeBuffer(buf *[]byte) {
     bytesPool.Put(buf)
 }
 
-func (self *Node) encode(buf *[]byte) error {
+// indenter tracks the nesting depth of the indented output.
+type indenter struct {
+    prefix string
+    indent string
+    depth  int
+}
+
+// newline breaks the line and indents the next one, nothing for the compact output.
+func (self *indenter) newline(buf *[]byte) {
+    if self == nil {
+        return
+    }
+    *buf = append(*buf, '\n')
+    *buf = append(*buf, self.prefix...)
+    for i := 0; i < self.depth; i++ {
+        *buf = append(*buf, self.indent...)
+    }
+}
+
+func (self *indenter) enter() {
+    if self != nil {
+        self.depth++
+    }
+}
+
+func (self *indenter) leave() {
+    if self != nil {
+        self.depth--
+    }
+}
+
+// linePrefix returns the leading spaces of the current line.
+func (self *indenter) linePrefix() string {
+    var buf []byte
+    self.newline(&buf)
+    return string(buf[1:])
+}
+
+func (self *Node) encode(buf *[]byte, ind *indenter) error {
     if self.isRaw() {
-        return self.encodeRaw(buf)
+        return self.encodeRaw(buf, ind)
     }
     switch int(self.itype()) {
         case V_NONE  : return ErrNotExist
@@ -139,26 +193,32 @@ func (self *Node) encode(buf *[]byte) error {
         case V_NULL  : return self.encodeNull(buf)
         case V_TRUE  : return self.encodeTrue(buf)
         case V_FALSE : return self.encodeFalse(buf)
-        case V_ARRAY : return self.encodeArray(buf)
-        case V_OBJECT: return self.encodeObject(buf)
+        case V_ARRAY : return self.encodeArray(buf, ind)
+        case V_OBJECT: return self.encodeObject(buf, ind)
         case V_STRING: return self.encodeString(buf)
         case V_NUMBER: return self.encodeNumber(buf)
-        case V_ANY   : return self.encodeInterface(buf)
+        case V_ANY   : return self.encodeInterface(buf, ind)
         default      : return ErrUnsupportType 
     }
 }
 
-func (self *Node) encodeRaw(buf *[]byte) error {
+func (self *Node) encodeRaw(buf *[]byte, ind *indenter) error {
     lock := self.rlock()
     if !self.isRaw() {
         self.runlock()
-        return self.encode(buf)
+        return self.encode(buf, ind)
     }
     raw := self.toString()
     if lock {
         self.runlock()
     }
-    *buf = append(*buf, raw...)
+    if ind == nil {
+        *buf = append(*buf, raw...)
+        return nil
+    }
+
+    /* the raw JSON is indented at the current line while copying */
+    *buf = alg.IndentText(*buf, rt.Str2Mem(raw), ind.linePrefix(), ind.indent)
     return nil
 }
 
@@ -193,7 +253,7 @@ func (self *Node) encodeString(buf *[]byte) error {
     return nil
 }
 
-func (self *Node) encodeArray(buf *[]byte) error {
+func (self *Node) encodeArray(buf *[]byte, ind *indenter) error {
     if self.isLazy() {
         if err := self.skipAllIndex(); err != nil {
             return err
@@ -207,6 +267,7 @@ func (self *Node) encodeArray(buf *[]byte) error {
     }
     
     *buf = append(*buf, '[')
+    ind.enter()
 
     var started bool
     for i := 0; i < nb; i++ {
@@ -218,28 +279,36 @@ func (self *Node) encodeArray(buf *[]byte) error {
             *buf = append(*buf, ',')
         }
         started = true
-        if err := n.encode(buf); err != nil {
+        ind.newline(buf)
+        if err := n.encode(buf, ind); err != nil {
             return err
         }
     }
 
+    ind.leave()
+    if started {
+        ind.newline(buf)
+    }
     *buf = append(*buf, ']')
     return nil
 }
 
-func (self *Pair) encode(buf *[]byte) error {
+func (self *Pair) encode(buf *[]byte, ind *indenter) error {
     if len(*buf) == 0 {
         *buf = append(*buf, '"', '"', ':')
-        return self.Value.encode(buf)
+        return self.Value.encode(buf, ind)
     }
 
     quote(buf, self.Key)
     *buf = append(*buf, ':')
+    if ind != nil {
+        *buf = append(*buf, ' ')
+    }
 
-    return self.Value.encode(buf)
+    return self.Value.encode(buf, ind)
 }
 
-func (self *Node) encodeObject(buf *[]byte) error {
+func (self *Node) encodeObject(buf *[]byte, ind *indenter) error {
     if self.isLazy() {
         if err := self.skipAllKey(); err != nil {
             return err
@@ -253,6 +322,7 @@ func (self *Node) encodeObject(buf *[]byte) error {
     }
     
     *buf = append(*buf, '{')
+    ind.enter()
 
     var started bool
     for i := 0; i < nb; i++ {
@@ -264,11 +334,16 @@ func (self *Node) encodeObject(buf *[]byte) error {
             *buf = append(*buf, ',')
         }
         started = true
-        if err := n.encode(buf); err != nil {
+        ind.newline(buf)
+        if err := n.encode(buf, ind); err != nil {
             return err
         }
     }
 
+    ind.leave()
+    if started {
+        ind.newline(buf)
+    }
     *buf = append(*buf, '}')
     return nil
 }
@@ -167,6 +167,39 @@ func TestEncodeNode(t *testing.T) {
     }
 }
 
+func TestEncodeNodeIndent(t *testing.T) {
+    data := `{"a":[{},[],-0.1,true,false,null,"",{"x":[1,{"y":{}}]}],"b":0,"c":{"d":[]}}`
+    for _, ind := range [][2]string{{"", "  "}, {">", "\t"}, {"", ""}} {
+        exp, err := json.MarshalIndent(json.RawMessage(data), ind[0], ind[1])
+        require.NoError(t, err)
+
+        /* raw, lazy-loaded and fully-loaded nodes */
+        root, e := NewSearcher(data).GetByPath()
+        require.NoError(t, e)
+        ret, err := root.MarshalIndent(ind[0], ind[1])
+        require.NoError(t, err)
+        require.Equal(t, string(exp), string(ret))
+        root.skipAllKey()
+        ret, err = root.MarshalIndent(ind[0], ind[1])
+        require.NoError(t, err)
+        require.Equal(t, string(exp), string(ret))
+        require.NoError(t, root.LoadAll())
+        ret, err = root.MarshalIndent(ind[0], ind[1])
+        require.NoError(t, err)
+        require.Equal(t, string(exp), string(ret))
+
+        /* nodes of the go values */
+        var v map[string]interface{}
+        require.NoError(t, json.Unmarshal([]byte(`{"a":{"b":[1,{}]}}`), &v))
+        exp, err = json.MarshalIndent(map[string]interface{}{"v": v}, ind[0], ind[1])
+        require.NoError(t, err)
+        node := NewObject([]Pair{NewPair("v", NewAny(v))})
+        ret, err = node.MarshalIndent(ind[0], ind[1])
+        require.NoError(t, err)
+        require.Equal(t, string(exp), string(ret))
+    }
+}
+
 type SortableNode struct {
     sorted bool
 	*Node
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `strings`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// NumberPolicy decides how Node.EqualWithOptions compares the numbers.
+type NumberPolicy int
+
+const (
+    // NumbersByValue compares the numbers by their exact decimal values,
+    // so that 1, 1.0, 10e-1 and 1E0 are equal.
+    NumbersByValue NumberPolicy = iota
+
+    // NumbersByKindAndValue distinguishes the integers from the floats,
+    // which have fractions or exponents, and compares the numbers of the same kind by value,
+    // so that 1.0 equals to 1E0, but not to 1.
+    NumbersByKindAndValue
+
+    // numbersByLiteral compares the literals of the numbers, which is the default of Diff
+    numbersByLiteral NumberPolicy = -1
+)
+
+// EqualOptions controls how Node.EqualWithOptions compares the values.
+type EqualOptions struct {
+    // Numbers is the policy of comparing the numbers
+    Numbers NumberPolicy
+}
+
+// Equal reports whether self and other are semantically equal JSON values.
+//
+// The keys order of objects and the formatting are ignored, the strings are compared after unescaping,
+// and the numbers are compared by their exact values.
+// The identical raw subtrees are compared without being parsed.
+func (self *Node) Equal(other *Node) (bool, error) {
+    return self.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions is same as Equal, except it compares the values with specific options.
+func (self *Node) EqualWithOptions(other *Node, opts EqualOptions) (bool, error) {
+    if err := self.Check(); err != nil {
+        return false, err
+    }
+    if err := other.Check(); err != nil {
+        return false, err
+    }
+    return equalNodes(self, other, opts)
+}
+
+// equalNodes is the comparator of both Equal and Diff.
+func equalNodes(a *Node, b *Node, opts EqualOptions) (bool, error) {
+    if isRawEqual(a, b) {
+        return true, nil
+    }
+    if ta, tb := a.itype(), b.itype(); ta != tb && ta != _V_ANY && tb != _V_ANY && a.Valid() && b.Valid() {
+        return false, nil
+    }
+    a, err := prepareDiff(a)
+    if err != nil {
+        return false, err
+    }
+    b, err = prepareDiff(b)
+    if err != nil {
+        return false, err
+    }
+    if a.itype() != b.itype() {
+        return false, nil
+    }
+
+    switch a.itype() {
+        case types.V_OBJECT:
+            if err := a.skipAllKey(); err != nil {
+                return false, err
+            }
+            if err := b.skipAllKey(); err != nil {
+                return false, err
+            }
+            if a.len() != b.len() {
+                return false, nil
+            }
+            it := a.properties()
+            for p := it.next(); p != nil; p = it.next() {
+                v := b.Get(p.Key)
+                if err := v.Check(); err != nil && err != ErrNotExist {
+                    return false, err
+                }
+                if !v.Exists() {
+                    return false, nil
+                }
+                if ok, err := equalNodes(&p.Value, v, opts); !ok || err != nil {
+                    return false, err
+                }
+            }
+
+            /* the keys may be duplicated */
+            it = b.properties()
+            for p := it.next(); p != nil; p = it.next() {
+                if !a.Get(p.Key).Exists() {
+                    return false, nil
+                }
+            }
+            return true, nil
+        case types.V_ARRAY:
+            if err := a.skipAllIndex(); err != nil {
+                return false, err
+            }
+            if err := b.skipAllIndex(); err != nil {
+                return false, err
+            }
+            if a.len() != b.len() {
+                return false, nil
+            }
+            for i := 0; i < a.len(); i++ {
+                if ok, err := equalNodes(a.nodeAt(i), b.nodeAt(i), opts); !ok || err != nil {
+                    return false, err
+                }
+            }
+            return true, nil
+        case types.V_STRING:
+            return a.toString() == b.toString(), nil
+        case _V_NUMBER:
+            if opts.Numbers == numbersByLiteral {
+                return a.toString() == b.toString(), nil
+            }
+            x, xok := parseDecimal(a.toString())
+            y, yok := parseDecimal(b.toString())
+            if !xok || !yok {
+                return a.toString() == b.toString(), nil
+            }
+            if opts.Numbers == NumbersByKindAndValue && x.float != y.float {
+                return false, nil
+            }
+            return x.compare(y) == 0, nil
+        default:
+            return true, nil
+    }
+}
+
+// Hash returns the hash of the JSON value, which is stable across the keys order, the formatting
+// and the processes, so that the semantically equal values (see Equal) have the same hash.
+func (self *Node) Hash() (uint64, error) {
+    if err := self.Check(); err != nil {
+        return 0, err
+    }
+    return hashNode(self)
+}
+
+const (
+    _FNV_OFFSET = 14695981039346656037
+    _FNV_PRIME  = 1099511628211
+)
+
+// the seeds of different types, to make the hashes of the values of different types different
+const (
+    _HASH_NULL = iota + 1
+    _HASH_TRUE
+    _HASH_FALSE
+    _HASH_STRING
+    _HASH_NUMBER
+    _HASH_ARRAY
+    _HASH_OBJECT
+)
+
+func hashString(h uint64, s string) uint64 {
+    for i := 0; i < len(s); i++ {
+        h = (h ^ uint64(s[i])) * _FNV_PRIME
+    }
+    return h
+}
+
+// mixHash is the finalizer of splitmix64, which spreads the bits of h.
+func mixHash(h uint64) uint64 {
+    h ^= h >> 30
+    h *= 0xbf58476d1ce4e5b9
+    h ^= h >> 27
+    h *= 0x94d049bb133111eb
+    return h ^ (h >> 31)
+}
+
+func hashNode(n *Node) (uint64, error) {
+    if !n.Exists() {
+        return 0, nil
+    }
+    n, err := prepareDiff(n)
+    if err != nil {
+        return 0, err
+    }
+
+    switch n.itype() {
+        case types.V_NULL  : return mixHash(_HASH_NULL), nil
+        case types.V_TRUE  : return mixHash(_HASH_TRUE), nil
+        case types.V_FALSE : return mixHash(_HASH_FALSE), nil
+        case types.V_STRING:
+            return mixHash(hashString(_FNV_OFFSET ^ _HASH_STRING, n.toString())), nil
+        case _V_NUMBER:
+            s := n.toString()
+            d, ok := parseDecimal(s)
+            if !ok {
+                return mixHash(hashString(_FNV_OFFSET ^ _HASH_NUMBER, s)), nil
+            }
+            h := hashString(_FNV_OFFSET ^ _HASH_NUMBER, d.digits)
+            if d.neg {
+                h = ^h
+            }
+            return mixHash(h ^ mixHash(uint64(d.exp))), nil
+        case types.V_ARRAY:
+            if err := n.skipAllIndex(); err != nil {
+                return 0, err
+            }
+            h := uint64(_FNV_OFFSET ^ _HASH_ARRAY)
+            it := n.values()
+            for v := it.next(); v != nil; v = it.next() {
+                vh, err := hashNode(v)
+                if err != nil {
+                    return 0, err
+                }
+                h = (h ^ vh) * _FNV_PRIME
+            }
+            return mixHash(h), nil
+        case types.V_OBJECT:
+            if err := n.skipAllKey(); err != nil {
+                return 0, err
+            }
+
+            /* the sum of the pairs hashes is independent of the keys order */
+            h := uint64(_HASH_OBJECT)
+            it := n.properties()
+            for p := it.next(); p != nil; p = it.next() {
+                vh, err := hashNode(&p.Value)
+                if err != nil {
+                    return 0, err
+                }
+                h += mixHash(hashString(_FNV_OFFSET, p.Key) ^ vh)
+            }
+            return mixHash(h), nil
+        default:
+            return 0, ErrUnsupportType
+    }
+}
+
+// decimal is the canonical form of a JSON number, whose value is (-1)^neg * 0.digits * 10^exp.
+type decimal struct {
+    neg    bool
+    digits string  // significant digits without leading and trailing zeros, empty for zero
+    exp    int64
+    float  bool    // the literal has fraction or exponent
+}
+
+// compare returns -1, 0 or +1 if d is less than, equal to or greater than o.
+func (d decimal) compare(o decimal) int {
+    if d.neg != o.neg {
+        if d.neg {
+            return -1
+        }
+        return 1
+    }
+    ret := d.compareAbs(o)
+    if d.neg {
+        return -ret
+    }
+    return ret
+}
+
+func (d decimal) compareAbs(o decimal) int {
+    switch {
+        case d.digits == "" && o.digits == "" : return 0
+        case d.digits == ""                   : return -1
+        case o.digits == ""                   : return 1
+        case d.exp < o.exp                    : return -1
+        case d.exp > o.exp                    : return 1
+        default                               : return strings.Compare(d.digits, o.digits)
+    }
+}
+
+// _MAX_DECIMAL_EXP saturates the huge exponents, which are not representable anyway.
+const _MAX_DECIMAL_EXP = 1 << 40
+
+// parseDecimal parses the literal of JSON number into its canonical form.
+func parseDecimal(s string) (decimal, bool) {
+    var d decimal
+    i, n := 0, len(s)
+    for n > 0 && isSpace(s[n - 1]) {
+        n--
+    }
+    if i < n && s[i] == '-' {
+        d.neg = true
+        i++
+    }
+
+    /* the integer part */
+    is := i
+    for i < n && s[i] >= '0' && s[i] <= '9' {
+        i++
+    }
+    ip := s[is:i]
+    if ip == "" {
+        return d, false
+    }
+
+    /* the fraction part */
+    fp := ""
+    if i < n && s[i] == '.' {
+        d.float = true
+        fs := i + 1
+        for i = fs; i < n && s[i] >= '0' && s[i] <= '9'; i++ {}
+        if fp = s[fs:i]; fp == "" {
+            return d, false
+        }
+    }
+
+    /* the exponent part */
+    if i < n && (s[i] == 'e' || s[i] == 'E') {
+        d.float = true
+        i++
+        eneg := false
+        if i < n && (s[i] == '+' || s[i] == '-') {
+            eneg = s[i] == '-'
+            i++
+        }
+        es := i
+        for ; i < n && s[i] >= '0' && s[i] <= '9'; i++ {
+            if d.exp < _MAX_DECIMAL_EXP {
+                d.exp = d.exp * 10 + int64(s[i] - '0')
+            }
+        }
+        if i == es {
+            return d, false
+        }
+        if eneg {
+            d.exp = -d.exp
+        }
+    }
+    if i != n {
+        return d, false
+    }
+
+    /* strip the leading and trailing zeros of the significand */
+    digits := ip
+    if fp != "" {
+        digits = ip + fp
+    }
+    for len(digits) > 0 && digits[0] == '0' {
+        digits = digits[1:]
+    }
+    d.exp -= int64(len(fp))
+    for len(digits) > 0 && digits[len(digits) - 1] == '0' {
+        digits = digits[:len(digits) - 1]
+        d.exp++
+    }
+
+    /* move the point to the front of the digits, and unify the zeros */
+    d.exp += int64(len(digits))
+    if d.digits = digits; digits == "" {
+        d.neg, d.exp = false, 0
+    }
+    return d, true
+}
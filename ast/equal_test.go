@@ -0,0 +1,121 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestNodeEqual(t *testing.T) {
+    tests := []struct {
+        a     string
+        b     string
+        equal bool
+        kind  bool  // equal by NumbersByKindAndValue
+    }{
+        {`{"a":1,"b":[1,2]}`, ` { "b" : [ 1 , 2 ] , "a" : 1 } `, true, true},
+        {`{"a":1}`, `{"a":1,"b":2}`, false, false},
+        {`{"a":1,"b":2}`, `{"a":1,"c":2}`, false, false},
+        {`[1,2]`, `[2,1]`, false, false},
+        {`"aA\/"`, `"aA/"`, true, true},
+        {`"a"`, `"b"`, false, false},
+        {`1`, `1.0`, true, false},
+        {`1.0`, `10e-1`, true, true},
+        {`-0`, `0.0e5`, true, false},
+        {`100`, `1E2`, true, false},
+        {`0.001`, `1e-3`, true, true},
+        {`12345678901234567890`, `12345678901234567891`, false, false},
+        {`-1`, `1`, false, false},
+        {`1e400`, `1E+400`, true, true},
+        {`null`, `null`, true, true},
+        {`true`, `false`, false, false},
+        {`null`, `0`, false, false},
+        {`{"a":[{"b":null}]}`, `{"a":[{"b":null}]}`, true, true},
+    }
+    for _, tt := range tests {
+        a, b := NewRaw(tt.a), NewRaw(tt.b)
+        ok, err := a.Equal(&b)
+        require.NoError(t, err, tt.a)
+        assert.Equal(t, tt.equal, ok, "%s == %s", tt.a, tt.b)
+        ok, err = a.EqualWithOptions(&b, EqualOptions{Numbers: NumbersByKindAndValue})
+        require.NoError(t, err, tt.a)
+        assert.Equal(t, tt.kind, ok, "%s == %s by kind", tt.a, tt.b)
+
+        /* the equal values have the same hash */
+        ha, err := a.Hash()
+        require.NoError(t, err, tt.a)
+        hb, err := b.Hash()
+        require.NoError(t, err, tt.b)
+        assert.Equal(t, tt.equal, ha == hb, "hash(%s) == hash(%s)", tt.a, tt.b)
+    }
+
+    /* the nodes built from Go values */
+    a := NewRaw(`{"a":[1,"x"],"b":true}`)
+    b := NewObject([]Pair{
+        NewPair("b", NewBool(true)),
+        NewPair("a", NewAny([]interface{}{1, "x"})),
+    })
+    ok, err := a.Equal(&b)
+    require.NoError(t, err)
+    assert.True(t, ok)
+    ha, _ := a.Hash()
+    hb, _ := b.Hash()
+    assert.Equal(t, ha, hb)
+
+    /* the identical raw subtrees are not parsed */
+    a, b = NewRaw(`{"a":{"x":[1,2]},"b":1}`), NewRaw(`{"b":1,"a":{"x":[1,2]}}`)
+    ok, err = a.Equal(&b)
+    require.NoError(t, err)
+    assert.True(t, ok)
+    assert.True(t, a.Get("a").isRaw())
+
+    /* the errors */
+    a, b = NewRaw(`{"a":[1,}`), NewRaw(`{"a":[1]}`)
+    _, err = a.Equal(&b)
+    assert.Error(t, err)
+    _, err = a.Hash()
+    assert.Error(t, err)
+}
+
+func TestNodeHash_Objects(t *testing.T) {
+    /* the hashes of pairs must not cancel each other */
+    docs := []string{`{}`, `{"a":1,"b":1}`, `{"a":1,"a":1}`, `{"a":1}`, `{"b":1}`, `{"a":"1"}`, `[]`, `[{}]`, `""`, `[""]`, `[[]]`}
+    seen := map[uint64]string{}
+    for _, doc := range docs {
+        n := NewRaw(doc)
+        h, err := n.Hash()
+        require.NoError(t, err, doc)
+        if prev, ok := seen[h]; ok {
+            t.Fatalf("hash(%s) == hash(%s)", doc, prev)
+        }
+        seen[h] = doc
+    }
+
+    a, b := NewRaw(`{"a":1,"a":1}`), NewRaw(`{"a":1,"b":1}`)
+    ok, err := a.Equal(&b)
+    require.NoError(t, err)
+    assert.False(t, ok)
+
+    /* the hash is stable across processes */
+    n := NewRaw(`{"a":[1,2.5,"s",null,true]}`)
+    h, err := n.Hash()
+    require.NoError(t, err)
+    assert.Equal(t, uint64(0x20750a76890249cb), h)
+}
@@ -0,0 +1,1258 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `regexp`
+    `strconv`
+    `strings`
+    `unicode/utf8`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// PathNode is a node selected by a JSONPath query,
+// with its normalized path (RFC 9535, section 2.7), like `$['a'][0]`.
+type PathNode struct {
+    Path string
+    Node *Node
+}
+
+// JSONPath is a compiled JSONPath query (RFC 9535).
+// It is safe to use a JSONPath concurrently if the queried nodes are.
+type JSONPath struct {
+    src  string
+    segs []pathSegment
+    abs  bool // some filters query from the root
+}
+
+// CompileJSONPath parses a JSONPath query, like `$.store..book[?@.price < 10].title`.
+func CompileJSONPath(expr string) (*JSONPath, error) {
+    p := pathParser{s: expr}
+    if !p.eat('$') {
+        return nil, p.error("expect '$' at the beginning of the query")
+    }
+    segs, err := p.parseSegments()
+    if err != nil {
+        return nil, err
+    }
+    if p.p != len(p.s) {
+        return nil, p.error("unexpected character")
+    }
+    return &JSONPath{src: expr, segs: segs, abs: p.abs}, nil
+}
+
+// String returns the source of the query.
+func (self *JSONPath) String() string {
+    return self.src
+}
+
+// Query returns all the nodes in root selected by the query, in the order of the query results.
+// Raw or lazy parts of root are parsed only as far as the query needs.
+func (self *JSONPath) Query(root *Node) ([]PathNode, error) {
+    ctx := pathContext{root: root, paths: true}
+    return ctx.selectNodes(self.segs, []PathNode{{Path: "$", Node: root}})
+}
+
+// Query compiles expr as a JSONPath query, and returns all the nodes in self selected by it.
+func (self *Node) Query(expr string) ([]PathNode, error) {
+    jp, err := CompileJSONPath(expr)
+    if err != nil {
+        return nil, err
+    }
+    return jp.Query(self)
+}
+
+// Query compiles expr as a JSONPath query, and returns all the nodes in the JSON selected by it.
+// The leading member names and indexes of the query are located in the raw JSON directly,
+// and only the value there is parsed as far as the rest of the query needs.
+func (self *Searcher) Query(expr string) ([]PathNode, error) {
+    jp, err := CompileJSONPath(expr)
+    if err != nil {
+        return nil, err
+    }
+
+    /* locate the singular prefix of the query, the filters querying from the root need the whole document */
+    path, norm, i := []interface{}(nil), "$", 0
+    for ; i < len(jp.segs) && !jp.abs; i++ {
+        key, ok := jp.segs[i].singular()
+        if !ok {
+            break
+        }
+        path = append(path, key)
+        if s, ok := key.(string); ok {
+            norm = appendPathName(norm, s)
+        } else {
+            norm = appendPathIndex(norm, key.(int))
+        }
+    }
+
+    /* search the prefix with the native searcher */
+    root, err := self.getByPath(path...)
+    if err == ErrNotExist {
+        return nil, nil
+    }
+
+    /* a type mismatch of the prefix is an error of the searcher, so evaluate the whole query */
+    if err != nil {
+        if len(path) == 0 {
+            return nil, err
+        }
+        if self.ConcurrentRead {
+            root = NewRawConcurrentRead(self.parser.s)
+        } else {
+            root = NewRaw(self.parser.s)
+        }
+        if err := root.Check(); err != nil {
+            return nil, err
+        }
+        norm, i = "$", 0
+    }
+    ctx := pathContext{root: &root, paths: true}
+    return ctx.selectNodes(jp.segs[i:], []PathNode{{Path: norm, Node: &root}})
+}
+
+/**------------------------------------ Query Structures ------------------------------------**/
+
+type pathSelectorKind int
+
+const (
+    _SEL_NAME pathSelectorKind = iota
+    _SEL_WILDCARD
+    _SEL_INDEX
+    _SEL_SLICE
+    _SEL_FILTER
+)
+
+type pathSelector struct {
+    kind   pathSelectorKind
+    name   string
+    index  int
+    slice  [3]*int
+    filter logicalExpr
+}
+
+type pathSegment struct {
+    desc bool
+    sels []pathSelector
+}
+
+// singular returns the member name or the non-negative index, if the segment selects at most one child.
+func (self *pathSegment) singular() (interface{}, bool) {
+    if self.desc || len(self.sels) != 1 {
+        return nil, false
+    }
+    switch sel := &self.sels[0]; sel.kind {
+        case _SEL_NAME  : return sel.name, true
+        case _SEL_INDEX : return sel.index, sel.index >= 0
+        default         : return nil, false
+    }
+}
+
+type pathQuery struct {
+    rel  bool
+    segs []pathSegment
+}
+
+func (self *pathQuery) isSingular() bool {
+    for i := range self.segs {
+        if seg := &self.segs[i]; seg.desc || len(seg.sels) != 1 || (seg.sels[0].kind != _SEL_NAME && seg.sels[0].kind != _SEL_INDEX) {
+            return false
+        }
+    }
+    return true
+}
+
+/**------------------------------------ Query Evaluation ------------------------------------**/
+
+type pathContext struct {
+    root  *Node
+    paths bool
+}
+
+func (self *pathContext) selectNodes(segs []pathSegment, nodes []PathNode) ([]PathNode, error) {
+    var err error
+    for i := range segs {
+        if len(nodes) == 0 {
+            break
+        }
+        if nodes, err = self.selectSegment(&segs[i], nodes); err != nil {
+            return nil, err
+        }
+    }
+    return nodes, nil
+}
+
+func (self *pathContext) selectSegment(seg *pathSegment, nodes []PathNode) ([]PathNode, error) {
+    var out []PathNode
+    for _, n := range nodes {
+        var err error
+        if seg.desc {
+            err = self.descend(n, func(d PathNode) error { return self.selectAll(seg.sels, d, &out) })
+        } else {
+            err = self.selectAll(seg.sels, n, &out)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return out, nil
+}
+
+// descend calls fn on n and all its descendants in the document order.
+func (self *pathContext) descend(n PathNode, fn func(PathNode) error) error {
+    if err := fn(n); err != nil {
+        return err
+    }
+    return self.children(n, func(c PathNode) error { return self.descend(c, fn) })
+}
+
+// children calls fn on the elements of an array, or the member values of an object.
+func (self *pathContext) children(n PathNode, fn func(PathNode) error) error {
+    if err := n.Node.checkRaw(); err != nil {
+        return err
+    }
+    switch n.Node.itype() {
+        case types.V_ARRAY:
+            if err := n.Node.skipAllIndex(); err != nil {
+                return err
+            }
+            for i := 0; i < n.Node.len(); i++ {
+                if c := n.Node.nodeAt(i); c != nil && c.Exists() {
+                    if err := fn(PathNode{Path: self.indexPath(n.Path, i), Node: c}); err != nil {
+                        return err
+                    }
+                }
+            }
+        case types.V_OBJECT:
+            if err := n.Node.skipAllKey(); err != nil {
+                return err
+            }
+            for i := 0; i < n.Node.len(); i++ {
+                if p := n.Node.pairAt(i); p != nil && p.Value.Exists() {
+                    if err := fn(PathNode{Path: self.namePath(n.Path, p.Key), Node: &p.Value}); err != nil {
+                        return err
+                    }
+                }
+            }
+    }
+    return nil
+}
+
+func (self *pathContext) indexPath(path string, i int) string {
+    if !self.paths {
+        return ""
+    }
+    return appendPathIndex(path, i)
+}
+
+func (self *pathContext) namePath(path string, key string) string {
+    if !self.paths {
+        return ""
+    }
+    return appendPathName(path, key)
+}
+
+func (self *pathContext) selectAll(sels []pathSelector, n PathNode, out *[]PathNode) error {
+    for i := range sels {
+        if err := self.selectOne(&sels[i], n, out); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (self *pathContext) selectOne(sel *pathSelector, n PathNode, out *[]PathNode) error {
+    if err := n.Node.checkRaw(); err != nil {
+        return err
+    }
+    switch sel.kind {
+        case _SEL_NAME:
+            if n.Node.itype() != types.V_OBJECT {
+                return nil
+            }
+            c := n.Node.Get(sel.name)
+            if c == nil {
+                return nil
+            }
+            if err := c.Check(); err != nil {
+                return err
+            }
+            if c.Exists() {
+                *out = append(*out, PathNode{Path: self.namePath(n.Path, sel.name), Node: c})
+            }
+            return nil
+        case _SEL_WILDCARD:
+            return self.children(n, func(c PathNode) error { *out = append(*out, c); return nil })
+        case _SEL_INDEX:
+            return self.selectIndex(sel.index, n, out)
+        case _SEL_SLICE:
+            return self.selectSlice(sel.slice, n, out)
+        default:
+            return self.children(n, func(c PathNode) error {
+                ok, err := sel.filter.test(self, c.Node)
+                if ok {
+                    *out = append(*out, c)
+                }
+                return err
+            })
+    }
+}
+
+func (self *pathContext) selectIndex(i int, n PathNode, out *[]PathNode) error {
+    if n.Node.itype() != types.V_ARRAY {
+        return nil
+    }
+
+    /* the length is needed for the negative indexes */
+    var c *Node
+    if i >= 0 {
+        c = n.Node.skipIndex(i)
+    } else if err := n.Node.skipAllIndex(); err != nil {
+        return err
+    } else if i += n.Node.len(); i >= 0 {
+        c = n.Node.nodeAt(i)
+    }
+
+    if c == nil {
+        return nil
+    }
+    if err := c.Check(); err != nil {
+        return err
+    }
+    if c.Exists() {
+        *out = append(*out, PathNode{Path: self.indexPath(n.Path, i), Node: c})
+    }
+    return nil
+}
+
+func (self *pathContext) selectSlice(s [3]*int, n PathNode, out *[]PathNode) error {
+    if n.Node.itype() != types.V_ARRAY {
+        return nil
+    }
+    if err := n.Node.skipAllIndex(); err != nil {
+        return err
+    }
+
+    /* RFC 9535, section 2.3.4.2.2 */
+    nb, step := n.Node.len(), 1
+    if s[2] != nil {
+        step = *s[2]
+    }
+    if step == 0 {
+        return nil
+    }
+    norm := func(p *int, def int) int {
+        if p == nil {
+            return def
+        } else if *p >= 0 {
+            return *p
+        } else {
+            return nb + *p
+        }
+    }
+
+    emit := func(i int) {
+        if c := n.Node.nodeAt(i); c != nil && c.Exists() {
+            *out = append(*out, PathNode{Path: self.indexPath(n.Path, i), Node: c})
+        }
+    }
+    if step > 0 {
+        lower := minInt(maxInt(norm(s[0], 0), 0), nb)
+        upper := minInt(maxInt(norm(s[1], nb), 0), nb)
+        for i := lower; i < upper; i += step {
+            emit(i)
+        }
+    } else {
+        upper := minInt(maxInt(norm(s[0], nb - 1), -1), nb - 1)
+        lower := minInt(maxInt(norm(s[1], -nb - 1), -1), nb - 1)
+        for i := upper; lower < i; i += step {
+            emit(i)
+        }
+    }
+    return nil
+}
+
+func minInt(a, b int) int {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+func maxInt(a, b int) int {
+    if a > b {
+        return a
+    }
+    return b
+}
+
+func appendPathIndex(path string, i int) string {
+    return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// appendPathName appends the member name with the escaping of the normalized paths.
+func appendPathName(path string, key string) string {
+    buf := make([]byte, 0, len(path) + len(key) + 4)
+    buf = append(buf, path...)
+    buf = append(buf, '[', '\'')
+    for i := 0; i < len(key); i++ {
+        switch c := key[i]; c {
+            case '\b' : buf = append(buf, '\\', 'b')
+            case '\f' : buf = append(buf, '\\', 'f')
+            case '\n' : buf = append(buf, '\\', 'n')
+            case '\r' : buf = append(buf, '\\', 'r')
+            case '\t' : buf = append(buf, '\\', 't')
+            case '\'' : buf = append(buf, '\\', '\'')
+            case '\\' : buf = append(buf, '\\', '\\')
+            default   :
+                if c < 0x20 {
+                    buf = append(buf, '\\', 'u', '0', '0', hexDigits[c >> 4], hexDigits[c & 0xf])
+                } else {
+                    buf = append(buf, c)
+                }
+        }
+    }
+    return string(append(buf, '\'', ']'))
+}
+
+const hexDigits = "0123456789abcdef"
+
+/**------------------------------------ Filter Expressions ------------------------------------**/
+
+type pathType int
+
+const (
+    _TYPE_VALUE pathType = iota
+    _TYPE_LOGICAL
+    _TYPE_NODES
+)
+
+// logicalExpr is an expression of LogicalType, which tests the current node.
+type logicalExpr interface {
+    test(ctx *pathContext, cur *Node) (bool, error)
+}
+
+// valueExpr is an expression of ValueType, which may result in Nothing.
+type valueExpr interface {
+    value(ctx *pathContext, cur *Node) (interface{}, bool, error)
+}
+
+type orExpr []logicalExpr
+type andExpr []logicalExpr
+
+type notExpr struct {
+    expr logicalExpr
+}
+
+type compareExpr struct {
+    op   string
+    l, r valueExpr
+}
+
+type existExpr struct {
+    query *pathQuery
+}
+
+type literalExpr struct {
+    val interface{}
+}
+
+type funcExpr struct {
+    name string
+    args []interface{}
+    re   *regexp.Regexp
+}
+
+func (self orExpr) test(ctx *pathContext, cur *Node) (bool, error) {
+    for _, e := range self {
+        if ok, err := e.test(ctx, cur); ok || err != nil {
+            return ok, err
+        }
+    }
+    return false, nil
+}
+
+func (self andExpr) test(ctx *pathContext, cur *Node) (bool, error) {
+    for _, e := range self {
+        if ok, err := e.test(ctx, cur); !ok || err != nil {
+            return false, err
+        }
+    }
+    return true, nil
+}
+
+func (self notExpr) test(ctx *pathContext, cur *Node) (bool, error) {
+    ok, err := self.expr.test(ctx, cur)
+    return !ok, err
+}
+
+func (self existExpr) test(ctx *pathContext, cur *Node) (bool, error) {
+    nodes, err := self.query.nodes(ctx, cur)
+    return len(nodes) != 0, err
+}
+
+func (self compareExpr) test(ctx *pathContext, cur *Node) (bool, error) {
+    l, lok, err := self.l.value(ctx, cur)
+    if err != nil {
+        return false, err
+    }
+    r, rok, err := self.r.value(ctx, cur)
+    if err != nil {
+        return false, err
+    }
+    switch self.op {
+        case "==" : return equalValues(l, lok, r, rok)
+        case "!=" : ok, err := equalValues(l, lok, r, rok); return !ok && err == nil, err
+        case "<"  : return lessValues(l, lok, r, rok), nil
+        case ">"  : return lessValues(r, rok, l, lok), nil
+        case "<=" : if lessValues(l, lok, r, rok) { return true, nil }; return equalValues(l, lok, r, rok)
+        default   : if lessValues(r, rok, l, lok) { return true, nil }; return equalValues(l, lok, r, rok)
+    }
+}
+
+// equalValues compares the numbers by their exact decimal values, and the arrays and objects deeply.
+func equalValues(l interface{}, lok bool, r interface{}, rok bool) (bool, error) {
+    if !lok || !rok {
+        return lok == rok, nil
+    }
+    switch lv := l.(type) {
+        case decimal : rv, ok := r.(decimal); return ok && lv.compare(rv) == 0, nil
+        case *Node   : rv, ok := r.(*Node); if !ok { return false, nil }; return equalNodes(lv, rv, EqualOptions{})
+        default      : return l == r, nil
+    }
+}
+
+func lessValues(l interface{}, lok bool, r interface{}, rok bool) bool {
+    if !lok || !rok {
+        return false
+    }
+    switch lv := l.(type) {
+        case decimal : rv, ok := r.(decimal); return ok && lv.compare(rv) < 0
+        case string  : rv, ok := r.(string); return ok && lv < rv
+        default      : return false
+    }
+}
+
+func (self literalExpr) value(_ *pathContext, _ *Node) (interface{}, bool, error) {
+    return self.val, true, nil
+}
+
+// nodes returns the nodes selected by the query from the current node or the root.
+func (self *pathQuery) nodes(ctx *pathContext, cur *Node) ([]PathNode, error) {
+    start := ctx.root
+    if self.rel {
+        start = cur
+    }
+    sub := pathContext{root: ctx.root}
+    return sub.selectNodes(self.segs, []PathNode{{Node: start}})
+}
+
+// value returns the value of the node selected by the singular query.
+func (self *pathQuery) value(ctx *pathContext, cur *Node) (interface{}, bool, error) {
+    nodes, err := self.nodes(ctx, cur)
+    if err != nil || len(nodes) != 1 {
+        return nil, false, err
+    }
+    return nodeValue(nodes[0].Node)
+}
+
+// nodeValue returns the value of the node to be compared, which is nil, bool, string,
+// decimal for the numbers, or the node itself for the arrays and objects.
+func nodeValue(n *Node) (interface{}, bool, error) {
+    n, err := prepareDiff(n)
+    if err != nil {
+        return nil, false, err
+    }
+    switch n.itype() {
+        case types.V_NULL   : return nil, true, nil
+        case types.V_TRUE   : return true, true, nil
+        case types.V_FALSE  : return false, true, nil
+        case types.V_STRING : return n.toString(), true, nil
+        case _V_NUMBER:
+            d, ok := parseDecimal(n.toString())
+            if !ok {
+                return nil, false, ErrUnsupportType
+            }
+            return d, true, nil
+        case types.V_ARRAY:
+            return n, true, n.skipAllIndex()
+        case types.V_OBJECT:
+            return n, true, n.skipAllKey()
+        default:
+            return nil, false, ErrUnsupportType
+    }
+}
+
+// intDecimal returns the decimal of a non-negative integer.
+func intDecimal(v int) decimal {
+    d, _ := parseDecimal(strconv.Itoa(v))
+    return d
+}
+
+/* the function extensions of RFC 9535, section 2.4 */
+
+type pathFunction struct {
+    ret  pathType
+    args []pathType
+}
+
+var pathFunctions = map[string]pathFunction {
+    "length" : { ret: _TYPE_VALUE   , args: []pathType{_TYPE_VALUE} },
+    "count"  : { ret: _TYPE_VALUE   , args: []pathType{_TYPE_NODES} },
+    "match"  : { ret: _TYPE_LOGICAL , args: []pathType{_TYPE_VALUE, _TYPE_VALUE} },
+    "search" : { ret: _TYPE_LOGICAL , args: []pathType{_TYPE_VALUE, _TYPE_VALUE} },
+    "value"  : { ret: _TYPE_VALUE   , args: []pathType{_TYPE_NODES} },
+}
+
+func (self *funcExpr) value(ctx *pathContext, cur *Node) (interface{}, bool, error) {
+    switch self.name {
+        case "length":
+            v, ok, err := self.args[0].(valueExpr).value(ctx, cur)
+            if !ok || err != nil {
+                return nil, false, err
+            }
+            switch vv := v.(type) {
+                case string : return intDecimal(utf8.RuneCountInString(vv)), true, nil
+                case *Node  : return intDecimal(vv.len()), true, nil
+                default     : return nil, false, nil
+            }
+        case "count":
+            nodes, err := self.args[0].(*pathQuery).nodes(ctx, cur)
+            return intDecimal(len(nodes)), err == nil, err
+        default:
+            nodes, err := self.args[0].(*pathQuery).nodes(ctx, cur)
+            if err != nil || len(nodes) != 1 {
+                return nil, false, err
+            }
+            return nodeValue(nodes[0].Node)
+    }
+}
+
+func (self *funcExpr) test(ctx *pathContext, cur *Node) (bool, error) {
+    v, ok, err := self.args[0].(valueExpr).value(ctx, cur)
+    if !ok || err != nil {
+        return false, err
+    }
+    s, ok := v.(string)
+    if !ok {
+        return false, nil
+    }
+
+    /* the literal patterns are compiled in advance */
+    re := self.re
+    if re == nil {
+        p, ok, err := self.args[1].(valueExpr).value(ctx, cur)
+        if !ok || err != nil {
+            return false, err
+        }
+        ps, ok := p.(string)
+        if !ok {
+            return false, nil
+        }
+        if re = compileIRegexp(ps, self.name == "match"); re == nil {
+            return false, nil
+        }
+    }
+    return re.MatchString(s), nil
+}
+
+// compileIRegexp compiles the I-Regexp (RFC 9485) pattern, or returns nil if it is invalid.
+// Only the literal patterns are compiled once, when the query is parsed,
+// since the patterns from the documents are unbounded.
+func compileIRegexp(pattern string, full bool) *regexp.Regexp {
+    /* '.' matches any character except the line breaks */
+    var buf strings.Builder
+    var class bool
+    for i := 0; i < len(pattern); i++ {
+        switch c := pattern[i]; {
+            case c == '\\' && i + 1 < len(pattern) : buf.WriteByte(c); i++; buf.WriteByte(pattern[i])
+            case c == '.' && !class                : buf.WriteString(`[^\n\r]`)
+            default                                :
+                if c == '[' {
+                    class = true
+                } else if c == ']' {
+                    class = false
+                }
+                buf.WriteByte(c)
+        }
+    }
+
+    src := buf.String()
+    if full {
+        src = `\A(?:` + src + `)\z`
+    }
+    re, err := regexp.Compile(src)
+    if err != nil {
+        return nil
+    }
+    return re
+}
+
+/**------------------------------------ Query Parser ------------------------------------**/
+
+type pathParser struct {
+    s   string
+    p   int
+    abs bool
+}
+
+func (self *pathParser) error(msg string) error {
+    return SyntaxError{
+        Pos  : self.p,
+        Src  : self.s,
+        Code : types.ERR_INVALID_CHAR,
+        Msg  : "invalid JSONPath: " + msg,
+    }
+}
+
+func isPathBlank(c byte) bool {
+    return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (self *pathParser) skipBlank() {
+    for self.p < len(self.s) && isPathBlank(self.s[self.p]) {
+        self.p++
+    }
+}
+
+func (self *pathParser) peek() byte {
+    if self.p < len(self.s) {
+        return self.s[self.p]
+    }
+    return 0
+}
+
+func (self *pathParser) eat(c byte) bool {
+    if self.peek() == c {
+        self.p++
+        return true
+    }
+    return false
+}
+
+func (self *pathParser) eatString(s string) bool {
+    if strings.HasPrefix(self.s[self.p:], s) {
+        self.p += len(s)
+        return true
+    }
+    return false
+}
+
+func (self *pathParser) parseSegments() ([]pathSegment, error) {
+    var segs []pathSegment
+    for {
+        /* blanks are allowed only before the segments */
+        save := self.p
+        self.skipBlank()
+        if c := self.peek(); c != '.' && c != '[' {
+            self.p = save
+            return segs, nil
+        }
+
+        seg, err := self.parseSegment()
+        if err != nil {
+            return nil, err
+        }
+        segs = append(segs, seg)
+    }
+}
+
+func (self *pathParser) parseSegment() (pathSegment, error) {
+    var seg pathSegment
+    if self.eat('[') {
+        sels, err := self.parseBracket()
+        return pathSegment{sels: sels}, err
+    }
+
+    /* the descendant segment */
+    self.p++
+    if self.eat('.') {
+        seg.desc = true
+        if self.eat('[') {
+            sels, err := self.parseBracket()
+            seg.sels = sels
+            return seg, err
+        }
+    }
+
+    /* the shorthands */
+    if self.eat('*') {
+        seg.sels = []pathSelector{{kind: _SEL_WILDCARD}}
+        return seg, nil
+    }
+    name, ok := self.parseMemberName()
+    if !ok {
+        return seg, self.error("expect a member name")
+    }
+    seg.sels = []pathSelector{{kind: _SEL_NAME, name: name}}
+    return seg, nil
+}
+
+func (self *pathParser) parseMemberName() (string, bool) {
+    s := self.p
+    for self.p < len(self.s) {
+        c, n := utf8.DecodeRuneInString(self.s[self.p:])
+        if !(c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (self.p > s && c >= '0' && c <= '9')) {
+            break
+        }
+        self.p += n
+    }
+    return self.s[s:self.p], self.p > s
+}
+
+func (self *pathParser) parseBracket() ([]pathSelector, error) {
+    var sels []pathSelector
+    for {
+        self.skipBlank()
+        sel, err := self.parseSelector()
+        if err != nil {
+            return nil, err
+        }
+        sels = append(sels, sel)
+        self.skipBlank()
+        if self.eat(']') {
+            return sels, nil
+        }
+        if !self.eat(',') {
+            return nil, self.error("expect ',' or ']'")
+        }
+    }
+}
+
+func (self *pathParser) parseSelector() (pathSelector, error) {
+    switch c := self.peek(); {
+        case c == '\'' || c == '"':
+            name, err := self.parseString()
+            return pathSelector{kind: _SEL_NAME, name: name}, err
+        case c == '*':
+            self.p++
+            return pathSelector{kind: _SEL_WILDCARD}, nil
+        case c == '?':
+            self.p++
+            self.skipBlank()
+            expr, err := self.parseOr()
+            return pathSelector{kind: _SEL_FILTER, filter: expr}, err
+        case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+            return self.parseIndexOrSlice()
+        default:
+            return pathSelector{}, self.error("expect a selector")
+    }
+}
+
+func (self *pathParser) parseIndexOrSlice() (pathSelector, error) {
+    var err error
+    var sel = pathSelector{kind: _SEL_INDEX}
+    for i := 0; i < 3; i++ {
+        if i > 0 {
+            self.skipBlank()
+            if !self.eat(':') {
+                break
+            }
+            sel.kind = _SEL_SLICE
+            self.skipBlank()
+        }
+        if c := self.peek(); c == '-' || (c >= '0' && c <= '9') {
+            v := 0
+            if v, err = self.parseInt(); err != nil {
+                return sel, err
+            }
+            sel.slice[i] = &v
+        }
+    }
+
+    if sel.kind == _SEL_INDEX {
+        if sel.slice[0] == nil {
+            return sel, self.error("expect an index")
+        }
+        sel.index = *sel.slice[0]
+    }
+    return sel, nil
+}
+
+// maxPathInt is the max integer in the I-JSON range.
+const maxPathInt = 1 << 53 - 1
+
+func (self *pathParser) parseInt() (int, error) {
+    s := self.p
+    self.eat('-')
+    d := self.p
+    for c := self.peek(); c >= '0' && c <= '9'; c = self.peek() {
+        self.p++
+    }
+    if self.p == d || (self.s[d] == '0' && (self.p - d > 1 || d > s)) {
+        self.p = s
+        return 0, self.error("invalid integer")
+    }
+    v, err := strconv.ParseInt(self.s[s:self.p], 10, 64)
+    if err != nil || v > maxPathInt || v < -maxPathInt {
+        self.p = s
+        return 0, self.error("integer out of range")
+    }
+    return int(v), nil
+}
+
+func (self *pathParser) parseString() (string, error) {
+    q := self.s[self.p]
+    self.p++
+
+    var buf []byte
+    for {
+        if self.p >= len(self.s) {
+            return "", self.error("unterminated string")
+        }
+        c := self.s[self.p]
+        if c == q {
+            self.p++
+            return string(buf), nil
+        }
+        if c < 0x20 {
+            return "", self.error("control character in string")
+        }
+        if c != '\\' {
+            buf = append(buf, c)
+            self.p++
+            continue
+        }
+
+        /* the escaped characters */
+        self.p++
+        switch e := self.peek(); e {
+            case 'b'  : buf = append(buf, '\b')
+            case 'f'  : buf = append(buf, '\f')
+            case 'n'  : buf = append(buf, '\n')
+            case 'r'  : buf = append(buf, '\r')
+            case 't'  : buf = append(buf, '\t')
+            case '/'  : buf = append(buf, '/')
+            case '\\' : buf = append(buf, '\\')
+            case 'u'  :
+                r, err := self.parseUnicode()
+                if err != nil {
+                    return "", err
+                }
+                buf = append(buf, string(r)...)
+                continue
+            default   :
+                if e != q {
+                    return "", self.error("invalid escape")
+                }
+                buf = append(buf, e)
+        }
+        self.p++
+    }
+}
+
+// parseUnicode parses the `uXXXX` escape, or a surrogate pair of them.
+func (self *pathParser) parseUnicode() (rune, error) {
+    r, ok := self.parseHex4()
+    if !ok {
+        return 0, self.error("invalid unicode escape")
+    }
+    if r >= 0xdc00 && r <= 0xdfff {
+        return 0, self.error("unpaired surrogate")
+    }
+    if r < 0xd800 || r > 0xdbff {
+        return r, nil
+    }
+    if !self.eatString(`\`) {
+        return 0, self.error("unpaired surrogate")
+    }
+    lo, ok := self.parseHex4()
+    if !ok || lo < 0xdc00 || lo > 0xdfff {
+        return 0, self.error("unpaired surrogate")
+    }
+    return 0x10000 + (r - 0xd800) << 10 + (lo - 0xdc00), nil
+}
+
+func (self *pathParser) parseHex4() (rune, bool) {
+    if !self.eat('u') || self.p + 4 > len(self.s) {
+        return 0, false
+    }
+    v, err := strconv.ParseUint(self.s[self.p:self.p + 4], 16, 32)
+    if err != nil {
+        return 0, false
+    }
+    self.p += 4
+    return rune(v), true
+}
+
+func (self *pathParser) parseOr() (logicalExpr, error) {
+    var ret orExpr
+    for {
+        expr, err := self.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        ret = append(ret, expr)
+        save := self.p
+        self.skipBlank()
+        if !self.eatString("||") {
+            self.p = save
+            break
+        }
+        self.skipBlank()
+    }
+    if len(ret) == 1 {
+        return ret[0], nil
+    }
+    return ret, nil
+}
+
+func (self *pathParser) parseAnd() (logicalExpr, error) {
+    var ret andExpr
+    for {
+        expr, err := self.parseBasic()
+        if err != nil {
+            return nil, err
+        }
+        ret = append(ret, expr)
+        save := self.p
+        self.skipBlank()
+        if !self.eatString("&&") {
+            self.p = save
+            break
+        }
+        self.skipBlank()
+    }
+    if len(ret) == 1 {
+        return ret[0], nil
+    }
+    return ret, nil
+}
+
+func (self *pathParser) parseBasic() (logicalExpr, error) {
+    if self.eat('!') {
+        self.skipBlank()
+        if self.peek() == '(' {
+            expr, err := self.parseParen()
+            return notExpr{expr}, err
+        }
+        expr, err := self.parseTest()
+        return notExpr{expr}, err
+    }
+    if self.peek() == '(' {
+        return self.parseParen()
+    }
+
+    /* either a comparison or a test expression */
+    s := self.p
+    l, lt, err := self.parseOperand()
+    if err != nil {
+        return nil, err
+    }
+    save := self.p
+    self.skipBlank()
+    op := self.parseCompareOp()
+    if op == "" {
+        self.p = s
+        return self.parseTest()
+    }
+    self.p = save
+    lv, err := self.comparable(l, lt)
+    if err != nil {
+        return nil, err
+    }
+
+    self.skipBlank()
+    self.p += len(op)
+    self.skipBlank()
+    r, rt, err := self.parseOperand()
+    if err != nil {
+        return nil, err
+    }
+    rv, err := self.comparable(r, rt)
+    if err != nil {
+        return nil, err
+    }
+    return compareExpr{op: op, l: lv, r: rv}, nil
+}
+
+func (self *pathParser) parseParen() (logicalExpr, error) {
+    self.p++
+    self.skipBlank()
+    expr, err := self.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    self.skipBlank()
+    if !self.eat(')') {
+        return nil, self.error("expect ')'")
+    }
+    return expr, nil
+}
+
+func (self *pathParser) parseCompareOp() string {
+    for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+        if strings.HasPrefix(self.s[self.p:], op) {
+            return op
+        }
+    }
+    return ""
+}
+
+// parseTest parses a test expression, which is a query or a function of LogicalType or NodesType.
+func (self *pathParser) parseTest() (logicalExpr, error) {
+    s := self.p
+    expr, typ, err := self.parseOperand()
+    if err != nil {
+        return nil, err
+    }
+    switch e := expr.(type) {
+        case *pathQuery:
+            return existExpr{e}, nil
+        case *funcExpr:
+            if typ == _TYPE_LOGICAL {
+                return e, nil
+            }
+    }
+    self.p = s
+    return nil, self.error("expect a test expression")
+}
+
+// comparable checks that the operand is a literal, a singular query or a function of ValueType.
+func (self *pathParser) comparable(expr interface{}, typ pathType) (valueExpr, error) {
+    if q, ok := expr.(*pathQuery); ok && !q.isSingular() {
+        return nil, self.error("non-singular query is not comparable")
+    }
+    if typ != _TYPE_VALUE {
+        return nil, self.error("expect a comparable")
+    }
+    return expr.(valueExpr), nil
+}
+
+// parseOperand parses a query, a function or a literal, and returns its type.
+// The singular queries are of ValueType when compared, and of NodesType otherwise.
+func (self *pathParser) parseOperand() (interface{}, pathType, error) {
+    switch c := self.peek(); {
+        case c == '@' || c == '$':
+            self.p++
+            segs, err := self.parseSegments()
+            q := &pathQuery{rel: c == '@', segs: segs}
+            self.abs = self.abs || !q.rel
+            if q.isSingular() {
+                return q, _TYPE_VALUE, err
+            }
+            return q, _TYPE_NODES, err
+        case c == '\'' || c == '"':
+            s, err := self.parseString()
+            return literalExpr{s}, _TYPE_VALUE, err
+        case c == '-' || (c >= '0' && c <= '9'):
+            v, err := self.parseNumber()
+            return literalExpr{v}, _TYPE_VALUE, err
+        case self.eatString("true"):
+            return literalExpr{true}, _TYPE_VALUE, nil
+        case self.eatString("false"):
+            return literalExpr{false}, _TYPE_VALUE, nil
+        case self.eatString("null"):
+            return literalExpr{nil}, _TYPE_VALUE, nil
+        case c >= 'a' && c <= 'z':
+            return self.parseFunction()
+        default:
+            return nil, 0, self.error("expect an operand")
+    }
+}
+
+func (self *pathParser) parseNumber() (decimal, error) {
+    s := self.p
+    self.eat('-')
+    d := self.p
+    for c := self.peek(); c >= '0' && c <= '9'; c = self.peek() {
+        self.p++
+    }
+    if self.p == d || (self.s[d] == '0' && self.p - d > 1) {
+        self.p = s
+        return decimal{}, self.error("invalid number")
+    }
+
+    /* the fraction and exponent parts */
+    if self.eat('.') {
+        f := self.p
+        for c := self.peek(); c >= '0' && c <= '9'; c = self.peek() {
+            self.p++
+        }
+        if self.p == f {
+            return decimal{}, self.error("invalid number")
+        }
+    }
+    if c := self.peek(); c == 'e' || c == 'E' {
+        self.p++
+        if c = self.peek(); c == '+' || c == '-' {
+            self.p++
+        }
+        e := self.p
+        for c := self.peek(); c >= '0' && c <= '9'; c = self.peek() {
+            self.p++
+        }
+        if self.p == e {
+            return decimal{}, self.error("invalid number")
+        }
+    }
+
+    v, _ := parseDecimal(self.s[s:self.p])
+    return v, nil
+}
+
+func (self *pathParser) parseFunction() (interface{}, pathType, error) {
+    s := self.p
+    for c := self.peek(); (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_'; c = self.peek() {
+        self.p++
+    }
+    name := self.s[s:self.p]
+    fn, ok := pathFunctions[name]
+    if !ok {
+        self.p = s
+        return nil, 0, self.error("unknown function " + strconv.Quote(name))
+    }
+    if !self.eat('(') {
+        return nil, 0, self.error("expect '('")
+    }
+
+    /* check the types of the arguments */
+    ret := &funcExpr{name: name}
+    for i, at := range fn.args {
+        self.skipBlank()
+        if i > 0 && !self.eat(',') {
+            return nil, 0, self.error("expect ','")
+        }
+        self.skipBlank()
+        p := self.p
+        arg, typ, err := self.parseOperand()
+        if err != nil {
+            return nil, 0, err
+        }
+        if _, ok := arg.(*pathQuery); ok && at == _TYPE_NODES {
+            typ = _TYPE_NODES
+        }
+        if typ != at {
+            self.p = p
+            return nil, 0, self.error("mismatched argument type of function " + strconv.Quote(name))
+        }
+        ret.args = append(ret.args, arg)
+    }
+    self.skipBlank()
+    if !self.eat(')') {
+        return nil, 0, self.error("expect ')'")
+    }
+
+    /* compile the literal patterns in advance */
+    if lit, ok := ret.args[len(ret.args) - 1].(literalExpr); ok && fn.ret == _TYPE_LOGICAL {
+        if ps, ok := lit.val.(string); ok {
+            ret.re = compileIRegexp(ps, name == "match")
+        }
+    }
+    return ret, fn.ret, nil
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+const _TestJSONPathStore = `{ "store": {
+    "book": [
+      { "category": "reference",
+        "author": "Nigel Rees",
+        "title": "Sayings of the Century",
+        "price": 8.95
+      },
+      { "category": "fiction",
+        "author": "Evelyn Waugh",
+        "title": "Sword of Honour",
+        "price": 12.99
+      },
+      { "category": "fiction",
+        "author": "Herman Melville",
+        "title": "Moby Dick",
+        "isbn": "0-553-21311-3",
+        "price": 8.99
+      },
+      { "category": "fiction",
+        "author": "J. R. R. Tolkien",
+        "title": "The Lord of the Rings",
+        "isbn": "0-395-19395-8",
+        "price": 22.99
+      }
+    ],
+    "bicycle": {
+      "color": "red",
+      "price": 399
+    }
+  }
+}`
+
+func queryPaths(nodes []PathNode) []string {
+    ret := []string{}
+    for _, n := range nodes {
+        ret = append(ret, n.Path)
+    }
+    return ret
+}
+
+func queryValues(t *testing.T, nodes []PathNode) []string {
+    ret := []string{}
+    for _, n := range nodes {
+        raw, err := n.Node.Raw()
+        require.NoError(t, err)
+        ret = append(ret, raw)
+    }
+    return ret
+}
+
+func TestJSONPath_Store(t *testing.T) {
+    tests := []struct {
+        expr  string
+        paths []string
+    }{
+        {`$.store.book[*].author`, []string{`$['store']['book'][0]['author']`, `$['store']['book'][1]['author']`, `$['store']['book'][2]['author']`, `$['store']['book'][3]['author']`}},
+        {`$..author`, []string{`$['store']['book'][0]['author']`, `$['store']['book'][1]['author']`, `$['store']['book'][2]['author']`, `$['store']['book'][3]['author']`}},
+        {`$.store.*`, []string{`$['store']['book']`, `$['store']['bicycle']`}},
+        {`$.store..price`, []string{`$['store']['book'][0]['price']`, `$['store']['book'][1]['price']`, `$['store']['book'][2]['price']`, `$['store']['book'][3]['price']`, `$['store']['bicycle']['price']`}},
+        {`$..book[2]`, []string{`$['store']['book'][2]`}},
+        {`$..book[2].author`, []string{`$['store']['book'][2]['author']`}},
+        {`$..book[2].publisher`, []string{}},
+        {`$..book[-1]`, []string{`$['store']['book'][3]`}},
+        {`$..book[0,1]`, []string{`$['store']['book'][0]`, `$['store']['book'][1]`}},
+        {`$..book[:2]`, []string{`$['store']['book'][0]`, `$['store']['book'][1]`}},
+        {`$..book[?@.isbn]`, []string{`$['store']['book'][2]`, `$['store']['book'][3]`}},
+        {`$..book[?@.price<10]`, []string{`$['store']['book'][0]`, `$['store']['book'][2]`}},
+        {`$["store"]['bicycle'] [ 'color' ]`, []string{`$['store']['bicycle']['color']`}},
+        {`$..book[?@.price > 10 && @.category == 'fiction'].title`, []string{`$['store']['book'][1]['title']`, `$['store']['book'][3]['title']`}},
+        {`$..book[?!(@.price < 10) || @.isbn == "0-553-21311-3"].price`, []string{`$['store']['book'][1]['price']`, `$['store']['book'][2]['price']`, `$['store']['book'][3]['price']`}},
+        {`$..book[?@.price == $.store.bicycle.price]`, []string{}},
+        {`$..book[?match(@.author, 'J.*')].title`, []string{`$['store']['book'][3]['title']`}},
+        {`$..book[?search(@.title, "of")].title`, []string{`$['store']['book'][0]['title']`, `$['store']['book'][1]['title']`, `$['store']['book'][3]['title']`}},
+        {`$..book[?length(@.title) > 16].price`, []string{`$['store']['book'][0]['price']`, `$['store']['book'][3]['price']`}},
+        {`$.store[?count(@.*) == 2]`, []string{`$['store']['bicycle']`}},
+        {`$.store[?value(@..color) == 'red']`, []string{`$['store']['bicycle']`}},
+        {`$..*[?@ == 399]`, []string{`$['store']['bicycle']['price']`}},
+    }
+
+    root := NewRaw(_TestJSONPathStore)
+    loaded := NewRaw(_TestJSONPathStore)
+    require.NoError(t, loaded.LoadAll())
+    for _, tt := range tests {
+        t.Run(tt.expr, func(t *testing.T) {
+            nodes, err := root.Query(tt.expr)
+            require.NoError(t, err)
+            assert.Equal(t, tt.paths, queryPaths(nodes))
+
+            nodes, err = loaded.Query(tt.expr)
+            require.NoError(t, err)
+            assert.Equal(t, tt.paths, queryPaths(nodes))
+
+            s := NewSearcher(_TestJSONPathStore)
+            nodes, err = s.Query(tt.expr)
+            require.NoError(t, err)
+            assert.Equal(t, tt.paths, queryPaths(nodes))
+        })
+    }
+}
+
+func TestJSONPath_Selectors(t *testing.T) {
+    src := `{"a":[0,1,2,3,4,5,6], "o":{"j j":{"ka":3}, "'\n\u0001":4}, "": 5, "n":[null, {"x":null}]}`
+    tests := []struct {
+        expr   string
+        values []string
+    }{
+        {`$.a[1:5:2]`, []string{`1`, `3`}},
+        {`$.a[5:1:-2]`, []string{`5`, `3`}},
+        {`$.a[::-3]`, []string{`6`, `3`, `0`}},
+        {`$.a[-2:]`, []string{`5`, `6`}},
+        {`$.a[1:3:0]`, []string{}},
+        {`$.a[7]`, []string{}},
+        {`$.a[-8]`, []string{}},
+        {`$.a[0, 0, -1]`, []string{`0`, `0`, `6`}},
+        {`$.o['j j']['ka']`, []string{`3`}},
+        {`$.o["'\n\u0001"]`, []string{`4`}},
+        {`$['']`, []string{`5`}},
+        {`$.a.b`, []string{}},
+        {`$.o[0]`, []string{}},
+        {`$.n[?@ == null]`, []string{`null`}},
+        {`$.n[?@.x == null]`, []string{`{"x":null}`}},
+        {`$.n[?@.y == @.z]`, []string{`null`, `{"x":null}`}},
+        {`$.a[?@ >= 5 || @ < 1]`, []string{`0`, `5`, `6`}},
+        {`$[?@ == 5]`, []string{`5`}},
+        {`$.o[?@.ka == 3.0e0]`, []string{`{"ka":3}`}},
+        {`$.o..*`, []string{`{"ka":3}`, `4`, `3`}},
+        {`$[?@ == $.a]`, []string{`[0,1,2,3,4,5,6]`}},
+        {`$[?length(@) == 7]`, []string{`[0,1,2,3,4,5,6]`}},
+    }
+    root := NewRaw(src)
+    for _, tt := range tests {
+        nodes, err := root.Query(tt.expr)
+        require.NoError(t, err, tt.expr)
+        assert.Equal(t, tt.values, queryValues(t, nodes), tt.expr)
+    }
+
+    /* the normalized paths escape the member names */
+    nodes, err := root.Query(`$.o.*`)
+    require.NoError(t, err)
+    assert.Equal(t, []string{`$['o']['j j']`, `$['o']['\'\n\u0001']`}, queryPaths(nodes))
+}
+
+func TestJSONPath_ExactNumbers(t *testing.T) {
+    src := `{"b":[9007199254740993, 9007199254740992, 1e400, 0.1, 1.0, -0, {"x":[1.0]}, {"x":[1]}], "s":["a.c", "a\\.c"]}`
+    tests := []struct {
+        expr   string
+        values []string
+    }{
+        {`$.b[?@ == 9007199254740993]`, []string{`9007199254740993`}},
+        {`$.b[?@ > 9007199254740992]`, []string{`9007199254740993`, `1e400`}},
+        {`$.b[?@ == 1e400]`, []string{`1e400`}},
+        {`$.b[?@ == 10e-1]`, []string{`1.0`}},
+        {`$.b[?@ == 0]`, []string{`-0`}},
+        {`$.b[?@ > 0.09999999999999999999 && @ <= 0.1]`, []string{`0.1`}},
+        {`$.b[?@.x == $.b[7].x]`, []string{`{"x":[1.0]}`, `{"x":[1]}`}},
+        {`$.b[?length(@.x) == 1.0]`, []string{`{"x":[1.0]}`, `{"x":[1]}`}},
+        {`$.s[?match(@, $.s[0])]`, []string{`"a.c"`}},
+        {`$.s[?match(@, $.s[1])]`, []string{`"a.c"`}},
+    }
+    root := NewRaw(src)
+    for _, tt := range tests {
+        nodes, err := root.Query(tt.expr)
+        require.NoError(t, err, tt.expr)
+        assert.Equal(t, tt.values, queryValues(t, nodes), tt.expr)
+    }
+}
+
+func TestJSONPath_Nodes(t *testing.T) {
+    root := NewObject([]Pair{
+        NewPair("a", NewArray([]Node{NewNumber("1"), NewString("x"), NewAny([]int{1, 2})})),
+        NewPair("b", NewAny(map[string]interface{}{"c": 1})),
+    })
+    nodes, err := root.Query(`$.a[?@ == 1 || @ == 'x']`)
+    require.NoError(t, err)
+    assert.Equal(t, []string{`$['a'][0]`, `$['a'][1]`}, queryPaths(nodes))
+    nodes, err = root.Query(`$..c`)
+    require.NoError(t, err)
+    assert.Empty(t, nodes)
+
+    /* the nodes can be modified through the results */
+    nodes, err = root.Query(`$.a[1]`)
+    require.NoError(t, err)
+    require.Len(t, nodes, 1)
+    *nodes[0].Node = NewString("y")
+    s, err := root.GetByPath("a", 1).String()
+    require.NoError(t, err)
+    assert.Equal(t, "y", s)
+}
+
+func TestJSONPath_SearcherRoot(t *testing.T) {
+    const src = `{"a":[{"x":1},{"x":2}],"b":2}`
+    for _, expr := range []string{`$.a[?@.x == $.b]`, `$.a[?@.x == $.a[1].x]`, `$.a[?$.b]`} {
+        root := NewRaw(src)
+        exp, err := root.Query(expr)
+        require.NoError(t, err)
+        nodes, err := NewSearcher(src).Query(expr)
+        require.NoError(t, err)
+        assert.Equal(t, queryPaths(exp), queryPaths(nodes), expr)
+    }
+    nodes, err := NewSearcher(src).Query(`$.a[?@.x == $.b]`)
+    require.NoError(t, err)
+    assert.Equal(t, []string{`$['a'][1]`}, queryPaths(nodes))
+}
+
+func TestJSONPath_Errors(t *testing.T) {
+    for _, expr := range []string{
+        ``, `a`, `$ `, ` $`, `$.`, `$..`, `$[`, `$[]`, `$[1`, `$[01]`, `$[-0]`, `$[9007199254740992]`,
+        `$['a]`, `$['\"']`, `$["\ud800"]`, `$.1a`, `$[?@.a == ]`, `$[?@.* == 1]`, `$[?1]`,
+        `$[?length(@.*) == 1]`, `$[?count(1) == 1]`, `$[?match(@.a)]`, `$[?foo(@)]`, `$[?length(@)]`,
+        `$[?!@.a == 1]`, `$[?(@.a]`, `$[?@.a === 1]`, `$[?@.a == [1]]`, "$['\x01']",
+    } {
+        _, err := CompileJSONPath(expr)
+        assert.Error(t, err, expr)
+    }
+
+    /* the invalid json */
+    root := NewRaw(`{"a":[1,}`)
+    _, err := root.Query(`$..*`)
+    assert.Error(t, err)
+    _, err = NewSearcher(`{"a":[1,}`).Query(`$.a[*]`)
+    assert.Error(t, err)
+    _, err = NewSearcher(`[1,}`).Query(`$.a`)
+    assert.Error(t, err)
+
+    /* the type mismatch of the prefix is not an error */
+    nodes, err := NewSearcher(`[1]`).Query(`$.a.b`)
+    assert.NoError(t, err)
+    assert.Empty(t, nodes)
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// MergeStrategy decides how Node.Merge merges two arrays.
+type MergeStrategy int
+
+const (
+    // MergeArrayReplace replaces the target array with the other one.
+    MergeArrayReplace MergeStrategy = iota
+
+    // MergeArrayAppend appends the elements of the other array to the target array.
+    MergeArrayAppend
+
+    // MergeArrayByIndex merges the elements at the same index,
+    // and appends the rest elements of the other array.
+    MergeArrayByIndex
+)
+
+// MergePatch applies the JSON Merge Patch (RFC 7386) to target.
+//
+// If the patch is an object, its members are merged into target recursively,
+// and the members with null values are removed from target.
+// Otherwise the patch replaces target.
+//
+// Only the members touched by the patch get loaded, the others stay lazy.
+// The target is modified in place, thus it may be partially patched if an error occurs.
+func MergePatch(target *Node, patch Node) error {
+    return mergePatch(target, &patch)
+}
+
+func mergePatch(target *Node, patch *Node) error {
+    if err := loadMergeSource(patch); err != nil {
+        return err
+    }
+    if patch.itype() != types.V_OBJECT {
+        *target = *patch
+        return nil
+    }
+    if err := target.checkRaw(); err != nil {
+        return err
+    }
+    if target.itype() != types.V_OBJECT {
+        *target = NewObject(nil)
+    }
+
+    it := patch.properties()
+    for p := it.next(); p != nil; p = it.next() {
+        if err := loadMergeSource(&p.Value); err != nil {
+            return err
+        }
+
+        /* null removes the member */
+        if p.Value.itype() == types.V_NULL {
+            if _, err := target.Unset(p.Key); err != nil {
+                return err
+            }
+            continue
+        }
+
+        /* the members of object must be merged, to remove the nulls in it */
+        if p.Value.itype() != types.V_OBJECT {
+            if _, err := target.Set(p.Key, p.Value); err != nil {
+                return err
+            }
+            continue
+        }
+        v, err := mergeChild(target, p.Key)
+        if err != nil {
+            return err
+        }
+        if err := mergePatch(v, &p.Value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// loadMergeSource loads the children of the container n,
+// which get copied into the target and must not be lazy.
+func loadMergeSource(n *Node) error {
+    if err := n.checkRaw(); err != nil {
+        return err
+    }
+    switch n.itype() {
+        case types.V_OBJECT : return n.skipAllKey()
+        case types.V_ARRAY  : return n.skipAllIndex()
+        default             : return nil
+    }
+}
+
+// mergeChild returns the child of key under target, and creates it if it does not exist.
+func mergeChild(target *Node, key string) (*Node, error) {
+    v := target.Get(key)
+    if v.Exists() {
+        return v, nil
+    } else if err := v.Check(); err != nil && err != ErrNotExist {
+        return nil, err
+    }
+    if _, err := target.Set(key, NewObject(nil)); err != nil {
+        return nil, err
+    }
+    return target.Get(key), nil
+}
+
+// Merge merges other into self deeply, which is useful to layer configurations.
+//
+// The members of objects are merged recursively, and the arrays are merged by the strategy.
+// Otherwise other replaces self, including null.
+//
+// Only the members touched by other get loaded, the others stay lazy.
+// Self is modified in place, thus it may be partially merged if an error occurs.
+func (self *Node) Merge(other Node, strategy MergeStrategy) error {
+    return self.merge(&other, strategy)
+}
+
+func (self *Node) merge(other *Node, strategy MergeStrategy) error {
+    if err := loadMergeSource(other); err != nil {
+        return err
+    }
+    if err := self.checkRaw(); err != nil {
+        return err
+    }
+
+    switch it := other.itype(); {
+        case it == types.V_OBJECT && self.itype() == types.V_OBJECT:
+            iter := other.properties()
+            for p := iter.next(); p != nil; p = iter.next() {
+                v := self.Get(p.Key)
+                if err := v.Check(); err != nil && err != ErrNotExist {
+                    return err
+                }
+                if !v.Exists() {
+                    if err := loadLazy(&p.Value); err != nil {
+                        return err
+                    }
+                    if _, err := self.Set(p.Key, p.Value); err != nil {
+                        return err
+                    }
+                } else if err := v.merge(&p.Value, strategy); err != nil {
+                    return err
+                }
+            }
+        case it == types.V_ARRAY && self.itype() == types.V_ARRAY && strategy == MergeArrayAppend:
+            iter := other.values()
+            for v := iter.next(); v != nil; v = iter.next() {
+                if err := loadLazy(v); err != nil {
+                    return err
+                }
+                if err := self.Add(*v); err != nil {
+                    return err
+                }
+            }
+        case it == types.V_ARRAY && self.itype() == types.V_ARRAY && strategy == MergeArrayByIndex:
+            if err := self.skipAllIndex(); err != nil {
+                return err
+            }
+            n := self.len()
+            iter := other.values()
+            for i, v := 0, iter.next(); v != nil; i, v = i + 1, iter.next() {
+                if i >= n {
+                    if err := loadLazy(v); err != nil {
+                        return err
+                    }
+                    if err := self.Add(*v); err != nil {
+                        return err
+                    }
+                } else if err := self.nodeAt(i).merge(v, strategy); err != nil {
+                    return err
+                }
+            }
+        default:
+            *self = *other
+    }
+    return nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestMergePatch_RFC7386(t *testing.T) {
+    tests := []struct {
+        target string
+        patch  string
+        exp    string
+    }{
+        {`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+        {`{"a":"b"}`, `{"a":null}`, `{}`},
+        {`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+        {`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+        {`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+        {`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+        {`["a","b"]`, `["c","d"]`, `["c","d"]`},
+        {`{"a":"b"}`, `["c"]`, `["c"]`},
+        {`{"a":"foo"}`, `null`, `null`},
+        {`{"a":"foo"}`, `"bar"`, `"bar"`},
+        {`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+        {`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+        {`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+    }
+    for _, tt := range tests {
+        target := NewRaw(tt.target)
+        require.NoError(t, MergePatch(&target, NewRaw(tt.patch)), tt.patch)
+        out, err := target.MarshalJSON()
+        require.NoError(t, err, tt.patch)
+        assert.Equal(t, tt.exp, string(out), tt.patch)
+    }
+
+    /* merge into an empty node */
+    var target Node
+    require.NoError(t, MergePatch(&target, NewRaw(`{"a":{"b":null,"c":1}}`)))
+    out, err := target.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":{"c":1}}`, string(out))
+
+    /* the errors */
+    target = NewRaw(`{"a":`)
+    assert.Error(t, MergePatch(&target, NewRaw(`{"a":1}`)))
+    target = NewRaw(`{}`)
+    assert.Error(t, MergePatch(&target, NewRaw(`{"a":[}`)))
+}
+
+func TestMergePatch_Lazy(t *testing.T) {
+    target := NewRaw(`{"a":{"x":[1,2]},"b":{"y":{"z":1}},"c":2}`)
+    require.NoError(t, MergePatch(&target, NewRaw(`{"b":{"y":{"w":2}},"c":null}`)))
+
+    /* the untouched subtrees are not parsed */
+    assert.True(t, target.Get("a").isRaw())
+    assert.True(t, target.Get("b").Get("y").Get("z").isRaw())
+    out, err := target.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":{"x":[1,2]},"b":{"y":{"z":1,"w":2}}}`, string(out))
+}
+
+func TestNodeMerge(t *testing.T) {
+    base := `{"a":[1,{"x":1}],"b":{"c":1,"d":[1]},"e":1}`
+    other := `{"a":[2,{"y":2},3],"b":{"d":[2],"f":null},"e":{"g":1}}`
+    tests := []struct {
+        strategy MergeStrategy
+        exp      string
+    }{
+        {MergeArrayReplace, `{"a":[2,{"y":2},3],"b":{"c":1,"d":[2],"f":null},"e":{"g":1}}`},
+        {MergeArrayAppend, `{"a":[1,{"x":1},2,{"y":2},3],"b":{"c":1,"d":[1,2],"f":null},"e":{"g":1}}`},
+        {MergeArrayByIndex, `{"a":[2,{"x":1,"y":2},3],"b":{"c":1,"d":[2],"f":null},"e":{"g":1}}`},
+    }
+    for _, tt := range tests {
+        node := NewRaw(base)
+        require.NoError(t, node.Merge(NewRaw(other), tt.strategy))
+        out, err := node.MarshalJSON()
+        require.NoError(t, err)
+        assert.Equal(t, tt.exp, string(out), tt.strategy)
+    }
+
+    /* layer the configurations */
+    conf := NewRaw(`{"log":{"level":"info","file":"a.log"},"hosts":["a"]}`)
+    require.NoError(t, conf.Merge(NewRaw(`{"log":{"level":"warn"}}`), MergeArrayAppend))
+    require.NoError(t, conf.Merge(NewRaw(`{"hosts":["b"],"debug":true}`), MergeArrayAppend))
+    assert.True(t, conf.Get("log").Get("file").isRaw())
+    out, err := conf.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"log":{"level":"warn","file":"a.log"},"hosts":["a","b"],"debug":true}`, string(out))
+
+    /* the errors */
+    node := NewRaw(`{"a":[}`)
+    assert.Error(t, node.Merge(NewRaw(`{"a":[1]}`), MergeArrayAppend))
+    node = NewRaw(`{}`)
+    assert.Error(t, node.Merge(NewRaw(`{"a":}`), MergeArrayAppend))
+}
@@ -567,7 +567,7 @@ func (self *Node) Cap() (int, error) {
 //
 // If self is V_NONE or V_NULL, it becomes V_OBJECT and sets the node at the key.
 func (self *Node) Set(key string, node Node) (bool, error) {
-    if err := self.checkRaw(); err != nil {
+    if err := self.checkMutable(); err != nil {
         return false, err
     }
     if err := node.Check(); err != nil {
@@ -611,6 +611,9 @@ func (self *Node) Unset(key string) (bool, error) {
     if err := self.should(types.V_OBJECT); err != nil {
         return false, err
     }
+    if err := self.checkMutable(); err != nil {
+        return false, err
+    }
     // NOTICE: must get acurate length before deduct
     if err := self.skipAllKey(); err != nil {
         return false, err
@@ -629,7 +632,7 @@ func (self *Node) Unset(key string) (bool, error) {
 //
 // The index must be within self's children.
 func (self *Node) SetByIndex(index int, node Node) (bool, error) {
-    if err := self.checkRaw(); err != nil {
+    if err := self.checkMutable(); err != nil {
         return false, err 
     }
     if err := node.Check(); err != nil {
@@ -662,7 +665,7 @@ func (self *Node) SetAnyByIndex(index int, val interface{}) (bool, error) {
 // WARN: this will change address of elements, which is a dangerous action.
 // Use Unset() for object or Pop() for array instead.
 func (self *Node) UnsetByIndex(index int) (bool, error) {
-    if err := self.checkRaw(); err != nil {
+    if err := self.checkMutable(); err != nil {
         return false, err
     }
 
@@ -709,7 +712,7 @@ func (self *Node) UnsetByIndex(index int) (bool, error) {
 //
 // If self is V_NONE or V_NULL, it becomes V_ARRAY and sets the node at index 0.
 func (self *Node) Add(node Node) error {
-    if err := self.checkRaw(); err != nil {
+    if err := self.checkMutable(); err != nil {
         return err
     }
 
@@ -734,7 +737,7 @@ func (self *Node) Add(node Node) error {
 
 // Pop remove the last child of the V_Array or V_Object node.
 func (self *Node) Pop() error {
-    if err := self.checkRaw(); err != nil {
+    if err := self.checkMutable(); err != nil {
         return err
     }
 
@@ -783,6 +786,9 @@ func (self *Node) Move(dst, src int) error {
     if err := self.should(types.V_ARRAY); err != nil {
         return err
     }
+    if err := self.checkMutable(); err != nil {
+        return err
+    }
 
     s, err := self.unsafeArray()
     if err != nil {
@@ -1034,7 +1040,7 @@ func (self *Node) SortKeys(recurse bool) error {
 
 func (self *Node) sortKeys(recurse bool) (err error) {
     // check raw node first
-    if err := self.checkRaw(); err != nil {
+    if err := self.checkMutable(); err != nil {
         return err
     }
     ps, err := self.unsafeMap()
@@ -1293,13 +1299,13 @@ func (self *Node) nodeAt(i int) *Node {
                     i--
                 }
                 if i < 0 {
-                    return v
+                    return p.own(v)
                 }
             }
             return nil
         } 
     }
-    return p.At(i)
+    return p.own(p.At(i))
 }
 
 func (self *Node) pairAt(i int) *Pair {
@@ -1317,13 +1323,13 @@ func (self *Node) pairAt(i int) *Pair {
                     i--
                 }
                 if i < 0 {
-                    return v
+                    return p.own(v)
                 }
             }
            return nil
        } 
     }
-    return p.At(i)
+    return p.own(p.At(i))
 }
 
 func (self *Node) skipAllIndex() error {
@@ -1368,7 +1374,9 @@ func (self *Node) skipKey(key string) (*Node, int) {
             s := (*parseObjectStack)(self.p)
             p, i = s.v.Get(key)
         } else {
-            p, i = (*linkedPairs)(self.p).Get(key)
+            s := (*linkedPairs)(self.p)
+            p, i = s.Get(key)
+            p = s.own(p)
         }
 
         if p != nil {
@@ -33,6 +33,7 @@ const (
 const (
     _ERR_NOT_FOUND      types.ParsingError = 33
     _ERR_UNSUPPORT_TYPE types.ParsingError = 34
+    _ERR_IMMUTABLE      types.ParsingError = 35
 )
 
 var (
@@ -41,6 +42,9 @@ var (
 
     // ErrUnsupportType means API on the node is unsupported
     ErrUnsupportType error = newError(_ERR_UNSUPPORT_TYPE, "unsupported type")
+
+    // ErrImmutable means the node belongs to a snapshot, thus can't be modified
+    ErrImmutable error = newError(_ERR_IMMUTABLE, "immutable snapshot")
 )
 
 type Parser struct {
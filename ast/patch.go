@@ -0,0 +1,406 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `errors`
+    `fmt`
+    `unsafe`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// ErrPatchTest means the value of a JSON Patch "test" operation is not equal to the target value.
+var ErrPatchTest = errors.New("test operation failed")
+
+// PatchError is returned by ApplyPatch when an operation of the patch fails.
+type PatchError struct {
+    Index int    // index of the operation in the patch
+    Op    string // name of the operation, may be empty if the operation is malformed
+    Err   error
+}
+
+func (self *PatchError) Error() string {
+    return fmt.Sprintf("json patch: operation %d (%s) failed: %v", self.Index, self.Op, self.Err)
+}
+
+func (self *PatchError) Unwrap() error {
+    return self.Err
+}
+
+// ApplyPatch applies the JSON Patch (RFC 6902) to doc.
+// The patch must be an array of operations, and the supported operations are
+// "add", "remove", "replace", "move", "copy" and "test".
+//
+// The patch is atomic: if any operation fails, doc is left unchanged and a *PatchError
+// reporting the failed operation is returned. Only the containers on the paths of
+// the operations get loaded and copied, so the untouched subtrees stay lazy.
+func ApplyPatch(doc *Node, patch Node) error {
+    if err := doc.Check(); err != nil {
+        return err
+    }
+    if err := patch.should(types.V_ARRAY); err != nil {
+        return err
+    }
+    if err := patch.skipAllIndex(); err != nil {
+        return err
+    }
+
+    /* the working root must not share the parsing state with doc */
+    if err := loadLazy(doc); err != nil {
+        return err
+    }
+    p := patcher{root: *doc}
+
+    it := patch.values()
+    for op, i := it.next(), 0; op != nil; op, i = it.next(), i + 1 {
+        if name, err := p.apply(op); err != nil {
+            return &PatchError{Index: i, Op: name, Err: err}
+        }
+    }
+    *doc = p.root
+    return nil
+}
+
+// patcher applies the operations on a copy-on-write tree of the document.
+// A container is copied before it is modified, unless it is owned by the patcher.
+type patcher struct {
+    root  Node
+    owned map[unsafe.Pointer]bool
+}
+
+func (self *patcher) apply(op *Node) (string, error) {
+    name, err := patchString(op, "op")
+    if err != nil {
+        return "", err
+    }
+    path, err := patchPointer(op, "path")
+    if err != nil {
+        return name, err
+    }
+
+    switch name {
+        case "add":
+            val, err := patchValue(op)
+            if err != nil {
+                return name, err
+            }
+            return name, self.add(path, val)
+        case "remove":
+            _, err := self.remove(path)
+            return name, err
+        case "replace":
+            val, err := patchValue(op)
+            if err != nil {
+                return name, err
+            }
+            return name, self.replace(path, val)
+        case "move":
+            from, err := patchPointer(op, "from")
+            if err != nil {
+                return name, err
+            }
+            return name, self.move(from, path)
+        case "copy":
+            from, err := patchPointer(op, "from")
+            if err != nil {
+                return name, err
+            }
+            val, err := self.get(from)
+            if err != nil {
+                return name, err
+            }
+            return name, self.add(path, self.copyValue(*val))
+        case "test":
+            val, err := patchValue(op)
+            if err != nil {
+                return name, err
+            }
+            return name, self.test(path, &val)
+        default:
+            return name, fmt.Errorf("unknown operation %q", name)
+    }
+}
+
+func patchString(op *Node, key string) (string, error) {
+    v := op.Get(key)
+    if err := v.Check(); err != nil {
+        if err == ErrNotExist {
+            return "", fmt.Errorf("missing member %q", key)
+        }
+        return "", err
+    }
+    if v.Type() != V_STRING {
+        return "", fmt.Errorf("member %q must be a string", key)
+    }
+    return v.String()
+}
+
+func patchPointer(op *Node, key string) (*Pointer, error) {
+    s, err := patchString(op, key)
+    if err != nil {
+        return nil, err
+    }
+    return CompilePointer(s)
+}
+
+func patchValue(op *Node) (Node, error) {
+    v := op.Get("value")
+    if err := v.Check(); err != nil {
+        if err == ErrNotExist {
+            return Node{}, errors.New(`missing member "value"`)
+        }
+        return Node{}, err
+    }
+    if err := loadLazy(v); err != nil {
+        return Node{}, err
+    }
+    return *v, nil
+}
+
+// loadLazy loads the lazy container, so that it can be copied safely.
+func loadLazy(n *Node) error {
+    if !n.isLazy() {
+        return nil
+    }
+    if n.itype() == types.V_OBJECT {
+        return n.skipAllKey()
+    }
+    return n.skipAllIndex()
+}
+
+// own makes the container n a copy owned by the patcher, so it can be modified.
+func (self *patcher) own(n *Node) error {
+    if err := n.checkRaw(); err != nil {
+        return err
+    }
+    switch n.itype() {
+        case types.V_OBJECT:
+            if err := n.skipAllKey(); err != nil {
+                return err
+            }
+            if self.owned[n.p] {
+                return nil
+            }
+            pairs := make([]Pair, 0, n.len())
+            it := n.properties()
+            for p := it.next(); p != nil; p = it.next() {
+                if err := loadLazy(&p.Value); err != nil {
+                    return err
+                }
+                pairs = append(pairs, *p)
+            }
+            *n = NewObject(pairs)
+        case types.V_ARRAY:
+            if err := n.skipAllIndex(); err != nil {
+                return err
+            }
+            if self.owned[n.p] {
+                return nil
+            }
+            nodes := make([]Node, 0, n.len())
+            it := n.values()
+            for v := it.next(); v != nil; v = it.next() {
+                if err := loadLazy(v); err != nil {
+                    return err
+                }
+                nodes = append(nodes, *v)
+            }
+            *n = NewArray(nodes)
+        default:
+            return ErrUnsupportType
+    }
+    if self.owned == nil {
+        self.owned = make(map[unsafe.Pointer]bool)
+    }
+    self.owned[n.p] = true
+    return nil
+}
+
+// copyValue copies the containers owned by the patcher in n,
+// the others are never modified and can be shared.
+func (self *patcher) copyValue(n Node) Node {
+    if n.p == nil || !self.owned[n.p] {
+        return n
+    }
+    if n.itype() == types.V_OBJECT {
+        pairs := make([]Pair, 0, n.len())
+        it := n.properties()
+        for p := it.next(); p != nil; p = it.next() {
+            pairs = append(pairs, NewPair(p.Key, self.copyValue(p.Value)))
+        }
+        return NewObject(pairs)
+    }
+    nodes := make([]Node, 0, n.len())
+    it := n.values()
+    for v := it.next(); v != nil; v = it.next() {
+        nodes = append(nodes, self.copyValue(*v))
+    }
+    return NewArray(nodes)
+}
+
+// get returns the node at the pointer without modifying the document.
+func (self *patcher) get(ptr *Pointer) (*Node, error) {
+    n := ptr.getParent(&self.root, len(ptr.keys))
+    if n == nil {
+        return nil, ErrNotExist
+    }
+    return n, n.Check()
+}
+
+// parent returns the owned parent container of the pointer.
+func (self *patcher) parent(ptr *Pointer) (*Node, error) {
+    cur := &self.root
+    for i := 0; ; i++ {
+        if err := self.own(cur); err != nil {
+            return nil, err
+        }
+        if i == len(ptr.keys) - 1 {
+            return cur, nil
+        }
+        if cur = self.child(cur, ptr, i); cur == nil {
+            return nil, ErrNotExist
+        }
+    }
+}
+
+// child returns the child of the loaded container at the i-th token, or nil if it does not exist.
+func (self *patcher) child(n *Node, ptr *Pointer, i int) *Node {
+    if n.itype() == types.V_OBJECT {
+        v, _ := n.skipKey(ptr.keys[i].(string))
+        if !v.Exists() {
+            return nil
+        }
+        return v
+    }
+    if ptr.idxs[i] == nil || ptr.idxs[i].(int) >= n.len() {
+        return nil
+    }
+    return n.nodeAt(ptr.idxs[i].(int))
+}
+
+func (self *patcher) add(ptr *Pointer, val Node) error {
+    if err := val.Check(); err != nil {
+        return err
+    }
+    if len(ptr.keys) == 0 {
+        self.root = val
+        return nil
+    }
+    parent, err := self.parent(ptr)
+    if err != nil {
+        return err
+    }
+
+    last := len(ptr.keys) - 1
+    if parent.itype() == types.V_OBJECT {
+        _, err := parent.Set(ptr.keys[last].(string), val)
+        return err
+    }
+    if ptr.isAppend(last) {
+        return parent.Add(val)
+    }
+    if ptr.idxs[last] == nil || ptr.idxs[last].(int) > parent.len() {
+        return ErrNotExist
+    }
+
+    /* insert the value by appending and moving it */
+    if err := parent.Add(val); err != nil {
+        return err
+    }
+    return parent.Move(ptr.idxs[last].(int), parent.len() - 1)
+}
+
+func (self *patcher) remove(ptr *Pointer) (Node, error) {
+    if len(ptr.keys) == 0 {
+        return Node{}, ErrUnsupportType
+    }
+    parent, err := self.parent(ptr)
+    if err != nil {
+        return Node{}, err
+    }
+
+    last := len(ptr.keys) - 1
+    v := self.child(parent, ptr, last)
+    if v == nil {
+        return Node{}, ErrNotExist
+    }
+    val := *v
+    if parent.itype() == types.V_OBJECT {
+        _, err = parent.Unset(ptr.keys[last].(string))
+    } else {
+        _, err = parent.UnsetByIndex(ptr.idxs[last].(int))
+    }
+    return val, err
+}
+
+func (self *patcher) replace(ptr *Pointer, val Node) error {
+    if err := val.Check(); err != nil {
+        return err
+    }
+    if len(ptr.keys) == 0 {
+        self.root = val
+        return nil
+    }
+    parent, err := self.parent(ptr)
+    if err != nil {
+        return err
+    }
+    v := self.child(parent, ptr, len(ptr.keys) - 1)
+    if v == nil {
+        return ErrNotExist
+    }
+    *v = val
+    return nil
+}
+
+func (self *patcher) move(from *Pointer, path *Pointer) error {
+    if len(from.keys) <= len(path.keys) && isPointerPrefix(from, path) {
+        if len(from.keys) == len(path.keys) {
+            _, err := self.get(from)
+            return err
+        }
+        return errors.New("cannot move a value into one of its children")
+    }
+    val, err := self.remove(from)
+    if err != nil {
+        return err
+    }
+    return self.add(path, val)
+}
+
+func isPointerPrefix(prefix *Pointer, ptr *Pointer) bool {
+    for i, k := range prefix.keys {
+        if k.(string) != ptr.keys[i].(string) {
+            return false
+        }
+    }
+    return true
+}
+
+func (self *patcher) test(ptr *Pointer, val *Node) error {
+    v, err := self.get(ptr)
+    if err != nil {
+        return err
+    }
+    if ok, err := equalNodes(v, val, EqualOptions{}); err != nil {
+        return err
+    } else if !ok {
+        return ErrPatchTest
+    }
+    return nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `errors`
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestApplyPatch_RFC6902(t *testing.T) {
+    tests := []struct {
+        doc   string
+        patch string
+        exp   string
+    }{
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+        {`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+        {`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+        {`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+        {`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+        {`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+        {`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+        {`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+        {`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+        {`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+        {`{"foo":1}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"test","path":"/bar","value":1.0}]`, `{"foo":1,"bar":1}`},
+        {`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+        {`{"a":[9007199254740993,{"b":"\u0063"}]}`, `[{"op":"test","path":"/a","value":[9007199254740993e0,{"b":"c"}]}]`, `{"a":[9007199254740993,{"b":"c"}]}`},
+        {`{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]},{"op":"add","path":"/0","value":0}]`, `[0,1]`},
+    }
+    for _, tt := range tests {
+        doc := NewRaw(tt.doc)
+        require.NoError(t, ApplyPatch(&doc, NewRaw(tt.patch)), tt.patch)
+        out, err := doc.MarshalJSON()
+        require.NoError(t, err, tt.patch)
+        assert.Equal(t, tt.exp, string(out), tt.patch)
+    }
+}
+
+func TestApplyPatch_Errors(t *testing.T) {
+    tests := []struct {
+        doc   string
+        patch string
+        index int
+    }{
+        {`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, 0},
+        {`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, 0},
+        {`{"a":[9007199254740993]}`, `[{"op":"test","path":"/a/0","value":9007199254740992}]`, 0},
+        {`{"a":{"b":0.1}}`, `[{"op":"test","path":"/a","value":{"b":0.10000000000000001}}]`, 0},
+        {`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, 0},
+        {`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/-"}]`, 0},
+        {`{"foo":{}}`, `[{"op":"add","path":"/bar","value":1},{"op":"move","from":"/foo","path":"/foo/x"}]`, 1},
+        {`{"foo":1}`, `[{"op":"add","path":"/bar","value":1},{"op":"replace","path":"/baz","value":1}]`, 1},
+        {`{"foo":1}`, `[{"op":"remove","path":"/foo"},{"op":"remove","path":"/foo"}]`, 1},
+        {`{"foo":1}`, `[{"op":"add","path":"/bar"}]`, 0},
+        {`{"foo":1}`, `[{"op":"copy","path":"/bar"}]`, 0},
+        {`{"foo":1}`, `[{"path":"/bar","value":1}]`, 0},
+        {`{"foo":1}`, `[{"op":"bad","path":"/bar"}]`, 0},
+        {`{"foo":1}`, `[{"op":"add","path":"bar","value":1}]`, 0},
+        {`{"foo":1}`, `[{"op":"add","path":"/foo/x","value":1}]`, 0},
+        {`{"foo":1}`, `[1]`, 0},
+    }
+    for _, tt := range tests {
+        doc := NewRaw(tt.doc)
+        err := ApplyPatch(&doc, NewRaw(tt.patch))
+        var pe *PatchError
+        require.True(t, errors.As(err, &pe), tt.patch)
+        assert.Equal(t, tt.index, pe.Index, tt.patch)
+        out, err := doc.MarshalJSON()
+        require.NoError(t, err)
+        assert.Equal(t, tt.doc, string(out), tt.patch)
+    }
+
+    doc := NewRaw(`{"baz":"qux"}`)
+    err := ApplyPatch(&doc, NewRaw(`[{"op":"test","path":"/baz","value":"bar"}]`))
+    assert.True(t, errors.Is(err, ErrPatchTest))
+    assert.Error(t, ApplyPatch(&doc, NewRaw(`{}`)))
+    assert.Error(t, ApplyPatch(&doc, NewRaw(`[`)))
+}
+
+func TestApplyPatch_Atomic(t *testing.T) {
+    src := `{"a":{"b":[1,2,3],"c":{"d":1}},"e":[{"f":1}]}`
+    doc := NewRaw(src)
+    require.NoError(t, doc.Get("a").Get("c").Check())
+    require.NoError(t, doc.Get("e").Index(0).LoadAll())
+
+    patch := NewRaw(`[
+        {"op":"add","path":"/a/b/0","value":0},
+        {"op":"remove","path":"/a/c/d"},
+        {"op":"replace","path":"/e/0/f","value":2},
+        {"op":"move","from":"/a/b","path":"/g"},
+        {"op":"copy","from":"/e","path":"/a/h"},
+        {"op":"add","path":"/a/h/0/i","value":3},
+        {"op":"test","path":"/x","value":0}
+    ]`)
+    err := ApplyPatch(&doc, patch)
+    var pe *PatchError
+    require.True(t, errors.As(err, &pe))
+    assert.Equal(t, 6, pe.Index)
+    assert.Equal(t, "test", pe.Op)
+    assert.Equal(t, ErrNotExist, pe.Err)
+    out, err := doc.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, src, string(out))
+
+    /* apply it without the failed operation */
+    _, err = patch.UnsetByIndex(6)
+    require.NoError(t, err)
+    require.NoError(t, ApplyPatch(&doc, patch))
+    out, err = doc.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":{"c":{},"h":[{"f":2,"i":3}]},"e":[{"f":2}],"g":[0,1,2,3]}`, string(out))
+}
+
+func TestApplyPatch_Lazy(t *testing.T) {
+    doc := NewRaw(`{"a":{"x":[1,2]},"b":{"y":{"z":1}}}`)
+    require.NoError(t, ApplyPatch(&doc, NewRaw(`[{"op":"add","path":"/b/y/w","value":2}]`)))
+
+    /* the untouched subtrees are not parsed */
+    assert.True(t, doc.Get("a").isRaw())
+    assert.True(t, doc.Get("b").Get("y").Get("z").isRaw())
+    out, err := doc.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":{"x":[1,2]},"b":{"y":{"z":1,"w":2}}}`, string(out))
+}
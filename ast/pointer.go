@@ -0,0 +1,332 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `strconv`
+    `strings`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// Pointer is a compiled JSON Pointer (RFC 6901), like `/a/b~1c/0`.
+//
+// A reference token is an object key, or an array index if it is a non-negative
+// decimal integer without leading zeros. The token `-` refers to the position after
+// the last element of an array, so it can only be used to append elements.
+type Pointer struct {
+    src  string
+    keys []interface{}
+    idxs []interface{}
+}
+
+// CompilePointer parses a JSON Pointer. The empty pointer refers to the whole document.
+func CompilePointer(ptr string) (*Pointer, error) {
+    ret := &Pointer{src: ptr}
+    if ptr == "" {
+        return ret, nil
+    }
+    if ptr[0] != '/' {
+        return nil, pointerError(ptr, 0, "expect '/' at the beginning of the pointer")
+    }
+
+    /* split and unescape the reference tokens */
+    for p := 1; p <= len(ptr); {
+        e := strings.IndexByte(ptr[p:], '/')
+        if e < 0 {
+            e = len(ptr)
+        } else {
+            e += p
+        }
+        tok, err := unescapePointerToken(ptr, p, e)
+        if err != nil {
+            return nil, err
+        }
+
+        /* the tokens are boxed in advance, so that searching does not allocate */
+        ret.keys = append(ret.keys, tok)
+        if idx, ok := pointerIndex(tok); ok {
+            ret.idxs = append(ret.idxs, idx)
+        } else {
+            ret.idxs = append(ret.idxs, nil)
+        }
+        p = e + 1
+    }
+    return ret, nil
+}
+
+func pointerError(ptr string, pos int, msg string) error {
+    return SyntaxError{
+        Pos  : pos,
+        Src  : ptr,
+        Code : types.ERR_INVALID_CHAR,
+        Msg  : "invalid JSON pointer: " + msg,
+    }
+}
+
+func unescapePointerToken(ptr string, s int, e int) (string, error) {
+    tok := ptr[s:e]
+    if strings.IndexByte(tok, '~') < 0 {
+        return tok, nil
+    }
+    buf := make([]byte, 0, len(tok))
+    for i := s; i < e; i++ {
+        if ptr[i] != '~' {
+            buf = append(buf, ptr[i])
+        } else if i + 1 < e && ptr[i + 1] == '0' {
+            buf = append(buf, '~')
+            i++
+        } else if i + 1 < e && ptr[i + 1] == '1' {
+            buf = append(buf, '/')
+            i++
+        } else {
+            return "", pointerError(ptr, i, "'~' must be followed by '0' or '1'")
+        }
+    }
+    return string(buf), nil
+}
+
+// pointerIndex parses the array index of the token.
+func pointerIndex(tok string) (int, bool) {
+    if tok == "" || len(tok) > 1 && tok[0] == '0' {
+        return 0, false
+    }
+    for i := 0; i < len(tok); i++ {
+        if tok[i] < '0' || tok[i] > '9' {
+            return 0, false
+        }
+    }
+    idx, err := strconv.Atoi(tok)
+    return idx, err == nil
+}
+
+// String returns the source of the pointer.
+func (self *Pointer) String() string {
+    return self.src
+}
+
+// Tokens returns the unescaped reference tokens of the pointer.
+func (self *Pointer) Tokens() []string {
+    ret := make([]string, len(self.keys))
+    for i, k := range self.keys {
+        ret[i] = k.(string)
+    }
+    return ret
+}
+
+func (self *Pointer) isAppend(i int) bool {
+    return self.keys[i].(string) == "-"
+}
+
+// Search locates the pointer in the JSON of s. Each reference token is searched
+// with the native searcher as an object key or an array index, depending on the
+// type of the value it is applied to.
+func (self *Pointer) Search(s *Searcher) (Node, error) {
+    p := &s.parser
+    p.p = 0
+    if len(self.keys) == 0 {
+        return s.found(p.getByPath(s.ValidateJSON))
+    }
+
+    start, err := 0, types.ParsingError(0)
+    for i := range self.keys {
+        var path []interface{}
+        p.p = p.lspace(start)
+        if p.p >= len(p.s) {
+            return Node{}, p.syntaxError(types.ERR_EOF)
+        }
+
+        /* choose the type of the token */
+        switch p.s[p.p] {
+            case '{' : path = self.keys[i:i + 1]
+            case '[' : path = self.idxs[i:i + 1]
+        }
+        if path == nil || path[0] == nil {
+            return Node{}, ErrNotExist
+        }
+
+        /* only the value at the pointer needs validating */
+        if start, err = p.getByPath(s.ValidateJSON && i == len(self.keys) - 1, path...); err != 0 {
+            break
+        }
+    }
+    return s.found(start, err)
+}
+
+// Get returns the node at the pointer in root, or an error node if it does not exist.
+func (self *Pointer) Get(root *Node) *Node {
+    n := self.getParent(root, len(self.keys))
+    if n != nil {
+        return n
+    }
+    return newError(_ERR_NOT_FOUND, "value not exists")
+}
+
+// getParent returns the node at the first n tokens of the pointer,
+// the error node if an error occurs, or nil if it does not exist.
+func (self *Pointer) getParent(root *Node, n int) *Node {
+    cur := root
+    for i := 0; i < n; i++ {
+        if err := cur.checkRaw(); err != nil {
+            return unwrapError(err)
+        }
+        switch cur.itype() {
+            case types.V_OBJECT:
+                cur = cur.Get(self.keys[i].(string))
+            case types.V_ARRAY:
+                if self.idxs[i] == nil {
+                    return nil
+                }
+                cur = cur.Index(self.idxs[i].(int))
+            default:
+                return nil
+        }
+        if !cur.Valid() {
+            return cur
+        }
+        if !cur.Exists() {
+            return nil
+        }
+    }
+    return cur
+}
+
+// Set sets the node at the pointer in root, and reports if the value has existed.
+//
+// The parent of the pointer must exist. If the parent is an array, the last token must be
+// the index of an existing element, the length of the array or `-` to append the node.
+// If the parent is V_NONE or V_NULL, it becomes an array if the last token is `-`,
+// otherwise an object.
+func (self *Pointer) Set(root *Node, node Node) (bool, error) {
+    if err := node.Check(); err != nil {
+        return false, err
+    }
+    if len(self.keys) == 0 {
+        exist := root.Exists()
+        *root = node
+        return exist, nil
+    }
+
+    last := len(self.keys) - 1
+    parent := self.getParent(root, last)
+    if parent == nil {
+        return false, ErrNotExist
+    } else if err := parent.checkRaw(); err != nil {
+        return false, err
+    }
+
+    switch parent.itype() {
+        case types.V_OBJECT:
+            return parent.Set(self.keys[last].(string), node)
+        case types.V_ARRAY:
+            if self.isAppend(last) {
+                return false, parent.Add(node)
+            }
+            if self.idxs[last] == nil {
+                return false, ErrNotExist
+            }
+            if err := parent.skipAllIndex(); err != nil {
+                return false, err
+            }
+            if idx := self.idxs[last].(int); idx == parent.len() {
+                return false, parent.Add(node)
+            } else {
+                return parent.SetByIndex(idx, node)
+            }
+        case _V_NONE, types.V_NULL:
+            if self.isAppend(last) {
+                return false, parent.Add(node)
+            }
+            return parent.Set(self.keys[last].(string), node)
+        default:
+            return false, ErrUnsupportType
+    }
+}
+
+// Unset removes the node at the pointer in root, and reports if it has existed.
+//
+// WARN: removing an array element changes the indexes of the elements after it.
+func (self *Pointer) Unset(root *Node) (bool, error) {
+    if len(self.keys) == 0 {
+        return false, ErrUnsupportType
+    }
+
+    last := len(self.keys) - 1
+    parent := self.getParent(root, last)
+    if parent == nil {
+        return false, nil
+    } else if err := parent.checkRaw(); err != nil {
+        return false, err
+    }
+
+    switch parent.itype() {
+        case types.V_OBJECT:
+            return parent.Unset(self.keys[last].(string))
+        case types.V_ARRAY:
+            if self.idxs[last] == nil {
+                return false, nil
+            }
+            if err := parent.skipAllIndex(); err != nil {
+                return false, err
+            }
+            if idx := self.idxs[last].(int); idx >= parent.len() {
+                return false, nil
+            } else {
+                return parent.UnsetByIndex(idx)
+            }
+        default:
+            return false, nil
+    }
+}
+
+// GetByPointer compiles ptr as a JSON Pointer, and searches it in the JSON.
+func (self *Searcher) GetByPointer(ptr string) (Node, error) {
+    p, err := CompilePointer(ptr)
+    if err != nil {
+        return Node{}, err
+    }
+    return p.Search(self)
+}
+
+// GetByPointer loads the node at the JSON Pointer ptr on demands,
+// or returns an error node if it is invalid or does not exist.
+func (self *Node) GetByPointer(ptr string) *Node {
+    p, err := CompilePointer(ptr)
+    if err != nil {
+        return unwrapError(err)
+    }
+    return p.Get(self)
+}
+
+// SetByPointer sets the node at the JSON Pointer ptr, and reports if the value has existed.
+// See Pointer.Set for the details.
+func (self *Node) SetByPointer(ptr string, node Node) (bool, error) {
+    p, err := CompilePointer(ptr)
+    if err != nil {
+        return false, err
+    }
+    return p.Set(self, node)
+}
+
+// UnsetByPointer removes the node at the JSON Pointer ptr, and reports if it has existed.
+func (self *Node) UnsetByPointer(ptr string) (bool, error) {
+    p, err := CompilePointer(ptr)
+    if err != nil {
+        return false, err
+    }
+    return p.Unset(self)
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+const _TestPointerJSON = `{
+    "foo": ["bar", "baz"],
+    "": 0,
+    "a/b": 1,
+    "c%d": 2,
+    "e^f": 3,
+    "g|h": 4,
+    "i\\j": 5,
+    "k\"l": 6,
+    " ": 7,
+    "m~n": 8,
+    "01": 9}`
+
+func TestPointer_RFC6901(t *testing.T) {
+    tests := []struct {
+        ptr string
+        exp string
+    }{
+        {``, ``},
+        {`/foo`, `["bar", "baz"]`},
+        {`/foo/0`, `"bar"`},
+        {`/`, `0`},
+        {`/a~1b`, `1`},
+        {`/c%d`, `2`},
+        {`/e^f`, `3`},
+        {`/g|h`, `4`},
+        {`/i\j`, `5`},
+        {`/k"l`, `6`},
+        {`/ `, `7`},
+        {`/m~0n`, `8`},
+        {`/01`, `9`},
+    }
+    for _, tt := range tests {
+        exp := tt.exp
+        if tt.ptr == `` {
+            exp = _TestPointerJSON
+        }
+
+        s := NewSearcher(_TestPointerJSON)
+        n, err := s.GetByPointer(tt.ptr)
+        require.NoError(t, err, tt.ptr)
+        raw, err := n.Raw()
+        require.NoError(t, err, tt.ptr)
+        assert.Equal(t, exp, raw, tt.ptr)
+
+        root := NewRaw(_TestPointerJSON)
+        np := root.GetByPointer(tt.ptr)
+        require.NoError(t, np.Check(), tt.ptr)
+        raw, err = np.Raw()
+        require.NoError(t, err, tt.ptr)
+        assert.Equal(t, exp, raw, tt.ptr)
+    }
+}
+
+func TestPointer_NotExist(t *testing.T) {
+    for _, ptr := range []string{`/foo/2`, `/foo/-`, `/foo/01`, `/foo/a`, `/foo/0/x`, `/x`, `/01/0`} {
+        _, err := NewSearcher(_TestPointerJSON).GetByPointer(ptr)
+        assert.Equal(t, ErrNotExist, err, ptr)
+        root := NewRaw(_TestPointerJSON)
+        assert.False(t, root.GetByPointer(ptr).Exists(), ptr)
+    }
+
+    /* the invalid pointers */
+    for _, ptr := range []string{`a`, `/a~`, `/a~2`, `/~/`} {
+        _, err := CompilePointer(ptr)
+        assert.Error(t, err, ptr)
+        root := NewRaw(_TestPointerJSON)
+        assert.Error(t, root.GetByPointer(ptr).Check(), ptr)
+    }
+
+    /* the invalid json */
+    _, err := NewSearcher(`{"a":[1,}`).GetByPointer(`/a/1`)
+    assert.Error(t, err)
+    _, err = NewSearcher(`{"a":`).GetByPointer(`/a/0`)
+    assert.Error(t, err)
+}
+
+func TestPointer_Set(t *testing.T) {
+    root := NewRaw(`{"a":[1,2],"b":{"c":null},"d":null}`)
+
+    exist, err := root.SetByPointer(`/a/0`, NewNumber("0"))
+    require.NoError(t, err)
+    assert.True(t, exist)
+    exist, err = root.SetByPointer(`/a/-`, NewNumber("3"))
+    require.NoError(t, err)
+    assert.False(t, exist)
+    exist, err = root.SetByPointer(`/a/3`, NewNumber("4"))
+    require.NoError(t, err)
+    assert.False(t, exist)
+    exist, err = root.SetByPointer(`/b/c~1d`, NewBool(true))
+    require.NoError(t, err)
+    assert.False(t, exist)
+    exist, err = root.SetByPointer(`/b/c`, NewString("x"))
+    require.NoError(t, err)
+    assert.True(t, exist)
+    _, err = root.SetByPointer(`/d/-`, NewNumber("5"))
+    require.NoError(t, err)
+
+    /* the errors */
+    _, err = root.SetByPointer(`/a/5`, NewNull())
+    assert.Error(t, err)
+    _, err = root.SetByPointer(`/a/x`, NewNull())
+    assert.Error(t, err)
+    _, err = root.SetByPointer(`/x/y`, NewNull())
+    assert.Error(t, err)
+    _, err = root.SetByPointer(`/a/0/y`, NewNull())
+    assert.Error(t, err)
+
+    out, err := root.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":[0,2,3,4],"b":{"c":"x","c/d":true},"d":[5]}`, string(out))
+
+    /* replace the root */
+    exist, err = root.SetByPointer(``, NewNumber("1"))
+    require.NoError(t, err)
+    assert.True(t, exist)
+    raw, err := root.Raw()
+    require.NoError(t, err)
+    assert.Equal(t, `1`, raw)
+}
+
+func TestPointer_Unset(t *testing.T) {
+    root := NewRaw(`{"a":[1,2,3],"b":{"c~":null}}`)
+
+    exist, err := root.UnsetByPointer(`/a/1`)
+    require.NoError(t, err)
+    assert.True(t, exist)
+    exist, err = root.UnsetByPointer(`/b/c~0`)
+    require.NoError(t, err)
+    assert.True(t, exist)
+    for _, ptr := range []string{`/a/2`, `/a/-`, `/b/c`, `/x/y`, `/a/0/z`} {
+        exist, err = root.UnsetByPointer(ptr)
+        require.NoError(t, err, ptr)
+        assert.False(t, exist, ptr)
+    }
+    _, err = root.UnsetByPointer(``)
+    assert.Error(t, err)
+
+    out, err := root.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":[1,3],"b":{}}`, string(out))
+}
+
+func TestPointer_SearchAllocs(t *testing.T) {
+    p, err := CompilePointer(`/foo/1`)
+    require.NoError(t, err)
+    assert.Equal(t, []string{"foo", "1"}, p.Tokens())
+    assert.Equal(t, `/foo/1`, p.String())
+    s := NewSearcher(_TestPointerJSON)
+    allocs := testing.AllocsPerRun(10, func() {
+        if _, err := p.Search(s); err != nil {
+            t.Fatal(err)
+        }
+    })
+    assert.Zero(t, allocs)
+}
@@ -67,11 +67,13 @@ func (self *Searcher) GetByPath(path ...interface{}) (Node, error) {
 }
 
 func (self *Searcher) getByPath(path ...interface{}) (Node, error) {
-    var err types.ParsingError
-    var start int
-
     self.parser.p = 0
-    start, err = self.parser.getByPath(self.ValidateJSON, path...)
+    start, err := self.parser.getByPath(self.ValidateJSON, path...)
+    return self.found(start, err)
+}
+
+// found returns the node from start to the current position of the parser.
+func (self *Searcher) found(start int, err types.ParsingError) (Node, error) {
     if err != 0 {
         // for compatibility with old version
         if err == types.ERR_NOT_FOUND {
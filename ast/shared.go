@@ -0,0 +1,409 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `sync`
+    `unsafe`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// SharedNode holds a JSON document, which can be read and modified by multiple goroutines.
+//
+// Every object and array of the document has its own lock. An operation read-locks
+// the containers on its path from the root, and locks only the container it reads or writes,
+// thus the modifications of different containers run in parallel,
+// and never block the readers of other containers.
+//
+// The nodes passed in and returned are copies, which are owned by the caller.
+//
+//  doc, _ := ast.NewSharedNode(ast.NewRaw(`{"flags":{"a":true}}`))
+//  on, _ := doc.GetByPath("flags", "a")
+//  doc.SetByPath(ast.NewBool(false), "flags", "b")
+type SharedNode struct {
+    mu   sync.RWMutex // guards the root slot
+    root Node
+}
+
+// NewSharedNode creates a SharedNode holding a copy of root, which is fully parsed first.
+func NewSharedNode(root Node) (*SharedNode, error) {
+    self := new(SharedNode)
+    if err := self.Store(root); err != nil {
+        return nil, err
+    }
+    return self, nil
+}
+
+// Load returns a copy of the whole document.
+func (self *SharedNode) Load() Node {
+    ret, _ := self.GetByPath()
+    return ret
+}
+
+// Store replaces the whole document with a copy of root.
+func (self *SharedNode) Store(root Node) error {
+    v, err := newShared(root)
+    if err != nil {
+        return err
+    }
+    self.mu.Lock()
+    self.root = v
+    self.mu.Unlock()
+    return nil
+}
+
+// GetByPath returns a copy of the node at given path.
+//
+// The copy is consistent, no modification is seen partially.
+func (self *SharedNode) GetByPath(path ...interface{}) (Node, error) {
+    var ret Node
+    err := self.walk(path, func(n *Node, locks *sharedLocks) error {
+        ret = n.sharedCopy(locks)
+        return nil
+    })
+    return ret, err
+}
+
+// SetByPath sets the node at given path, and reports if the path has existed.
+//
+// The parent of the path must be an existing object or array,
+// and the index of an array must be within its children.
+// An empty path replaces the whole document.
+func (self *SharedNode) SetByPath(node Node, path ...interface{}) (bool, error) {
+    if len(path) == 0 {
+        return true, self.Store(node)
+    }
+    v, err := newShared(node)
+    if err != nil {
+        return false, err
+    }
+    exist := false
+    err = self.walkParent(path, func(n *Node, i int) error {
+        if i >= 0 {
+            *n.sharedAt(i) = v
+            exist = true
+            return nil
+        }
+        k, ok := path[len(path)-1].(string)
+        if !ok {
+            return ErrNotExist
+        }
+        (*linkedPairs)(n.p).Push(NewPair(k, v))
+        n.l++
+        return nil
+    })
+    return exist, err
+}
+
+// UnsetByPath removes the node at given path, and reports if the path has existed.
+//
+// The elements following the removed one in an array are moved forward.
+func (self *SharedNode) UnsetByPath(path ...interface{}) (bool, error) {
+    if len(path) == 0 {
+        return false, ErrUnsupportType
+    }
+    exist := false
+    err := self.walkParent(path, func(n *Node, i int) error {
+        if i >= 0 {
+            n.sharedRemove(i)
+            exist = true
+        }
+        return nil
+    })
+    return exist, err
+}
+
+// AddByPath appends the node to the array at given path.
+func (self *SharedNode) AddByPath(node Node, path ...interface{}) error {
+    v, err := newShared(node)
+    if err != nil {
+        return err
+    }
+    return self.walk(path, func(n *Node, locks *sharedLocks) error {
+        if n.t != types.V_ARRAY {
+            return ErrUnsupportType
+        }
+        s := (*linkedNodes)(n.p)
+        locks.lock(s.mu)
+        s.Push(v)
+        n.l++
+        return nil
+    })
+}
+
+// Update calls fn with a copy of the node at given path, and sets the result back.
+//
+// The parent of the path is locked during fn, thus the read-modify-write is atomic.
+// If fn or the result is invalid, the error is returned and nothing is changed.
+// An empty path locks the whole document.
+func (self *SharedNode) Update(fn func(node *Node) error, path ...interface{}) error {
+    if len(path) == 0 {
+        self.mu.Lock()
+        defer self.mu.Unlock()
+        return self.root.sharedUpdate(fn)
+    }
+    return self.walkParent(path, func(n *Node, i int) error {
+        if i < 0 {
+            return ErrNotExist
+        }
+        return n.sharedAt(i).sharedUpdate(fn)
+    })
+}
+
+// walk read-locks the containers on path, and calls fn with the node at path.
+// All the locks are released after fn returns.
+func (self *SharedNode) walk(path []interface{}, fn func(n *Node, locks *sharedLocks) error) error {
+    locks := sharedLocks{}
+    defer locks.release()
+    locks.rlock(&self.mu)
+    n := &self.root
+    for _, p := range path {
+        mu := n.sharedLock()
+        if mu == nil {
+            return ErrUnsupportType
+        }
+        locks.rlock(mu)
+        i, err := n.sharedIndex(p)
+        if err != nil {
+            return err
+        }
+        if i < 0 {
+            return ErrNotExist
+        }
+        n = n.sharedAt(i)
+    }
+    return fn(n, &locks)
+}
+
+// walkParent locks the parent container of path for writing,
+// and calls fn with it and the index of the last key, or -1 if not found.
+func (self *SharedNode) walkParent(path []interface{}, fn func(n *Node, i int) error) error {
+    key := path[len(path)-1]
+    return self.walk(path[:len(path)-1], func(n *Node, locks *sharedLocks) error {
+        mu := n.sharedLock()
+        if mu == nil {
+            return ErrUnsupportType
+        }
+        locks.lock(mu)
+        i, err := n.sharedIndex(key)
+        if err != nil {
+            return err
+        }
+        return fn(n, i)
+    })
+}
+
+type sharedLock struct {
+    mu    *sync.RWMutex
+    write bool
+}
+
+type sharedLocks []sharedLock
+
+func (self *sharedLocks) rlock(mu *sync.RWMutex) {
+    mu.RLock()
+    *self = append(*self, sharedLock{mu, false})
+}
+
+func (self *sharedLocks) lock(mu *sync.RWMutex) {
+    mu.Lock()
+    *self = append(*self, sharedLock{mu, true})
+}
+
+func (self *sharedLocks) release() {
+    for i := len(*self) - 1; i >= 0; i-- {
+        if l := (*self)[i]; l.write {
+            l.mu.Unlock()
+        } else {
+            l.mu.RUnlock()
+        }
+    }
+    *self = (*self)[:0]
+}
+
+// newShared returns a fully-parsed copy of node, whose containers all have their locks.
+func newShared(node Node) (Node, error) {
+    ret, err := node.Clone()
+    if err != nil {
+        return Node{}, err
+    }
+    if err := ret.share(); err != nil {
+        return Node{}, err
+    }
+    return ret, nil
+}
+
+func (self *Node) share() error {
+    /* the go values are converted, since they can't be locked */
+    if self.isAny() {
+        buf, err := self.MarshalJSON()
+        if err != nil {
+            return err
+        }
+        *self = NewRaw(string(buf))
+    }
+    if self.isRaw() {
+        self.parseRaw(true)
+    }
+    if err := self.Check(); err != nil {
+        return err
+    }
+
+    switch self.t {
+    case _V_ARRAY_LAZY:
+        if err := self.loadAllIndex(false); err != nil {
+            return err
+        }
+    case _V_OBJECT_LAZY:
+        if err := self.loadAllKey(false); err != nil {
+            return err
+        }
+    }
+
+    switch self.t {
+    case types.V_ARRAY:
+        if self.p == nil {
+            self.p = unsafe.Pointer(new(linkedNodes))
+        }
+        s := (*linkedNodes)(self.p)
+        for i := 0; i < s.Len(); i++ {
+            if err := s.At(i).share(); err != nil {
+                return err
+            }
+        }
+        s.mu = new(sync.RWMutex)
+    case types.V_OBJECT:
+        if self.p == nil {
+            self.p = unsafe.Pointer(new(linkedPairs))
+        }
+        s := (*linkedPairs)(self.p)
+        for i := 0; i < s.Len(); i++ {
+            if err := s.At(i).Value.share(); err != nil {
+                return err
+            }
+        }
+        s.mu = new(sync.RWMutex)
+    }
+    return nil
+}
+
+// sharedLock returns the lock of the container self, or nil if self is not a container.
+func (self *Node) sharedLock() *sync.RWMutex {
+    switch self.t {
+    case types.V_ARRAY:
+        return (*linkedNodes)(self.p).mu
+    case types.V_OBJECT:
+        return (*linkedPairs)(self.p).mu
+    default:
+        return nil
+    }
+}
+
+// sharedIndex returns the index of key in the locked container self, or -1 if not found.
+func (self *Node) sharedIndex(key interface{}) (int, error) {
+    switch k := key.(type) {
+    case string:
+        if self.t != types.V_OBJECT {
+            return -1, ErrUnsupportType
+        }
+        _, i := (*linkedPairs)(self.p).Get(k)
+        return i, nil
+    case int:
+        if self.t != types.V_ARRAY {
+            return -1, ErrUnsupportType
+        }
+        if k < 0 || k >= (*linkedNodes)(self.p).Len() {
+            return -1, nil
+        }
+        return k, nil
+    default:
+        panic("path must be either int or string")
+    }
+}
+
+func (self *Node) sharedAt(i int) *Node {
+    if self.t == types.V_OBJECT {
+        return &(*linkedPairs)(self.p).At(i).Value
+    }
+    return (*linkedNodes)(self.p).At(i)
+}
+
+// sharedRemove removes the i-th child of the locked container self,
+// and moves the following ones forward to keep the children contiguous.
+func (self *Node) sharedRemove(i int) {
+    if self.t == types.V_OBJECT {
+        s := (*linkedPairs)(self.p)
+        for ; i < s.Len()-1; i++ {
+            *s.At(i) = *s.At(i+1)
+        }
+        s.index = nil
+        s.Pop()
+        if s.Len() > _Threshold_Index {
+            s.BuildIndex()
+        }
+    } else {
+        s := (*linkedNodes)(self.p)
+        s.MoveOne(i, s.Len()-1)
+        s.Pop()
+    }
+    self.l--
+}
+
+// sharedCopy returns a copy of self, read-locking its containers if locks is not nil.
+func (self *Node) sharedCopy(locks *sharedLocks) Node {
+    switch self.t {
+    case types.V_ARRAY:
+        s := (*linkedNodes)(self.p)
+        if locks != nil {
+            locks.rlock(s.mu)
+        }
+        ret := new(linkedNodes)
+        for i := 0; i < s.Len(); i++ {
+            ret.Push(s.At(i).sharedCopy(locks))
+        }
+        return newArray(ret)
+    case types.V_OBJECT:
+        s := (*linkedPairs)(self.p)
+        if locks != nil {
+            locks.rlock(s.mu)
+        }
+        ret := new(linkedPairs)
+        for i := 0; i < s.Len(); i++ {
+            p := s.At(i)
+            ret.Push(NewPair(p.Key, p.Value.sharedCopy(locks)))
+        }
+        return newObject(ret)
+    default:
+        return *self
+    }
+}
+
+// sharedUpdate replaces self with the result of fn on its copy.
+// The caller must hold the parent of self exclusively, thus nobody is inside self.
+func (self *Node) sharedUpdate(fn func(node *Node) error) error {
+    cp := self.sharedCopy(nil)
+    if err := fn(&cp); err != nil {
+        return err
+    }
+    v, err := newShared(cp)
+    if err != nil {
+        return err
+    }
+    *self = v
+    return nil
+}
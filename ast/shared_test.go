@@ -0,0 +1,206 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `errors`
+    `fmt`
+    `strconv`
+    `sync`
+    `testing`
+    `time`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestSharedNode_Update(t *testing.T) {
+    doc, err := NewSharedNode(NewRaw(`{"flags":{"a":true},"list":[]}`))
+    require.NoError(t, err)
+
+    exist, err := doc.SetByPath(NewBool(false), "flags", "b")
+    require.NoError(t, err)
+    assert.False(t, exist)
+    exist, err = doc.SetByPath(NewAny(map[string]interface{}{"x": 1}), "flags", "a")
+    require.NoError(t, err)
+    assert.True(t, exist)
+    require.NoError(t, doc.AddByPath(NewString("x"), "list"))
+    require.NoError(t, doc.AddByPath(NewNull(), "list"))
+    exist, err = doc.SetByPath(NewNumber("1"), "list", 1)
+    require.NoError(t, err)
+    assert.True(t, exist)
+    cur := doc.Load()
+    assert.Equal(t, `{"flags":{"a":{"x":1},"b":false},"list":["x",1]}`, mustRaw(t, &cur))
+
+    /* the copies are owned by the caller */
+    flags, err := doc.GetByPath("flags")
+    require.NoError(t, err)
+    _, err = flags.Set("c", NewNull())
+    require.NoError(t, err)
+    v, err := doc.GetByPath("flags", "a", "x")
+    require.NoError(t, err)
+    x, _ := v.Int64()
+    assert.Equal(t, int64(1), x)
+    node := NewObject(nil)
+    _, err = doc.SetByPath(node, "new")
+    require.NoError(t, err)
+    _, err = node.Set("a", NewNull())
+    require.NoError(t, err)
+
+    exist, err = doc.UnsetByPath("flags", "a")
+    require.NoError(t, err)
+    assert.True(t, exist)
+    exist, err = doc.UnsetByPath("list", 0)
+    require.NoError(t, err)
+    assert.True(t, exist)
+    exist, err = doc.UnsetByPath("flags", "none")
+    require.NoError(t, err)
+    assert.False(t, exist)
+    cur = doc.Load()
+    assert.Equal(t, `{"flags":{"b":false},"list":[1],"new":{}}`, mustRaw(t, &cur))
+
+    /* the invalid paths */
+    _, err = doc.GetByPath("none", "a")
+    assert.Equal(t, ErrNotExist, err)
+    _, err = doc.GetByPath("list", "a")
+    assert.Equal(t, ErrUnsupportType, err)
+    _, err = doc.SetByPath(NewNull(), "list", 1)
+    assert.Equal(t, ErrNotExist, err)
+    _, err = doc.SetByPath(NewNull(), "flags", "b", "c")
+    assert.Equal(t, ErrUnsupportType, err)
+    assert.Equal(t, ErrUnsupportType, doc.AddByPath(NewNull(), "flags"))
+    _, err = doc.SetByPath(NewRaw(`{"a":}`), "bad")
+    assert.Error(t, err)
+
+    /* nothing is changed if failed */
+    require.NoError(t, doc.Update(func(n *Node) error {
+        _, err := n.Set("c", NewNumber("2"))
+        return err
+    }, "flags"))
+    fail := errors.New("fail")
+    assert.Equal(t, fail, doc.Update(func(n *Node) error {
+        n.Add(NewNull())
+        return fail
+    }, "list"))
+    assert.Error(t, doc.Update(func(n *Node) error {
+        _, err := n.Set("bad", NewRaw(`{"a":}`))
+        return err
+    }))
+    assert.Equal(t, ErrNotExist, doc.Update(func(n *Node) error { return nil }, "none"))
+    cur = doc.Load()
+    assert.Equal(t, `{"flags":{"b":false,"c":2},"list":[1],"new":{}}`, mustRaw(t, &cur))
+
+    /* replaced as a whole */
+    require.NoError(t, doc.Store(NewRaw(`[1]`)))
+    cur = doc.Load()
+    assert.Equal(t, `[1]`, mustRaw(t, &cur))
+    assert.Error(t, doc.Store(NewRaw(`[1,`)))
+    _, err = NewSharedNode(NewRaw(`[1,`))
+    assert.Error(t, err)
+}
+
+func TestSharedNode_Concurrent(t *testing.T) {
+    doc, err := NewSharedNode(NewRaw(`{"w0":{"n":0,"log":[]},"w1":{"n":0,"log":[]},"w2":{"n":0,"log":[]},"w3":{"n":0,"log":[]},"total":0}`))
+    require.NoError(t, err)
+
+    wg := sync.WaitGroup{}
+    for i := 0; i < 4; i++ {
+        key := fmt.Sprintf("w%d", i)
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 100; j++ {
+                /* each writer has its own container */
+                if err := doc.Update(func(n *Node) error {
+                    if _, err := n.Set("n", NewNumber(strconv.Itoa(j+1))); err != nil {
+                        return err
+                    }
+                    return n.Get("log").Add(NewNumber(strconv.Itoa(j)))
+                }, key); err != nil {
+                    panic(err)
+                }
+                /* and all writers share the counter */
+                if err := doc.Update(func(n *Node) error {
+                    x, err := n.Int64()
+                    *n = NewNumber(strconv.FormatInt(x+1, 10))
+                    return err
+                }, "total"); err != nil {
+                    panic(err)
+                }
+                if err := doc.AddByPath(NewNumber(strconv.Itoa(j)), key, "log"); err != nil {
+                    panic(err)
+                }
+            }
+        }()
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 100; j++ {
+                /* each copy is consistent */
+                v, err := doc.GetByPath(key)
+                if err != nil {
+                    panic(err)
+                }
+                n, _ := v.Get("n").Int64()
+                l, _ := v.Get("log").Len()
+                if l != int(n)*2 && l != int(n)*2-1 {
+                    panic(fmt.Sprintf("inconsistent copy: %d, %d", n, l))
+                }
+                if _, err := v.MarshalJSON(); err != nil {
+                    panic(err)
+                }
+            }
+        }()
+    }
+    wg.Wait()
+
+    total, err := doc.GetByPath("total")
+    require.NoError(t, err)
+    x, _ := total.Int64()
+    assert.Equal(t, int64(400), x)
+    for i := 0; i < 4; i++ {
+        l, err := doc.GetByPath(fmt.Sprintf("w%d", i), "log")
+        require.NoError(t, err)
+        n, _ := l.Len()
+        assert.Equal(t, 200, n)
+    }
+}
+
+func TestSharedNode_PerContainer(t *testing.T) {
+    doc, err := NewSharedNode(NewRaw(`{"a":{"x":{}},"b":{"y":{}}}`))
+    require.NoError(t, err)
+
+    /* the writers and readers of other containers are not blocked */
+    require.NoError(t, doc.Update(func(n *Node) error {
+        done := make(chan error)
+        go func() {
+            _, err := doc.SetByPath(NewNull(), "b", "y", "k")
+            if err == nil {
+                _, err = doc.GetByPath("b")
+            }
+            done <- err
+        }()
+        select {
+        case err := <-done:
+            return err
+        case <-time.After(10 * time.Second):
+            return errors.New("blocked")
+        }
+    }, "a", "x"))
+    cur := doc.Load()
+    assert.Equal(t, `{"a":{"x":{}},"b":{"y":{"k":null}}}`, mustRaw(t, &cur))
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `sync`
+    `unsafe`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// Snapshot returns an immutable view of self, which shares the children with self.
+//
+// self is fully parsed first, then its containers are frozen and shared by the snapshot.
+// Later modifications on self copy the frozen containers on write, level by level,
+// thus the snapshot never sees them, and it can be READ concurrently without locks.
+//
+// Modifying the snapshot or any node got from it returns ErrImmutable,
+// call Clone on it to get a writable copy.
+// The Go values of V_ANY nodes are shared as is.
+//
+// NOTICE: after Snapshot, reading self may copy its frozen children,
+// thus self itself is NOT concurrently safe to read, share the snapshot instead.
+func (self *Node) Snapshot() (Node, error) {
+    if err := self.Check(); err != nil {
+        return Node{}, err
+    }
+    if self.isFrozen() {
+        return *self, nil
+    }
+    if err := self.freeze(); err != nil {
+        return Node{}, err
+    }
+    ret := *self
+    self.thaw()
+    return ret, nil
+}
+
+// Clone returns a deep copy of self, which shares nothing writable with self.
+//
+// The lazy children are loaded first, and the raw children are copied as is.
+// The Go values of V_ANY nodes are shared as is.
+func (self *Node) Clone() (Node, error) {
+    if err := self.Check(); err != nil {
+        return Node{}, err
+    }
+
+    /* the raw JSON is immutable */
+    it := self.itype()
+    if self.isRaw() {
+        it = _V_NONE
+    }
+
+    switch it {
+    case types.V_ARRAY:
+        if err := self.skipAllIndex(); err != nil {
+            return Node{}, err
+        }
+        ret := new(linkedNodes)
+        it := self.values()
+        for v := it.next(); v != nil; v = it.next() {
+            n, err := v.Clone()
+            if err != nil {
+                return Node{}, err
+            }
+            ret.Push(n)
+        }
+        return newArray(ret), nil
+    case types.V_OBJECT:
+        if err := self.skipAllKey(); err != nil {
+            return Node{}, err
+        }
+        ret := new(linkedPairs)
+        it := self.properties()
+        for p := it.next(); p != nil; p = it.next() {
+            n, err := p.Value.Clone()
+            if err != nil {
+                return Node{}, err
+            }
+            ret.Push(NewPair(p.Key, n))
+        }
+        return newObject(ret), nil
+    default:
+        ret := *self
+        if ret.m != nil {
+            ret.m = new(sync.RWMutex)
+        }
+        ret.thaw()
+        return ret, nil
+    }
+}
+
+// frozenNull marks the frozen null nodes, which would become containers if they were modified.
+// The other scalars can't be modified in place.
+var frozenNull byte
+
+// freeze fully parses self, and marks all the containers and nulls under self as frozen.
+func (self *Node) freeze() error {
+    if self.isRaw() {
+        self.parseRaw(true)
+    }
+    if err := self.Check(); err != nil {
+        return err
+    }
+
+    switch self.t {
+    case _V_ARRAY_LAZY:
+        if err := self.loadAllIndex(false); err != nil {
+            return err
+        }
+    case _V_OBJECT_LAZY:
+        if err := self.loadAllKey(false); err != nil {
+            return err
+        }
+    }
+
+    switch self.t {
+    case types.V_NULL:
+        self.p = unsafe.Pointer(&frozenNull)
+    case types.V_ARRAY:
+        /* the empty container is also frozen, to refuse the modifications */
+        if self.p == nil {
+            self.p = unsafe.Pointer(new(linkedNodes))
+        }
+        s := (*linkedNodes)(self.p)
+        if s.frozen {
+            return nil
+        }
+        for i := 0; i < s.Len(); i++ {
+            if err := s.At(i).freeze(); err != nil {
+                return err
+            }
+        }
+        s.frozen = true
+    case types.V_OBJECT:
+        if self.p == nil {
+            self.p = unsafe.Pointer(new(linkedPairs))
+        }
+        s := (*linkedPairs)(self.p)
+        if s.frozen {
+            return nil
+        }
+        for i := 0; i < s.Len(); i++ {
+            if err := s.At(i).Value.freeze(); err != nil {
+                return err
+            }
+        }
+        s.frozen = true
+    }
+    return nil
+}
+
+// isFrozen reports if self is a frozen container or null.
+func (self *Node) isFrozen() bool {
+    switch self.t {
+    case types.V_NULL:
+        return self.p == unsafe.Pointer(&frozenNull)
+    case types.V_ARRAY:
+        s := (*linkedNodes)(self.p)
+        return s != nil && s.frozen
+    case types.V_OBJECT:
+        s := (*linkedPairs)(self.p)
+        return s != nil && s.frozen
+    default:
+        return false
+    }
+}
+
+// thaw replaces the frozen container of self with a writable copy,
+// whose children are still frozen.
+func (self *Node) thaw() {
+    switch self.t {
+    case types.V_NULL:
+        self.p = nil
+    case types.V_ARRAY:
+        if s := (*linkedNodes)(self.p); s != nil && s.frozen {
+            self.p = unsafe.Pointer(s.copy())
+        }
+    case types.V_OBJECT:
+        if s := (*linkedPairs)(self.p); s != nil && s.frozen {
+            self.p = unsafe.Pointer(s.copy())
+        }
+    }
+}
+
+// checkMutable checks if self can be modified.
+func (self *Node) checkMutable() error {
+    if err := self.checkRaw(); err != nil {
+        return err
+    }
+    if self.isFrozen() {
+        return ErrImmutable
+    }
+    return nil
+}
+
+// own makes the child n of self writable before it is exposed, if self is writable.
+func (self *linkedNodes) own(n *Node) *Node {
+    if n != nil && !self.frozen {
+        n.thaw()
+    }
+    return n
+}
+
+// own makes the child p of self writable before it is exposed, if self is writable.
+func (self *linkedPairs) own(p *Pair) *Pair {
+    if p != nil && !self.frozen {
+        p.Value.thaw()
+    }
+    return p
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `fmt`
+    `strconv`
+    `sync`
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+const _SnapshotJson = `{"a":{"b":[1,2,{"c":"x"}],"d":{}},"e":[],"f":null}`
+
+func mustRaw(t *testing.T, n *Node) string {
+    buf, err := n.MarshalJSON()
+    require.NoError(t, err)
+    return string(buf)
+}
+
+func TestNode_Clone(t *testing.T) {
+    for _, src := range []string{_SnapshotJson, `1`, `"s"`, `[]`} {
+        root, e := NewParser(src).Parse()
+        require.Zero(t, e)
+        clone, err := root.Clone()
+        require.NoError(t, err)
+        assert.Equal(t, src, mustRaw(t, &clone))
+    }
+
+    root := NewRaw(_SnapshotJson)
+    root.Get("a").Get("b").Index(1)
+    clone, err := root.Clone()
+    require.NoError(t, err)
+
+    /* the modifications of clone are not seen by the origin */
+    _, err = clone.Get("a").Get("b").Index(2).Set("c", NewString("y"))
+    require.NoError(t, err)
+    require.NoError(t, clone.Get("e").Add(NewNull()))
+    _, err = clone.Unset("f")
+    require.NoError(t, err)
+    assert.Equal(t, `{"a":{"b":[1,2,{"c":"y"}],"d":{}},"e":[null]}`, mustRaw(t, &clone))
+    assert.Equal(t, _SnapshotJson, mustRaw(t, &root))
+
+    /* the errors */
+    root = NewRaw(`{"a":[1,}`)
+    _, err = root.Clone()
+    assert.Error(t, err)
+}
+
+func TestNode_Snapshot(t *testing.T) {
+    root := NewRaw(_SnapshotJson)
+    snap, err := root.Snapshot()
+    require.NoError(t, err)
+
+    /* the modifications of origin are copied on write */
+    _, err = root.Get("a").Get("b").Index(2).Set("c", NewString("y"))
+    require.NoError(t, err)
+    require.NoError(t, root.Get("a").Get("b").Add(NewNumber("3")))
+    require.NoError(t, root.Get("e").Add(NewNull()))
+    _, err = root.Get("a").Get("d").Set("g", NewBool(true))
+    require.NoError(t, err)
+    _, err = root.Unset("f")
+    require.NoError(t, err)
+    require.NoError(t, root.Get("a").Get("b").Move(0, 1))
+    assert.Equal(t, `{"a":{"b":[2,1,{"c":"y"},3],"d":{"g":true}},"e":[null]}`, mustRaw(t, &root))
+    assert.Equal(t, _SnapshotJson, mustRaw(t, &snap))
+
+    /* the snapshot can't be modified */
+    _, err = snap.Set("x", NewNull())
+    assert.Equal(t, ErrImmutable, err)
+    assert.Equal(t, ErrImmutable, snap.Get("e").Add(NewNull()))
+    assert.Equal(t, ErrImmutable, snap.Get("a").Get("b").Pop())
+    _, err = snap.Get("a").Get("d").Set("x", NewNull())
+    assert.Equal(t, ErrImmutable, err)
+    _, err = snap.Get("a").Get("b").Index(2).Unset("c")
+    assert.Equal(t, ErrImmutable, err)
+    assert.Equal(t, ErrImmutable, snap.SortKeys(true))
+
+    /* the null children can't become containers either */
+    _, err = snap.Get("f").Set("x", NewNull())
+    assert.Equal(t, ErrImmutable, err)
+    assert.Equal(t, ErrImmutable, snap.Get("f").Add(NewNull()))
+    assert.Equal(t, _SnapshotJson, mustRaw(t, &snap))
+
+    /* the snapshot of snapshot is itself, and its clone is writable */
+    snap2, err := snap.Snapshot()
+    require.NoError(t, err)
+    assert.Equal(t, snap.p, snap2.p)
+    clone, err := snap.Clone()
+    require.NoError(t, err)
+    _, err = clone.Get("a").Get("b").Index(2).Set("c", NewString("z"))
+    require.NoError(t, err)
+    assert.Equal(t, _SnapshotJson, mustRaw(t, &snap))
+
+    /* the second snapshot shares the unchanged children */
+    snap3, err := root.Snapshot()
+    require.NoError(t, err)
+    root.Get("a").Get("d").Set("g", NewBool(false))
+    assert.Equal(t, `{"a":{"b":[2,1,{"c":"y"},3],"d":{"g":true}},"e":[null]}`, mustRaw(t, &snap3))
+    assert.Equal(t, _SnapshotJson, mustRaw(t, &snap))
+
+    /* the null children of origin are writable */
+    root = NewRaw(`{"a":null,"b":[null]}`)
+    snap, err = root.Snapshot()
+    require.NoError(t, err)
+    _, err = root.Get("a").Set("x", NewNull())
+    require.NoError(t, err)
+    require.NoError(t, root.Get("b").Index(0).Add(NewNull()))
+    assert.Equal(t, `{"a":{"x":null},"b":[[null]]}`, mustRaw(t, &root))
+    assert.Equal(t, `{"a":null,"b":[null]}`, mustRaw(t, &snap))
+    clone, err = snap.Get("a").Clone()
+    require.NoError(t, err)
+    require.NoError(t, clone.Add(NewNull()))
+
+    /* the null snapshot */
+    root = NewNull()
+    snap, err = root.Snapshot()
+    require.NoError(t, err)
+    assert.Equal(t, ErrImmutable, snap.Add(NewNull()))
+    require.NoError(t, root.Add(NewNull()))
+    assert.Equal(t, `null`, mustRaw(t, &snap))
+
+    /* the errors */
+    root = NewRaw(`{"a":[1,}`)
+    _, err = root.Snapshot()
+    assert.Error(t, err)
+}
+
+func TestNode_SnapshotConcurrentRead(t *testing.T) {
+    root := NewRaw(`{"list":[` + `{"v":0}` + `],"n":0}`)
+    snap, err := root.Snapshot()
+    require.NoError(t, err)
+
+    wg := sync.WaitGroup{}
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 100; j++ {
+                v, err := snap.GetByPath("list", 0, "v").Int64()
+                if err != nil || v != 0 {
+                    panic(fmt.Sprintf("unexpected %v %v", v, err))
+                }
+                if buf, err := snap.MarshalJSON(); err != nil || string(buf) != `{"list":[{"v":0}],"n":0}` {
+                    panic(fmt.Sprintf("unexpected %s %v", buf, err))
+                }
+            }
+        }()
+    }
+    for j := 0; j < 100; j++ {
+        root.GetByPath("list", 0).Set("v", NewNumber(strconv.Itoa(j)))
+        root.Get("list").Add(NewNull())
+        root.Set("n", NewNumber(strconv.Itoa(j)))
+    }
+    wg.Wait()
+}
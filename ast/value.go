@@ -0,0 +1,487 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `encoding`
+    `encoding/json`
+    `errors`
+    `reflect`
+    `strconv`
+    `strings`
+    `unsafe`
+
+    `github.com/bytedance/sonic/encoder`
+    `github.com/bytedance/sonic/internal/native/types`
+    `github.com/bytedance/sonic/internal/resolver`
+    `github.com/bytedance/sonic/internal/rt`
+)
+
+var (
+    nodeType            = reflect.TypeOf(Node{})
+    nodePtrType         = reflect.TypeOf((*Node)(nil))
+    jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+    textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+var errTooDeep = errors.New("value nested too deeply")
+
+/**------------------------------------ Go Value to Node ------------------------------------**/
+
+// NewFromValue creates a fully loaded node tree from the Go value v, which is the
+// same as encoding v by encoder.Encode with option SortMapKeys and parsing the
+// output, so the struct tags, the float formats and the marshalers are all applied.
+//
+// If v can't be encoded, NewFromValue returns a error Node.
+func NewFromValue(v interface{}) Node {
+    buf, err := encoder.Encode(v, encoder.SortMapKeys)
+    if err != nil {
+        return *unwrapError(err)
+    }
+    parser := NewParserObj(rt.Mem2Str(buf))
+    parser.noLazy = true
+    n, e := parser.Parse()
+    if e != 0 {
+        return *newSyntaxError(parser.syntaxError(e))
+    }
+    return n
+}
+
+// fieldAt returns the pointer to the field described by fm in the struct at p,
+// allocating the embedded struct pointers if alloc is true,
+// or returns nil if it is in a nil embedded struct pointer.
+func fieldAt(p unsafe.Pointer, fm *resolver.FieldMeta, alloc bool) unsafe.Pointer {
+    for _, off := range fm.Path {
+        p = unsafe.Pointer(uintptr(p) + off.Size)
+        if off.Kind != resolver.F_deref {
+            continue
+        }
+        pv := reflect.NewAt(reflect.PtrTo(off.Type), p).Elem()
+        if pv.IsNil() {
+            if !alloc {
+                return nil
+            }
+            pv.Set(reflect.New(off.Type))
+        }
+        p = unsafe.Pointer(pv.Pointer())
+    }
+    return p
+}
+
+/**------------------------------------ Node to Go Value ------------------------------------**/
+
+// Decode stores the value represented by the node into the Go value pointed to by val,
+// following the same rules as decoding the JSON of the node into val,
+// but without producing the JSON text for the loaded parts of the node.
+// Raw parts of the node are decoded from their JSON text directly.
+func (self *Node) Decode(val interface{}) error {
+    rv := reflect.ValueOf(val)
+    if rv.Kind() != reflect.Ptr || rv.IsNil() {
+        return &json.InvalidUnmarshalError{Type: reflect.TypeOf(val)}
+    }
+    return self.decode(rv.Elem(), false, 0)
+}
+
+func (self *Node) decode(rv reflect.Value, quoted bool, depth int) error {
+    if err := self.Check(); err != nil {
+        return err
+    }
+    if !self.Exists() {
+        return ErrNotExist
+    }
+    if depth > types.MAX_RECURSE {
+        return errTooDeep
+    }
+
+    /* nodes are copied as they are */
+    switch rv.Type() {
+        case nodeType    : rv.Set(reflect.ValueOf(*self)); return nil
+        case nodePtrType : n := *self; rv.Set(reflect.ValueOf(&n)); return nil
+    }
+
+    /* raw json can be decoded directly */
+    if self.isRaw() {
+        if !quoted {
+            lock := self.rlock()
+            src := self.toString()
+            if lock {
+                self.runlock()
+            }
+            return decodeRaw(src, rv.Addr().Interface())
+        }
+        if err := self.checkRaw(); err != nil {
+            return err
+        }
+    }
+
+    /* null only resets the pointers, interfaces, maps and slices */
+    vt := self.itype()
+    if vt == types.V_NULL {
+        switch rv.Kind() {
+            case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+                rv.Set(reflect.Zero(rv.Type()))
+                return nil
+        }
+    }
+
+    /* allocate the pointers */
+    if rv.Kind() == reflect.Ptr && !rv.Type().Implements(jsonUnmarshalerType) {
+        if rv.IsNil() {
+            rv.Set(reflect.New(rv.Type().Elem()))
+        }
+        return self.decode(rv.Elem(), quoted, depth + 1)
+    }
+
+    /* check for the unmarshalers */
+    if rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(jsonUnmarshalerType) {
+        buf, err := self.MarshalJSON()
+        if err != nil {
+            return err
+        }
+        return rv.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(buf)
+    }
+    if rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(textUnmarshalerType) && vt != types.V_NULL {
+        if vt != types.V_STRING {
+            return self.mismatched(rv.Type())
+        }
+        return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(self.toString()))
+    }
+
+    /* the `string` option */
+    if quoted && vt == types.V_STRING {
+        n := NewRaw(self.toString())
+        if err := n.Check(); err != nil || !n.Exists() {
+            return self.mismatched(rv.Type())
+        }
+        return n.decode(rv, false, depth + 1)
+    }
+
+    switch vt {
+        case types.V_NULL   : return nil
+        case types.V_TRUE   : return self.decodeBool(rv, true)
+        case types.V_FALSE  : return self.decodeBool(rv, false)
+        case types.V_STRING : return self.decodeString(rv)
+        case _V_NUMBER      : return self.decodeNumber(rv)
+        case types.V_ARRAY  : return self.decodeArray(rv, depth)
+        case types.V_OBJECT : return self.decodeObject(rv, depth)
+        case _V_ANY         : return self.decodeAny(rv)
+        default             : return ErrUnsupportType
+    }
+}
+
+func (self *Node) mismatched(vt reflect.Type) error {
+    var val string
+    switch self.itype() {
+        case types.V_NULL                 : val = "null"
+        case types.V_TRUE, types.V_FALSE  : val = "bool"
+        case types.V_STRING               : val = "string"
+        case _V_NUMBER                    : val = "number " + self.toString()
+        case types.V_ARRAY                : val = "array"
+        case types.V_OBJECT               : val = "object"
+        default                           : val = "value"
+    }
+    return &json.UnmarshalTypeError{Value: val, Type: vt}
+}
+
+// setInterface stores v into the empty interface rv, and reports if rv is an empty interface.
+func setInterface(rv reflect.Value, v interface{}) bool {
+    if rv.Kind() != reflect.Interface || rv.NumMethod() != 0 {
+        return false
+    }
+    if v == nil {
+        rv.Set(reflect.Zero(rv.Type()))
+    } else {
+        rv.Set(reflect.ValueOf(v))
+    }
+    return true
+}
+
+func (self *Node) decodeBool(rv reflect.Value, v bool) error {
+    if rv.Kind() == reflect.Bool {
+        rv.SetBool(v)
+        return nil
+    }
+    if setInterface(rv, v) {
+        return nil
+    }
+    return self.mismatched(rv.Type())
+}
+
+func (self *Node) decodeString(rv reflect.Value) error {
+    s := self.toString()
+    switch rv.Kind() {
+        case reflect.String:
+            rv.SetString(s)
+            return nil
+        case reflect.Slice:
+            if rv.Type().Elem().Kind() != reflect.Uint8 {
+                break
+            }
+            buf, err := rt.DecodeBase64(rt.Str2Mem(s))
+            if err != nil {
+                return err
+            }
+            rv.SetBytes(buf)
+            return nil
+        case reflect.Interface:
+            if setInterface(rv, s) {
+                return nil
+            }
+    }
+    return self.mismatched(rv.Type())
+}
+
+func (self *Node) decodeNumber(rv reflect.Value) error {
+    s := self.toString()
+    switch rv.Kind() {
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            v, err := strconv.ParseInt(s, 10, 64)
+            if err != nil || rv.OverflowInt(v) {
+                return self.mismatched(rv.Type())
+            }
+            rv.SetInt(v)
+            return nil
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            v, err := strconv.ParseUint(s, 10, 64)
+            if err != nil || rv.OverflowUint(v) {
+                return self.mismatched(rv.Type())
+            }
+            rv.SetUint(v)
+            return nil
+        case reflect.Float32, reflect.Float64:
+            v, err := strconv.ParseFloat(s, rv.Type().Bits())
+            if err != nil || rv.OverflowFloat(v) {
+                return self.mismatched(rv.Type())
+            }
+            rv.SetFloat(v)
+            return nil
+        case reflect.String:
+            if rv.Type() == reflect.TypeOf(json.Number("")) {
+                rv.SetString(s)
+                return nil
+            }
+        case reflect.Interface:
+            v, err := self.Interface()
+            if err != nil {
+                return err
+            }
+            if setInterface(rv, v) {
+                return nil
+            }
+    }
+    return self.mismatched(rv.Type())
+}
+
+func (self *Node) decodeAny(rv reflect.Value) error {
+    v := self.packAny()
+    if v != nil && reflect.TypeOf(v).AssignableTo(rv.Type()) {
+        rv.Set(reflect.ValueOf(v))
+        return nil
+    }
+    buf, err := self.MarshalJSON()
+    if err != nil {
+        return err
+    }
+    return decodeRaw(string(buf), rv.Addr().Interface())
+}
+
+func (self *Node) decodeArray(rv reflect.Value, depth int) error {
+    if self.isLazy() {
+        if err := self.skipAllIndex(); err != nil {
+            return err
+        }
+    }
+
+    /* generic arrays */
+    if rv.Kind() == reflect.Interface {
+        if rv.NumMethod() != 0 {
+            return self.mismatched(rv.Type())
+        }
+        v, err := self.Interface()
+        if err != nil {
+            return err
+        }
+        rv.Set(reflect.ValueOf(v))
+        return nil
+    }
+
+    nb := self.len()
+    switch rv.Kind() {
+        case reflect.Slice : rv.Set(reflect.MakeSlice(rv.Type(), nb, nb))
+        case reflect.Array : break
+        default            : return self.mismatched(rv.Type())
+    }
+
+    /* decode each element, and zero the rest of the Go arrays */
+    i := 0
+    for j := 0; j < nb && i < rv.Len(); j++ {
+        n := self.nodeAt(j)
+        if !n.Exists() {
+            continue
+        }
+        if err := n.decode(rv.Index(i), false, depth + 1); err != nil {
+            return err
+        }
+        i++
+    }
+    if rv.Kind() == reflect.Slice {
+        rv.SetLen(i)
+    }
+    for ; i < rv.Len(); i++ {
+        rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+    }
+    return nil
+}
+
+func (self *Node) decodeObject(rv reflect.Value, depth int) error {
+    if self.isLazy() {
+        if err := self.skipAllKey(); err != nil {
+            return err
+        }
+    }
+    switch rv.Kind() {
+        case reflect.Map       : return self.decodeMap(rv, depth)
+        case reflect.Struct    : return self.decodeStruct(rv, depth)
+        case reflect.Interface : break
+        default                : return self.mismatched(rv.Type())
+    }
+
+    /* generic objects */
+    if rv.NumMethod() != 0 {
+        return self.mismatched(rv.Type())
+    }
+    v, err := self.Interface()
+    if err != nil {
+        return err
+    }
+    rv.Set(reflect.ValueOf(v))
+    return nil
+}
+
+func (self *Node) decodeMap(rv reflect.Value, depth int) error {
+    mt := rv.Type()
+    if rv.IsNil() {
+        rv.Set(reflect.MakeMap(mt))
+    }
+
+    nb := self.len()
+    for i := 0; i < nb; i++ {
+        p := self.pairAt(i)
+        if p == nil || !p.Value.Exists() {
+            continue
+        }
+        kv, err := mapKeyValue(p.Key, mt.Key())
+        if err != nil {
+            return err
+        }
+        ev := reflect.New(mt.Elem()).Elem()
+        if err := p.Value.decode(ev, false, depth + 1); err != nil {
+            return err
+        }
+        rv.SetMapIndex(kv, ev)
+    }
+    return nil
+}
+
+func mapKeyValue(key string, kt reflect.Type) (reflect.Value, error) {
+    if reflect.PtrTo(kt).Implements(textUnmarshalerType) {
+        kv := reflect.New(kt)
+        if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+            return reflect.Value{}, err
+        }
+        return kv.Elem(), nil
+    }
+    kv := reflect.New(kt).Elem()
+    switch kt.Kind() {
+        case reflect.String:
+            kv.SetString(key)
+            return kv, nil
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            v, err := strconv.ParseInt(key, 10, 64)
+            if err != nil || kv.OverflowInt(v) {
+                break
+            }
+            kv.SetInt(v)
+            return kv, nil
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            v, err := strconv.ParseUint(key, 10, 64)
+            if err != nil || kv.OverflowUint(v) {
+                break
+            }
+            kv.SetUint(v)
+            return kv, nil
+    }
+    return reflect.Value{}, &json.UnmarshalTypeError{Value: "number " + key, Type: kt}
+}
+
+func (self *Node) decodeStruct(rv reflect.Value, depth int) error {
+    fv := resolver.ResolveStruct(rv.Type())
+    nb := self.len()
+    for i := 0; i < nb; i++ {
+        p := self.pairAt(i)
+        if p == nil || !p.Value.Exists() {
+            continue
+        }
+
+        /* unknown fields are ignored */
+        fm := matchField(fv, p.Key)
+        if fm == nil {
+            continue
+        }
+
+        vp := fieldAt(unsafe.Pointer(rv.UnsafeAddr()), fm, true)
+        val := reflect.NewAt(fm.Type, vp).Elem()
+        quoted := fm.Opts & resolver.F_stringize != 0 && isQuotable(fm.Type)
+        if err := p.Value.decode(val, quoted, depth + 1); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// matchField finds the field of key, preferring an exact match to a case-insensitive one.
+func matchField(fv []resolver.FieldMeta, key string) *resolver.FieldMeta {
+    for i := range fv {
+        if fv[i].Name == key {
+            return &fv[i]
+        }
+    }
+    for i := range fv {
+        if strings.EqualFold(fv[i].Name, key) {
+            return &fv[i]
+        }
+    }
+    return nil
+}
+
+func isQuotable(vt reflect.Type) bool {
+    if vt.Kind() == reflect.Ptr {
+        vt = vt.Elem()
+    }
+    return vt.Kind() == reflect.String || isScalarKind(vt.Kind())
+}
+
+func isScalarKind(k reflect.Kind) bool {
+    switch k {
+        case reflect.Bool, reflect.Float32, reflect.Float64,
+             reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+             reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            return true
+        default:
+            return false
+    }
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `encoding/json`
+    `math`
+    `testing`
+    `time`
+
+    `github.com/bytedance/sonic/encoder`
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+type ValueEmbedded struct {
+    E int `json:"e"`
+}
+
+type valueText int
+
+func (self valueText) MarshalText() ([]byte, error) {
+    return []byte{'k', byte('a' + self)}, nil
+}
+
+func (self *valueText) UnmarshalText(b []byte) error {
+    *self = valueText(b[len(b) - 1] - 'a')
+    return nil
+}
+
+type valueObject struct {
+    *ValueEmbedded
+    A int                      `json:"a"`
+    B string                   `json:"b,omitempty"`
+    C float64                  `json:"c,string"`
+    D []byte                   `json:"d"`
+    F []interface{}            `json:"f"`
+    G map[string]*valueObject  `json:"g,omitempty"`
+    H [2]uint8                 `json:"h"`
+    I time.Time                `json:"i"`
+    J valueText                `json:"j"`
+    K map[valueText]bool       `json:"k"`
+    L map[int]string           `json:"l"`
+    M json.RawMessage          `json:"m,omitempty"`
+    N *Node                    `json:"n,omitempty"`
+    S string                   `json:"s,string"`
+    p int
+}
+
+func TestNewFromValue(t *testing.T) {
+    var cases = []struct {
+        name string
+        val  interface{}
+    }{
+        {"embedded", &valueObject{ValueEmbedded: &ValueEmbedded{E: 7}, A: -1, p: 1}},
+        {"omitempty", valueObject{B: "", G: map[string]*valueObject{}}},
+        {"string option", valueObject{B: "b", C: 1.5e-7, S: "str"}},
+        {"bytes", valueObject{D: []byte("hello"), H: [2]uint8{1, 2}}},
+        {"interfaces", valueObject{F: []interface{}{nil, true, "x", 1.25, map[string]interface{}{"y": []interface{}{}}}}},
+        {"nested", valueObject{G: map[string]*valueObject{"z": {A: 2, D: []byte{0}}, "nil": nil}}},
+        {"marshalers", valueObject{I: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), J: 2, M: json.RawMessage(`{"raw":[1, 2]}`)}},
+        {"map keys", valueObject{K: map[valueText]bool{1: true, 0: false}, L: map[int]string{-3: "a", 10: "b"}}},
+        {"nodes", valueObject{N: &[]Node{NewArray([]Node{NewString("\"quoted\""), NewNumber("3")})}[0]}},
+        {"node", &[]Node{NewObject([]Pair{NewPair("a", NewNull())})}[0]},
+        {"sorted map", map[int]int{3: 1, 1: 2, 2: 3}},
+        {"nil", nil},
+        {"nil pointer", (*int)(nil)},
+        {"nil slice", []int(nil)},
+        {"nil map", map[string]int(nil)},
+        {"nil node", (*Node)(nil)},
+    }
+    for _, c := range cases {
+        exp, err := json.Marshal(c.val)
+        require.NoError(t, err, c.name)
+        node := NewFromValue(c.val)
+        require.NoError(t, node.Check(), c.name)
+        out, err := node.MarshalJSON()
+        require.NoError(t, err, c.name)
+        assert.JSONEq(t, string(exp), string(out), c.name)
+    }
+
+    /* the nodes are navigable without loading, in the order of keys */
+    node := NewFromValue(valueObject{C: 1.5e-7, G: map[string]*valueObject{"z": {A: 2}}, S: "str"})
+    v, err := node.GetByPath("g", "z", "a").Int64()
+    require.NoError(t, err)
+    assert.Equal(t, int64(2), v)
+    str, err := node.Get("c").String()
+    require.NoError(t, err)
+    assert.Equal(t, "1.5e-7", str)
+    str, err = node.Get("s").String()
+    require.NoError(t, err)
+    assert.Equal(t, `"str"`, str)
+    assert.False(t, node.Get("b").Exists())
+    assert.False(t, node.Get("p").Exists())
+    sorted := NewFromValue(map[int]int{3: 1, 1: 2, 2: 3})
+    out, err := sorted.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, `{"1":2,"2":3,"3":1}`, string(out))
+
+    /* the unsupported values */
+    for _, v := range []interface{}{math.NaN(), map[string]interface{}{"ch": make(chan int)}, json.RawMessage(`{`)} {
+        n := NewFromValue(v)
+        assert.Error(t, n.Check(), "%#v", v)
+    }
+}
+
+func TestNewFromValue_Marshal(t *testing.T) {
+    type prices struct {
+        Fl   float64            `json:"fl,decimals=2"`
+        Pr   float32            `json:"pr,precision=2,exponent"`
+        Neg  float64            `json:"neg,negzero"`
+        Big  int64              `json:"big,string"`
+        Maps map[string]float64 `json:"maps"`
+    }
+    v := prices{Fl: 1.5, Pr: 1234.5, Neg: math.Copysign(0, -1), Big: 1 << 60, Maps: map[string]float64{"b": 2, "a": 0.1}}
+    exp, err := encoder.Encode(v, encoder.SortMapKeys)
+    require.NoError(t, err)
+    node := NewFromValue(v)
+    out, err := node.MarshalJSON()
+    require.NoError(t, err)
+    assert.Equal(t, string(exp), string(out))
+    assert.Equal(t, `{"fl":1.50,"pr":1.2e+3,"neg":-0,"big":"1152921504606846976","maps":{"a":0.1,"b":2}}`, string(out))
+}
+
+func TestNodeDecode(t *testing.T) {
+    var cases = []struct {
+        name string
+        src  string
+    }{
+        {"fields", `{"e":7,"a":-1,"b":"x","c":"1.5e-7","s":"\"str\"","p":1,"unknown":[]}`},
+        {"bytes and arrays", `{"d":"aGVsbG8=","h":[1,2,3]}`},
+        {"generic", `{"f":[null,true,"x",1.25,{"y":[]}]}`},
+        {"nested", `{"g":{"z":{"a":2,"d":"AA=="},"nil":null}}`},
+        {"unmarshalers", `{"i":"2024-01-02T03:04:05.000000006Z","j":"kc","m":{"raw":[1, 2]}}`},
+        {"map keys", `{"k":{"kb":true,"ka":false},"l":{"-3":"a","10":"b"}}`},
+        {"nodes", `{"n":["\"quoted\"",3]}`},
+    }
+    for _, c := range cases {
+        var exp valueObject
+        require.NoError(t, json.Unmarshal([]byte(c.src), &exp), c.name)
+        nodes := map[string]func() Node{
+            "value" : func() Node { return NewFromValue(&exp) },
+            "raw"   : func() Node { return NewRaw(c.src) },
+            "lazy"  : func() Node { n := NewRaw(c.src); n.Get("g"); return n },
+            "loaded": func() Node { n := NewRaw(c.src); require.NoError(t, n.LoadAll()); return n },
+        }
+        for name, newNode := range nodes {
+            node := newNode()
+            var val valueObject
+            require.NoError(t, node.Decode(&val), c.name + "/" + name)
+            expOut, err := json.Marshal(&exp)
+            require.NoError(t, err)
+            out, err := json.Marshal(&val)
+            require.NoError(t, err)
+            assert.JSONEq(t, string(expOut), string(out), c.name + "/" + name)
+            assert.Equal(t, exp.I, val.I, c.name + "/" + name)
+            assert.Equal(t, exp.K, val.K, c.name + "/" + name)
+
+            /* decode into the generic values */
+            var iv, ev interface{}
+            require.NoError(t, node.Decode(&iv))
+            if name == "value" {
+                require.NoError(t, json.Unmarshal(expOut, &ev))
+            } else {
+                require.NoError(t, json.Unmarshal([]byte(c.src), &ev))
+            }
+            assert.Equal(t, ev, iv, c.name + "/" + name)
+        }
+    }
+
+    /* decode into the nodes */
+    node := NewRaw(`{"n":["\"quoted\"",3],"f":[null,true]}`)
+    var nv struct {
+        N  Node  `json:"n"`
+        P  *Node `json:"f"`
+    }
+    require.NoError(t, node.Decode(&nv))
+    s, err := nv.N.Index(0).String()
+    require.NoError(t, err)
+    assert.Equal(t, `"quoted"`, s)
+    b, err := nv.P.Index(1).Bool()
+    require.NoError(t, err)
+    assert.True(t, b)
+
+    /* null keeps the non-nullable values */
+    iv := struct{ A int; B *int; C []int }{A: 1, B: new(int), C: []int{1}}
+    node = NewObject([]Pair{NewPair("A", NewNull()), NewPair("B", NewNull()), NewPair("c", NewNull())})
+    require.NoError(t, node.Decode(&iv))
+    assert.Equal(t, 1, iv.A)
+    assert.Nil(t, iv.B)
+    assert.Nil(t, iv.C)
+
+    /* the errors */
+    var i8 int8
+    num, str := NewNumber("128"), NewString("1")
+    assert.Error(t, num.Decode(&i8))
+    assert.Error(t, str.Decode(&i8))
+    assert.Error(t, num.Decode(i8))
+    assert.Error(t, num.Decode(nil))
+    var m map[string]int
+    node = NewObject([]Pair{NewPair("x", NewBool(true))})
+    assert.Error(t, node.Decode(&m))
+    assert.Equal(t, ErrNotExist, node.Get("none").Decode(&m))
+}
+
+func TestNodeDecodeAny(t *testing.T) {
+    type inner struct {
+        X int `json:"x"`
+    }
+    node := NewObject([]Pair{NewPair("a", NewAny(&inner{X: 1})), NewPair("b", NewAny(map[string]int{"x": 2}))})
+    var val struct {
+        A *inner `json:"a"`
+        B inner  `json:"b"`
+    }
+    require.NoError(t, node.Decode(&val))
+    assert.Equal(t, 1, val.A.X)
+    assert.Equal(t, 2, val.B.X)
+}
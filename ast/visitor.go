@@ -98,14 +98,24 @@ type VisitorOptions struct {
     // conversion, then the first argument of OnInt64 / OnFloat64 will always
     // be zero.
     OnlyNumber bool
+
+    // BufferSize is the size of buffer used by PreorderReader, which is
+    // 64KB by default. It is ignored by Preorder.
+    BufferSize int
+
+    // Path, if not nil, is kept by the traverser as the path of the current
+    // value, so that the callbacks can read it. It must not be shared by the
+    // concurrent traversals.
+    Path *VisitorPath
 }
 
 var defaultVisitorOptions = &VisitorOptions{}
 
 // Preorder decodes the whole JSON string and callbacks each AST node to visitor
 // during preorder traversal. Any visitor method with an error returned will
-// break the traversal and the given error will be directly returned. The opts
-// argument can be reused after every call.
+// break the traversal and the given error will be directly returned, except
+// that Stop ends the traversal with nil returned. The opts argument can be
+// reused after every call.
 func Preorder(str string, visitor Visitor, opts *VisitorOptions) error {
     if opts == nil {
         opts = defaultVisitorOptions
@@ -123,7 +133,9 @@ func Preorder(str string, visitor Visitor, opts *VisitorOptions) error {
             skipValue: false,
         },
         visitor: visitor,
+        path:    opts.Path,
     }
+    tv.path.reset()
 
     if optDecodeNumber {
         tv.parser.decodeNumber(true)
@@ -134,12 +146,16 @@ func Preorder(str string, visitor Visitor, opts *VisitorOptions) error {
     if optDecodeNumber {
         tv.parser.decodeNumber(false)
     }
+    if err == Stop {
+        return nil
+    }
     return err
 }
 
 type traverser struct {
     parser  Parser
     visitor Visitor
+    path    *VisitorPath
 }
 
 // NOTE: keep in sync with (*Parser).Parse method.
@@ -200,8 +216,10 @@ func (self *traverser) decodeArray() error {
         return self.visitor.OnArrayEnd()
     }
 
-    for {
+    self.path.push("", 0)
+    for i := 0; ; i++ {
         /* decode the value */
+        self.path.setIndex(i)
         if err := self.decodeValue(); err != nil {
             return err
         }
@@ -218,6 +236,7 @@ func (self *traverser) decodeArray() error {
             self.parser.p++
         case ']':
             self.parser.p++
+            self.path.pop()
             return self.visitor.OnArrayEnd()
         default:
             return types.ERR_INVALID_CHAR
@@ -275,8 +294,13 @@ func (self *traverser) decodeObject() error {
             }
         }
 
+        self.path.push(key, -1)
+        skip := false
         if err := self.visitor.OnObjectKey(key); err != nil {
-            return err
+            if err != SkipChildren {
+                return err
+            }
+            skip = true
         }
 
         /* expect a ':' delimiter */
@@ -284,10 +308,15 @@ func (self *traverser) decodeObject() error {
             return err
         }
 
-        /* decode the value */
-        if err := self.decodeValue(); err != nil {
+        /* decode or skip the value */
+        if skip {
+            if _, err = self.parser.skipFast(); err != 0 {
+                return err
+            }
+        } else if err := self.decodeValue(); err != nil {
             return err
         }
+        self.path.pop()
 
         self.parser.p = self.parser.lspace(self.parser.p)
 
@@ -330,3 +359,67 @@ func (self *traverser) decodeString(iv int64, ep int) error {
 // If visitor return this error on `OnObjectBegin()` or `OnArrayBegin()`,
 // the transverer will skip entiry object or array
 var VisitOPSkip = errors.New("")
+
+// SkipChildren is the same as VisitOPSkip, and it can also be returned by
+// `OnObjectKey()` to skip the value of the member without any callback.
+var SkipChildren = VisitOPSkip
+
+// Stop can be returned by any method of visitor to end the traversal cleanly,
+// and then the traversal returns nil.
+var Stop = errors.New("stop traversal")
+
+// VisitorPath is the path from the root to the current value during traversal.
+//
+// In OnObjectKey, the path ends with the key. In OnObjectBegin, OnArrayBegin
+// and their ends, the path is of the object or array itself.
+type VisitorPath struct {
+    elems []visitorPathElem
+}
+
+type visitorPathElem struct {
+    key   string
+    index int // -1 for the key of object
+}
+
+// Len returns the depth of the current value.
+func (self *VisitorPath) Len() int {
+    return len(self.elems)
+}
+
+// Path returns a copy of the path, whose elements are string keys or int
+// indexes, as the arguments of GetByPath.
+func (self *VisitorPath) Path() []interface{} {
+    ret := make([]interface{}, len(self.elems))
+    for i, e := range self.elems {
+        if e.index < 0 {
+            ret[i] = e.key
+        } else {
+            ret[i] = e.index
+        }
+    }
+    return ret
+}
+
+func (self *VisitorPath) reset() {
+    if self != nil {
+        self.elems = self.elems[:0]
+    }
+}
+
+func (self *VisitorPath) push(key string, index int) {
+    if self != nil {
+        self.elems = append(self.elems, visitorPathElem{key, index})
+    }
+}
+
+func (self *VisitorPath) setIndex(index int) {
+    if self != nil {
+        self.elems[len(self.elems) - 1].index = index
+    }
+}
+
+func (self *VisitorPath) pop() {
+    if self != nil {
+        self.elems = self.elems[:len(self.elems) - 1]
+    }
+}
@@ -0,0 +1,613 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `encoding/json`
+    `io`
+    `strconv`
+    `unicode/utf16`
+    `unicode/utf8`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+const (
+    _DEFAULT_READER_BUFFER = 64 * 1024
+    _MIN_READER_BUFFER     = 64
+)
+
+// StringChunkVisitor is an optional extension of Visitor for PreorderReader.
+//
+// If the visitor implements it, the strings longer than the buffer are delivered
+// by OnStringChunk in chunks instead of OnString, so that they are never held whole.
+// The object keys are always delivered whole by OnObjectKey.
+type StringChunkVisitor interface {
+    Visitor
+
+    // OnStringChunk handles a chunk of a JSON string value, and last reports
+    // if it is the last chunk of the string. The chunks never split a UTF-8 character.
+    OnStringChunk(chunk string, last bool) error
+}
+
+// PreorderReader is same as Preorder, except it reads the JSON from r through a bounded buffer,
+// thus the arbitrarily large input can be traversed without being loaded whole.
+//
+// The size of buffer is VisitorOptions.BufferSize. A number longer than the buffer is invalid.
+// A string longer than the buffer is delivered whole by OnString,
+// or in chunks by OnStringChunk if the visitor implements StringChunkVisitor.
+//
+// The traversal stops after the first JSON value or Stop returned by the visitor,
+// and the rest of r is not read.
+func PreorderReader(r io.Reader, visitor Visitor, opts *VisitorOptions) error {
+    if opts == nil {
+        opts = defaultVisitorOptions
+    }
+    size := opts.BufferSize
+    if size <= 0 {
+        size = _DEFAULT_READER_BUFFER
+    } else if size < _MIN_READER_BUFFER {
+        size = _MIN_READER_BUFFER
+    }
+
+    tv := &readerTraverser{
+        r:            r,
+        buf:          make([]byte, 0, size),
+        visitor:      visitor,
+        path:         opts.Path,
+        decodeNumber: !opts.OnlyNumber,
+    }
+    tv.chunker, _ = visitor.(StringChunkVisitor)
+    tv.path.reset()
+    if err := tv.decodeValue(); err != Stop {
+        return err
+    }
+    return nil
+}
+
+type readerTraverser struct {
+    r            io.Reader
+    buf          []byte
+    p            int
+    err          error
+    str          []byte
+    visitor      Visitor
+    chunker      StringChunkVisitor
+    path         *VisitorPath
+    decodeNumber bool
+}
+
+// fill reads more data into the buffer, after moving the unread data to the front.
+// It returns false if no more data can be read.
+func (self *readerTraverser) fill() bool {
+    if self.err != nil {
+        return false
+    }
+    if self.p > 0 {
+        n := copy(self.buf[:cap(self.buf)], self.buf[self.p:])
+        self.buf = self.buf[:n]
+        self.p = 0
+    }
+    if len(self.buf) == cap(self.buf) {
+        return false
+    }
+    for {
+        n, err := self.r.Read(self.buf[len(self.buf):cap(self.buf)])
+        self.buf = self.buf[:len(self.buf) + n]
+        if err != nil {
+            self.err = err
+        }
+        if n > 0 || err != nil {
+            return n > 0
+        }
+    }
+}
+
+// ensure ensures there are at least n unread bytes in the buffer if possible.
+func (self *readerTraverser) ensure(n int) bool {
+    for len(self.buf) - self.p < n {
+        if !self.fill() {
+            return false
+        }
+    }
+    return true
+}
+
+// readError returns the error of reading, or ERR_EOF if the input is exhausted.
+func (self *readerTraverser) readError() error {
+    if self.err != nil && self.err != io.EOF {
+        return self.err
+    }
+    return types.ERR_EOF
+}
+
+// next returns the next non-blank character without consuming it.
+func (self *readerTraverser) next() (byte, error) {
+    for {
+        for ; self.p < len(self.buf); self.p++ {
+            if c := self.buf[self.p]; !isSpace(c) {
+                return c, nil
+            }
+        }
+        if !self.fill() {
+            return 0, self.readError()
+        }
+    }
+}
+
+// NOTE: keep in sync with (*traverser).decodeValue method.
+func (self *readerTraverser) decodeValue() error {
+    c, err := self.next()
+    if err != nil {
+        return err
+    }
+    switch c {
+    case 'n':
+        if err := self.literal("null"); err != nil {
+            return err
+        }
+        return self.visitor.OnNull()
+    case 't':
+        if err := self.literal("true"); err != nil {
+            return err
+        }
+        return self.visitor.OnBool(true)
+    case 'f':
+        if err := self.literal("false"); err != nil {
+            return err
+        }
+        return self.visitor.OnBool(false)
+    case '"':
+        self.p++
+        return self.decodeString()
+    case '[':
+        self.p++
+        return self.decodeArray()
+    case '{':
+        self.p++
+        return self.decodeObject()
+    default:
+        if c == '-' || (c >= '0' && c <= '9') {
+            return self.decodeNumberValue()
+        }
+        return types.ERR_INVALID_CHAR
+    }
+}
+
+func (self *readerTraverser) literal(lit string) error {
+    if !self.ensure(len(lit)) {
+        if err := self.readError(); err != types.ERR_EOF {
+            return err
+        }
+        return types.ERR_INVALID_CHAR
+    }
+    if string(self.buf[self.p:self.p + len(lit)]) != lit {
+        return types.ERR_INVALID_CHAR
+    }
+    self.p += len(lit)
+    return nil
+}
+
+func isNumberChar(c byte) bool {
+    return (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+func (self *readerTraverser) decodeNumberValue() error {
+    i := 0
+    for {
+        for ; self.p + i < len(self.buf) && isNumberChar(self.buf[self.p + i]); i++ {}
+        if self.p + i < len(self.buf) {
+            break
+        }
+
+        /* the number may continue in the next read */
+        if !self.fill() {
+            if err := self.readError(); err != types.ERR_EOF {
+                return err
+            }
+            if i == cap(self.buf) {
+                return types.ERR_INVALID_NUMBER_FMT
+            }
+            break
+        }
+    }
+
+    num := string(self.buf[self.p:self.p + i])
+    if _, ok := parseDecimal(num); !ok || !validNumber(num) {
+        return types.ERR_INVALID_NUMBER_FMT
+    }
+    self.p += i
+    if !self.decodeNumber {
+        return self.visitor.OnFloat64(0, json.Number(num))
+    }
+    if !isFloatNumber(num) {
+        if v, err := strconv.ParseInt(num, 10, 64); err == nil {
+            return self.visitor.OnInt64(v, json.Number(num))
+        }
+    }
+    v, err := strconv.ParseFloat(num, 64)
+    if err != nil {
+        return types.ERR_FLOAT_INFINITY
+    }
+    return self.visitor.OnFloat64(v, json.Number(num))
+}
+
+// validNumber checks the leading zeros, which parseDecimal accepts.
+func validNumber(num string) bool {
+    if num[0] == '-' {
+        num = num[1:]
+    }
+    return len(num) == 1 || num[0] != '0' || (num[1] < '0' || num[1] > '9')
+}
+
+func isFloatNumber(num string) bool {
+    for i := 0; i < len(num); i++ {
+        if c := num[i]; c == '.' || c == 'e' || c == 'E' {
+            return true
+        }
+    }
+    return false
+}
+
+func (self *readerTraverser) decodeString() error {
+    chunked, err := self.readString(self.chunker)
+    if err != nil {
+        return err
+    }
+    if chunked {
+        return self.chunker.OnStringChunk(string(self.str), true)
+    }
+    return self.visitor.OnString(string(self.str))
+}
+
+// readString decodes the string after the opening quote into self.str.
+// If chunker is not nil, the leading chunks of a long string are delivered to it,
+// then chunked is true and self.str holds the last chunk.
+func (self *readerTraverser) readString(chunker StringChunkVisitor) (chunked bool, err error) {
+    self.str = self.str[:0]
+    for {
+        /* fast path: copy the plain characters */
+        s := self.p
+        for ; self.p < len(self.buf); self.p++ {
+            if c := self.buf[self.p]; c == '"' || c == '\\' || c < 0x20 {
+                break
+            }
+        }
+        self.str = append(self.str, self.buf[s:self.p]...)
+
+        /* deliver the chunk of long string */
+        if chunker != nil && len(self.str) >= cap(self.buf) {
+            n := chunkSize(self.str)
+            if err := chunker.OnStringChunk(string(self.str[:n]), false); err != nil {
+                return false, err
+            }
+            self.str = self.str[:copy(self.str, self.str[n:])]
+            chunked = true
+        }
+
+        if self.p == len(self.buf) {
+            if !self.fill() {
+                return false, self.readError()
+            }
+            continue
+        }
+
+        switch c := self.buf[self.p]; {
+        case c == '"':
+            self.p++
+            return chunked, nil
+        case c == '\\':
+            if err := self.decodeEscape(); err != nil {
+                return false, err
+            }
+        default:
+            return false, types.ERR_INVALID_CHAR
+        }
+    }
+}
+
+// chunkSize returns the length of the chunk of buf, which doesn't split the last UTF-8 character.
+func chunkSize(buf []byte) int {
+    n := len(buf)
+    for i := n - 1; i >= 0 && i >= n - utf8.UTFMax; i-- {
+        if utf8.RuneStart(buf[i]) {
+            if !utf8.FullRune(buf[i:]) {
+                return i
+            }
+            break
+        }
+    }
+    return n
+}
+
+// decodeEscape decodes the escape sequence at the current position.
+func (self *readerTraverser) decodeEscape() error {
+    if !self.ensure(2) {
+        return self.readError()
+    }
+    c := self.buf[self.p + 1]
+    switch c {
+    case '"', '\\', '/':
+        self.str = append(self.str, c)
+    case 'b':
+        self.str = append(self.str, '\b')
+    case 'f':
+        self.str = append(self.str, '\f')
+    case 'n':
+        self.str = append(self.str, '\n')
+    case 'r':
+        self.str = append(self.str, '\r')
+    case 't':
+        self.str = append(self.str, '\t')
+    case 'u':
+        return self.decodeUnicode()
+    default:
+        return types.ERR_INVALID_ESCAPE
+    }
+    self.p += 2
+    return nil
+}
+
+func (self *readerTraverser) decodeUnicode() error {
+    if !self.ensure(6) {
+        return self.readError()
+    }
+    r, ok := decodeHex4(self.buf[self.p + 2:self.p + 6])
+    if !ok {
+        return types.ERR_INVALID_UNICODE
+    }
+    self.p += 6
+
+    /* combine the surrogate pair */
+    if utf16.IsSurrogate(r) {
+        if self.ensure(6) && self.buf[self.p] == '\\' && self.buf[self.p + 1] == 'u' {
+            if r2, ok := decodeHex4(self.buf[self.p + 2:self.p + 6]); ok {
+                if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+                    self.p += 6
+                    r = dec
+                }
+            }
+        }
+        if utf16.IsSurrogate(r) {
+            r = utf8.RuneError
+        }
+    }
+    var tmp [utf8.UTFMax]byte
+    self.str = append(self.str, tmp[:utf8.EncodeRune(tmp[:], r)]...)
+    return nil
+}
+
+func decodeHex4(b []byte) (rune, bool) {
+    var r rune
+    for _, c := range b {
+        switch {
+        case c >= '0' && c <= '9':
+            c -= '0'
+        case c >= 'a' && c <= 'f':
+            c -= 'a' - 10
+        case c >= 'A' && c <= 'F':
+            c -= 'A' - 10
+        default:
+            return 0, false
+        }
+        r = r << 4 | rune(c)
+    }
+    return r, true
+}
+
+// NOTE: keep in sync with (*traverser).decodeArray method.
+func (self *readerTraverser) decodeArray() error {
+    if err := self.visitor.OnArrayBegin(_DEFAULT_NODE_CAP); err != nil {
+        if err == VisitOPSkip {
+            if err := self.skipContainer(); err != nil {
+                return err
+            }
+            return self.visitor.OnArrayEnd()
+        }
+        return err
+    }
+
+    /* check for empty array */
+    c, err := self.next()
+    if err != nil {
+        return err
+    }
+    if c == ']' {
+        self.p++
+        return self.visitor.OnArrayEnd()
+    }
+
+    self.path.push("", 0)
+    for i := 0; ; i++ {
+        /* decode the value */
+        self.path.setIndex(i)
+        if err := self.decodeValue(); err != nil {
+            return err
+        }
+
+        /* check for the next character */
+        if c, err = self.next(); err != nil {
+            return err
+        }
+        switch c {
+        case ',':
+            self.p++
+        case ']':
+            self.p++
+            self.path.pop()
+            return self.visitor.OnArrayEnd()
+        default:
+            return types.ERR_INVALID_CHAR
+        }
+    }
+}
+
+// NOTE: keep in sync with (*traverser).decodeObject method.
+func (self *readerTraverser) decodeObject() error {
+    if err := self.visitor.OnObjectBegin(_DEFAULT_NODE_CAP); err != nil {
+        if err == VisitOPSkip {
+            if err := self.skipContainer(); err != nil {
+                return err
+            }
+            return self.visitor.OnObjectEnd()
+        }
+        return err
+    }
+
+    /* check for empty object */
+    c, err := self.next()
+    if err != nil {
+        return err
+    }
+    if c == '}' {
+        self.p++
+        return self.visitor.OnObjectEnd()
+    }
+
+    for {
+        /* decode the key, which is always delivered whole */
+        if c != '"' {
+            return types.ERR_INVALID_CHAR
+        }
+        self.p++
+        if _, err := self.readString(nil); err != nil {
+            return err
+        }
+        key := string(self.str)
+        self.path.push(key, -1)
+        skip := false
+        if err := self.visitor.OnObjectKey(key); err != nil {
+            if err != SkipChildren {
+                return err
+            }
+            skip = true
+        }
+
+        /* expect a ':' delimiter */
+        if c, err = self.next(); err != nil {
+            return err
+        }
+        if c != ':' {
+            return types.ERR_INVALID_CHAR
+        }
+        self.p++
+
+        /* decode or skip the value */
+        if skip {
+            if err := self.skipValue(); err != nil {
+                return err
+            }
+        } else if err := self.decodeValue(); err != nil {
+            return err
+        }
+        self.path.pop()
+
+        /* check for the next character */
+        if c, err = self.next(); err != nil {
+            return err
+        }
+        switch c {
+        case ',':
+            self.p++
+        case '}':
+            self.p++
+            return self.visitor.OnObjectEnd()
+        default:
+            return types.ERR_INVALID_CHAR
+        }
+        if c, err = self.next(); err != nil {
+            return err
+        }
+    }
+}
+
+// skipValue skips the value at the current position.
+func (self *readerTraverser) skipValue() error {
+    c, err := self.next()
+    if err != nil {
+        return err
+    }
+    switch c {
+    case '"':
+        return self.skipString()
+    case '[', '{':
+        self.p++
+        return self.skipContainer()
+    }
+
+    /* skip the literal or number until a delimiter */
+    for {
+        for ; self.p < len(self.buf); self.p++ {
+            if c := self.buf[self.p]; c == ',' || c == ']' || c == '}' || isSpace(c) {
+                return nil
+            }
+        }
+        if !self.fill() {
+            if err := self.readError(); err != types.ERR_EOF {
+                return err
+            }
+            return nil
+        }
+    }
+}
+
+// skipContainer skips the rest of the array or object after the opening bracket.
+func (self *readerTraverser) skipContainer() error {
+    depth := 1
+    for {
+        if self.p == len(self.buf) && !self.fill() {
+            return self.readError()
+        }
+        switch c := self.buf[self.p]; c {
+        case '[', '{':
+            depth++
+        case ']', '}':
+            if depth--; depth == 0 {
+                self.p++
+                return nil
+            }
+        case '"':
+            if err := self.skipString(); err != nil {
+                return err
+            }
+            continue
+        }
+        self.p++
+    }
+}
+
+// skipString skips the string at the current position.
+func (self *readerTraverser) skipString() error {
+    self.p++
+    for {
+        for ; self.p < len(self.buf); self.p++ {
+            switch self.buf[self.p] {
+            case '"':
+                self.p++
+                return nil
+            case '\\':
+                if !self.ensure(2) {
+                    return self.readError()
+                }
+                self.p++
+            }
+        }
+        if !self.fill() {
+            return self.readError()
+        }
+    }
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+    `encoding/json`
+    `errors`
+    `fmt`
+    `strings`
+    `testing`
+    `testing/iotest`
+    `unicode/utf8`
+
+    `github.com/bytedance/sonic/internal/native/types`
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+// eventVisitor records the callbacks as events.
+type eventVisitor struct {
+    events []string
+    skip   bool
+}
+
+func (self *eventVisitor) add(format string, args ...interface{}) error {
+    self.events = append(self.events, fmt.Sprintf(format, args...))
+    return nil
+}
+
+func (self *eventVisitor) OnNull() error                 { return self.add("null") }
+func (self *eventVisitor) OnBool(v bool) error           { return self.add("bool %v", v) }
+func (self *eventVisitor) OnString(v string) error       { return self.add("string %q", v) }
+func (self *eventVisitor) OnObjectKey(key string) error  { return self.add("key %q", key) }
+func (self *eventVisitor) OnObjectEnd() error            { return self.add("}") }
+func (self *eventVisitor) OnArrayEnd() error             { return self.add("]") }
+
+func (self *eventVisitor) OnInt64(v int64, n json.Number) error {
+    return self.add("int %d %s", v, n)
+}
+
+func (self *eventVisitor) OnFloat64(v float64, n json.Number) error {
+    return self.add("float %v %s", v, n)
+}
+
+func (self *eventVisitor) OnObjectBegin(capacity int) error {
+    self.add("{")
+    if self.skip && len(self.events) > 1 {
+        return VisitOPSkip
+    }
+    return nil
+}
+
+func (self *eventVisitor) OnArrayBegin(capacity int) error {
+    self.add("[")
+    if self.skip && len(self.events) > 1 {
+        return VisitOPSkip
+    }
+    return nil
+}
+
+// chunkVisitor records the chunks of strings.
+type chunkVisitor struct {
+    eventVisitor
+    chunks []string
+    first  int
+}
+
+func (self *chunkVisitor) OnStringChunk(chunk string, last bool) error {
+    self.chunks = append(self.chunks, chunk)
+    if last {
+        self.add("string %q", strings.Join(self.chunks[self.first:], ""))
+        self.first = len(self.chunks)
+    }
+    return nil
+}
+
+func TestPreorderReader_Events(t *testing.T) {
+    cases := []string{
+        `[1, -2.5e3, 9007199254740993, 0, -0.0, true, false, null, "", "a\"\\\/\b\f\n\r\té😀\ud800x"]`,
+        `{"a" : {"b": [[], {}, [{}]]}, "":"中文", "c\n": -1} `,
+    }
+    for _, c := range visitorTestCases {
+        cases = append(cases, c.jsonStr)
+    }
+
+    for _, src := range cases {
+        for _, opts := range []*VisitorOptions{nil, {OnlyNumber: true}, {BufferSize: 1}} {
+            for _, skip := range []bool{false, true} {
+                exp := &eventVisitor{skip: skip}
+                require.NoError(t, Preorder(src, exp, opts))
+
+                /* the one-byte reader splits all the tokens */
+                act := &eventVisitor{skip: skip}
+                require.NoError(t, PreorderReader(iotest.OneByteReader(strings.NewReader(src)), act, opts))
+                require.Equal(t, exp.events, act.events)
+
+                act = &eventVisitor{skip: skip}
+                require.NoError(t, PreorderReader(strings.NewReader(src), act, opts))
+                require.Equal(t, exp.events, act.events)
+            }
+        }
+    }
+}
+
+func TestPreorderReader_LongString(t *testing.T) {
+    long := strings.Repeat("a中\\n", 100)
+    src := `{"` + long + `": ["` + long + `", "` + long + `"]}`
+    exp := &eventVisitor{}
+    require.NoError(t, Preorder(src, exp, nil))
+
+    /* delivered whole */
+    act := &eventVisitor{}
+    require.NoError(t, PreorderReader(strings.NewReader(src), act, &VisitorOptions{BufferSize: 64}))
+    assert.Equal(t, exp.events, act.events)
+
+    /* delivered in chunks, but the keys are whole */
+    cv := &chunkVisitor{}
+    require.NoError(t, PreorderReader(iotest.OneByteReader(strings.NewReader(src)), cv, &VisitorOptions{BufferSize: 64}))
+    assert.Equal(t, exp.events, cv.events)
+    assert.True(t, len(cv.chunks) > 2)
+    for _, chunk := range cv.chunks {
+        assert.True(t, utf8.ValidString(chunk))
+        assert.True(t, len(chunk) < 128)
+    }
+}
+
+func TestPreorderReader_Errors(t *testing.T) {
+    cases := []struct {
+        src string
+        err error
+    }{
+        {``, types.ERR_EOF},
+        {`[1,`, types.ERR_EOF},
+        {`{"a":1`, types.ERR_EOF},
+        {`"abc`, types.ERR_EOF},
+        {`[1 2]`, types.ERR_INVALID_CHAR},
+        {`{"a" 1}`, types.ERR_INVALID_CHAR},
+        {`{1:1}`, types.ERR_INVALID_CHAR},
+        {`nul`, types.ERR_INVALID_CHAR},
+        {`x`, types.ERR_INVALID_CHAR},
+        {"\"a\nb\"", types.ERR_INVALID_CHAR},
+        {`01`, types.ERR_INVALID_NUMBER_FMT},
+        {`1.e1`, types.ERR_INVALID_NUMBER_FMT},
+        {`-`, types.ERR_INVALID_NUMBER_FMT},
+        {"1" + strings.Repeat("0", 100), types.ERR_INVALID_NUMBER_FMT},
+        {`"\x"`, types.ERR_INVALID_ESCAPE},
+        {`"\u12g4"`, types.ERR_INVALID_UNICODE},
+        {`1e400`, types.ERR_FLOAT_INFINITY},
+    }
+    for _, c := range cases {
+        err := PreorderReader(strings.NewReader(c.src), &eventVisitor{}, &VisitorOptions{BufferSize: 64})
+        assert.Equal(t, c.err, err, c.src)
+    }
+
+    /* the errors of reading and visiting are returned as is */
+    rerr := errors.New("read error")
+    err := PreorderReader(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader(`[1]`))), &eventVisitor{}, nil)
+    assert.Equal(t, iotest.ErrTimeout, err)
+    err = PreorderReader(iotest.ErrReader(rerr), &eventVisitor{}, nil)
+    assert.Equal(t, rerr, err)
+}
@@ -26,6 +26,7 @@ import (
     `strings`
     `testing`
 
+    `github.com/bytedance/sonic/internal/native/types`
     `github.com/stretchr/testify/assert`
     `github.com/stretchr/testify/require`
 )
@@ -760,6 +761,63 @@ func TestVisitor_OpSkip(t *testing.T) {
     }
 }
 
+// pathVisitor records the paths of values, skips the members named "skip"
+// and stops at the string "stop".
+type pathVisitor struct {
+    eventVisitor
+    opts VisitorOptions
+}
+
+func (self *pathVisitor) record() {
+    self.add("%v", self.opts.Path.Path())
+}
+
+func (self *pathVisitor) OnNull() error { self.record(); return nil }
+
+func (self *pathVisitor) OnString(v string) error {
+    self.record()
+    if v == "stop" {
+        return Stop
+    }
+    return nil
+}
+
+func (self *pathVisitor) OnInt64(v int64, n json.Number) error { self.record(); return nil }
+
+func (self *pathVisitor) OnObjectKey(key string) error {
+    if key == "skip" {
+        return SkipChildren
+    }
+    return nil
+}
+
+func (self *pathVisitor) OnArrayBegin(capacity int) error {
+    self.record()
+    return nil
+}
+
+func TestVisitor_ControlFlow(t *testing.T) {
+    src := `{"a": [1, {"b": null, "skip": {"x": [1, 2]}}, []], "skip": "x", "c": {"d": "stop", "e": 1}, "f": 2}`
+    exp := []string{"{", "[a]", "[a 0]", "{", "[a 1 b]", "}", "[a 2]", "]", "]", "{", "[c d]"}
+
+    v := &pathVisitor{opts: VisitorOptions{Path: &VisitorPath{}}}
+    require.NoError(t, Preorder(src, v, &v.opts))
+    assert.Equal(t, exp, v.events)
+    assert.Equal(t, 2, v.opts.Path.Len())
+
+    /* the same as PreorderReader, and the path is reset */
+    v.events = nil
+    require.NoError(t, PreorderReader(strings.NewReader(src), v, &v.opts))
+    assert.Equal(t, exp, v.events)
+
+    /* the skipped values are still checked */
+    v.events = nil
+    err := Preorder(`{"skip": [1, }`, v, &v.opts)
+    assert.Error(t, err)
+    err = PreorderReader(strings.NewReader(`{"skip": [1, `), v, &v.opts)
+    assert.Equal(t, types.ERR_EOF, err)
+}
+
 func BenchmarkVisitor_UserNode(b *testing.B) {
     const str = _TwitterJson
     b.Run("AST", func(b *testing.B) {
@@ -24,6 +24,8 @@ import (
     `io`
     `reflect`
 
+    `github.com/bytedance/sonic/decoder`
+    `github.com/bytedance/sonic/encoder`
     `github.com/bytedance/sonic/option`
 )
 
@@ -31,11 +33,56 @@ const apiKind = UseStdJSON
 
 type frozenConfig struct {
     Config
+    encoder    *encoder.Encoder // the shared fallback encoder, or nil
+    encoderErr error            // the error of the invalid encoder options
+}
+
+// newEncoder returns the fallback encoder for the options which encoding/json does
+// not support, or nil if it is not needed. It is built only once by Froze.
+func (cfg frozenConfig) newEncoder() (*encoder.Encoder, error) {
+    if !cfg.RedactSensitive && !cfg.int64AsString() && cfg.FloatFormat == (encoder.FloatFormat{}) &&
+        cfg.EncoderMaxDepth <= 0 && cfg.EncoderMaxSize <= 0 {
+        return nil, nil
+    }
+    enc := &encoder.Encoder{}
+    if err := cfg.setupEncoder(enc); err != nil {
+        return nil, err
+    }
+    return enc, nil
+}
+
+func (cfg frozenConfig) setupEncoder(enc *encoder.Encoder) error {
+    enc.SetEscapeHTML(cfg.EscapeHTML)
+    enc.SetRedactSensitive(cfg.RedactSensitive)
+    enc.SetRedactor(cfg.Redactor)
+    enc.SetInt64AsString(cfg.Int64AsString)
+    enc.SetLargeInt64AsString(cfg.LargeInt64AsString)
+    enc.SetMaxDepth(cfg.EncoderMaxDepth)
+    enc.SetMaxSize(cfg.EncoderMaxSize)
+    return enc.SetFloatFormat(cfg.FloatFormat)
+}
+
+func (cfg frozenConfig) int64AsString() bool {
+    return cfg.Int64AsString || cfg.LargeInt64AsString
+}
+
+// decoderOpts returns the options of the fallback decoder, which is only needed
+// to accept the strings of the 64-bit integers.
+func (cfg frozenConfig) decoderOpts() decoder.Options {
+    opts := decoder.OptionInt64FromString
+    if cfg.UseNumber {
+        opts |= decoder.OptionUseNumber
+    }
+    if cfg.DisallowUnknownFields {
+        opts |= decoder.OptionDisableUnknown
+    }
+    return opts
 }
 
 // Froze convert the Config to API
 func (cfg Config) Froze() API {
     api := &frozenConfig{Config: cfg}
+    api.encoder, api.encoderErr = api.newEncoder()
     return api
 }
 
@@ -57,6 +104,12 @@ func (cfg frozenConfig) marshalOptions(val interface{}, prefix, indent string) (
 
 // Marshal is implemented by sonic
 func (cfg frozenConfig) Marshal(val interface{}) ([]byte, error) {
+    if cfg.encoderErr != nil {
+        return nil, cfg.encoderErr
+    }
+    if enc := cfg.encoder; enc != nil {
+        return enc.Encode(val)
+    }
     if !cfg.EscapeHTML {
         return cfg.marshalOptions(val, "", "")
     }
@@ -71,6 +124,18 @@ func (cfg frozenConfig) MarshalToString(val interface{}) (string, error) {
 
 // MarshalIndent is implemented by sonic
 func (cfg frozenConfig) MarshalIndent(val interface{}, prefix, indent string) ([]byte, error) {
+    if cfg.encoderErr != nil {
+        return nil, cfg.encoderErr
+    }
+    if enc := cfg.encoder; enc != nil {
+        out, err := enc.EncodeIndented(val, prefix, indent)
+        if err != nil {
+            return nil, err
+        }
+
+        /* the output is terminated by a newline like json.Encoder */
+        return out[:len(out) - 1], nil
+    }
     if !cfg.EscapeHTML {
         return cfg.marshalOptions(val, prefix, indent)
     }
@@ -79,6 +144,11 @@ func (cfg frozenConfig) MarshalIndent(val interface{}, prefix, indent string) ([
 
 // UnmarshalFromString is implemented by sonic
 func (cfg frozenConfig) UnmarshalFromString(buf string, val interface{}) error {
+    if cfg.int64AsString() {
+        dec := decoder.NewDecoder(buf)
+        dec.SetOptions(cfg.decoderOpts())
+        return dec.Decode(val)
+    }
     r := bytes.NewBufferString(buf)
     dec := json.NewDecoder(r)
     if cfg.UseNumber {
@@ -97,16 +167,57 @@ func (cfg frozenConfig) Unmarshal(buf []byte, val interface{}) error {
 
 // NewEncoder is implemented by sonic
 func (cfg frozenConfig) NewEncoder(writer io.Writer) Encoder {
-    enc := json.NewEncoder(writer)
+    if cfg.encoderErr != nil {
+        return invalidEncoder{cfg.encoderErr}
+    }
+    if cfg.encoder != nil {
+        return &streamEncoder{w: writer, enc: *cfg.encoder}
+    }
+    enc := encoder.NewStreamEncoder(writer)
     if !cfg.EscapeHTML {
         enc.SetEscapeHTML(cfg.EscapeHTML)
     }
     return enc
 }
 
+// streamEncoder writes the output of the fallback encoder to the stream,
+// for the options which encoding/json does not support.
+type streamEncoder struct {
+    w      io.Writer
+    enc    encoder.Encoder
+    prefix string
+    indent string
+}
+
+func (self *streamEncoder) Encode(val interface{}) error {
+    var out []byte
+    var err error
+    if self.prefix != "" || self.indent != "" {
+        out, err = self.enc.EncodeIndented(val, self.prefix, self.indent)
+    } else if out, err = self.enc.Encode(val); err == nil {
+        out = append(out, '\n')
+    }
+    if err != nil {
+        return err
+    }
+    _, err = self.w.Write(out)
+    return err
+}
+
+func (self *streamEncoder) SetEscapeHTML(on bool) {
+    self.enc.SetEscapeHTML(on)
+}
+
+func (self *streamEncoder) SetIndent(prefix, indent string) {
+    self.prefix, self.indent = prefix, indent
+}
+
 // NewDecoder is implemented by sonic
 func (cfg frozenConfig) NewDecoder(reader io.Reader) Decoder {
     dec := json.NewDecoder(reader)
+    if cfg.int64AsString() {
+        return &streamDecoder{Decoder: dec, opts: cfg.decoderOpts()}
+    }
     if cfg.UseNumber {
         dec.UseNumber()
     }
@@ -116,6 +227,31 @@ func (cfg frozenConfig) NewDecoder(reader io.Reader) Decoder {
     return dec
 }
 
+// streamDecoder reads each value with encoding/json, and decodes it with the
+// fallback decoder to accept the strings of the 64-bit integers.
+type streamDecoder struct {
+    *json.Decoder
+    opts decoder.Options
+}
+
+func (self *streamDecoder) Decode(val interface{}) error {
+    var raw json.RawMessage
+    if err := self.Decoder.Decode(&raw); err != nil {
+        return err
+    }
+    dec := decoder.NewDecoder(string(raw))
+    dec.SetOptions(self.opts)
+    return dec.Decode(val)
+}
+
+func (self *streamDecoder) UseNumber() {
+    self.opts |= decoder.OptionUseNumber
+}
+
+func (self *streamDecoder) DisallowUnknownFields() {
+    self.opts |= decoder.OptionDisableUnknown
+}
+
 // Valid is implemented by sonic
 func (cfg frozenConfig) Valid(data []byte) bool {
     return json.Valid(data)
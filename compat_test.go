@@ -22,6 +22,8 @@ import (
     `reflect`
     `testing`
 
+    `github.com/bytedance/sonic/ast`
+    `github.com/bytedance/sonic/encoder`
     `github.com/bytedance/sonic/option`
     `github.com/stretchr/testify/require`
 )
@@ -132,6 +134,131 @@ func TestCompatEncoderStd(t *testing.T) {
     require.Equal(t, w1.String(), w2.String())
 }
 
+func TestCompatRedactSensitive(t *testing.T) {
+    type cred struct {
+        User     string `json:"user"`
+        Password string `json:"password,sensitive"`
+    }
+    api := Config{RedactSensitive: true}.Froze()
+    out, err := api.Marshal(cred{"a", "secret"})
+    require.Nil(t, err)
+    require.Equal(t, `{"user":"a","password":"***"}`, string(out))
+
+    var w = bytes.NewBuffer(nil)
+    require.Nil(t, api.NewEncoder(w).Encode(cred{"a", "secret"}))
+    require.Equal(t, "{\"user\":\"a\",\"password\":\"***\"}\n", w.String())
+}
+
+func TestCompatInt64AsString(t *testing.T) {
+    type order struct {
+        ID     int64             `json:"id"`
+        Total  uint64            `json:"total"`
+        Small  int32             `json:"small"`
+        Tagged int64             `json:"tagged,string"`
+        Ref    *int64            `json:"ref"`
+        Attrs  map[string]uint64 `json:"attrs"`
+        Extra  interface{}       `json:"extra"`
+    }
+    ref := int64(-1 << 53)
+    obj := order{ID: 1 << 60, Total: 1, Small: 7, Tagged: 5, Ref: &ref, Attrs: map[string]uint64{"a": 1}, Extra: int64(1 << 62)}
+
+    api := Config{Int64AsString: true}.Froze()
+    out, err := api.Marshal(obj)
+    require.Nil(t, err)
+    require.Equal(t, `{"id":"1152921504606846976","total":"1","small":7,"tagged":"5","ref":"-9007199254740992",`+
+        `"attrs":{"a":"1"},"extra":"4611686018427387904"}`, string(out))
+
+    var ret order
+    require.Nil(t, api.Unmarshal(out, &ret))
+    require.Equal(t, "4611686018427387904", ret.Extra)
+    ret.Extra = obj.Extra
+    require.Equal(t, obj, ret)
+
+    api = Config{LargeInt64AsString: true}.Froze()
+    out, err = api.Marshal(obj)
+    require.Nil(t, err)
+    require.Equal(t, `{"id":"1152921504606846976","total":1,"small":7,"tagged":"5","ref":"-9007199254740992",`+
+        `"attrs":{"a":1},"extra":"4611686018427387904"}`, string(out))
+
+    ret = order{}
+    require.Nil(t, api.NewDecoder(bytes.NewReader(out)).Decode(&ret))
+    ret.Extra = obj.Extra
+    require.Equal(t, obj, ret)
+
+    /* only the integer strings are accepted, and null keeps the pointers nil */
+    ret = order{}
+    require.NotNil(t, api.UnmarshalFromString(`{"id":"1.5"}`, &ret))
+    require.Nil(t, api.UnmarshalFromString(`{"ref":null,"small":1}`, &ret))
+    require.Nil(t, ret.Ref)
+    require.Equal(t, int32(1), ret.Small)
+    require.NotNil(t, api.UnmarshalFromString(`{"small":"1"}`, &ret))
+}
+
+func TestCompatMarshalLimits(t *testing.T) {
+    type object struct {
+        A []interface{}    `json:"a"`
+        B map[string][]int `json:"b"`
+    }
+    obj := object{A: []interface{}{map[string]interface{}{"x": []int{1}}}, B: map[string][]int{"y": {}}}
+    exp, err := json.Marshal(obj)
+    require.Nil(t, err)
+
+    /* the depth of the values in {"a":[{"x":[1]}]} is 4 */
+    out, err := Config{EncoderMaxDepth: 4}.Froze().Marshal(obj)
+    require.Nil(t, err)
+    require.Equal(t, string(exp), string(out))
+    _, err = Config{EncoderMaxDepth: 4}.Froze().MarshalIndent(obj, "", "  ")
+    require.Nil(t, err)
+    _, err = Config{EncoderMaxDepth: 3}.Froze().Marshal(obj)
+    require.Equal(t, &encoder.LimitError{Limit: "depth", Max: 3}, err)
+    _, err = Config{EncoderMaxDepth: 3}.Froze().MarshalIndent(obj, "", "  ")
+    require.Equal(t, &encoder.LimitError{Limit: "depth", Max: 3}, err)
+
+    /* the empty containers do not contain any value */
+    out, err = Config{EncoderMaxDepth: 1}.Froze().Marshal(object{A: []interface{}{}, B: map[string][]int{}})
+    require.Nil(t, err)
+    require.Equal(t, `{"a":[],"b":{}}`, string(out))
+
+    _, err = Config{EncoderMaxSize: len(exp) - 1}.Froze().Marshal(obj)
+    require.Equal(t, &encoder.LimitError{Limit: "size", Max: len(exp) - 1}, err)
+
+    /* the encoding stops soon after the size is exceeded */
+    calls := 0
+    items := make([]countedMarshaler, 1 << 12)
+    for i := range items {
+        items[i].calls = &calls
+    }
+    _, err = Config{EncoderMaxSize: 64}.Froze().Marshal(items)
+    require.Equal(t, &encoder.LimitError{Limit: "size", Max: 64}, err)
+    require.Less(t, calls, len(items) / 2)
+
+    /* the stream encoder terminates each value by a newline */
+    var buf bytes.Buffer
+    enc := Config{EncoderMaxSize: 64}.Froze().NewEncoder(&buf)
+    require.Nil(t, enc.Encode(obj))
+    enc.SetIndent("", " ")
+    require.Nil(t, enc.Encode([]int{1}))
+    require.Equal(t, string(exp) + "\n[\n 1\n]\n", buf.String())
+}
+
+func TestCompatMarshalFloatFormatInvalid(t *testing.T) {
+    api := Config{FloatFormat: encoder.FloatFormat{Fixed: true, Precision: 3}}.Froze()
+    _, err := api.Marshal(1.5)
+    require.Error(t, err)
+    _, err = api.MarshalIndent(1.5, "", " ")
+    require.Error(t, err)
+    require.Error(t, api.NewEncoder(&bytes.Buffer{}).Encode(1.5))
+}
+
+type countedMarshaler struct {
+    calls *int
+}
+
+func (self countedMarshaler) MarshalJSON() ([]byte, error) {
+    *self.calls++
+    return []byte(`"0123456789abcd"`), nil
+}
+
 func TestCompatDecoderStd(t *testing.T) {
     var o1 = map[string]interface{}{}
     var o2 = map[string]interface{}{}
@@ -186,3 +313,56 @@ func TestGet(t *testing.T) {
     }
 }
 
+func TestGetByPointer(t *testing.T) {
+    var data = []byte(`{"a":{"b/c":[1,{"d~":"e"}]}}`)
+    r, err := GetByPointer(data, "/a/b~1c/1/d~0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    v, err := r.String()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if v != "e" {
+        t.Fatal(v)
+    }
+    if _, err := GetByPointer(data, "/a/x"); err == nil {
+        t.Fatal("expect error")
+    }
+}
+
+func TestEditByPath(t *testing.T) {
+    var data = []byte("{\n  \"a\": {\"b\": [1, 2]},\n  \"c\": true\n}")
+    out, err := SetByPath(data, map[string]int{"x": 1}, "c")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if exp := "{\n  \"a\": {\"b\": [1, 2]},\n  \"c\": {\"x\":1}\n}"; string(out) != exp {
+        t.Fatal(string(out))
+    }
+    out, err = SetByPathWithOptions(data, "v", ast.EditOptions{CreateMissing: true}, "d", "e")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if exp := "{\n  \"a\": {\"b\": [1, 2]},\n  \"c\": true,\n  \"d\":{\"e\":\"v\"}\n}"; string(out) != exp {
+        t.Fatal(string(out))
+    }
+    out, err = InsertByPath(data, 0, "a", "b", 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if exp := "{\n  \"a\": {\"b\": [0, 1, 2]},\n  \"c\": true\n}"; string(out) != exp {
+        t.Fatal(string(out))
+    }
+    out, err = DeleteByPath(data, "a")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if exp := "{\n  \"c\": true\n}"; string(out) != exp {
+        t.Fatal(string(out))
+    }
+    if _, err := SetByPath(data, 1, "x", "y"); err == nil {
+        t.Fatal("expect error")
+    }
+}
+
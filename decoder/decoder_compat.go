@@ -38,6 +38,7 @@ const (
      _F_disable_urc     = 2
      _F_disable_unknown = 3
      _F_copy_string     = 4
+     _F_int64_string    = 7
  
      _F_use_number      = types.B_USE_NUMBER
      _F_validate_string = types.B_VALIDATE_STRING
@@ -55,6 +56,7 @@ const (
      OptionCopyString       Options = 1 << _F_copy_string
      OptionValidateString   Options = 1 << _F_validate_string
      OptionNoValidateJSON   Options = 1 << _F_no_validate_json
+     OptionInt64FromString  Options = 1 << _F_int64_string
 )
 
 func (self *Decoder) SetOptions(opts Options) {
@@ -120,7 +122,16 @@ func (self *Decoder) Decode(val interface{}) error {
    if (self.f & uint64(OptionDisableUnknown)) != 0  {
        dec.DisallowUnknownFields()
    }
-   return dec.Decode(val)
+   if (self.f & uint64(OptionInt64FromString)) == 0 {
+       return dec.Decode(val)
+   }
+
+   /* the 64-bit integers are decoded by reflection to accept their strings */
+   rv := reflect.ValueOf(val)
+   if rv.Kind() != reflect.Ptr || rv.IsNil() {
+       return &json.InvalidUnmarshalError{Type: reflect.TypeOf(val)}
+   }
+   return self.newMaskedDecoder(dec).value(rv.Elem(), nil)
 }
 
 // UseInt64 indicates the Decoder to unmarshal an integer into an interface{} as an
@@ -162,6 +173,13 @@ func (self *Decoder) ValidateString() {
      self.f |= 1 << _F_validate_string
 }
 
+// Int64FromString indicates the Decoder to also accept the JSON strings of integers
+// (such as "123") for the 64-bit integers (int64, uint64, and int, uint on 64-bit
+// platforms), which is the counterpart of the encoder option Int64AsString.
+func (self *Decoder) Int64FromString() {
+     self.f |= 1 << _F_int64_string
+}
+
 // Pretouch compiles vt ahead-of-time to avoid JIT compilation on-the-fly, in
 // order to reduce the first-hit latency.
 //
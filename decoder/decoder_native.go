@@ -44,6 +44,7 @@ const (
     OptionCopyString       Options = api.OptionCopyString
     OptionValidateString   Options = api.OptionValidateString
     OptionNoValidateJSON   Options = api.OptionNoValidateJSON
+    OptionInt64FromString  Options = api.OptionInt64FromString
 )
 
 // StreamDecoder is the decoder context object for streaming input.
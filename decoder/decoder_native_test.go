@@ -339,6 +339,50 @@ func TestDecoder_SetOption(t *testing.T) {
     assert.Equal(t, v, int64(123))
 }
 
+func TestDecoder_Int64FromString(t *testing.T) {
+    type obj struct {
+        A int64            `json:"a"`
+        B *uint64          `json:"b"`
+        C []int            `json:"c"`
+        D map[string]int64 `json:"d"`
+        E int64            `json:"e,string"`
+        F int32            `json:"f"`
+    }
+    src := `{"a": "-9223372036854775808", "b" : "18446744073709551615", "c":[1, "2"], "d":{"x": "3"}, "e":"4", "f":5}`
+
+    var v obj
+    d := NewDecoder(src)
+    d.Int64FromString()
+    require.NoError(t, d.Decode(&v))
+    require.Equal(t, int64(-1 << 63), v.A)
+    require.Equal(t, uint64(1 << 64 - 1), *v.B)
+    require.Equal(t, []int{1, 2}, v.C)
+    require.Equal(t, map[string]int64{"x": 3}, v.D)
+    require.Equal(t, int64(4), v.E)
+    require.Equal(t, int32(5), v.F)
+
+    /* mismatched strings are skipped with the error */
+    v = obj{}
+    d = NewDecoder(`{"a":"1.5","f":"5","b":"-1","e":"6"}`)
+    d.Int64FromString()
+    err := d.Decode(&v)
+    require.IsType(t, &MismatchTypeError{}, err)
+    require.Equal(t, int64(0), v.A)
+    require.Equal(t, int32(0), v.F)
+    require.Equal(t, int64(6), v.E)
+
+    /* the quoted integers are not accepted by default */
+    v = obj{}
+    err = NewDecoder(`{"a":"1"}`).Decode(&v)
+    require.IsType(t, &MismatchTypeError{}, err)
+
+    var x interface{}
+    d = NewDecoder(`"1"`)
+    d.Int64FromString()
+    require.NoError(t, d.Decode(&x))
+    require.Equal(t, "1", x)
+}
+
 func BenchmarkSkip_Sonic(b *testing.B) {
     var data = rt.Str2Mem(TwitterJson)
     if ret, _ := Skip(data); ret < 0 {
@@ -295,6 +295,120 @@ func TestDecoder_MapWithIndirectElement(t *testing.T) {
     assert.Equal(t, [129]byte{1, 2, 3, 4, 5}, v[""].A)
 }
 
+type maskOwner struct {
+    ID    int    `json:"id"`
+    Email string `json:"email"`
+}
+
+type maskItem struct {
+    ID   int      `json:"id"`
+    Tags []string `json:"tags"`
+}
+
+type maskNode struct {
+    Name     string      `json:"name"`
+    Children []*maskNode `json:"children"`
+}
+
+type maskDoc struct {
+    ID    int               `json:"id"`
+    Name  string            `json:"name"`
+    Owner *maskOwner        `json:"owner"`
+    Items []maskItem        `json:"items"`
+    Attrs map[string]string `json:"attrs"`
+    Node  maskNode          `json:"node"`
+}
+
+const maskJson = `{
+    "id": 1,
+    "name": "doc",
+    "owner": {"id": 2, "email": "x@y.z"},
+    "items": [{"id": 3, "tags": ["a"]}, {"id": 4, "tags": ["b"]}],
+    "attrs": {"k": "v"},
+    "node": {"name": "root", "children": [{"name": "leaf", "children": []}]}
+}`
+
+func TestDecoder_DecodeWithFields(t *testing.T) {
+    var v = maskDoc{Name: "old", Items: []maskItem{{Tags: []string{"old"}}}}
+    d := NewDecoder(maskJson)
+    err := d.DecodeWithFields(&v, []string{"id", "owner.email", "items.id", "attrs", "node.children.name"})
+    require.NoError(t, err)
+    assert.Equal(t, maskDoc{
+        ID: 1,
+        Name: "old",
+        Owner: &maskOwner{Email: "x@y.z"},
+        Items: []maskItem{{ID: 3, Tags: []string{"old"}}, {ID: 4}},
+        Attrs: map[string]string{"k": "v"},
+        Node: maskNode{Children: []*maskNode{{Name: "leaf"}}},
+    }, v)
+    assert.Equal(t, len(maskJson), d.Pos())
+
+    /* the masked fields are still known fields */
+    var w maskDoc
+    d = NewDecoder(maskJson)
+    d.DisallowUnknownFields()
+    require.NoError(t, d.DecodeWithFields(&w, []string{"name"}))
+    assert.Equal(t, maskDoc{Name: "doc"}, w)
+
+    d = NewDecoder(`{"name":"doc","unknown":1}`)
+    d.DisallowUnknownFields()
+    require.Error(t, d.DecodeWithFields(&w, []string{"name"}))
+
+    /* the selected fields are still checked */
+    d = NewDecoder(`{"id":"1","name":"doc"}`)
+    require.Error(t, d.DecodeWithFields(&w, []string{"id"}))
+    d = NewDecoder(`{"id":1,"name":"doc"}`)
+    require.Error(t, d.DecodeWithFields(&w, []string{"id."}))
+
+    /* the fields of embedded structs are selected by their promoted names */
+    type embedded struct {
+        maskOwner
+        *maskItem
+        Name string `json:"name"`
+    }
+    var e embedded
+    d = NewDecoder(`{"id":1,"email":"x@y.z","tags":["a"],"name":"doc"}`)
+    require.NoError(t, d.DecodeWithFields(&e, []string{"email", "tags", "unknown"}))
+    assert.Equal(t, embedded{maskOwner: maskOwner{Email: "x@y.z"}, maskItem: &maskItem{Tags: []string{"a"}}}, e)
+}
+
+func TestDecoder_Int64FromStringBoth(t *testing.T) {
+    type obj struct {
+        A int64         `json:"a"`
+        B *uint64       `json:"b"`
+        C []int         `json:"c"`
+        D map[int]int64 `json:"d"`
+        E *int64        `json:"e"`
+        F interface{}   `json:"f"`
+    }
+    var v obj
+    d := NewDecoder(`{"a":"-9223372036854775808","b":"18446744073709551615","c":[1,"2"],"d":{"7":"3"},"e":null,"f":"4"}`)
+    d.Int64FromString()
+    require.NoError(t, d.Decode(&v))
+    assert.Equal(t, obj{A: -1 << 63, B: &[]uint64{1 << 64 - 1}[0], C: []int{1, 2}, D: map[int]int64{7: 3}, F: "4"}, v)
+
+    /* the selected fields also accept the strings */
+    var w maskDoc
+    d = NewDecoder(`{"id":"1","name":"doc"}`)
+    d.Int64FromString()
+    require.NoError(t, d.DecodeWithFields(&w, []string{"id"}))
+    assert.Equal(t, maskDoc{ID: 1}, w)
+
+    d = NewDecoder(`{"a":"1.5"}`)
+    d.Int64FromString()
+    require.Error(t, d.Decode(&v))
+}
+
+func BenchmarkDecoder_DecodeWithFields_Sonic(b *testing.B) {
+    fields := []string{"id", "owner.email"}
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        var v maskDoc
+        _ = NewDecoder(maskJson).DecodeWithFields(&v, fields)
+    }
+}
+
 func BenchmarkDecoder_Generic_Sonic(b *testing.B) {
     var w interface{}
     _, _ = decode(TwitterJson, &w, true)
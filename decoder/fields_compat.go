@@ -0,0 +1,427 @@
+// +build !amd64,!arm64 go1.24 !go1.17 arm64,!go1.20
+
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+    `bytes`
+    `encoding`
+    `encoding/json`
+    `fmt`
+    `reflect`
+    `strconv`
+    `strings`
+    `unsafe`
+
+    `github.com/bytedance/sonic/internal/resolver`
+)
+
+// DecodeWithFields is like Decode but only decodes the struct fields selected by fields,
+// each path of fields is a dot-separated list of JSON field names (such as "owner.email").
+// The values of other fields are left untouched.
+func (self *Decoder) DecodeWithFields(val interface{}, fields []string) error {
+    ft, err := resolver.NewFieldTree(fields)
+    if err != nil {
+        return err
+    }
+
+    /* must be a non-nil pointer */
+    rv := reflect.ValueOf(val)
+    if rv.Kind() != reflect.Ptr || rv.IsNil() {
+        return &json.InvalidUnmarshalError{Type: reflect.TypeOf(val)}
+    }
+
+    /* decode the value in a single pass from the current position */
+    dec := json.NewDecoder(bytes.NewBufferString(self.s[self.i:]))
+    if (self.f & uint64(OptionUseNumber)) != 0 {
+        dec.UseNumber()
+    }
+    if (self.f & uint64(OptionDisableUnknown)) != 0 {
+        dec.DisallowUnknownFields()
+    }
+    md := self.newMaskedDecoder(dec)
+    err = md.value(rv.Elem(), ft)
+    self.i += int(dec.InputOffset())
+    return err
+}
+
+func (self *Decoder) newMaskedDecoder(dec *json.Decoder) *maskedDecoder {
+    return &maskedDecoder{
+        dec     : dec,
+        unknown : (self.f & uint64(OptionDisableUnknown)) != 0,
+        number  : (self.f & uint64(OptionUseNumber)) != 0,
+        int64s  : (self.f & uint64(OptionInt64FromString)) != 0,
+    }
+}
+
+// maskedDecoder decodes the selected struct fields by reflection, and the others
+// values as a whole with encoding/json. If int64s is set, all the values which may
+// contain the 64-bit integers are decoded by reflection, to accept their strings.
+type maskedDecoder struct {
+    dec     *json.Decoder
+    unknown bool
+    number  bool
+    int64s  bool
+    null    bool
+}
+
+var (
+    jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+    textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (self *maskedDecoder) value(rv reflect.Value, ft resolver.FieldTree) error {
+    if ft == nil && !self.int64s {
+        return self.dec.Decode(rv.Addr().Interface())
+    }
+    if ft == nil && isUnmarshaler(rv.Type()) {
+        return self.whole(rv)
+    }
+    switch rv.Kind() {
+        case reflect.Ptr    : return self.pointer(rv, ft)
+        case reflect.Struct : return self.object(rv, ft)
+        case reflect.Slice  : return self.slice(rv, ft)
+        case reflect.Array  : return self.array(rv, ft)
+    }
+
+    /* only the whole values reach here */
+    switch rv.Kind() {
+        case reflect.Map    : return self.mapping(rv)
+        case reflect.Int    ,
+             reflect.Int64  ,
+             reflect.Uint   ,
+             reflect.Uint64 : if rv.Type().Size() == 8 { return self.int64(rv) }
+    }
+    return self.whole(rv)
+}
+
+// whole decodes the value as a whole with encoding/json, and tells the pointer
+// holding it if the value is null.
+func (self *maskedDecoder) whole(rv reflect.Value) error {
+    var raw json.RawMessage
+    if err := self.dec.Decode(&raw); err != nil {
+        return err
+    }
+    self.null = string(raw) == "null"
+    dec := json.NewDecoder(bytes.NewReader(raw))
+    if self.number {
+        dec.UseNumber()
+    }
+    if self.unknown {
+        dec.DisallowUnknownFields()
+    }
+    return dec.Decode(rv.Addr().Interface())
+}
+
+// isUnmarshaler tells if the values of vt are decoded by their own methods.
+func isUnmarshaler(vt reflect.Type) bool {
+    pt := reflect.PtrTo(vt)
+    return pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+// int64 decodes the 64-bit integer from either a JSON number or a JSON string of it.
+func (self *maskedDecoder) int64(rv reflect.Value) error {
+    var raw json.RawMessage
+    if err := self.dec.Decode(&raw); err != nil {
+        return err
+    }
+    if string(raw) == "null" {
+        self.null = true
+        return nil
+    }
+    if raw[0] != '"' {
+        return json.Unmarshal(raw, rv.Addr().Interface())
+    }
+
+    /* the string must be exactly an integer literal */
+    var s string
+    if err := json.Unmarshal(raw, &s); err != nil {
+        return err
+    }
+    if rv.Kind() == reflect.Int || rv.Kind() == reflect.Int64 {
+        v, err := strconv.ParseInt(s, 10, 64)
+        if err != nil {
+            return &json.UnmarshalTypeError{Value: "string " + string(raw), Type: rv.Type(), Offset: self.dec.InputOffset()}
+        }
+        rv.SetInt(v)
+    } else {
+        v, err := strconv.ParseUint(s, 10, 64)
+        if err != nil {
+            return &json.UnmarshalTypeError{Value: "string " + string(raw), Type: rv.Type(), Offset: self.dec.InputOffset()}
+        }
+        rv.SetUint(v)
+    }
+    return nil
+}
+
+// mapping decodes the JSON object into the map rv, with the keys converted like
+// encoding/json does.
+func (self *maskedDecoder) mapping(rv reflect.Value) error {
+    tok, err := self.next(rv, '{')
+    if err != nil {
+        return err
+    }
+    if tok == nil {
+        rv.Set(reflect.Zero(rv.Type()))
+        return nil
+    }
+    if rv.IsNil() {
+        rv.Set(reflect.MakeMap(rv.Type()))
+    }
+
+    /* decode each member into a new element */
+    vt := rv.Type()
+    for self.dec.More() {
+        key, err := self.dec.Token()
+        if err != nil {
+            return err
+        }
+        kv, err := self.mapKey(vt.Key(), key.(string))
+        if err != nil {
+            return err
+        }
+        ev := reflect.New(vt.Elem()).Elem()
+        if err := self.value(ev, nil); err != nil {
+            return err
+        }
+        rv.SetMapIndex(kv, ev)
+    }
+    self.null = false
+    _, err = self.dec.Token()
+    return err
+}
+
+func (self *maskedDecoder) mapKey(kt reflect.Type, key string) (reflect.Value, error) {
+    kv := reflect.New(kt)
+    if tu, ok := kv.Interface().(encoding.TextUnmarshaler); ok {
+        return kv.Elem(), tu.UnmarshalText([]byte(key))
+    }
+    switch kt.Kind() {
+        case reflect.String:
+            kv.Elem().SetString(key)
+            return kv.Elem(), nil
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            v, err := strconv.ParseInt(key, 10, 64)
+            if err != nil || kv.Elem().OverflowInt(v) {
+                break
+            }
+            kv.Elem().SetInt(v)
+            return kv.Elem(), nil
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            v, err := strconv.ParseUint(key, 10, 64)
+            if err != nil || kv.Elem().OverflowUint(v) {
+                break
+            }
+            kv.Elem().SetUint(v)
+            return kv.Elem(), nil
+    }
+    return kv.Elem(), &json.UnmarshalTypeError{Value: "number " + key, Type: kt, Offset: self.dec.InputOffset()}
+}
+
+// next returns the next token if it is the delimiter d, or nil if it is null.
+func (self *maskedDecoder) next(rv reflect.Value, d json.Delim) (json.Token, error) {
+    tok, err := self.dec.Token()
+    if err != nil {
+        return nil, err
+    }
+    if tok == nil || tok == d {
+        self.null = tok == nil
+        return tok, nil
+    }
+    return nil, &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: rv.Type(), Offset: self.dec.InputOffset()}
+}
+
+func (self *maskedDecoder) pointer(rv reflect.Value, ft resolver.FieldTree) error {
+    pv := rv
+    if rv.IsNil() {
+        pv = reflect.New(rv.Type().Elem())
+    }
+
+    /* the pointer is set to nil if the value is null */
+    self.null = false
+    if err := self.value(pv.Elem(), ft); err != nil {
+        return err
+    }
+    if self.null {
+        rv.Set(reflect.Zero(rv.Type()))
+    } else {
+        rv.Set(pv)
+    }
+    self.null = false
+    return nil
+}
+
+func (self *maskedDecoder) object(rv reflect.Value, ft resolver.FieldTree) error {
+    tok, err := self.next(rv, '{')
+    if err != nil || tok == nil {
+        return err
+    }
+
+    /* decode or skip each member */
+    fv := resolver.ResolveStruct(rv.Type())
+    for self.dec.More() {
+        key, err := self.dec.Token()
+        if err != nil {
+            return err
+        }
+        fm := matchField(fv, key.(string))
+        if fm == nil && self.unknown {
+            return fmt.Errorf("json: unknown field %q", key)
+        }
+
+        /* skip the fields not selected */
+        st, ok := ft[fieldName(fm)]
+        if fm == nil || !(ok || ft == nil) {
+            var raw json.RawMessage
+            if err := self.dec.Decode(&raw); err != nil {
+                return err
+            }
+            continue
+        }
+
+        /* decode the selected field */
+        fp := reflect.NewAt(fm.Type, fieldAt(unsafe.Pointer(rv.UnsafeAddr()), fm)).Elem()
+        if fm.Opts & resolver.F_stringize != 0 && st == nil {
+            err = self.stringized(fp)
+        } else {
+            err = self.value(fp, st)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    self.null = false
+    _, err = self.dec.Token()
+    return err
+}
+
+// matchField finds the field of key, preferring an exact match like encoding/json.
+func matchField(fv []resolver.FieldMeta, key string) *resolver.FieldMeta {
+    for i := range fv {
+        if fv[i].Name == key {
+            return &fv[i]
+        }
+    }
+    for i := range fv {
+        if strings.EqualFold(fv[i].Name, key) {
+            return &fv[i]
+        }
+    }
+    return nil
+}
+
+func fieldName(fm *resolver.FieldMeta) string {
+    if fm == nil {
+        return ""
+    }
+    return fm.Name
+}
+
+// fieldAt returns the pointer to the field described by fm in the struct at p,
+// allocating the nil embedded struct pointers.
+func fieldAt(p unsafe.Pointer, fm *resolver.FieldMeta) unsafe.Pointer {
+    for _, off := range fm.Path {
+        p = unsafe.Pointer(uintptr(p) + off.Size)
+        if off.Kind != resolver.F_deref {
+            continue
+        }
+        pv := reflect.NewAt(reflect.PtrTo(off.Type), p).Elem()
+        if pv.IsNil() {
+            pv.Set(reflect.New(off.Type))
+        }
+        p = unsafe.Pointer(pv.Pointer())
+    }
+    return p
+}
+
+// stringized decodes the field with the `string` option from a JSON string.
+func (self *maskedDecoder) stringized(rv reflect.Value) error {
+    var raw json.RawMessage
+    if err := self.dec.Decode(&raw); err != nil {
+        return err
+    }
+    if string(raw) == "null" {
+        return nil
+    }
+    var s string
+    if err := json.Unmarshal(raw, &s); err != nil {
+        return &json.UnmarshalTypeError{Value: string(raw), Type: rv.Type(), Offset: self.dec.InputOffset()}
+    }
+    return json.Unmarshal([]byte(s), rv.Addr().Interface())
+}
+
+func (self *maskedDecoder) slice(rv reflect.Value, ft resolver.FieldTree) error {
+    tok, err := self.next(rv, '[')
+    if err != nil {
+        return err
+    }
+    if tok == nil {
+        rv.Set(reflect.Zero(rv.Type()))
+        return nil
+    }
+
+    /* reuse the existing elements, like encoding/json */
+    n := 0
+    for ; self.dec.More(); n++ {
+        if n >= rv.Cap() {
+            nv := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Cap() * 2 + 4)
+            reflect.Copy(nv, rv)
+            rv.Set(nv)
+        }
+        if n >= rv.Len() {
+            rv.SetLen(n + 1)
+            rv.Index(n).Set(reflect.Zero(rv.Type().Elem()))
+        }
+        if err := self.value(rv.Index(n), ft); err != nil {
+            return err
+        }
+    }
+    if n == 0 && rv.IsNil() {
+        rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+    }
+    rv.SetLen(n)
+    self.null = false
+    _, err = self.dec.Token()
+    return err
+}
+
+func (self *maskedDecoder) array(rv reflect.Value, ft resolver.FieldTree) error {
+    tok, err := self.next(rv, '[')
+    if err != nil || tok == nil {
+        return err
+    }
+
+    /* the extra elements are skipped, and the missing ones are zeroed */
+    n := 0
+    for ; self.dec.More(); n++ {
+        if n < rv.Len() {
+            err = self.value(rv.Index(n), ft)
+        } else {
+            err = self.dec.Decode(new(json.RawMessage))
+        }
+        if err != nil {
+            return err
+        }
+    }
+    for ; n < rv.Len(); n++ {
+        rv.Index(n).Set(reflect.Zero(rv.Type().Elem()))
+    }
+    self.null = false
+    _, err = self.dec.Token()
+    return err
+}
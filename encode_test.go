@@ -22,6 +22,7 @@ package sonic
 import (
     `bytes`
     `encoding`
+    `errors`
     `encoding/json`
     `fmt`
     `log`
@@ -1224,4 +1225,327 @@ func TestMarshalInfOrNan(t *testing.T) {
         assert.NotNil(t, err)
         assert.True(t, strings.Contains(err.Error(), "json: unsupported value: NaN or ±Infinite"))
     }
-}
\ No newline at end of file
+}
+
+func TestMarshalRedactSensitive(t *testing.T) {
+    type account struct {
+        Name   string `json:"name"`
+        Secret string `json:"secret,sensitive"`
+        Card   string `json:"card,sensitive"`
+    }
+    obj := account{Name: "a", Secret: "s", Card: "1234"}
+
+    redact := Config{RedactSensitive: true}.Froze()
+    out, err := redact.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"name":"a","secret":"***","card":"***"}`, string(out))
+
+    hook := Config{
+        RedactSensitive: true,
+        Redactor: func(vt reflect.Type, name string) []byte {
+            if name == "card" {
+                return []byte(`"****"`)
+            }
+            return nil
+        },
+    }.Froze()
+    out, err = hook.MarshalIndent(obj, "", "")
+    assert.Nil(t, err)
+    assert.Equal(t, "{\n\"name\": \"a\",\n\"card\": \"****\"\n}", string(out))
+
+    var w bytes.Buffer
+    assert.Nil(t, hook.NewEncoder(&w).Encode(obj))
+    assert.Equal(t, "{\"name\":\"a\",\"card\":\"****\"}\n", w.String())
+
+    /* trusted peers still get the full value */
+    out, err = ConfigDefault.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"name":"a","secret":"s","card":"1234"}`, string(out))
+}
+
+func TestMarshalEscape(t *testing.T) {
+    obj := map[string]interface{}{"path": "/tmp/é", "emoji": "😀", "quote": "it's"}
+
+    api := Config{SortMapKeys: true, EscapeASCII: true, EscapeSlash: true, EscapeChars: "'"}.Froze()
+    out, err := api.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"emoji":"\ud83d\ude00","path":"\/tmp\/\u00e9","quote":"it\u0027s"}`, string(out))
+
+    out, err = api.MarshalIndent([]string{"it's"}, "", " ")
+    assert.Nil(t, err)
+    assert.Equal(t, "[\n \"it\\u0027s\"\n]", string(out))
+
+    var w bytes.Buffer
+    assert.Nil(t, api.NewEncoder(&w).Encode("it's /é"))
+    assert.Equal(t, "\"it\\u0027s \\/\\u00e9\"\n", w.String())
+}
+
+func TestMarshalFloatFormat(t *testing.T) {
+    type bill struct {
+        Amount float64 `json:"amount,decimals=2"`
+        Rate   float64 `json:"rate"`
+    }
+    obj := bill{Amount: 12.5, Rate: 0.123456}
+
+    api := Config{FloatFormat: encoder.FloatFormat{Precision: 3}}.Froze()
+    out, err := api.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"amount":12.50,"rate":0.123}`, string(out))
+
+    var w bytes.Buffer
+    assert.Nil(t, api.NewEncoder(&w).Encode([]interface{}{obj.Rate, float32(2.0/3)}))
+    assert.Equal(t, "[0.123,0.667]\n", w.String())
+
+    out, err = ConfigDefault.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"amount":12.50,"rate":0.123456}`, string(out))
+
+    /* the invalid formats fail all the encodings */
+    api = Config{FloatFormat: encoder.FloatFormat{Decimals: 2}}.Froze()
+    _, err = api.Marshal(obj)
+    assert.Error(t, err)
+    _, err = api.MarshalIndent(obj, "", " ")
+    assert.Error(t, err)
+    assert.Error(t, api.NewEncoder(&w).Encode(obj))
+}
+
+func TestMarshalInt64AsString(t *testing.T) {
+    type order struct {
+        ID     int64             `json:"id"`
+        Total  uint64            `json:"total"`
+        Count  int               `json:"count"`
+        Small  int32             `json:"small"`
+        Tagged int64             `json:"tagged,string"`
+        Refs   []int64           `json:"refs"`
+        Attrs  map[string]uint64 `json:"attrs"`
+        Extra  interface{}       `json:"extra"`
+    }
+    obj := order{
+        ID: 1 << 60, Total: 1, Count: -1 << 53, Small: 7, Tagged: 5,
+        Refs: []int64{1, -(1 << 53)}, Attrs: map[string]uint64{"a": 1 << 53 - 1}, Extra: int64(1 << 62),
+    }
+
+    api := Config{Int64AsString: true}.Froze()
+    out, err := api.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"id":"1152921504606846976","total":"1","count":"-9007199254740992","small":7,"tagged":"5",`+
+        `"refs":["1","-9007199254740992"],"attrs":{"a":"9007199254740991"},"extra":"4611686018427387904"}`, string(out))
+
+    var ret order
+    assert.Nil(t, api.Unmarshal(out, &ret))
+    assert.Equal(t, obj.Refs, ret.Refs)
+    assert.Equal(t, obj.Attrs, ret.Attrs)
+    assert.Equal(t, "4611686018427387904", ret.Extra)
+    ret.Extra = obj.Extra
+    assert.Equal(t, obj, ret)
+
+    api = Config{LargeInt64AsString: true}.Froze()
+    out, err = api.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"id":"1152921504606846976","total":1,"count":"-9007199254740992","small":7,"tagged":"5",`+
+        `"refs":[1,"-9007199254740992"],"attrs":{"a":9007199254740991},"extra":"4611686018427387904"}`, string(out))
+
+    ret = order{}
+    assert.Nil(t, api.Unmarshal(out, &ret))
+    ret.Extra = obj.Extra
+    assert.Equal(t, obj, ret)
+
+    out, err = ConfigDefault.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, `{"id":1152921504606846976,"total":1,"count":-9007199254740992,"small":7,"tagged":"5",`+
+        `"refs":[1,-9007199254740992],"attrs":{"a":9007199254740991},"extra":4611686018427387904}`, string(out))
+}
+
+type indentMarshaler struct{}
+
+func (indentMarshaler) MarshalJSON() ([]byte, error) {
+    return []byte(` {"a" : [1, {}, {"b":[]}]} `), nil
+}
+
+type indentObject struct {
+    ID     int                    `json:"id"`
+    Tags   []string               `json:"tags"`
+    Empty  []int                  `json:"empty"`
+    None   []int                  `json:"none,omitempty"`
+    Pair   [2]float64             `json:"pair"`
+    Zero   [0]int                 `json:"zero"`
+    Attrs  map[string]interface{} `json:"attrs"`
+    Inner  struct{}               `json:"inner"`
+    Next   *indentObject          `json:"next"`
+    Any    interface{}            `json:"any"`
+    Custom indentMarshaler        `json:"custom"`
+    Raw    json.RawMessage        `json:"raw"`
+}
+
+func TestMarshalIndentSinglePass(t *testing.T) {
+    obj := indentObject{
+        ID:    1,
+        Tags:  []string{"x", "<y>"},
+        Empty: []int{},
+        Pair:  [2]float64{1.5, -2},
+        Attrs: map[string]interface{}{"k": []interface{}{1, map[string]int{}, map[string]int{"a": 1}}, "m": "n"},
+        Next:  &indentObject{Any: []int{}, Raw: json.RawMessage(`null`)},
+        Any:   map[string]interface{}{"q": indentObject{Raw: json.RawMessage(`[]`)}},
+        Raw:   json.RawMessage(` [ 1 , {"a" : 2} ] `),
+    }
+    for _, ind := range [][2]string{{"", "  "}, {"\t", "    "}, {">", "\t"}, {"", ""}} {
+        exp, err := json.MarshalIndent(obj, ind[0], ind[1])
+        assert.Nil(t, err)
+
+        /* escaping the output may not alter the prefix */
+        for _, api := range []API{ConfigStd, Config{SortMapKeys: true, EscapeHTML: true, CompactMarshaler: true}.Froze(), Config{SortMapKeys: true, EscapeHTML: true, EscapeChars: "~"}.Froze()} {
+            out, err := api.MarshalIndent(obj, ind[0], ind[1])
+            assert.Nil(t, err)
+            assert.Equal(t, string(exp), string(out))
+        }
+
+        if ind[0] == "" && ind[1] == "" {
+            continue
+        }
+        var buf bytes.Buffer
+        enc := ConfigStd.NewEncoder(&buf)
+        enc.SetIndent(ind[0], ind[1])
+        assert.Nil(t, enc.Encode(obj))
+        assert.Equal(t, string(exp)+"\n", buf.String())
+    }
+
+    /* the indented output does not change the compact one */
+    exp, err := json.Marshal(obj)
+    assert.Nil(t, err)
+    out, err := ConfigStd.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, string(exp), string(out))
+}
+
+type cyclicNode struct {
+    Name string        `json:"name"`
+    Next *cyclicNode   `json:"next"`
+    Kids []interface{} `json:"kids,omitempty"`
+}
+
+type cyclicSlice []cyclicSlice
+
+type cyclicGroup struct {
+    Members map[string][]interface{} `json:"members"`
+}
+
+func TestMarshalDetectCycles(t *testing.T) {
+    api := Config{DetectCycles: true}.Froze()
+
+    /* the deep but acyclic values are not affected */
+    var list *cyclicNode
+    for i := 0; i < 3*1000/10; i++ {
+        list = &cyclicNode{Name: strconv.Itoa(i), Next: list}
+    }
+    exp, err := json.Marshal(list)
+    assert.Nil(t, err)
+    out, err := api.Marshal(list)
+    assert.Nil(t, err)
+    assert.Equal(t, string(exp), string(out))
+
+    /* shared values are not cycles */
+    leaf := &cyclicNode{Name: "leaf"}
+    out, err = api.Marshal([]*cyclicNode{leaf, leaf})
+    assert.Nil(t, err)
+    assert.Equal(t, `[{"name":"leaf","next":null},{"name":"leaf","next":null}]`, string(out))
+
+    ptr := &cyclicNode{Name: "a", Next: &cyclicNode{Name: "b"}}
+    ptr.Next.Next = ptr
+    _, err = api.Marshal(ptr)
+    assert.Equal(t, "json: unsupported value: encountered a cycle via *sonic.cyclicNode -> *sonic.cyclicNode -> *sonic.cyclicNode", err.Error())
+
+    obj := map[string]interface{}{}
+    obj["self"] = []interface{}{obj}
+    _, err = api.Marshal(obj)
+    assert.Equal(t, "json: unsupported value: encountered a cycle via map[string]interface {} -> []interface {} -> map[string]interface {}", err.Error())
+
+    kid := &cyclicNode{Name: "kid"}
+    kid.Kids = []interface{}{kid}
+    _, err = api.Marshal(kid)
+    assert.Equal(t, "json: unsupported value: encountered a cycle via *sonic.cyclicNode -> []interface {} -> *sonic.cyclicNode", err.Error())
+
+    /* every container between the tracked values is reported */
+    group := map[string][]interface{}{}
+    group["all"] = []interface{}{cyclicGroup{Members: group}}
+    _, err = api.Marshal(group)
+    assert.Equal(t, "json: unsupported value: encountered a cycle via sonic.cyclicGroup -> map[string][]interface {} -> []interface {} -> sonic.cyclicGroup", err.Error())
+
+    arr := cyclicSlice{nil}
+    arr[0] = arr
+    _, err = api.Marshal(arr)
+    assert.Error(t, err)
+    assert.Contains(t, err.Error(), "encountered a cycle via sonic.cyclicSlice -> sonic.cyclicSlice")
+
+    /* without the option, it fails for the too deep nesting */
+    _, err = ConfigDefault.Marshal(ptr)
+    assert.Error(t, err)
+    assert.NotContains(t, err.Error(), "cycle")
+}
+
+type limitedObject struct {
+    A []interface{}     `json:"a"`
+    B map[string][]int  `json:"b"`
+    C *limitedObject    `json:"c,omitempty"`
+    D struct{}          `json:"d"`
+}
+
+func TestMarshalLimits(t *testing.T) {
+    obj := limitedObject{
+        A: []interface{}{[]int{}, map[string]interface{}{"x": []int{1}}},
+        B: map[string][]int{"y": {}},
+        C: &limitedObject{},
+    }
+    exp, err := json.Marshal(obj)
+    assert.Nil(t, err)
+
+    /* the depth of the values in {"a":[{"x":[1]}]} is 4 */
+    for _, ind := range []string{"", "  "} {
+        api := Config{EncoderMaxDepth: 4}.Froze()
+        out, err := api.MarshalIndent(obj, "", ind)
+        assert.Nil(t, err)
+        if ind == "" {
+            out, err = api.Marshal(obj)
+            assert.Nil(t, err)
+            assert.Equal(t, string(exp), string(out))
+        }
+
+        api = Config{EncoderMaxDepth: 3}.Froze()
+        _, err = api.Marshal(obj)
+        var le *encoder.LimitError
+        assert.True(t, errors.As(err, &le))
+        assert.Equal(t, &encoder.LimitError{Limit: "depth", Max: 3}, le)
+        _, err = api.MarshalIndent(obj, "", ind)
+        assert.Equal(t, &encoder.LimitError{Limit: "depth", Max: 3}, err)
+    }
+
+    /* the empty containers do not contain any value */
+    out, err := Config{EncoderMaxDepth: 1}.Froze().Marshal(limitedObject{A: []interface{}{}, B: map[string][]int{}})
+    assert.Nil(t, err)
+    assert.Equal(t, `{"a":[],"b":{},"d":{}}`, string(out))
+
+    /* the size limit */
+    api := Config{EncoderMaxSize: len(exp)}.Froze()
+    out, err = api.Marshal(obj)
+    assert.Nil(t, err)
+    assert.Equal(t, string(exp), string(out))
+    api = Config{EncoderMaxSize: len(exp) - 1}.Froze()
+    _, err = api.Marshal(obj)
+    assert.Equal(t, &encoder.LimitError{Limit: "size", Max: len(exp) - 1}, err)
+
+    /* the buffer does not grow without bound */
+    big := make([]string, 1 << 16)
+    for i := range big {
+        big[i] = strings.Repeat("x", 1024)
+    }
+    api = Config{EncoderMaxSize: 1 << 20}.Froze()
+    _, err = api.Marshal(big)
+    assert.Equal(t, &encoder.LimitError{Limit: "size", Max: 1 << 20}, err)
+    var buf bytes.Buffer
+    assert.Equal(t, &encoder.LimitError{Limit: "size", Max: 1 << 20}, api.NewEncoder(&buf).Encode(big))
+    assert.Equal(t, 0, buf.Len())
+
+    /* the escaping options are counted */
+    api = Config{EscapeHTML: true, EncoderMaxSize: 10}.Froze()
+    _, err = api.Marshal("<<<")
+    assert.Equal(t, &encoder.LimitError{Limit: "size", Max: 10}, err)
+}
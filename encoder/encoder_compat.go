@@ -24,6 +24,9 @@ import (
     `encoding/json`
     `reflect`
 
+    `github.com/bytedance/sonic/internal/encoder/alg`
+    `github.com/bytedance/sonic/internal/encoder/vars`
+    `github.com/bytedance/sonic/internal/resolver`
     `github.com/bytedance/sonic/option`
 )
 
@@ -46,6 +49,14 @@ const (
     bitValidateString
     bitNoValidateJSONMarshaler
     bitNoEncoderNewline
+    bitEncodeNullForInfOrNan
+    bitRedactSensitive
+    bitCanonical
+    bitEscapeASCII
+    bitEscapeSlash
+    bitInt64AsString
+    bitLargeInt64AsString
+    bitDetectCycles
 
     // used for recursive compile
     bitPointerValue = 63
@@ -87,6 +98,65 @@ const (
   
     // CompatibleWithStd is used to be compatible with std encoder.
     CompatibleWithStd Options = SortMapKeys | EscapeHTML | CompactMarshaler
+
+    // RedactSensitive indicates that the struct fields tagged with `json:",sensitive"`
+    // should be replaced by `"***"`, or by the output of the Redactor of the Encoder.
+    RedactSensitive Options = 1 << bitRedactSensitive
+
+    // Canonical indicates that the output JSON should be in the canonical form of
+    // RFC 8785 (JCS), see Canonicalize. It overrides SortMapKeys and the escaping options.
+    // WARNING: This hurts performance A LOT, USE WITH CARE.
+    Canonical Options = 1 << bitCanonical
+
+    // EscapeASCII indicates that all the non-ASCII characters in the strings should be
+    // escaped as \uXXXX (with surrogate pairs for the supplementary characters), and the
+    // invalid UTF-8 bytes as \ufffd, so that the output JSON is pure ASCII.
+    EscapeASCII Options = 1 << bitEscapeASCII
+
+    // EscapeSlash indicates that the '/' in the strings should be escaped as `\/`.
+    EscapeSlash Options = 1 << bitEscapeSlash
+
+    // Int64AsString indicates that the 64-bit integers (int64, uint64, and int, uint
+    // on 64-bit platforms) should be encoded as JSON strings, for the JavaScript
+    // consumers which lose the precision above 2^53. The ones in the fields with
+    // the "string" tag are not quoted twice.
+    Int64AsString Options = 1 << bitInt64AsString
+
+    // LargeInt64AsString is like Int64AsString, but only for the integers out of the
+    // safe range of JavaScript, [-(2^53-1), 2^53-1]. It is ignored if Int64AsString is set.
+    LargeInt64AsString Options = 1 << bitLargeInt64AsString
+
+    // DetectCycles indicates that the encoder should track the pointers of the deeply
+    // nested values, and fail with an error naming the types on the cycle if a value
+    // contains itself, instead of failing for the too deep nesting.
+    // NOTICE: the fallback encoder always detects the cycles.
+    DetectCycles Options = 1 << bitDetectCycles
+)
+
+// Redactor returns the JSON text replacing the value of the sensitive field name
+// of struct vt, or nil to omit the field.
+type Redactor = vars.Redactor
+
+// FloatFormat controls how the floats are encoded.
+type FloatFormat = vars.FloatFormat
+
+// FloatExponent controls the exponent notation of the floats.
+type FloatExponent = vars.FloatExponent
+
+// LimitError is returned when the output exceeds the limits of the Encoder, see
+// Encoder.SetMaxDepth and Encoder.SetMaxSize.
+type LimitError = vars.LimitError
+
+const (
+    // FloatExponentAuto uses the exponent notation only for the very large or
+    // very small floats (< 1e-6 or >= 1e21), the same as the default encoding.
+    FloatExponentAuto = vars.FloatExponentAuto
+
+    // FloatExponentNever never uses the exponent notation.
+    FloatExponentNever = vars.FloatExponentNever
+
+    // FloatExponentAlways always uses the exponent notation.
+    FloatExponentAlways = vars.FloatExponentAlways
 )
 
 // Encoder represents a specific set of encoder configurations.
@@ -94,14 +164,92 @@ type Encoder struct {
     Opts Options
     prefix string
     indent string
+    redactor Redactor
+    escapes *vars.EscapeSet
+    ff *vars.FloatFormat
+    maxDepth int
+    maxSize int
 }
 
 // Encode returns the JSON encoding of v.
 func (self *Encoder) Encode(v interface{}) ([]byte, error) {
     if self.indent != "" || self.prefix != "" { 
-        return EncodeIndented(v, self.prefix, self.indent, self.Opts)
+        return self.EncodeIndented(v, self.prefix, self.indent)
     }
-    return Encode(v, self.Opts)
+    if fb := self.fallbackFor(v); fb != nil {
+        return self.encodeFallback(fb, v, nil, nil)
+    }
+    buf, err := encode(v, self.Opts)
+    if err != nil {
+        return nil, err
+    }
+    return self.checkSize(buf)
+}
+
+// EncodeIndented is like Encode but indents the output with prefix and indent,
+// even if both of them are empty, regardless of SetIndent.
+// Like json.Encoder, the output is terminated by a newline.
+func (self *Encoder) EncodeIndented(v interface{}, prefix string, indent string) ([]byte, error) {
+    if fb := self.fallbackFor(v); fb != nil {
+        buf, err := self.encodeFallback(fb, v, nil, &vars.Indent{Prefix: prefix, Indent: indent})
+        if err != nil {
+            return nil, err
+        }
+        return append(buf, '\n'), nil
+    }
+    buf, err := encodeIndented(v, prefix, indent, self.Opts)
+    if err != nil {
+        return nil, err
+    }
+    return self.checkSize(buf)
+}
+
+// fallback returns the fallback encoder if any of the options which encoding/json
+// does not support is enabled, or nil.
+func (self *Encoder) fallback() *fallback {
+    if self.Opts & (RedactSensitive | Canonical | EscapeASCII | EscapeSlash | Int64AsString | LargeInt64AsString) == 0 &&
+       self.escapes == nil && self.ff == nil && self.maxDepth <= 0 && self.maxSize <= 0 {
+        return nil
+    }
+    return self.newFallback()
+}
+
+// fallbackFor is like fallback, but also returns the fallback encoder if v may
+// have the float formats in the struct tags, which is cached by the type of v.
+func (self *Encoder) fallbackFor(v interface{}) *fallback {
+    if fb := self.fallback(); fb != nil {
+        return fb
+    }
+    if vt := reflect.TypeOf(v); vt != nil && hasFloatFormat(vt) {
+        return self.newFallback()
+    }
+    return nil
+}
+
+func (self *Encoder) newFallback() *fallback {
+    fb := newFallback(self.Opts)
+    fb.redactor = self.redactor
+    fb.escapes = self.escapes
+    fb.cf, fb.ff = self.ff, self.ff
+    fb.maxDepth = self.maxDepth
+    fb.maxSize = self.maxSize
+    return fb
+}
+
+func (self *Encoder) encodeFallback(fb *fallback, v interface{}, proj resolver.FieldTree, ind *vars.Indent) ([]byte, error) {
+    fb.ind = ind
+    buf, err := fb.encode(nil, v, proj)
+    if err != nil {
+        return nil, err
+    }
+    return self.checkSize(buf)
+}
+
+func (self *Encoder) checkSize(buf []byte) ([]byte, error) {
+    if self.maxSize > 0 && len(buf) > self.maxSize {
+        return nil, vars.Error_max_size(self.maxSize)
+    }
+    return buf, nil
 }
 
 // SortKeys enables the SortMapKeys option.
@@ -164,6 +312,102 @@ func (self *Encoder) SetNoQuoteTextMarshaler(f bool) {
     }
 }
 
+// SetRedactSensitive specifies if option RedactSensitive opens
+func (self *Encoder) SetRedactSensitive(f bool) {
+    if f {
+        self.Opts |= RedactSensitive
+    } else {
+        self.Opts &= ^RedactSensitive
+    }
+}
+
+// SetRedactor sets the Redactor for the sensitive fields, which takes effect
+// only if option RedactSensitive opens.
+func (self *Encoder) SetRedactor(fn Redactor) {
+    self.redactor = fn
+}
+
+// SetFloatFormat sets the format of all the floats, except the ones of the struct
+// fields with the float format options in their tags. It fails and leaves the
+// format unchanged if ff is invalid, see FloatFormat.
+func (self *Encoder) SetFloatFormat(ff FloatFormat) error {
+    if err := vars.ValidateFloatFormat(ff); err != nil {
+        return err
+    }
+    self.ff = vars.InternFloatFormat(ff)
+    return nil
+}
+
+// SetEscapeASCII specifies if option EscapeASCII opens
+func (self *Encoder) SetEscapeASCII(f bool) {
+    if f {
+        self.Opts |= EscapeASCII
+    } else {
+        self.Opts &= ^EscapeASCII
+    }
+}
+
+// SetEscapeSlash specifies if option EscapeSlash opens
+func (self *Encoder) SetEscapeSlash(f bool) {
+    if f {
+        self.Opts |= EscapeSlash
+    } else {
+        self.Opts &= ^EscapeSlash
+    }
+}
+
+// SetInt64AsString specifies if option Int64AsString opens
+func (self *Encoder) SetInt64AsString(f bool) {
+    if f {
+        self.Opts |= Int64AsString
+    } else {
+        self.Opts &= ^Int64AsString
+    }
+}
+
+// SetLargeInt64AsString specifies if option LargeInt64AsString opens
+func (self *Encoder) SetLargeInt64AsString(f bool) {
+    if f {
+        self.Opts |= LargeInt64AsString
+    } else {
+        self.Opts &= ^LargeInt64AsString
+    }
+}
+
+// SetDetectCycles specifies if option DetectCycles opens
+func (self *Encoder) SetDetectCycles(f bool) {
+    if f {
+        self.Opts |= DetectCycles
+    } else {
+        self.Opts &= ^DetectCycles
+    }
+}
+
+// SetMaxDepth limits the nesting depth of the output, that is, the number of the arrays
+// and objects enclosing any value, 0 for unlimited. The encoding is aborted with a
+// *LimitError once the limit is exceeded.
+func (self *Encoder) SetMaxDepth(n int) {
+    self.maxDepth = n
+}
+
+// SetMaxSize limits the length in bytes of each encoded output, 0 for unlimited. The
+// encoding is aborted with a *LimitError once the limit is exceeded, instead of
+// growing the buffer without bound.
+func (self *Encoder) SetMaxSize(n int) {
+    self.maxSize = n
+}
+
+// SetEscapeChars sets the extra characters to be escaped as \uXXXX in the strings.
+// The characters which are always escaped by JSON are ignored, and SetEscapeChars("")
+// clears the set.
+func (self *Encoder) SetEscapeChars(chars string) {
+    if chars == "" {
+        self.escapes = nil
+    } else {
+        self.escapes = vars.NewEscapeSet(chars)
+    }
+}
+
 // SetIndent instructs the encoder to format each subsequent encoded
 // value as if indented by the package-level function EncodeIndent().
 // Calling SetIndent("", "") disables indentation.
@@ -185,9 +429,49 @@ func Quote(s string) string {
 
 // Encode returns the JSON encoding of val, encoded with opts.
 func Encode(val interface{}, opts Options) ([]byte, error) {
+   enc := Encoder{Opts: opts}
+   return enc.Encode(val)
+}
+
+func encode(val interface{}, opts Options) ([]byte, error) {
    return json.Marshal(val)
 }
 
+// Canonicalize returns the canonical form of the JSON src, which is defined by
+// RFC 8785 (JSON Canonicalization Scheme): no whitespace, object keys sorted by
+// their UTF-16 code units, minimal string escapes, and numbers serialized as
+// ECMAScript does. The src must be a single valid I-JSON value.
+func Canonicalize(src []byte) ([]byte, error) {
+   return alg.Canonicalize(make([]byte, 0, len(src)), src)
+}
+
+// EncodeWithFields is like Encode but only emits the struct fields selected by fields.
+//
+// Each path of fields is a dot-separated list of JSON field names (such as "owner.email"),
+// which selects the named field of the struct and applies the remaining names to the
+// field value. Pointers, slices and arrays are transparent to the paths, so "items.id"
+// selects the "id" field of every element of "items". The values of maps, interfaces and
+// marshalers are always emitted as a whole, and the names that do not match any field are
+// ignored.
+func EncodeWithFields(val interface{}, fields []string, opts Options) ([]byte, error) {
+    enc := Encoder{Opts: opts}
+    return enc.EncodeWithFields(val, fields)
+}
+
+// EncodeWithFields is like Encode but only emits the struct fields selected by fields,
+// see the package-level EncodeWithFields.
+func (self *Encoder) EncodeWithFields(v interface{}, fields []string) ([]byte, error) {
+    proj, err := resolver.NewFieldTree(fields)
+    if err != nil {
+        return nil, err
+    }
+    fb := self.newFallback()
+    if self.indent != "" || self.prefix != "" {
+        return self.encodeFallback(fb, v, proj, &vars.Indent{Prefix: self.prefix, Indent: self.indent})
+    }
+    return self.encodeFallback(fb, v, proj, nil)
+}
+
 // EncodeInto is like Encode but uses a user-supplied buffer instead of allocating
 // a new one.
 func EncodeInto(buf *[]byte, val interface{}, opts Options) error {
@@ -222,13 +506,19 @@ func HTMLEscape(dst []byte, src []byte) []byte {
 // Each JSON element in the output will begin on a new line beginning with prefix
 // followed by one or more copies of indent according to the indentation nesting.
 func EncodeIndented(val interface{}, prefix string, indent string, opts Options) ([]byte, error) {
+   enc := Encoder{Opts: opts}
+   return enc.EncodeIndented(val, prefix, indent)
+}
+
+func encodeIndented(val interface{}, prefix string, indent string, opts Options) ([]byte, error) {
    w := bytes.NewBuffer([]byte{})
    enc := json.NewEncoder(w)
    enc.SetEscapeHTML((opts & EscapeHTML) != 0)
    enc.SetIndent(prefix, indent)
-   err := enc.Encode(val)
-   out := w.Bytes()
-   return out, err
+   if err := enc.Encode(val); err != nil {
+       return nil, err
+   }
+   return w.Bytes(), nil
 }
 
 // Pretouch compiles vt ahead-of-time to avoid JIT compilation on-the-fly, in
@@ -249,13 +539,12 @@ func Valid(data []byte) (ok bool, start int) {
    return json.Valid(data), 0
 }
 
-// StreamEncoder uses io.Writer as 
+// StreamEncoder uses io.Writer as input.
 type StreamEncoder = json.Encoder
 
 // NewStreamEncoder adapts to encoding/json.NewDecoder API.
 //
 // NewStreamEncoder returns a new encoder that write to w.
 func NewStreamEncoder(w io.Writer) *StreamEncoder {
-   return json.NewEncoder(w)
+    return json.NewEncoder(w)
 }
-
@@ -73,6 +73,77 @@ const (
 
     // Encode Infinity or Nan float into `null`, instead of returning an error.
     EncodeNullForInfOrNan Options = encoder.EncodeNullForInfOrNan
+
+    // RedactSensitive indicates that the struct fields tagged with `json:",sensitive"`
+    // should be replaced by `"***"`, or by the output of the Redactor of the Encoder.
+    RedactSensitive Options = encoder.RedactSensitive
+
+    // Canonical indicates that the output JSON should be in the canonical form of
+    // RFC 8785 (JCS), see Canonicalize. It overrides SortMapKeys and the escaping options.
+    // WARNING: This hurts performance A LOT, USE WITH CARE.
+    Canonical Options = encoder.Canonical
+
+    // EscapeASCII indicates that all the non-ASCII characters in the strings should be
+    // escaped as \uXXXX (with surrogate pairs for the supplementary characters), and the
+    // invalid UTF-8 bytes as \ufffd, so that the output JSON is pure ASCII.
+    EscapeASCII Options = encoder.EscapeASCII
+
+    // EscapeSlash indicates that the '/' in the strings should be escaped as `\/`.
+    EscapeSlash Options = encoder.EscapeSlash
+
+    // Int64AsString indicates that the 64-bit integers (int64, uint64, and int, uint
+    // on 64-bit platforms) should be encoded as JSON strings, for the JavaScript
+    // consumers which lose the precision above 2^53. The ones in the fields with
+    // the "string" tag are not quoted twice.
+    Int64AsString Options = encoder.Int64AsString
+
+    // LargeInt64AsString is like Int64AsString, but only for the integers out of the
+    // safe range of JavaScript, [-(2^53-1), 2^53-1]. It is ignored if Int64AsString is set.
+    LargeInt64AsString Options = encoder.LargeInt64AsString
+
+    // DetectCycles indicates that the encoder should track the pointers of the deeply
+    // nested values, and fail with an error naming the types on the cycle if a value
+    // contains itself, instead of failing for the too deep nesting.
+    DetectCycles Options = encoder.DetectCycles
+)
+
+// Redactor returns the JSON text replacing the value of the sensitive field name
+// of struct vt, or nil to omit the field.
+//
+// It is called only once for each field when compiling, and the compiled programs
+// are cached for every Redactor, so it should be a long-lived function.
+type Redactor = encoder.Redactor
+
+// FloatFormat controls how the floats are encoded. The zero value is the shortest
+// representation that round-trips, which is the default.
+//
+// The format of a struct field can also be set by the options in its json tag, which
+// replace the format of the Encoder entirely:
+//   - "decimals=N": exactly N digits after the decimal point;
+//   - "precision=N": at most N significant digits;
+//   - "exponent" or "noexponent": always or never use the exponent notation;
+//   - "negzero": emit -0 as `-0` instead of `0`.
+// For example, `json:"price,decimals=2"`. The options apply to the floats in the field
+// value, such as the elements of a []float64, but not to the fields of the nested structs.
+type FloatFormat = encoder.FloatFormat
+
+// LimitError is returned when the output exceeds the limits of the Encoder, see
+// Encoder.SetMaxDepth and Encoder.SetMaxSize.
+type LimitError = encoder.LimitError
+
+// FloatExponent controls the exponent notation of the floats.
+type FloatExponent = encoder.FloatExponent
+
+const (
+    // FloatExponentAuto uses the exponent notation only for the very large or
+    // very small floats (< 1e-6 or >= 1e21), the same as the default encoding.
+    FloatExponentAuto = encoder.FloatExponentAuto
+
+    // FloatExponentNever never uses the exponent notation.
+    FloatExponentNever = encoder.FloatExponentNever
+
+    // FloatExponentAlways always uses the exponent notation.
+    FloatExponentAlways = encoder.FloatExponentAlways
 )
 
 
@@ -96,6 +167,24 @@ var (
     // be used.
     HTMLEscape = encoder.HTMLEscape
 
+    // EncodeWithFields is like Encode but only emits the struct fields selected by fields.
+    //
+    // Each path of fields is a dot-separated list of JSON field names (such as "owner.email"),
+    // which selects the named field of the struct and applies the remaining names to the
+    // field value. Pointers, slices and arrays are transparent to the paths, so "items.id"
+    // selects the "id" field of every element of "items". The values of maps, interfaces and
+    // marshalers are always emitted as a whole, and the names that do not match any field are
+    // ignored.
+    //
+    // The projected program is compiled once and cached for every type and set of fields.
+    EncodeWithFields = encoder.EncodeWithFields
+
+    // Canonicalize returns the canonical form of the JSON src, which is defined by
+    // RFC 8785 (JSON Canonicalization Scheme): no whitespace, object keys sorted by
+    // their UTF-16 code units, minimal string escapes, and numbers serialized as
+    // ECMAScript does. The src must be a single valid I-JSON value.
+    Canonicalize = encoder.Canonicalize
+
     // Pretouch compiles vt ahead-of-time to avoid JIT compilation on-the-fly, in
     // order to reduce the first-hit latency.
     //
@@ -19,6 +19,7 @@
 package encoder
 
 import (
+    `bytes`
     `encoding/json`
     `testing`
 
@@ -118,3 +119,15 @@ func TestEncoder_Marshal_EscapeHTML(t *testing.T) {
     require.Equal(t, string(ret), "{\"K\":\"\\u2028\\u2028\xe2\"}")
     require.NoError(t, err)
 }
+
+func TestStreamEncoder_RedactSensitive(t *testing.T) {
+    type Cred struct {
+        User     string `json:"user"`
+        Password string `json:"password,sensitive"`
+    }
+    w := bytes.NewBuffer(nil)
+    senc := NewStreamEncoder(w)
+    senc.SetRedactSensitive(true)
+    require.NoError(t, senc.Encode(&Cred{"a", "secret"}))
+    require.Equal(t, "{\"user\":\"a\",\"password\":\"***\"}\n", w.String())
+}
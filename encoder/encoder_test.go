@@ -20,11 +20,13 @@ import (
     `bytes`
     `encoding`
     `encoding/json`
+    `reflect`
     `runtime`
     `runtime/debug`
     `strconv`
     `testing`
     `time`
+    `strings`
 
     `github.com/bytedance/sonic/internal/rt`
     `github.com/stretchr/testify/require`
@@ -238,6 +240,220 @@ func TestEncoder_Binding(t *testing.T) {
     println(string(v))
 }
 
+func TestEncodeWithFields(t *testing.T) {
+    type Owner struct {
+        ID    int    `json:"id"`
+        Email string `json:"email"`
+    }
+    type Item struct {
+        ID   int    `json:"id"`
+        Name string `json:"name"`
+    }
+    type Doc struct {
+        ID    int    `json:"id"`
+        Items []Item `json:"items"`
+        Name  string `json:"name"`
+        Owner *Owner `json:"owner"`
+    }
+    doc := Doc{
+        ID: 1,
+        Items: []Item{{ID: 2, Name: "a"}, {ID: 3, Name: "b"}},
+        Name: "doc",
+        Owner: &Owner{ID: 4, Email: "x@y.z"},
+    }
+    v, e := EncodeWithFields(doc, []string{"id", "items.id", "owner.email"}, 0)
+    require.NoError(t, e)
+    require.Equal(t, `{"id":1,"items":[{"id":2},{"id":3}],"owner":{"email":"x@y.z"}}`, string(v))
+
+    _, e = EncodeWithFields(doc, []string{"owner."}, 0)
+    require.Error(t, e)
+
+    /* the fields keep the declaration order, and the maps are emitted as a whole */
+    type Attrs struct {
+        Z     int               `json:"z"`
+        A     int               `json:"a"`
+        Attrs map[string]string `json:"attrs"`
+    }
+    v, e = EncodeWithFields(Attrs{1, 2, map[string]string{"k": "v"}}, []string{"a", "z", "attrs.x"}, 0)
+    require.NoError(t, e)
+    require.Equal(t, `{"z":1,"a":2,"attrs":{"k":"v"}}`, string(v))
+}
+
+func TestEncodeRedactSensitive(t *testing.T) {
+    type Cred struct {
+        User     string `json:"user"`
+        Password string `json:"password,sensitive"`
+    }
+    v, e := Encode(Cred{"a", "secret"}, RedactSensitive)
+    require.NoError(t, e)
+    require.Equal(t, `{"user":"a","password":"***"}`, string(v))
+
+    enc := Encoder{Opts: RedactSensitive}
+    enc.SetRedactor(func(vt reflect.Type, name string) []byte { return nil })
+    v, e = enc.Encode([]Cred{{"a", "secret"}})
+    require.NoError(t, e)
+    require.Equal(t, `[{"user":"a"}]`, string(v))
+}
+
+func TestEncodeCanonical(t *testing.T) {
+    type Doc struct {
+        Name  string                 `json:"name"`
+        Score float64                `json:"score"`
+        Attrs map[string]interface{} `json:"attrs"`
+        Any   interface{}            `json:"any"`
+    }
+    doc := Doc{
+        Name: "<a\u2028>",
+        Score: 1e21,
+        Attrs: map[string]interface{}{"\ufb33": 1, "\U0001F600": 2.50, "b": nil},
+        Any: map[string]int{"y": 1, "x": 2},
+    }
+    v, e := Encode(doc, Canonical|EscapeHTML)
+    require.NoError(t, e)
+    require.Equal(t, "{\"any\":{\"x\":2,\"y\":1},\"attrs\":{\"b\":null,\"\U0001F600\":2.5,\"\ufb33\":1},"+
+        "\"name\":\"<a\u2028>\",\"score\":1e+21}", string(v))
+
+    v, e = Canonicalize([]byte(`{"b": [1.0, "\u00e9"], "a": -0.0}`))
+    require.NoError(t, e)
+    require.Equal(t, "{\"a\":0,\"b\":[1,\"\u00e9\"]}", string(v))
+
+    _, e = Canonicalize([]byte(`{"a":1e999}`))
+    require.Error(t, e)
+}
+
+type canonicalKey struct {
+    s string
+}
+
+func (k canonicalKey) MarshalText() ([]byte, error) {
+    return bytes.ToLower([]byte(k.s)), nil
+}
+
+func TestEncodeCanonical_Kernels(t *testing.T) {
+    type Doc struct {
+        Z  int64       `json:"z"`
+        E  float32     `json:"דּ"`
+        A  json.Number `json:"a"`
+        U  uint64      `json:"𝒜"`
+        Q  int64       `json:"q,string"`
+        R  json.RawMessage
+    }
+    doc := Doc{
+        Z: 1 << 60,
+        E: 0.1,
+        A: "1.50e1",
+        U: 9007199254740993,
+        Q: 1 << 60,
+        R: json.RawMessage(`{ "b" : 1.0 , "a" : [ "\u0041" ] }`),
+    }
+    v, e := Encode(doc, Canonical)
+    require.NoError(t, e)
+    require.Equal(t, `{"R":{"a":["A"],"b":1},"a":15,"q":"1152921504606846976",`+
+        `"z":1152921504606847000,"𝒜":9007199254740992,"דּ":0.1}`, string(v))
+
+    /* duplicate keys are errors */
+    _, e = Encode(map[canonicalKey]int{{"A"}: 1, {"a"}: 2}, Canonical)
+    require.Error(t, e)
+    _, e = Encode(json.RawMessage(`{"a":1,"a":2}`), Canonical)
+    require.Error(t, e)
+    v, e = Encode(map[canonicalKey]int{{"B"}: 1, {"a"}: 2}, Canonical)
+    require.NoError(t, e)
+    require.Equal(t, `{"a":2,"b":1}`, string(v))
+}
+
+func TestEncodeFloatFormat(t *testing.T) {
+    type Bill struct {
+        Amount float64     `json:"amount,decimals=2"`
+        Tax    float64     `json:"tax,string,decimals=1"`
+        Ratio  float32     `json:"ratio"`
+        Extra  interface{} `json:"extra"`
+    }
+    out, err := Encode(Bill{Amount: 10, Tax: 0.25, Ratio: 0.5, Extra: 0.125}, 0)
+    require.NoError(t, err)
+    require.Equal(t, `{"amount":10.00,"tax":"0.2","ratio":0.5,"extra":0.125}`, string(out))
+
+    enc := Encoder{}
+    require.NoError(t, enc.SetFloatFormat(FloatFormat{Precision: 1, Exponent: FloatExponentAlways}))
+    out, err = enc.Encode(Bill{Amount: 10, Tax: 0.25, Ratio: 0.5, Extra: 0.125})
+    require.NoError(t, err)
+    require.Equal(t, `{"amount":10.00,"tax":"0.2","ratio":5e-1,"extra":1e-1}`, string(out))
+
+    /* the decimals without Fixed are rejected like the tag options */
+    require.Error(t, enc.SetFloatFormat(FloatFormat{Decimals: 2}))
+    out, err = enc.Encode(0.25)
+    require.NoError(t, err)
+    require.Equal(t, `2e-1`, string(out))
+
+    _, err = Encode(struct {
+        A float64 `json:"a,decimals=x"`
+    }{}, 0)
+    require.Error(t, err)
+}
+
+func TestEncodeEscape(t *testing.T) {
+    v := map[string]string{"url": "http://a.b/é😀"}
+    out, err := Encode(v, EscapeASCII|EscapeSlash)
+    require.NoError(t, err)
+    require.Equal(t, `{"url":"http:\/\/a.b\/\u00e9\ud83d\ude00"}`, string(out))
+
+    enc := Encoder{}
+    enc.SetEscapeChars("'é")
+    out, err = enc.Encode(v)
+    require.NoError(t, err)
+    require.Equal(t, `{"url":"http://a.b/\u00e9😀"}`, string(out))
+
+    enc.SetEscapeASCII(true)
+    enc.SetEscapeChars("")
+    out, err = enc.Encode([]string{"it's", "😀"})
+    require.NoError(t, err)
+    require.Equal(t, `["it's","\ud83d\ude00"]`, string(out))
+}
+
+type escapedDoc struct {
+    Path string          `json:"a/é"`
+    Raw  json.RawMessage `json:"raw"`
+    Num  int             `json:"n/m,string"`
+}
+
+func TestEncodeEscape_QuotePath(t *testing.T) {
+    doc := escapedDoc{Path: "<é/>", Raw: json.RawMessage(`{"x/y":"\u00e9/é"}`), Num: 1}
+    out, err := Encode(doc, EscapeASCII|EscapeSlash|EscapeHTML)
+    require.NoError(t, err)
+    require.Equal(t, `{"a\/\u00e9":"\u003c\u00e9\/\u003e","raw":{"x\/y":"\u00e9\/\u00e9"},"n\/m":"1"}`, string(out))
+
+    /* the map keys are escaped with or without sorting */
+    m := map[string]string{"/": "é"}
+    for _, opts := range []Options{EscapeASCII | EscapeSlash, EscapeASCII | EscapeSlash | SortMapKeys} {
+        out, err = Encode(m, opts)
+        require.NoError(t, err)
+        require.Equal(t, `{"\/":"\u00e9"}`, string(out))
+    }
+
+    /* the indentation is not escaped */
+    enc := Encoder{Opts: EscapeSlash}
+    enc.SetIndent("/", "  ")
+    out, err = enc.Encode([]string{"/"})
+    require.NoError(t, err)
+    require.Equal(t, "[\n/  \"\\/\"\n/]", strings.TrimSuffix(string(out), "\n"))
+}
+
+// The indented output of compat is terminated by a newline like json.Encoder.
+func TestEncodeIndent_SinglePass(t *testing.T) {
+    /* the prefix is not escaped, and the raw JSON is indented at its line */
+    doc := escapedDoc{Path: "<é/>", Raw: json.RawMessage(`{"x":["<"], "e":{}}`), Num: 1}
+    exp, err := json.MarshalIndent(doc, "<", "\t")
+    require.NoError(t, err)
+    out, err := EncodeIndented(doc, "<", "\t", EscapeHTML)
+    require.NoError(t, err)
+    require.Equal(t, string(exp), strings.TrimSuffix(string(out), "\n"))
+
+    /* the canonical form is indented while being written */
+    v := map[string]interface{}{"b": json.RawMessage(`{"d":1.0,"c":[]}`), "a": 1}
+    out, err = EncodeIndented(v, "", " ", Canonical)
+    require.NoError(t, err)
+    require.Equal(t, "{\n \"a\": 1,\n \"b\": {\n  \"c\": [],\n  \"d\": 1\n }\n}", strings.TrimSuffix(string(out), "\n"))
+}
+
 func TestEncoder_MapSortKey(t *testing.T) {
     m := map[string]string {
         "C": "third",
@@ -0,0 +1,746 @@
+// +build !amd64,!arm64 go1.24 !go1.17 arm64,!go1.20
+
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package encoder
+
+import (
+    `bytes`
+    `encoding`
+    `encoding/base64`
+    `encoding/json`
+    `fmt`
+    `math`
+    `reflect`
+    `sort`
+    `strconv`
+    `sync`
+    `unsafe`
+
+    `github.com/bytedance/sonic/internal/encoder/alg`
+    `github.com/bytedance/sonic/internal/encoder/vars`
+    `github.com/bytedance/sonic/internal/resolver`
+)
+
+var (
+    jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+    textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+    jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+// the pointers are tracked for cycles only below this level, like encoding/json
+const cycleLevel = 1000
+
+type visit struct {
+    p unsafe.Pointer
+    n int
+    t reflect.Type
+}
+
+// fallback encodes the values by reflection, with the same struct fields metadata
+// and options as the native encoder, for the features which encoding/json lacks.
+type fallback struct {
+    opts     Options
+    redactor Redactor
+    escapes  *vars.EscapeSet
+    cf       *vars.FloatFormat // the format of the floats of the encoder
+    ff       *vars.FloatFormat // the format of the floats of the current field
+    ind      *vars.Indent      // the indentation of the output, or nil
+    depth    int
+    maxDepth int
+    maxSize  int
+    level    int
+    seen     map[visit]struct{}
+}
+
+func newFallback(opts Options) *fallback {
+    return &fallback{opts: opts}
+}
+
+// encode appends the JSON of val to buf, only emitting the struct fields selected
+// by proj if it is not nil.
+func (self *fallback) encode(buf []byte, val interface{}, proj resolver.FieldTree) ([]byte, error) {
+    return self.value(buf, reflect.ValueOf(val), proj)
+}
+
+func (self *fallback) value(buf []byte, rv reflect.Value, proj resolver.FieldTree) ([]byte, error) {
+    if !rv.IsValid() {
+        return append(buf, "null"...), nil
+    }
+
+    /* check for marshalers, the pointer methods are used if the value is addressable */
+    vt := rv.Type()
+    if vt.Kind() != reflect.Ptr && rv.CanAddr() && isMarshaler(reflect.PtrTo(vt)) {
+        rv, vt = rv.Addr(), reflect.PtrTo(vt)
+    }
+    if isMarshaler(vt) {
+        return self.marshaler(buf, rv)
+    }
+    if vt == jsonNumberType {
+        return self.number(buf, rv.String())
+    }
+
+    /* encode by the kind */
+    switch vt.Kind() {
+        case reflect.Bool      : return strconv.AppendBool(buf, rv.Bool()), nil
+        case reflect.Int       ,
+             reflect.Int8      ,
+             reflect.Int16     ,
+             reflect.Int32     ,
+             reflect.Int64     : return self.int(buf, rv.Int(), rv.Type().Size() == 8), nil
+        case reflect.Uint      ,
+             reflect.Uint8     ,
+             reflect.Uint16    ,
+             reflect.Uint32    ,
+             reflect.Uint64    : return self.uint(buf, rv.Uint(), rv.Type().Size() == 8), nil
+        case reflect.Uintptr   : return self.uint(buf, rv.Uint(), false), nil
+        case reflect.Float32   : return self.float(buf, rv, 32)
+        case reflect.Float64   : return self.float(buf, rv, 64)
+        case reflect.String    : return self.quote(buf, rv.String())
+        case reflect.Interface : return self.iface(buf, rv)
+        case reflect.Ptr       : return self.pointer(buf, rv, proj)
+        case reflect.Struct    : return self.object(buf, rv, proj)
+        case reflect.Map       : return self.mapping(buf, rv)
+        case reflect.Slice     : return self.slice(buf, rv, proj)
+        case reflect.Array     : return self.array(buf, rv, proj)
+        default                : return nil, &json.UnsupportedTypeError{Type: vt}
+    }
+}
+
+func isMarshaler(vt reflect.Type) bool {
+    return vt.Implements(jsonMarshalerType) || vt.Implements(textMarshalerType)
+}
+
+func (self *fallback) marshaler(buf []byte, rv reflect.Value) ([]byte, error) {
+    if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+        return append(buf, "null"...), nil
+    }
+
+    /* json.Marshaler is preferred */
+    if m, ok := rv.Interface().(json.Marshaler); ok {
+        out, err := m.MarshalJSON()
+        if err != nil {
+            return nil, &json.MarshalerError{Type: rv.Type(), Err: err}
+        }
+        return self.raw(buf, out, rv.Type())
+    }
+
+    /* encoding.TextMarshaler */
+    out, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+    if err != nil {
+        return nil, &json.MarshalerError{Type: rv.Type(), Err: err}
+    }
+    if self.opts & NoQuoteTextMarshaler != 0 {
+        buf = append(buf, '"')
+        return append(append(buf, out...), '"'), nil
+    }
+    return self.quote(buf, string(out))
+}
+
+// raw appends the output of json.Marshaler, which is compacted and validated
+// unless NoValidateJSONMarshaler is set, or canonicalized in the canonical form.
+// It is always validated if indented, since it is indented token by token.
+func (self *fallback) raw(buf []byte, out []byte, vt reflect.Type) ([]byte, error) {
+    if self.opts & Canonical != 0 {
+        ret, err := self.text(buf, out)
+        if err != nil {
+            return nil, &json.MarshalerError{Type: vt, Err: err}
+        }
+        return ret, nil
+    }
+    if self.ind != nil {
+        if ok, pos := alg.Valid(out); !ok {
+            return nil, &json.MarshalerError{Type: vt, Err: vars.Error_marshaler(out, pos)}
+        }
+        return self.text(buf, out)
+    }
+    if self.opts & NoValidateJSONMarshaler != 0 {
+        return self.text(buf, out)
+    }
+    if self.escaped() {
+        dst := bytes.NewBuffer(nil)
+        if err := json.Compact(dst, out); err != nil {
+            return nil, &json.MarshalerError{Type: vt, Err: err}
+        }
+        return alg.EscapeText(buf, dst.Bytes(), uint64(self.opts), self.escapes), nil
+    }
+    dst := bytes.NewBuffer(buf)
+    if err := json.Compact(dst, out); err != nil {
+        return nil, &json.MarshalerError{Type: vt, Err: err}
+    }
+    buf = dst.Bytes()
+    if self.opts & EscapeHTML != 0 {
+        n := len(buf) - len(out)
+        buf = alg.HtmlEscape(buf[:n], append([]byte(nil), buf[n:]...))
+    }
+    return buf, nil
+}
+
+func (self *fallback) float(buf []byte, rv reflect.Value, bits int) ([]byte, error) {
+    v := rv.Float()
+    if math.IsNaN(v) || math.IsInf(v, 0) {
+        if self.opts & (1 << bitEncodeNullForInfOrNan) != 0 {
+            return append(buf, "null"...), nil
+        }
+        return nil, &json.UnsupportedValueError{Value: rv, Str: strconv.FormatFloat(v, 'g', -1, bits)}
+    }
+    if self.opts & Canonical != 0 {
+        return alg.FloatES6(buf, v, bits), nil
+    }
+    if self.ff != nil {
+        return alg.FormatFloat(buf, v, bits, self.ff), nil
+    }
+    if bits == 32 {
+        return alg.F32toa(buf, float32(v)), nil
+    } else {
+        return alg.F64toa(buf, v), nil
+    }
+}
+
+// int appends the integer v, which is rounded to a double in the canonical form if
+// it is out of [-MaxSafeInteger, MaxSafeInteger]. The 64-bit integers are quoted
+// like alg.EncodeInt64 does.
+func (self *fallback) int(buf []byte, v int64, wide bool) []byte {
+    if wide {
+        alg.EncodeInt64(&buf, unsafe.Pointer(&v), uint64(self.opts))
+        return buf
+    }
+    if self.opts & Canonical != 0 && (v > alg.MaxSafeInteger || v < -alg.MaxSafeInteger) {
+        return alg.F64toES6(buf, float64(v))
+    }
+    return strconv.AppendInt(buf, v, 10)
+}
+
+// uint is like int but for the unsigned integers.
+func (self *fallback) uint(buf []byte, v uint64, wide bool) []byte {
+    if wide {
+        alg.EncodeUint64(&buf, unsafe.Pointer(&v), uint64(self.opts))
+        return buf
+    }
+    if self.opts & Canonical != 0 && v > alg.MaxSafeInteger {
+        return alg.F64toES6(buf, float64(v))
+    }
+    return strconv.AppendUint(buf, v, 10)
+}
+
+// number appends the json.Number s, which must be a valid number literal.
+func (self *fallback) number(buf []byte, s string) ([]byte, error) {
+    if self.opts & Canonical != 0 {
+        return alg.NumberES6(buf, s)
+    }
+    if s == "" {
+        return append(buf, '0'), nil
+    }
+    if !alg.IsValidNumber(s) {
+        return nil, vars.Error_number(json.Number(s))
+    }
+    return append(buf, s...), nil
+}
+
+// newline appends the line break and the indentation of the current depth if
+// the output is indented.
+func (self *fallback) newline(buf []byte) []byte {
+    if self.ind == nil {
+        return buf
+    }
+    buf = append(buf, '\n')
+    buf = append(buf, self.ind.Prefix...)
+    for i := 0; i < self.depth; i++ {
+        buf = append(buf, self.ind.Indent...)
+    }
+    return buf
+}
+
+func (self *fallback) linePrefix() string {
+    return string(self.newline(nil)[1:])
+}
+
+// colon appends the separator between the key and value of a member.
+func (self *fallback) colon(buf []byte) []byte {
+    if self.ind == nil {
+        return append(buf, ':')
+    }
+    return append(buf, ':', ' ')
+}
+
+// escaped tells if the strings are escaped for the options which the native
+// quoter does not support, see alg.Escaped.
+func (self *fallback) escaped() bool {
+    return alg.Escaped(uint64(self.opts), self.escapes)
+}
+
+func (self *fallback) quote(buf []byte, s string) ([]byte, error) {
+    if self.opts & Canonical != 0 {
+        err := alg.EncodeString(&buf, s, nil, uint64(self.opts), alg.StrPlain)
+        return buf, err
+    }
+    return alg.QuoteEscaped(buf, s, uint64(self.opts), self.escapes), nil
+}
+
+func (self *fallback) iface(buf []byte, rv reflect.Value) ([]byte, error) {
+    if rv.IsNil() {
+        return append(buf, "null"...), nil
+    }
+
+    /* the float format of the field does not apply to the dynamic values */
+    ff := self.ff
+    self.ff = self.cf
+    buf, err := self.value(buf, rv.Elem(), nil)
+    self.ff = ff
+    return buf, err
+}
+
+func (self *fallback) pointer(buf []byte, rv reflect.Value, proj resolver.FieldTree) ([]byte, error) {
+    if rv.IsNil() {
+        return append(buf, "null"...), nil
+    }
+    if err := self.enter(rv, visit{unsafe.Pointer(rv.Pointer()), 0, rv.Type()}); err != nil {
+        return nil, err
+    }
+    buf, err := self.value(buf, rv.Elem(), proj)
+    self.leave(visit{unsafe.Pointer(rv.Pointer()), 0, rv.Type()})
+    return buf, err
+}
+
+// enter tracks the pointer of v once the nesting is deep enough, and fails if
+// it has already been entered.
+func (self *fallback) enter(rv reflect.Value, v visit) error {
+    if self.level++; self.level <= cycleLevel {
+        return nil
+    }
+    if self.seen == nil {
+        self.seen = map[visit]struct{}{}
+    }
+    if _, ok := self.seen[v]; ok {
+        return &json.UnsupportedValueError{Value: rv, Str: fmt.Sprintf("encountered a cycle via %s", rv.Type())}
+    }
+    self.seen[v] = struct{}{}
+    return nil
+}
+
+func (self *fallback) leave(v visit) {
+    if self.level--; self.level >= cycleLevel {
+        delete(self.seen, v)
+    }
+}
+
+func (self *fallback) object(buf []byte, rv reflect.Value, proj resolver.FieldTree) ([]byte, error) {
+    var err error
+    if !rv.CanAddr() {
+        pv := reflect.New(rv.Type())
+        pv.Elem().Set(rv)
+        rv = pv.Elem()
+    }
+
+    /* emit each field in the declaration order */
+    ff := self.ff
+    buf = append(buf, '{')
+    n := len(buf)
+    self.depth++
+    vp := unsafe.Pointer(rv.UnsafeAddr())
+    fv := self.fields(rv.Type())
+    for i := range fv {
+        fm := &fv[i]
+        sub, ok := proj[fm.Name]
+        if proj != nil && !ok {
+            continue
+        }
+
+        /* replace or omit the sensitive fields */
+        var mask []byte
+        if self.opts & RedactSensitive != 0 && fm.Opts & resolver.F_sensitive != 0 {
+            if mask, err = self.redact(rv.Type(), fm.Name); err != nil {
+                return nil, err
+            } else if mask == nil {
+                continue
+            }
+        }
+
+        /* skip the fields in nil embedded struct pointers */
+        fp := fieldAt(vp, fm)
+        if fp == nil {
+            continue
+        }
+
+        /* check for "omitempty" */
+        val := reflect.NewAt(fm.Type, fp).Elem()
+        if fm.Opts & resolver.F_omitempty != 0 && isEmptyValue(val) {
+            continue
+        }
+
+        /* apply the float format of the field */
+        self.ff = self.cf
+        if fm.Format != "" {
+            if self.ff, err = fieldFloatFormat(rv.Type(), fm); err != nil {
+                return nil, err
+            }
+        }
+
+        /* emit the field */
+        if len(buf) != n {
+            buf = append(buf, ',')
+        }
+        if buf, err = self.item(buf); err != nil {
+            return nil, err
+        }
+        if buf, err = self.quote(buf, fm.Name); err != nil {
+            return nil, err
+        }
+        buf = self.colon(buf)
+        if mask != nil {
+            buf, err = self.text(buf, mask)
+        } else if fm.Opts & resolver.F_stringize != 0 {
+            buf, err = self.stringize(buf, val)
+        } else {
+            buf, err = self.value(buf, val, sub)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    self.ff = ff
+    return self.close(buf, n, '}'), nil
+}
+
+// fields returns the fields of struct vt, sorted by the UTF-16 code units of their
+// names in the canonical form.
+func (self *fallback) fields(vt reflect.Type) []resolver.FieldMeta {
+    fv := resolver.ResolveStruct(vt)
+    if self.opts & Canonical != 0 {
+        fv = append([]resolver.FieldMeta(nil), fv...)
+        sort.SliceStable(fv, func(i, j int) bool { return alg.LessUTF16(fv[i].Name, fv[j].Name) })
+    }
+    return fv
+}
+
+// text appends the JSON text, which is canonicalized in the canonical form, or
+// has its string literals escaped for the escaping options. It is indented at the
+// current line while being copied if the output is indented.
+func (self *fallback) text(buf []byte, text []byte) ([]byte, error) {
+    if self.ind != nil {
+        err := alg.EncodeIndentedText(&buf, text, uint64(self.opts), self.escapes, self.linePrefix(), self.ind.Indent)
+        return buf, err
+    }
+    if self.opts & Canonical != 0 {
+        err := alg.EncodeText(&buf, string(text), nil, uint64(self.opts))
+        return buf, err
+    }
+    if self.escaped() {
+        return alg.EscapeText(buf, text, uint64(self.opts), self.escapes), nil
+    }
+    return append(buf, text...), nil
+}
+
+// the default replacement of the sensitive fields
+const redactMask = `"***"`
+
+// redact returns the replacement of the sensitive field name of struct vt, or
+// nil to omit the field.
+func (self *fallback) redact(vt reflect.Type, name string) ([]byte, error) {
+    if self.redactor == nil {
+        return []byte(redactMask), nil
+    }
+
+    /* the replacement must be a valid JSON value */
+    ret := self.redactor(vt, name)
+    if len(ret) == 0 {
+        return nil, nil
+    } else if !json.Valid(ret) {
+        return nil, vars.Error_redactor(vt, name, ret)
+    } else {
+        return ret, nil
+    }
+}
+
+// fieldFloatFormat returns the float format in the tag of field fm of struct vt.
+func fieldFloatFormat(vt reflect.Type, fm *resolver.FieldMeta) (*vars.FloatFormat, error) {
+    ff, err := vars.ParseFloatFormat(fm.Format)
+    if err != nil {
+        return nil, vars.Error_float_format(vt, fm.Name, err)
+    }
+    return vars.InternFloatFormat(ff), nil
+}
+
+// floatFormats caches if the values of a type may have the float formats in tags.
+var floatFormats sync.Map
+
+// hasFloatFormat tells if the values of vt may have the float format options in
+// the struct tags, which encoding/json ignores. The interfaces are assumed to have.
+func hasFloatFormat(vt reflect.Type) bool {
+    if ret, ok := floatFormats.Load(vt); ok {
+        return ret.(bool)
+    }
+    ret := findFloatFormat(vt, map[reflect.Type]bool{})
+    floatFormats.Store(vt, ret)
+    return ret
+}
+
+func findFloatFormat(vt reflect.Type, seen map[reflect.Type]bool) bool {
+    if seen[vt] || isMarshaler(vt) || isMarshaler(reflect.PtrTo(vt)) {
+        return false
+    }
+    seen[vt] = true
+    switch vt.Kind() {
+        case reflect.Interface : return true
+        case reflect.Ptr       ,
+             reflect.Slice     ,
+             reflect.Array     : return findFloatFormat(vt.Elem(), seen)
+        case reflect.Map       : return findFloatFormat(vt.Elem(), seen)
+        case reflect.Struct    : {
+            for _, fm := range resolver.ResolveStruct(vt) {
+                if fm.Format != "" || findFloatFormat(fm.Type, seen) {
+                    return true
+                }
+            }
+            return false
+        }
+        default: return false
+    }
+}
+
+// fieldAt returns the pointer to the field described by fm in the struct at p,
+// or nil if it is in a nil embedded struct pointer.
+func fieldAt(p unsafe.Pointer, fm *resolver.FieldMeta) unsafe.Pointer {
+    for _, off := range fm.Path {
+        p = unsafe.Pointer(uintptr(p) + off.Size)
+        if off.Kind != resolver.F_deref {
+            continue
+        }
+        if p = *(*unsafe.Pointer)(p); p == nil {
+            return nil
+        }
+    }
+    return p
+}
+
+// stringize encodes the field with the `string` option as a JSON string.
+func (self *fallback) stringize(buf []byte, rv reflect.Value) ([]byte, error) {
+    if isMarshaler(rv.Type()) || isMarshaler(reflect.PtrTo(rv.Type())) {
+        return self.value(buf, rv, nil)
+    }
+    if rv.Kind() == reflect.Ptr {
+        if rv.IsNil() {
+            return append(buf, "null"...), nil
+        }
+        rv = rv.Elem()
+    }
+
+    /* strings are quoted twice */
+    if rv.Kind() == reflect.String && rv.Type() != jsonNumberType {
+        return self.quote2(buf, rv.String())
+    }
+
+    /* the integers are already quoted, and always exact */
+    switch rv.Kind() {
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            return append(strconv.AppendInt(append(buf, '"'), rv.Int(), 10), '"'), nil
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            return append(strconv.AppendUint(append(buf, '"'), rv.Uint(), 10), '"'), nil
+    }
+    buf, err := self.value(append(buf, '"'), rv, nil)
+    if err != nil {
+        return nil, err
+    }
+    return append(buf, '"'), nil
+}
+
+// quote2 appends the JSON string of the quoted s.
+func (self *fallback) quote2(buf []byte, s string) ([]byte, error) {
+    if self.opts & Canonical != 0 {
+        err := alg.EncodeString(&buf, s, nil, uint64(self.opts), alg.StrDouble)
+        return buf, err
+    }
+    str, _ := self.quote(nil, s)
+    return self.quote(buf, string(str))
+}
+
+func isEmptyValue(v reflect.Value) bool {
+    switch v.Kind() {
+        case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+            return v.Len() == 0
+        case reflect.Bool:
+            return !v.Bool()
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            return v.Int() == 0
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            return v.Uint() == 0
+        case reflect.Float32, reflect.Float64:
+            return v.Float() == 0
+        case reflect.Interface, reflect.Ptr:
+            return v.IsNil()
+        default:
+            return false
+    }
+}
+
+type mapPair struct {
+    k string
+    v reflect.Value
+}
+
+func (self *fallback) mapping(buf []byte, rv reflect.Value) ([]byte, error) {
+    if rv.IsNil() {
+        return self.empty(buf, "{}"), nil
+    }
+    if err := self.enter(rv, visit{unsafe.Pointer(rv.Pointer()), 0, rv.Type()}); err != nil {
+        return nil, err
+    }
+    defer self.leave(visit{unsafe.Pointer(rv.Pointer()), 0, rv.Type()})
+
+    /* the keys are always sorted, like encoding/json */
+    kvs := make([]mapPair, 0, rv.Len())
+    for it := rv.MapRange(); it.Next(); {
+        key, err := mapKey(it.Key())
+        if err != nil {
+            return nil, err
+        }
+        kvs = append(kvs, mapPair{key, it.Value()})
+    }
+    if err := self.sortKeys(kvs); err != nil {
+        return nil, err
+    }
+
+    /* emit each pair */
+    var err error
+    buf = append(buf, '{')
+    n := len(buf)
+    self.depth++
+    for i, kv := range kvs {
+        if i != 0 {
+            buf = append(buf, ',')
+        }
+        if buf, err = self.item(buf); err != nil {
+            return nil, err
+        }
+        if buf, err = self.quote(buf, kv.k); err != nil {
+            return nil, err
+        }
+        if buf, err = self.value(self.colon(buf), kv.v, nil); err != nil {
+            return nil, err
+        }
+    }
+    return self.close(buf, n, '}'), nil
+}
+
+// sortKeys sorts the pairs by the keys, or by the UTF-16 code units of the keys
+// in the canonical form, which rejects the duplicate keys.
+func (self *fallback) sortKeys(kvs []mapPair) error {
+    if self.opts & Canonical == 0 {
+        sort.Slice(kvs, func(i, j int) bool { return kvs[i].k < kvs[j].k })
+        return nil
+    }
+    sort.Slice(kvs, func(i, j int) bool { return alg.LessUTF16(kvs[i].k, kvs[j].k) })
+    for i := 1; i < len(kvs); i++ {
+        if kvs[i].k == kvs[i - 1].k {
+            return vars.Error_duplicate_key(kvs[i].k)
+        }
+    }
+    return nil
+}
+
+func mapKey(kv reflect.Value) (string, error) {
+    if kv.Kind() == reflect.String {
+        return kv.String(), nil
+    }
+    if tm, ok := kv.Interface().(encoding.TextMarshaler); ok {
+        if kv.Kind() == reflect.Ptr && kv.IsNil() {
+            return "", nil
+        }
+        buf, err := tm.MarshalText()
+        return string(buf), err
+    }
+    switch kv.Kind() {
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+            return strconv.FormatInt(kv.Int(), 10), nil
+        case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+            return strconv.FormatUint(kv.Uint(), 10), nil
+        default:
+            return "", &json.UnsupportedTypeError{Type: kv.Type()}
+    }
+}
+
+// empty appends the empty container if NoNullSliceOrMap is set, or null.
+func (self *fallback) empty(buf []byte, v string) []byte {
+    if self.opts & NoNullSliceOrMap != 0 {
+        return append(buf, v...)
+    }
+    return append(buf, "null"...)
+}
+
+func (self *fallback) slice(buf []byte, rv reflect.Value, proj resolver.FieldTree) ([]byte, error) {
+    if rv.IsNil() {
+        return self.empty(buf, "[]"), nil
+    }
+
+    /* byte slices are encoded with Base64 */
+    if et := rv.Type().Elem(); et.Kind() == reflect.Uint8 && !isMarshaler(reflect.PtrTo(et)) {
+        src := rv.Bytes()
+        n := len(buf) + 1
+        buf = append(buf, make([]byte, base64.StdEncoding.EncodedLen(len(src)) + 2)...)
+        base64.StdEncoding.Encode(buf[n:], src)
+        buf[n - 1], buf[len(buf) - 1] = '"', '"'
+        return buf, nil
+    }
+
+    /* check for cycles with the pointer and the length */
+    v := visit{unsafe.Pointer(rv.Pointer()), rv.Len(), rv.Type()}
+    if err := self.enter(rv, v); err != nil {
+        return nil, err
+    }
+    buf, err := self.array(buf, rv, proj)
+    self.leave(v)
+    return buf, err
+}
+
+func (self *fallback) array(buf []byte, rv reflect.Value, proj resolver.FieldTree) ([]byte, error) {
+    var err error
+    buf = append(buf, '[')
+    n := len(buf)
+    self.depth++
+    for i := 0; i < rv.Len(); i++ {
+        if i != 0 {
+            buf = append(buf, ',')
+        }
+        if buf, err = self.item(buf); err != nil {
+            return nil, err
+        }
+        if buf, err = self.value(buf, rv.Index(i), proj); err != nil {
+            return nil, err
+        }
+    }
+    return self.close(buf, n, ']'), nil
+}
+
+// item begins an element or member of the current array or object, and fails if
+// it is nested too deep, or the output is already too long.
+func (self *fallback) item(buf []byte) ([]byte, error) {
+    if self.maxDepth > 0 && self.depth > self.maxDepth {
+        return nil, vars.Error_max_depth(self.maxDepth)
+    }
+    if self.maxSize > 0 && len(buf) > self.maxSize {
+        return nil, vars.Error_max_size(self.maxSize)
+    }
+    return self.newline(buf), nil
+}
+
+// close leaves the array or object opened at n, which is kept as it is if empty.
+func (self *fallback) close(buf []byte, n int, c byte) []byte {
+    if self.depth--; len(buf) != n {
+        buf = self.newline(buf)
+    }
+    return append(buf, c)
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package caching
+
+import (
+    `sync`
+    `sync/atomic`
+)
+
+// BoundedCache is a concurrent map holding at most a fixed number of entries,
+// an arbitrary entry is evicted to make room for the new one.
+//
+// The lookups never lock, thus it suits the caches keyed by the inputs of users,
+// which are read far more often than written.
+type BoundedCache struct {
+    m   sync.Map
+    n   int64
+    max int64
+}
+
+// NewBoundedCache creates a cache holding at most max entries.
+func NewBoundedCache(max int) *BoundedCache {
+    if max <= 0 {
+        panic("the capacity of cache must be positive")
+    }
+    return &BoundedCache{max: int64(max)}
+}
+
+// Load returns the value stored under key.
+func (self *BoundedCache) Load(key interface{}) (interface{}, bool) {
+    return self.m.Load(key)
+}
+
+// LoadOrStore returns the value stored under key if present,
+// otherwise it stores and returns val, evicting other entries if the cache is full.
+func (self *BoundedCache) LoadOrStore(key interface{}, val interface{}) interface{} {
+    if ret, ok := self.m.Load(key); ok {
+        return ret
+    }
+
+    /* make room for the new entry */
+    for atomic.LoadInt64(&self.n) >= self.max {
+        self.evict()
+    }
+
+    /* the concurrent stores may overrun the capacity by a few entries at most */
+    ret, loaded := self.m.LoadOrStore(key, val)
+    if !loaded {
+        atomic.AddInt64(&self.n, 1)
+    }
+    return ret
+}
+
+// Len returns the number of entries.
+func (self *BoundedCache) Len() int {
+    return int(atomic.LoadInt64(&self.n))
+}
+
+func (self *BoundedCache) evict() {
+    self.m.Range(func(key interface{}, _ interface{}) bool {
+        if _, ok := self.m.LoadAndDelete(key); ok {
+            atomic.AddInt64(&self.n, -1)
+        }
+        return false
+    })
+}
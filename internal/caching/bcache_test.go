@@ -0,0 +1,64 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package caching
+
+import (
+    `sync`
+    `testing`
+)
+
+func TestBoundedCache(t *testing.T) {
+    bc := NewBoundedCache(8)
+    if v := bc.LoadOrStore("a", 1); v != 1 {
+        t.Fatalf("invalid value: %v", v)
+    }
+    if v := bc.LoadOrStore("a", 2); v != 1 {
+        t.Fatalf("the stored value should be kept: %v", v)
+    }
+    for i := 0; i < 100; i++ {
+        if v := bc.LoadOrStore(i, i); v != i {
+            t.Fatalf("invalid value: %v", v)
+        }
+        if v, ok := bc.Load(i); !ok || v != i {
+            t.Fatalf("the latest entry should be loaded: %v", v)
+        }
+        if n := bc.Len(); n > 8 {
+            t.Fatalf("too many entries: %d", n)
+        }
+    }
+}
+
+func TestBoundedCacheRace(t *testing.T) {
+    t.Parallel()
+
+    bc := NewBoundedCache(16)
+    wg := sync.WaitGroup{}
+    for g := 0; g < 8; g++ {
+        wg.Add(1)
+        go func(g int) {
+            defer wg.Done()
+            for i := 0; i < 1000; i++ {
+                bc.LoadOrStore(g * 1000 + i, i)
+                bc.Load(i)
+            }
+        }(g)
+    }
+    wg.Wait()
+    if n := bc.Len(); n > 16 + 8 {
+        t.Fatalf("too many entries: %d", n)
+    }
+}
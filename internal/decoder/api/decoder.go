@@ -35,6 +35,7 @@ const (
 	_F_use_int64 = consts.F_use_int64
 	_F_use_number = consts.F_use_number
 	_F_validate_string = consts.F_validate_string
+	_F_int64_string = consts.F_int64_string
 
 	_MaxStack = consts.MaxStack
 
@@ -45,6 +46,7 @@ const (
     OptionCopyString       = consts.OptionCopyString
     OptionValidateString   = consts.OptionValidateString
     OptionNoValidateJSON   = consts.OptionNoValidateJSON
+    OptionInt64FromString  = consts.OptionInt64FromString
 )
 
 type (
@@ -113,6 +115,18 @@ func (self *Decoder) Decode(val interface{}) error {
 	return decodeImpl(&self.s, &self.i, self.f, val)
 }
 
+// DecodeWithFields is like Decode but only decodes the struct fields selected by fields,
+// each path of fields is a dot-separated list of JSON field names (such as "owner.email").
+// Pointers, slices and arrays are transparent to the paths, while the values of maps and
+// interfaces are always decoded as a whole.
+//
+// The values of the fields not in the mask are fast-skipped without being validated, and
+// the fields are left untouched. The names matching no field are ignored. The masked programs
+// are cached for every type and set of fields, up to a limit.
+func (self *Decoder) DecodeWithFields(val interface{}, fields []string) error {
+	return decodeWithFieldsImpl(&self.s, &self.i, self.f, val, fields)
+}
+
 // UseInt64 indicates the Decoder to unmarshal an integer into an interface{} as an
 // int64 instead of as a float64.
 func (self *Decoder) UseInt64() {
@@ -152,6 +166,14 @@ func (self *Decoder) ValidateString() {
     self.f |= 1 << _F_validate_string
 }
 
+// Int64FromString indicates the Decoder to also accept the JSON strings of integers
+// (such as "123") for the 64-bit integers (int64, uint64, and int, uint on 64-bit
+// platforms), which is the counterpart of the encoder option Int64AsString.
+// The strings decoded into interface{} are still strings.
+func (self *Decoder) Int64FromString() {
+    self.f |= 1 << _F_int64_string
+}
+
 // Pretouch compiles vt ahead-of-time to avoid JIT compilation on-the-fly, in
 // order to reduce the first-hit latency.
 //
@@ -28,11 +28,13 @@ import (
 var (
 	pretouchImpl = jitdec.Pretouch
 	decodeImpl = jitdec.Decode
+	decodeWithFieldsImpl = jitdec.DecodeWithFields
 ) 
 
  func init() {
 	if envs.UseOptDec {
 		pretouchImpl = optdec.Pretouch
 		decodeImpl = optdec.Decode
+		decodeWithFieldsImpl = optdec.DecodeWithFields
 	}
  }
@@ -26,6 +26,7 @@ import (
 var (
 	pretouchImpl = optdec.Pretouch
 	decodeImpl = optdec.Decode
+	decodeWithFieldsImpl = optdec.DecodeWithFields
 )
 
 
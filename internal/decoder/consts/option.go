@@ -11,6 +11,7 @@ const (
     F_disable_urc     = 2
     F_disable_unknown = 3
     F_copy_string     = 4
+    F_int64_string    = 7
 
 
     F_use_number      = types.B_USE_NUMBER
@@ -29,6 +30,7 @@ const (
     OptionCopyString       Options = 1 << F_copy_string
     OptionValidateString   Options = 1 << F_validate_string
     OptionNoValidateJSON   Options = 1 << F_no_validate_json
+    OptionInt64FromString  Options = 1 << F_int64_string
 )
 
 const (
@@ -295,6 +295,7 @@ var _OpFuncTab = [256]func(*_Assembler, *_Instr) {
     _OP_slice_init       : (*_Assembler)._asm_OP_slice_init,
     _OP_slice_append     : (*_Assembler)._asm_OP_slice_append,
     _OP_object_next      : (*_Assembler)._asm_OP_object_next,
+    _OP_object_skip      : (*_Assembler)._asm_OP_object_skip,
     _OP_struct_field     : (*_Assembler)._asm_OP_struct_field,
     _OP_unmarshal        : (*_Assembler)._asm_OP_unmarshal,
     _OP_unmarshal_p      : (*_Assembler)._asm_OP_unmarshal_p,
@@ -770,6 +771,29 @@ func (self *_Assembler) parse_unsigned(vt reflect.Type, pin string, pin2 int) {
     self.check_err(vt, pin, pin2)
 }
 
+// parse_int64 parses the 64-bit integer with fn, which can also be quoted if
+// _F_int64_string is set. BX is left at the leading quote to skip the string when
+// it is mismatched.
+func (self *_Assembler) parse_int64(vt reflect.Type, fn obj.Addr, pin string) {
+    self.Emit("BTQ" , jit.Imm(_F_int64_string), _ARG_fv)           // BTQ  ${_F_int64_string}, fv
+    self.Sjmp("JNC" , "_int64_{n}")                                 // JNC  _int64_{n}
+    self.lspace("_int64_{n}")                                       // LSPACE
+    self.Emit("CMPB", jit.Sib(_IP, _IC, 1, 0), jit.Imm('"'))        // CMPB (IP)(IC), $'"'
+    self.Sjmp("JNE" , "_int64_{n}")                                 // JNE  _int64_{n}
+    self.Emit("MOVQ", _IC, _BX)                                     // MOVQ IC, BX
+    self.Emit("ADDQ", jit.Imm(1), _IC)                              // ADDQ $1, IC
+    self.Sjmp("JMP" , "_int64_parse_{n}")                           // JMP  _int64_parse_{n}
+    self.Link("_int64_{n}")                                         // _int64_{n}:
+    self.Emit("MOVQ", _IC, _BX)                                     // save ic when call native func
+    self.Link("_int64_parse_{n}")                                   // _int64_parse_{n}:
+    self.call_vf(fn)                                                // CALL_VF $fn
+    self.check_err(vt, pin, -1)
+    self.Emit("CMPB", jit.Sib(_IP, _BX, 1, 0), jit.Imm('"'))        // CMPB (IP)(BX), $'"'
+    self.Sjmp("JNE" , "_int64_end_{n}")                             // JNE  _int64_end_{n}
+    self.match_char('"')                                            // MATCH $'"'
+    self.Link("_int64_end_{n}")                                     // _int64_end_{n}:
+}
+
 // Pointer: DI, Size: SI, Return: R9  
 func (self *_Assembler) copy_string() {
     self.Link("_copy_string")
@@ -1082,6 +1106,7 @@ func (self *_Assembler) mapassign_utext(t reflect.Type, addressable bool) {
 
 var (
     _F_skip_one = jit.Imm(int64(native.S_skip_one))
+    _F_skip_one_fast = jit.Imm(int64(native.S_skip_one_fast))
     _F_skip_array  = jit.Imm(int64(native.S_skip_array))
     _F_skip_number = jit.Imm(int64(native.S_skip_number))
 )
@@ -1401,7 +1426,7 @@ func (self *_Assembler) _asm_OP_i32(_ *_Instr) {
 
 func (self *_Assembler) _asm_OP_i64(_ *_Instr) {
     var pin = "_i64_end_{n}"
-    self.parse_signed(int64Type, pin, -1)                         // PARSE int64
+    self.parse_int64(int64Type, _F_vsigned, pin)                  // PARSE int64
     self.Emit("MOVQ", _VAR_st_Iv, _AX)          // MOVQ  st.Iv, AX
     self.Emit("MOVQ", _AX, jit.Ptr(_VP, 0))     // MOVQ  AX, (VP)
     self.Link(pin)
@@ -1433,7 +1458,7 @@ func (self *_Assembler) _asm_OP_u32(_ *_Instr) {
 
 func (self *_Assembler) _asm_OP_u64(_ *_Instr) {
     var pin = "_u64_end_{n}"
-    self.parse_unsigned(uint64Type, pin, -1)                       // PARSE uint64
+    self.parse_int64(uint64Type, _F_vunsigned, pin)                // PARSE uint64
     self.Emit("MOVQ", _VAR_st_Iv, _AX)          // MOVQ  st.Iv, AX
     self.Emit("MOVQ", _AX, jit.Ptr(_VP, 0))     // MOVQ  AX, (VP)
     self.Link(pin)
@@ -1738,6 +1763,12 @@ func (self *_Assembler) _asm_OP_object_next(_ *_Instr) {
     self.Sjmp("JS"   , _LB_parsing_error_v)     // JS      _parse_error_v
 }
 
+func (self *_Assembler) _asm_OP_object_skip(_ *_Instr) {
+    self.call_sf(_F_skip_one_fast)              // CALL_SF skip_one_fast
+    self.Emit("TESTQ", _AX, _AX)                // TESTQ   AX, AX
+    self.Sjmp("JS"   , _LB_parsing_error_v)     // JS      _parse_error_v
+}
+
 func (self *_Assembler) _asm_OP_struct_field(p *_Instr) {
     assert_eq(caching.FieldEntrySize, 32, "invalid field entry size")
     self.Emit("MOVQ" , jit.Imm(-1), _AX)                        // MOVQ    $-1, AX
@@ -78,6 +78,7 @@ const (
     _OP_slice_init
     _OP_slice_append
     _OP_object_next
+    _OP_object_skip
     _OP_struct_field
     _OP_unmarshal
     _OP_unmarshal_p
@@ -156,6 +157,7 @@ var _OpNames = [256]string {
     _OP_slice_init       : "slice_init",
     _OP_slice_append     : "slice_append",
     _OP_object_next      : "object_next",
+    _OP_object_skip      : "object_skip",
     _OP_struct_field     : "struct_field",
     _OP_unmarshal        : "unmarshal",
     _OP_unmarshal_p      : "unmarshal_p",
@@ -506,6 +508,7 @@ type _Compiler struct {
     opts option.CompileOptions
     tab  map[reflect.Type]bool
     rec  map[reflect.Type]bool
+    mask resolver.FieldTree
 }
 
 func newCompiler() *_Compiler {
@@ -589,9 +592,9 @@ func (self *_Compiler) checkMarshaler(p *_Program, vt reflect.Type, flags int, e
 }
 
 func (self *_Compiler) compileOne(p *_Program, sp int, vt reflect.Type) {
-    /* check for recursive nesting */
+    /* check for recursive nesting, masked types are always inlined */
     ok := self.tab[vt]
-    if ok {
+    if ok && self.mask == nil {
         p.rtt(_OP_recurse, vt)
         return
     }
@@ -604,7 +607,18 @@ func (self *_Compiler) compileOne(p *_Program, sp int, vt reflect.Type) {
     p.add(_OP_lspace)
     self.tab[vt] = true
     self.compileOps(p, sp, vt)
-    delete(self.tab, vt)
+
+    /* exit the recursion, masked types may be entered more than once */
+    if !ok {
+        delete(self.tab, vt)
+    }
+}
+
+func (self *_Compiler) compileUnmasked(p *_Program, sp int, vt reflect.Type, fn func(*_Program, int, reflect.Type)) {
+    fm := self.mask
+    self.mask = nil
+    fn(p, sp, vt)
+    self.mask = fm
 }
 
 func (self *_Compiler) compileOps(p *_Program, sp int, vt reflect.Type) {
@@ -626,7 +640,7 @@ func (self *_Compiler) compileOps(p *_Program, sp int, vt reflect.Type) {
         case reflect.String    : self.compileString    (p, vt)
         case reflect.Array     : self.compileArray     (p, sp, vt)
         case reflect.Interface : self.compileInterface (p, vt)
-        case reflect.Map       : self.compileMap       (p, sp, vt)
+        case reflect.Map       : self.compileUnmasked  (p, sp, vt, self.compileMap)
         case reflect.Ptr       : self.compilePtr       (p, sp, vt)
         case reflect.Slice     : self.compileSlice     (p, sp, vt)
         case reflect.Struct    : self.compileStruct    (p, sp, vt)
@@ -726,9 +740,9 @@ func (self *_Compiler) compilePtr(p *_Program, sp int, et reflect.Type) {
         p.rtt(_OP_deref, et)
     }
 
-    /* check for recursive nesting */
+    /* check for recursive nesting, masked types are always inlined */
     ok := self.tab[et]
-    if ok {
+    if ok && self.mask == nil {
         p.rtt(_OP_recurse, et)
     } else {
         /* enter the recursion */
@@ -882,7 +896,9 @@ func (self *_Compiler) compileStringBody(vt reflect.Type, p *_Program) {
 }
 
 func (self *_Compiler) compileStruct(p *_Program, sp int, vt reflect.Type) {
-    if sp >= self.opts.MaxInlineDepth || p.pc() >= _MAX_ILBUF || (sp > 0 && vt.NumField() >= _MAX_FIELDS) {
+    if self.mask != nil {
+        self.compileStructBody(p, sp, vt)
+    } else if sp >= self.opts.MaxInlineDepth || p.pc() >= _MAX_ILBUF || (sp > 0 && vt.NumField() >= _MAX_FIELDS) {
         p.rtt(_OP_recurse, vt)
         if self.opts.RecursiveDepth > 0 {
             self.rec[vt] = true
@@ -948,10 +964,30 @@ func (self *_Compiler) compileStructBody(p *_Program, sp int, vt reflect.Type) {
     p.int(_OP_goto, y0)
 
     /* process each field */
+    sk := -1
+    pm := self.mask
     for i, f := range fv {
-        sw[i] = p.pc()
         fm.Set(f.Name, i)
 
+        /* fast-skip the fields not selected by the mask, and leave them untouched */
+        if pm != nil {
+            if _, ok := pm[f.Name]; !ok {
+                if sk == -1 {
+                    sk = p.pc()
+                    p.add(_OP_object_skip)
+                    p.int(_OP_goto, y0)
+                }
+                sw[i] = sk
+                continue
+            }
+        }
+
+        /* narrow the mask to the sub-fields of this field */
+        sw[i] = p.pc()
+        if pm != nil {
+            self.mask = pm[f.Name]
+        }
+
         /* index to the field */
         for _, o := range f.Path {
             if p.int(_OP_index, int(o.Size)); o.Kind == resolver.F_deref {
@@ -971,6 +1007,7 @@ func (self *_Compiler) compileStructBody(p *_Program, sp int, vt reflect.Type) {
         p.int(_OP_goto, y0)
     }
 
+    self.mask = pm
     p.pin(x)
     p.pin(y1)
     p.add(_OP_drop)
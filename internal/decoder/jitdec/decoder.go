@@ -27,6 +27,7 @@ const (
 	_F_use_number = consts.F_use_number
 	_F_no_validate_json = consts.F_no_validate_json
 	_F_validate_string = consts.F_validate_string
+	_F_int64_string = consts.F_int64_string
 )
 
 var (
@@ -42,6 +43,22 @@ var (
 // Decode parses the JSON-encoded data from current position and stores the result
 // in the value pointed to by val.
 func Decode(s *string, i *int, f uint64, val interface{}) error {
+    return decodeWith(s, i, f, val, findOrCompile)
+}
+
+// DecodeWithFields is like Decode but only decodes the struct fields selected by fields,
+// each path of fields is a dot-separated list of JSON field names (such as "owner.email").
+// The values of other fields are fast-skipped without validation and left untouched.
+//
+// The masked programs are cached for every type and set of fields, and the masks beyond
+// the limit of the loaded programs are decoded by optdec.
+func DecodeWithFields(s *string, i *int, f uint64, val interface{}, fields []string) error {
+    return decodeWith(s, i, f, val, func(vt *rt.GoType) (_Decoder, error) {
+        return findOrCompileMasked(vt, fields)
+    })
+}
+
+func decodeWith(s *string, i *int, f uint64, val interface{}, find func(*rt.GoType) (_Decoder, error)) error {
     /* validate json if needed */
     if (f & (1 << _F_validate_string)) != 0  && !utf8.ValidateString(*s){
         dbuf := utf8.CorrectWith(nil, rt.Str2Mem(*s), "\ufffd")
@@ -72,7 +89,7 @@ func Decode(s *string, i *int, f uint64, val interface{}) error {
 
     /* create a new stack, and call the decoder */
     sb := newStack()
-    nb, err := decodeTypedPointer(*s, *i, etp, vp, sb, f)
+    nb, err := decodeTypedPointerWith(*s, *i, etp, vp, sb, f, find)
     /* return the stack back */
     *i = nb
     freeStack(sb)
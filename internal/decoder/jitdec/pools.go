@@ -21,7 +21,9 @@ import (
     `unsafe`
 
     `github.com/bytedance/sonic/internal/caching`
+    `github.com/bytedance/sonic/internal/decoder/optdec`
     `github.com/bytedance/sonic/internal/native/types`
+    `github.com/bytedance/sonic/internal/resolver`
     `github.com/bytedance/sonic/internal/rt`
 )
 
@@ -143,3 +145,78 @@ func findOrCompile(vt *rt.GoType) (_Decoder, error) {
         return nil, err
     }
 }
+
+type maskKey struct {
+    vt     *rt.GoType
+    fields string
+}
+
+const (
+    _MaxMaskPaths = 4096 // the most paths of fields cached
+)
+
+var (
+    maskPaths = caching.NewBoundedCache(_MaxMaskPaths)
+    maskCache sync.Map
+    maskCount int
+    maskMutex sync.Mutex
+)
+
+// maxJitMasks is the most masked programs loaded, since the loaded programs are never
+// freed. The other masks are decoded by optdec.
+var maxJitMasks = 1024
+
+func findOrCompileMasked(vt *rt.GoType, fields []string) (_Decoder, error) {
+    key := maskKey{vt, resolver.FieldPathsKey(fields)}
+    if val, ok := maskPaths.Load(key); ok {
+        return val.(_Decoder), nil
+    }
+
+    /* build the field mask, without the names matching no field */
+    fm, err := resolver.NewFieldTree(fields)
+    if err != nil {
+        return nil, err
+    }
+
+    /* the same mask may come from different paths */
+    fn, err := compileMasked(vt, fm.Normalize(vt.Pack()))
+    if err != nil {
+        return nil, err
+    }
+    return maskPaths.LoadOrStore(key, fn).(_Decoder), nil
+}
+
+func compileMasked(vt *rt.GoType, fm resolver.FieldTree) (_Decoder, error) {
+    mk := maskKey{vt, fm.Key()}
+    if val, ok := maskCache.Load(mk); ok {
+        return val.(_Decoder), nil
+    }
+
+    /* double check with the lock held */
+    maskMutex.Lock()
+    defer maskMutex.Unlock()
+    if val, ok := maskCache.Load(mk); ok {
+        return val.(_Decoder), nil
+    }
+
+    /* no more room for the programs */
+    if maskCount >= maxJitMasks {
+        return func(s string, i int, vp unsafe.Pointer, _ *_Stack, fv uint64, _ string, _ unsafe.Pointer) (int, error) {
+            return optdec.DecodeMasked(s, i, fv, vt, vp, fm)
+        }, nil
+    }
+
+    /* compile the masked program */
+    cc := newCompiler()
+    cc.mask = fm
+    pp, err := cc.compile(vt.Pack())
+    if err != nil {
+        return nil, err
+    }
+
+    /* load and cache the program */
+    fn := newAssembler(pp).Load()
+    maskCache.Store(mk, fn)
+    maskCount++
+    return fn, nil
+}
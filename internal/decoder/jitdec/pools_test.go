@@ -0,0 +1,77 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jitdec
+
+import (
+    `strings`
+    `testing`
+
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+type maskOwner struct {
+    ID    int    `json:"id"`
+    Email string `json:"email"`
+}
+
+type maskDoc struct {
+    ID    int        `json:"id"`
+    Owner *maskOwner `json:"owner"`
+}
+
+const maskJson = `{"id":1,"owner":{"id":2,"email":"x@y.z"}}`
+
+func decodeMasked(t *testing.T, fields ...string) maskDoc {
+    var v maskDoc
+    s, i := maskJson, 0
+    require.NoError(t, DecodeWithFields(&s, &i, 0, &v, fields))
+    assert.Equal(t, len(maskJson), i)
+    return v
+}
+
+func TestPools_MaskKeys(t *testing.T) {
+    long := strings.Repeat("x", 121)
+
+    /* the paths never collide with the trees */
+    assert.Equal(t, maskDoc{}, decodeMasked(t, long + `"}`))
+    assert.Equal(t, maskDoc{ID: 1}, decodeMasked(t, "id"))
+    assert.Equal(t, maskDoc{}, decodeMasked(t, long))
+
+    /* the names matching no field share the same program */
+    n := maskCount
+    assert.Equal(t, maskDoc{ID: 1}, decodeMasked(t, "id", "unknown"))
+    assert.Equal(t, maskDoc{ID: 1}, decodeMasked(t, "unknown.x", "id", "unknown"))
+    assert.Equal(t, n, maskCount)
+}
+
+func TestPools_MaskFallback(t *testing.T) {
+    old := maxJitMasks
+    maxJitMasks = 0
+    defer func() { maxJitMasks = old }()
+
+    /* the masks beyond the limit are decoded by optdec */
+    n := maskCount
+    assert.Equal(t, maskDoc{Owner: &maskOwner{Email: "x@y.z"}}, decodeMasked(t, "owner.email", "owner.unknown"))
+    assert.Equal(t, n, maskCount)
+
+    var v maskDoc
+    s, i := `{"id":1,"owner":{"email":1}}`, 0
+    err := DecodeWithFields(&s, &i, 0, &v, []string{"owner.email", "owner.nothing"})
+    require.Error(t, err)
+    assert.Equal(t, 25, err.(*MismatchTypeError).Pos)
+}
@@ -26,7 +26,11 @@ import (
 )
 
 func decodeTypedPointer(s string, i int, vt *rt.GoType, vp unsafe.Pointer, sb *_Stack, fv uint64) (int, error) {
-    if fn, err := findOrCompile(vt); err != nil {
+    return decodeTypedPointerWith(s, i, vt, vp, sb, fv, findOrCompile)
+}
+
+func decodeTypedPointerWith(s string, i int, vt *rt.GoType, vp unsafe.Pointer, sb *_Stack, fv uint64, find func(*rt.GoType) (_Decoder, error)) (int, error) {
+    if fn, err := find(vt); err != nil {
         return 0, err
     } else {
         rt.MoreStack(_FP_size + _VD_size + native.MaxFrameSize)
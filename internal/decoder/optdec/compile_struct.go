@@ -129,7 +129,7 @@ func (c *compiler) compileStruct(vt reflect.Type) decFunc {
 		return c.compileStructBody(vt)
 	}
 
-	if c.depth >= c.opts.MaxInlineDepth + 1 || (c.counts > 0 &&  vt.NumField() >= _MAX_FIELDS) {
+	if c.mask == nil && (c.depth >= c.opts.MaxInlineDepth + 1 || (c.counts > 0 &&  vt.NumField() >= _MAX_FIELDS)) {
 		return &recuriveDecoder{
 			typ: rt.UnpackType(vt),
 		}
@@ -142,8 +142,27 @@ func (c *compiler) compileStructBody(vt reflect.Type) decFunc {
 	fv := resolver.ResolveStruct(vt)
 	entries := make([]fieldEntry, 0, len(fv))
 
+	fm := c.mask
+	defer func() {
+		c.mask = fm
+	}()
+
 	for _, f := range fv {
 		var dec decFunc
+
+		/* leave the fields not selected by the mask untouched */
+		if fm != nil {
+			sm, ok := fm[f.Name]
+			if !ok {
+				entries = append(entries, fieldEntry{
+					FieldMeta: f,
+					fieldDec:  &maskedFieldDecoder{},
+				})
+				continue
+			}
+			c.mask = sm
+		}
+
 		/* dealt with field tag options */
 		if f.Opts&resolver.F_stringize != 0 {
 			dec = c.compileFieldStringOption(f.Type)
@@ -8,6 +8,7 @@ import (
 	"github.com/bytedance/sonic/option"
 	"github.com/bytedance/sonic/internal/rt"
 	"github.com/bytedance/sonic/internal/caching"
+	"github.com/bytedance/sonic/internal/resolver"
 )
 
 var (
@@ -28,12 +29,64 @@ func findOrCompile(vt *rt.GoType) (decFunc, error) {
 	}
 }
 
+type maskKey struct {
+	vt     *rt.GoType
+	fields string
+}
+
+const (
+	_MaxMaskPaths = 4096 // the most paths of fields cached
+	_MaxMasks     = 1024 // the most masked decoders cached
+)
+
+var (
+	maskPaths = caching.NewBoundedCache(_MaxMaskPaths)
+	maskCache = caching.NewBoundedCache(_MaxMasks)
+)
+
+func findOrCompileMasked(vt *rt.GoType, fields []string) (decFunc, error) {
+	key := maskKey{vt, resolver.FieldPathsKey(fields)}
+	if val, ok := maskPaths.Load(key); ok {
+		return val.(decFunc), nil
+	}
+
+	/* build the field mask, without the names matching no field */
+	fm, err := resolver.NewFieldTree(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	/* the same mask may come from different paths */
+	dec, err := compileMasked(vt, fm.Normalize(vt.Pack()))
+	if err != nil {
+		return nil, err
+	}
+	return maskPaths.LoadOrStore(key, dec).(decFunc), nil
+}
+
+func compileMasked(vt *rt.GoType, fm resolver.FieldTree) (decFunc, error) {
+	mk := maskKey{vt, fm.Key()}
+	if val, ok := maskCache.Load(mk); ok {
+		return val.(decFunc), nil
+	}
+
+	/* compile the masked decoder */
+	cc := newCompiler()
+	cc.mask = fm
+	dec, err := cc.compileType(vt.Pack())
+	if err != nil {
+		return nil, err
+	}
+	return maskCache.LoadOrStore(mk, dec).(decFunc), nil
+}
+
 type compiler struct {
 	visited map[reflect.Type]bool
 	depth   int
 	counts  int
 	opts 	option.CompileOptions
 	namedPtr bool
+	mask    resolver.FieldTree
 }
 
 func newCompiler() *compiler {
@@ -107,7 +160,8 @@ func (c *compiler) compileType(vt reflect.Type) (rt decFunc, err error) {
 }
 
 func (c *compiler) compile(vt reflect.Type) decFunc {
-	if c.visited[vt] {
+	// masked types are always inlined
+	if c.visited[vt] && c.mask == nil {
 		return &recuriveDecoder{
 			typ: rt.UnpackType(vt),
 		}
@@ -161,7 +215,7 @@ func (c *compiler) compileBasic(vt reflect.Type) decFunc {
 	case reflect.Interface:
 		return c.compileInterface(vt)
 	case reflect.Map:
-		return c.compileMap(vt)
+		return c.compileUnmasked(vt, c.compileMap)
 	case reflect.Ptr:
 		return c.compilePtr(vt)
 	case reflect.Slice:
@@ -173,6 +227,15 @@ func (c *compiler) compileBasic(vt reflect.Type) decFunc {
 	}
 }
 
+func (c *compiler) compileUnmasked(vt reflect.Type, fn func(reflect.Type) decFunc) decFunc {
+	fm := c.mask
+	c.mask = nil
+	defer func() {
+		c.mask = fm
+	}()
+	return fn(vt)
+}
+
 func (c *compiler) compilePtr(vt reflect.Type) decFunc {
 	c.enter(vt)
 	defer c.exit(vt)
@@ -6,6 +6,7 @@ import (
 
 	"encoding/json"
 	"github.com/bytedance/sonic/internal/rt"
+	"github.com/bytedance/sonic/internal/resolver"
 	"github.com/bytedance/sonic/option"
 	"github.com/bytedance/sonic/internal/decoder/errors"
 	"github.com/bytedance/sonic/internal/decoder/consts"
@@ -25,6 +26,7 @@ const (
 	_F_use_int64 = consts.F_use_int64
 	_F_use_number = consts.F_use_number
 	_F_validate_string = consts.F_validate_string
+	_F_int64_string = consts.F_int64_string
 )
 
 type Options = consts.Options
@@ -36,10 +38,45 @@ const (
 	OptionDisableUnknown = consts.OptionDisableUnknown
 	OptionCopyString = consts.OptionCopyString
 	OptionValidateString = consts.OptionValidateString
+	OptionInt64FromString = consts.OptionInt64FromString
 )
 
 
 func Decode(s *string, i *int, f uint64, val interface{}) error {
+	return decodeWith(s, i, f, val, findOrCompile)
+}
+
+// DecodeWithFields is like Decode but only decodes the struct fields selected by fields,
+// each path of fields is a dot-separated list of JSON field names (such as "owner.email").
+// The values of other fields are left untouched.
+//
+// The masked decoders are cached for every type and set of fields, up to a limit.
+func DecodeWithFields(s *string, i *int, f uint64, val interface{}, fields []string) error {
+	return decodeWith(s, i, f, val, func(vt *rt.GoType) (decFunc, error) {
+		return findOrCompileMasked(vt, fields)
+	})
+}
+
+// DecodeMasked decodes the JSON at s[i:] into the value of type vt at vp, and only the
+// struct fields selected by the normalized mask. It returns the position after the value.
+//
+// It decodes the masks which the JIT decoder has no room for.
+func DecodeMasked(s string, i int, f uint64, vt *rt.GoType, vp unsafe.Pointer, mask resolver.FieldTree) (int, error) {
+	dec, err := compileMasked(vt, mask)
+	if err != nil {
+		return i, err
+	}
+
+	/* parse into document */
+	ctx, err := NewContext(s, i, f, vt)
+	defer ctx.Delete()
+	if err == nil {
+		err = dec.FromDom(vp, ctx.Root(), &ctx)
+	}
+	return i + ctx.Parser.Pos(), fix_error(ctx.Parser.Json, i, err)
+}
+
+func decodeWith(s *string, i *int, f uint64, val interface{}, find func(*rt.GoType) (decFunc, error)) error {
 	vv := rt.UnpackEface(val)
 	vp := vv.Value
 
@@ -62,7 +99,7 @@ func Decode(s *string, i *int, f uint64, val interface{}) error {
 		vp = unsafe.Pointer(&newp)
 	}
 
-	dec, err := findOrCompile(etp)
+	dec, err := find(etp)
 	if err != nil {
 		return err
 	}
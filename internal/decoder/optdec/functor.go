@@ -114,7 +114,7 @@ func (d *i64Decoder) FromDom(vp unsafe.Pointer, node Node, ctx *context) error {
 		return nil
 	}
 
-	ret, ok := node.AsI64(ctx)
+	ret, ok := node.AsInt64(ctx)
 	if !ok  {
 		return error_mismatch(node, ctx, int64Type)
 	}
@@ -178,7 +178,7 @@ func (d *u64Decoder) FromDom(vp unsafe.Pointer, node Node, ctx *context) error {
 		return nil
 	}
 
-	ret, ok := node.AsU64(ctx)
+	ret, ok := node.AsUint64(ctx)
 	if !ok {
 		return error_mismatch(node, ctx, uint64Type)
 	}
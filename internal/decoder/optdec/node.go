@@ -301,6 +301,32 @@ func (self Node) AsI64(ctx *Context) (int64, bool) {
 	}
 }
 
+// AsInt64 is like AsI64, but also accepts the JSON string of the integer if the
+// option Int64FromString is set.
+func (self Node) AsInt64(ctx *Context) (int64, bool) {
+	if ret, ok := self.AsI64(ctx); ok || !self.quotedInt64(ctx) {
+		return ret, ok
+	}
+	s, _ := self.AsStrRef(ctx)
+	ret, err := ParseI64(s)
+	return ret, err == nil
+}
+
+// AsUint64 is like AsU64, but also accepts the JSON string of the integer if the
+// option Int64FromString is set.
+func (self Node) AsUint64(ctx *Context) (uint64, bool) {
+	if ret, ok := self.AsU64(ctx); ok || !self.quotedInt64(ctx) {
+		return ret, ok
+	}
+	s, _ := self.AsStrRef(ctx)
+	ret, err := ParseU64(s)
+	return ret, err == nil
+}
+
+func (self Node) quotedInt64(ctx *Context) bool {
+	return self.IsStr() && ctx.Options() & (1 << _F_int64_string) != 0
+}
+
 /********* Parse Node String into Value ***************/
 
 func (val Node) ParseI64(ctx *Context) (int64, bool) {
@@ -764,7 +790,7 @@ func (node *Node) AsSliceI64(ctx *Context, vp unsafe.Pointer) error {
 	for i := 0; i < size; i++ {
 		val := NewNode(next)
 
-		ret, ok := val.AsI64(ctx)
+		ret, ok := val.AsInt64(ctx)
 		if !ok {
 			if gerr == nil {
 				gerr = newUnmatched(val.Position(), rt.Int64Type)
@@ -822,7 +848,7 @@ func (node *Node) AsSliceU64(ctx *Context, vp unsafe.Pointer) error {
 	var gerr error
 	for i := 0; i < size; i++ {
 		val := NewNode(next)
-		ret, ok := val.AsU64(ctx)
+		ret, ok := val.AsUint64(ctx)
 		if !ok {
 			if gerr == nil {
 				gerr = newUnmatched(val.Position(), rt.Uint64Type)
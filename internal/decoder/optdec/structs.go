@@ -59,3 +59,9 @@ func (d *structDecoder) FromDom(vp unsafe.Pointer, node Node, ctx *context) erro
 	return gerr
 }
 
+
+type maskedFieldDecoder struct{}
+
+func (d *maskedFieldDecoder) FromDom(vp unsafe.Pointer, node Node, ctx *context) error {
+	return nil
+}
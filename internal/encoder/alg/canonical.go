@@ -0,0 +1,553 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `encoding/json`
+    `fmt`
+    `math`
+    `sort`
+    `strconv`
+    `unicode/utf16`
+    `unicode/utf8`
+
+    `github.com/bytedance/sonic/internal/encoder/vars`
+    `github.com/bytedance/sonic/internal/rt`
+)
+
+// the max nesting depth of the canonicalized JSON
+const _MaxCanonicalDepth = 4096
+
+// Canonicalize appends to dst the canonical form of the JSON src, which is
+// defined by RFC 8785 (JSON Canonicalization Scheme):
+//   - no whitespace between the tokens;
+//   - object keys are sorted by their UTF-16 code units;
+//   - strings are escaped minimally;
+//   - numbers are serialized as ECMAScript (ES6) does.
+//
+// The src must be a single valid I-JSON value, which means the strings must be
+// valid UTF-16, the numbers must fit in IEEE-754 doubles, and the keys of each
+// object must be unique.
+func Canonicalize(dst []byte, src []byte) ([]byte, error) {
+    return canonicalize(dst, src, false)
+}
+
+// canonicalize is like Canonicalize, but replaces the invalid UTF-8 bytes in the
+// strings with U+FFFD if fix is true.
+func canonicalize(dst []byte, src []byte, fix bool) ([]byte, error) {
+    c := canonicalizer{src: src, fix: fix}
+    return c.finish(dst)
+}
+
+// canonicalizer writes the canonical form of src, which is indented with prefix
+// and indent like IndentText does if ind is true.
+type canonicalizer struct {
+    src    []byte
+    pos    int
+    fix    bool
+    ind    bool
+    prefix string
+    indent string
+}
+
+// finish appends the canonical form of the whole src to dst.
+func (self *canonicalizer) finish(dst []byte) ([]byte, error) {
+    ret, err := self.value(dst, 0)
+    if err != nil {
+        return dst, err
+    }
+
+    /* check for trailing chars */
+    if self.skip(); self.pos != len(self.src) {
+        return dst, self.error("invalid char")
+    }
+    return ret, nil
+}
+
+// newline appends the line break before the values at depth if indenting.
+func (self *canonicalizer) newline(dst []byte, depth int) []byte {
+    if !self.ind {
+        return dst
+    }
+    return appendNewline(dst, self.prefix, self.indent, depth)
+}
+
+type canonicalMember struct {
+    key string
+    off int
+    end int
+}
+
+func (self *canonicalizer) error(msg string) error {
+    return fmt.Errorf("canonicalize JSON at %d: %s", self.pos, msg)
+}
+
+func (self *canonicalizer) skip() {
+    for self.pos < len(self.src) {
+        switch self.src[self.pos] {
+            case ' ', '\t', '\n', '\r' : self.pos++
+            default                    : return
+        }
+    }
+}
+
+func (self *canonicalizer) value(dst []byte, depth int) ([]byte, error) {
+    if self.skip(); self.pos >= len(self.src) {
+        return dst, self.error("unexpected end of input")
+    }
+
+    /* dispatch by the first char */
+    switch c := self.src[self.pos]; {
+        case c == '{'             : return self.object(dst, depth + 1)
+        case c == '['             : return self.array(dst, depth + 1)
+        case c == '"'             : return self.string(dst)
+        case c == 't'             : return self.literal(dst, "true")
+        case c == 'f'             : return self.literal(dst, "false")
+        case c == 'n'             : return self.literal(dst, "null")
+        case c == '-' || isDigit(c) : return self.number(dst)
+        default                   : return dst, self.error("invalid char")
+    }
+}
+
+func (self *canonicalizer) literal(dst []byte, lit string) ([]byte, error) {
+    if len(self.src) - self.pos < len(lit) || string(self.src[self.pos:self.pos + len(lit)]) != lit {
+        return dst, self.error("invalid literal")
+    }
+    self.pos += len(lit)
+    return append(dst, lit...), nil
+}
+
+func (self *canonicalizer) array(dst []byte, depth int) ([]byte, error) {
+    if depth > _MaxCanonicalDepth {
+        return dst, self.error("nesting too deep")
+    }
+
+    /* check for empty array */
+    self.pos++
+    dst = append(dst, '[')
+    if self.skip(); self.pos < len(self.src) && self.src[self.pos] == ']' {
+        self.pos++
+        return append(dst, ']'), nil
+    }
+
+    /* canonicalize each element */
+    for {
+        var err error
+        if dst, err = self.value(self.newline(dst, depth), depth); err != nil {
+            return dst, err
+        }
+
+        /* check for the delimiter */
+        if self.skip(); self.pos >= len(self.src) {
+            return dst, self.error("unexpected end of input")
+        } else if c := self.src[self.pos]; c == ']' {
+            self.pos++
+            return append(self.newline(dst, depth - 1), ']'), nil
+        } else if c != ',' {
+            return dst, self.error("expect ',' or ']'")
+        }
+        self.pos++
+        dst = append(dst, ',')
+    }
+}
+
+func (self *canonicalizer) object(dst []byte, depth int) ([]byte, error) {
+    if depth > _MaxCanonicalDepth {
+        return dst, self.error("nesting too deep")
+    }
+
+    /* check for empty object */
+    self.pos++
+    if self.skip(); self.pos < len(self.src) && self.src[self.pos] == '}' {
+        self.pos++
+        return append(dst, '{', '}'), nil
+    }
+
+    /* canonicalize the values after dst, and sort the members later */
+    var err error
+    var ms []canonicalMember
+    start := len(dst)
+    for {
+        var key string
+        if self.skip(); self.pos >= len(self.src) || self.src[self.pos] != '"' {
+            return dst, self.error("expect object key")
+        } else if key, err = self.unquote(); err != nil {
+            return dst, err
+        }
+
+        /* check for the colon */
+        if self.skip(); self.pos >= len(self.src) || self.src[self.pos] != ':' {
+            return dst, self.error("expect ':'")
+        }
+        self.pos++
+
+        /* canonicalize the value */
+        off := len(dst)
+        if dst, err = self.value(dst, depth); err != nil {
+            return dst, err
+        }
+        ms = append(ms, canonicalMember{key, off - start, len(dst) - start})
+
+        /* check for the delimiter */
+        if self.skip(); self.pos >= len(self.src) {
+            return dst, self.error("unexpected end of input")
+        } else if c := self.src[self.pos]; c == '}' {
+            self.pos++
+            break
+        } else if c != ',' {
+            return dst, self.error("expect ',' or '}'")
+        }
+        self.pos++
+    }
+
+    /* sort the members by the UTF-16 code units of keys */
+    sort.SliceStable(ms, func(i, j int) bool {
+        return LessUTF16(ms[i].key, ms[j].key)
+    })
+
+    /* the keys must be unique */
+    for i := 1; i < len(ms); i++ {
+        if ms[i].key == ms[i - 1].key {
+            return dst, self.error("duplicate key " + strconv.Quote(ms[i].key))
+        }
+    }
+
+    /* dump the sorted members */
+    vals := append([]byte(nil), dst[start:]...)
+    dst = append(dst[:start], '{')
+    for i, m := range ms {
+        if i != 0 {
+            dst = append(dst, ',')
+        }
+        dst = appendCanonical(self.newline(dst, depth), m.key)
+        if dst = append(dst, ':'); self.ind {
+            dst = append(dst, ' ')
+        }
+        dst = append(dst, vals[m.off:m.end]...)
+    }
+    return append(self.newline(dst, depth - 1), '}'), nil
+}
+
+func (self *canonicalizer) string(dst []byte) ([]byte, error) {
+    if str, err := self.unquote(); err != nil {
+        return dst, err
+    } else {
+        return appendCanonical(dst, str), nil
+    }
+}
+
+func (self *canonicalizer) unquote() (string, error) {
+    var buf []byte
+    self.pos++
+
+    /* unescape until the closing quote */
+    for self.pos < len(self.src) {
+        switch c := self.src[self.pos]; {
+            case c == '"': {
+                self.pos++
+                return string(buf), nil
+            }
+            case c == '\\': {
+                r, err := self.escaped()
+                if err != nil {
+                    return "", err
+                }
+                var rb [utf8.UTFMax]byte
+                buf = append(buf, rb[:utf8.EncodeRune(rb[:], r)]...)
+            }
+            case c < 0x20: {
+                return "", self.error("control char in string")
+            }
+            case c < utf8.RuneSelf: {
+                buf = append(buf, c)
+                self.pos++
+            }
+            default: {
+                r, n := utf8.DecodeRune(self.src[self.pos:])
+                if r == utf8.RuneError && n == 1 {
+                    if !self.fix {
+                        return "", self.error("invalid UTF-8")
+                    }
+                    buf = append(buf, "\ufffd"...)
+                    self.pos++
+                    continue
+                }
+                buf = append(buf, self.src[self.pos:self.pos + n]...)
+                self.pos += n
+            }
+        }
+    }
+    return "", self.error("unexpected end of input")
+}
+
+func (self *canonicalizer) escaped() (rune, error) {
+    if self.pos + 1 >= len(self.src) {
+        return 0, self.error("unexpected end of input")
+    }
+
+    /* simple escape chars */
+    c := self.src[self.pos + 1]
+    self.pos += 2
+    switch c {
+        case '"', '\\', '/' : return rune(c), nil
+        case 'b'            : return '\b', nil
+        case 'f'            : return '\f', nil
+        case 'n'            : return '\n', nil
+        case 'r'            : return '\r', nil
+        case 't'            : return '\t', nil
+        case 'u'            : break
+        default             : return 0, self.error("invalid escape char")
+    }
+
+    /* unicode escapes, which may be surrogate pairs */
+    r, err := self.hex4()
+    if err != nil {
+        return 0, err
+    } else if !utf16.IsSurrogate(r) {
+        return r, nil
+    } else if r >= 0xdc00 || self.pos + 1 >= len(self.src) || self.src[self.pos] != '\\' || self.src[self.pos + 1] != 'u' {
+        return 0, self.error("invalid surrogate")
+    }
+
+    /* decode the low surrogate */
+    self.pos += 2
+    lo, err := self.hex4()
+    if err != nil {
+        return 0, err
+    } else if r = utf16.DecodeRune(r, lo); r == utf8.RuneError {
+        return 0, self.error("invalid surrogate")
+    } else {
+        return r, nil
+    }
+}
+
+func (self *canonicalizer) hex4() (rune, error) {
+    if self.pos + 4 > len(self.src) {
+        return 0, self.error("unexpected end of input")
+    }
+    v, err := strconv.ParseUint(string(self.src[self.pos:self.pos + 4]), 16, 16)
+    if err != nil {
+        return 0, self.error("invalid unicode escape")
+    }
+    self.pos += 4
+    return rune(v), nil
+}
+
+func (self *canonicalizer) number(dst []byte) ([]byte, error) {
+    i := self.pos
+    if self.src[i] == '-' {
+        i++
+    }
+
+    /* integer part, which has no leading zeros */
+    if i < len(self.src) && self.src[i] == '0' {
+        i++
+    } else if i < len(self.src) && isDigit(self.src[i]) {
+        for i < len(self.src) && isDigit(self.src[i]) { i++ }
+    } else {
+        return dst, self.error("invalid number")
+    }
+
+    /* fraction part */
+    if i < len(self.src) && self.src[i] == '.' {
+        if i++; i >= len(self.src) || !isDigit(self.src[i]) {
+            return dst, self.error("invalid number")
+        }
+        for i < len(self.src) && isDigit(self.src[i]) { i++ }
+    }
+
+    /* exponent part */
+    if i < len(self.src) && (self.src[i] == 'e' || self.src[i] == 'E') {
+        if i++; i < len(self.src) && (self.src[i] == '+' || self.src[i] == '-') {
+            i++
+        }
+        if i >= len(self.src) || !isDigit(self.src[i]) {
+            return dst, self.error("invalid number")
+        }
+        for i < len(self.src) && isDigit(self.src[i]) { i++ }
+    }
+
+    /* the number must fit in doubles */
+    f, err := strconv.ParseFloat(string(self.src[self.pos:i]), 64)
+    if err != nil {
+        return dst, self.error("number out of range")
+    }
+    self.pos = i
+    return F64toES6(dst, f), nil
+}
+
+func isDigit(c byte) bool {
+    return c >= '0' && c <= '9'
+}
+
+// F64toES6 appends f serialized as the Number.prototype.toString() of ECMAScript,
+// which is required by RFC 8785. The f must be a finite number.
+func F64toES6(dst []byte, f float64) []byte {
+    if f == 0 {
+        return append(dst, '0')
+    } else if math.IsNaN(f) || math.IsInf(f, 0) {
+        panic("F64toES6: NaN or Infinity")
+    } else if f < 0 {
+        dst = append(dst, '-')
+        f = -f
+    }
+
+    /* the shortest digits, and the exponent of the form 0.ddd x 10^n */
+    var buf [32]byte
+    s := strconv.AppendFloat(buf[:0], f, 'e', -1, 64)
+    e := 0
+    ds := make([]byte, 0, 17)
+    for i, c := range s {
+        if c == 'e' {
+            e, _ = strconv.Atoi(string(s[i + 1:]))
+            break
+        } else if c != '.' {
+            ds = append(ds, c)
+        }
+    }
+    k, n := len(ds), e + 1
+
+    /* choose the notation as ECMAScript does */
+    switch {
+        case k <= n && n <= 21: {
+            dst = append(dst, ds...)
+            for i := k; i < n; i++ {
+                dst = append(dst, '0')
+            }
+        }
+        case 0 < n && n <= 21: {
+            dst = append(dst, ds[:n]...)
+            dst = append(dst, '.')
+            dst = append(dst, ds[n:]...)
+        }
+        case -6 < n && n <= 0: {
+            dst = append(dst, '0', '.')
+            for i := n; i < 0; i++ {
+                dst = append(dst, '0')
+            }
+            dst = append(dst, ds...)
+        }
+        default: {
+            dst = append(dst, ds[0])
+            if k > 1 {
+                dst = append(dst, '.')
+                dst = append(dst, ds[1:]...)
+            }
+            dst = append(dst, 'e')
+            if n - 1 >= 0 {
+                dst = append(dst, '+')
+            }
+            dst = strconv.AppendInt(dst, int64(n - 1), 10)
+        }
+    }
+    return dst
+}
+
+// FloatES6 appends the finite v serialized as ECMAScript does, where v is widened
+// from its shortest decimal form if it is a float32 (bits is 32).
+func FloatES6(dst []byte, v float64, bits int) []byte {
+    if bits == 32 {
+        v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', -1, 32), 64)
+    }
+    return F64toES6(dst, v)
+}
+
+// NumberES6 appends the json.Number num serialized as ECMAScript does, which
+// must fit in doubles. The empty num is "0".
+func NumberES6(dst []byte, num string) ([]byte, error) {
+    if num == "" {
+        return append(dst, '0'), nil
+    } else if !IsValidNumber(num) {
+        return dst, vars.Error_number(json.Number(num))
+    } else if f, err := strconv.ParseFloat(num, 64); err != nil {
+        return dst, vars.Error_number(json.Number(num))
+    } else {
+        return F64toES6(dst, f), nil
+    }
+}
+
+// LessUTF16 compares a and b by their UTF-16 code units, which is the order of
+// the object keys in the canonical form.
+func LessUTF16(a string, b string) bool {
+    for a != "" && b != "" {
+        ra, na := utf8.DecodeRuneInString(a)
+        rb, nb := utf8.DecodeRuneInString(b)
+
+        /* only the surrogates differ from the order of code points */
+        if ra != rb {
+            ha, hb := ra, rb
+            if ra >= 0x10000 {
+                ha, _ = utf16.EncodeRune(ra)
+            }
+            if rb >= 0x10000 {
+                hb, _ = utf16.EncodeRune(rb)
+            }
+            if ha != hb {
+                return ha < hb
+            } else {
+                return ra < rb
+            }
+        }
+        a, b = a[na:], b[nb:]
+    }
+    return len(a) < len(b)
+}
+
+const _HexChars = "0123456789abcdef"
+
+// QuoteCanonical appends str quoted with the minimal escapes of RFC 8785, and
+// fails if str is not valid UTF-8, unless fix is true, with which the invalid
+// bytes are replaced with U+FFFD.
+func QuoteCanonical(dst []byte, str string, fix bool) ([]byte, error) {
+    if utf8.ValidString(str) {
+        return appendCanonical(dst, str), nil
+    } else if !fix {
+        return dst, vars.Error_invalid_utf8(str)
+    }
+
+    /* replace each invalid byte, like ValidateString does */
+    buf := make([]byte, 0, len(str) + 8)
+    for i := 0; i < len(str); {
+        r, n := utf8.DecodeRuneInString(str[i:])
+        if r == utf8.RuneError && n == 1 {
+            buf = append(buf, "\ufffd"...)
+        } else {
+            buf = append(buf, str[i:i + n]...)
+        }
+        i += n
+    }
+    return appendCanonical(dst, rt.Mem2Str(buf)), nil
+}
+
+// appendCanonical quotes the valid UTF-8 str with the minimal escapes.
+func appendCanonical(dst []byte, str string) []byte {
+    dst = append(dst, '"')
+    for i := 0; i < len(str); i++ {
+        switch c := str[i]; {
+            case c == '"'  : dst = append(dst, '\\', '"')
+            case c == '\\' : dst = append(dst, '\\', '\\')
+            case c == '\b' : dst = append(dst, '\\', 'b')
+            case c == '\f' : dst = append(dst, '\\', 'f')
+            case c == '\n' : dst = append(dst, '\\', 'n')
+            case c == '\r' : dst = append(dst, '\\', 'r')
+            case c == '\t' : dst = append(dst, '\\', 't')
+            case c < 0x20  : dst = append(dst, '\\', 'u', '0', '0', _HexChars[c >> 4], _HexChars[c & 0xf])
+            default        : dst = append(dst, c)
+        }
+    }
+    return append(dst, '"')
+}
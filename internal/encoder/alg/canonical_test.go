@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `encoding/json`
+    `math`
+    `testing`
+)
+
+func TestCanonicalize_RFC8785(t *testing.T) {
+    var cases = []struct {
+        src string
+        exp string
+    }{
+        {
+            `{
+                "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+                "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+                "literals": [null, true, false]
+            }`,
+            `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+        },
+        {
+            `{
+                "\u20ac": "Euro Sign",
+                "\r": "Carriage Return",
+                "\ufb33": "Hebrew Letter Dalet With Dagesh",
+                "1": "One",
+                "\ud83d\ude00": "Emoji: Grinning Face",
+                "\u0080": "Control",
+                "\u00f6": "Latin Small Letter O With Diaeresis"
+            }`,
+            "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\"," +
+            "\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+        },
+        {` [ ] `, `[]`},
+        {`{"b":{"d":[1,{"f":2,"e":1}],"c":{}},"a":"<&>"}`, `{"a":"<&>","b":{"c":{},"d":[1,{"e":1,"f":2}]}}`},
+        {`"\u2028\u007f\/"`, "\"\u2028\u007f/\""},
+        {`-0`, `0`},
+    }
+    for _, c := range cases {
+        out, err := Canonicalize(nil, []byte(c.src))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if string(out) != c.exp {
+            t.Fatalf("canonicalize %s:\nexpect %s\nactual %s", c.src, c.exp, out)
+        }
+    }
+}
+
+func TestCanonicalize_Invalid(t *testing.T) {
+    for _, src := range []string{
+        ``, `{`, `[1,]`, `{"a" 1}`, `{"a":1,}`, `01`, `1.`, `1e`, `1e400`, `nul`,
+        `"\ud800"`, `"\udc00\ud800"`, "\"\x01\"", "\"\xff\"", `"\x"`, `1 2`,
+    } {
+        if out, err := Canonicalize(nil, []byte(src)); err == nil {
+            t.Fatalf("%q should be invalid, got %q", src, out)
+        }
+    }
+}
+
+func TestF64toES6(t *testing.T) {
+    var cases = []struct {
+        bits uint64
+        exp  string
+    }{
+        {0x0000000000000000, "0"},
+        {0x8000000000000000, "0"},
+        {0x0000000000000001, "5e-324"},
+        {0x8000000000000001, "-5e-324"},
+        {0x7fefffffffffffff, "1.7976931348623157e+308"},
+        {0xffefffffffffffff, "-1.7976931348623157e+308"},
+        {0x4340000000000000, "9007199254740992"},
+        {0xc340000000000000, "-9007199254740992"},
+        {0x4430000000000000, "295147905179352830000"},
+        {0x44b52d02c7e14af5, "9.999999999999997e+22"},
+        {0x44b52d02c7e14af6, "1e+23"},
+        {0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+        {0x444b1ae4d6e2ef4e, "999999999999999700000"},
+        {0x444b1ae4d6e2ef50, "1e+21"},
+        {0x3eb0c6f7a0b5ed8d, "0.000001"},
+        {0x3eb0c6f7a0b5ed8e, "0.0000010000000000000002"},
+        {0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+    }
+    for _, c := range cases {
+        if out := string(F64toES6(nil, math.Float64frombits(c.bits))); out != c.exp {
+            t.Fatalf("%016x: expect %s, actual %s", c.bits, c.exp, out)
+        }
+    }
+}
+
+func TestIsValidNumber(t *testing.T) {
+    for _, s := range []string{"0", "-0", "1", "-12", "0.5", "1.25e10", "1E+2", "-0.0e-0", "123456789012345678901234567890"} {
+        if !IsValidNumber(s) || !json.Valid([]byte(s)) {
+            t.Fatalf("%q should be valid", s)
+        }
+    }
+    for _, s := range []string{"", "-", "01", "+1", ".5", "1.", "1e", "1e+", "0x1", "1 ", " 1", "NaN", "1.5.5", "--1"} {
+        if IsValidNumber(s) {
+            t.Fatalf("%q should be invalid", s)
+        }
+    }
+}
+
+func TestLessUTF16(t *testing.T) {
+    if !LessUTF16("\U0001F600", "\uFB33") {
+        t.Fatal("surrogates should be less than U+FB33")
+    }
+    if LessUTF16("\uFB33", "\U0001F600") {
+        t.Fatal("U+FB33 should be greater than surrogates")
+    }
+    if !LessUTF16("a", "ab") || LessUTF16("ab", "a") || LessUTF16("a", "a") {
+        t.Fatal("invalid prefix order")
+    }
+}
@@ -0,0 +1,367 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `bytes`
+    `encoding/binary`
+    `unicode/utf16`
+    `unicode/utf8`
+
+    `github.com/bytedance/sonic/internal/encoder/vars`
+    `github.com/bytedance/sonic/internal/rt`
+)
+
+// the escaping options which select the table of the candidate bytes
+const (
+    escASCII = 1 << iota
+    escSlash
+    escHTML
+    escFix
+)
+
+// escapeTabs are the bytes which may need escaping, for each set of the options.
+var escapeTabs [16][256]bool
+
+// escapeWords are the printable ASCII bytes and 0xe2 in escapeTabs, repeated in the
+// words to scan 8 bytes at a time. The control characters and the non-ASCII bytes are
+// matched by their ranges instead.
+var escapeWords [16][]uint64
+
+const (
+    _SWAR_lo = 0x0101010101010101
+    _SWAR_hi = 0x8080808080808080
+    _SWAR_sp = 0x2020202020202020
+)
+
+func init() {
+    for f := range escapeTabs {
+        tab := &escapeTabs[f]
+        for c := 0; c < 0x20; c++ {
+            tab[c] = true
+        }
+        tab['"'], tab['\\'] = true, true
+        if f & escSlash != 0 {
+            tab['/'] = true
+        }
+        if f & escHTML != 0 {
+            tab['<'], tab['>'], tab['&'] = true, true, true
+            tab[0xe2] = true // the leading byte of U+2028 and U+2029
+        }
+        if f & (escASCII | escFix) != 0 {
+            for c := 0x80; c < 0x100; c++ {
+                tab[c] = true
+            }
+        }
+        for c := 0x20; c < 0x80; c++ {
+            if tab[c] {
+                escapeWords[f] = append(escapeWords[f], _SWAR_lo * uint64(c))
+            }
+        }
+        if tab[0xe2] && !tab[0x80] {
+            escapeWords[f] = append(escapeWords[f], _SWAR_lo * 0xe2)
+        }
+    }
+}
+
+// escaper escapes the characters of the string literals for the options, which
+// are the flags of the encoder and the extra characters of set.
+//
+// The clean runs are skipped 8 bytes at a time, unless there are too many bytes
+// to match in words.
+type escaper struct {
+    tab   *[256]bool
+    words [8]uint64 // the bytes to match, repeated in the words
+    nw    int       // the number of the bytes, which may overflow words
+    high  bool      // all the non-ASCII bytes are candidates
+    hi    bool
+    ascii bool
+    slash bool
+    html  bool
+    fix   bool
+    set   *vars.EscapeSet
+}
+
+func newEscaper(fv uint64, set *vars.EscapeSet) escaper {
+    f := 0
+    if fv & (1 << BitEscapeASCII) != 0 {
+        f |= escASCII
+    }
+    if fv & (1 << BitEscapeSlash) != 0 {
+        f |= escSlash
+    }
+    if fv & (1 << BitEscapeHTML) != 0 {
+        f |= escHTML
+    }
+    if fv & (1 << BitValidateString) != 0 {
+        f |= escFix
+    }
+    ret := escaper{
+        tab   : &escapeTabs[f],
+        hi    : set != nil && set.NonASCII(),
+        ascii : f & escASCII != 0,
+        slash : f & escSlash != 0,
+        html  : f & escHTML != 0,
+        fix   : f & escFix != 0,
+        set   : set,
+    }
+
+    /* the words to match, padded with the first one */
+    ret.high = ret.hi || escapeTabs[f][0x80]
+    ret.nw = copy(ret.words[:], escapeWords[f])
+    if set != nil {
+        for _, c := range set.Bytes() {
+            if ret.nw < len(ret.words) {
+                ret.words[ret.nw] = _SWAR_lo * uint64(c)
+            }
+            ret.nw++
+        }
+    }
+    for i := ret.nw; i < len(ret.words); i++ {
+        ret.words[i] = ret.words[0]
+    }
+    return ret
+}
+
+// QuoteEscaped appends the JSON string of s to dst, with the characters changed
+// to the \uXXXX form if:
+//   - BitEscapeASCII is set and the character is non-ASCII, using surrogate pairs
+//     for the supplementary characters, and \ufffd for the invalid UTF-8 bytes;
+//   - BitEscapeSlash is set and the character is '/', which is changed to `\/`;
+//   - BitEscapeHTML is set and the character is one of <, >, &, U+2028 and U+2029;
+//   - the character is in set, which can be nil.
+// The invalid UTF-8 bytes are also replaced by \ufffd if BitValidateString is set.
+func QuoteEscaped(dst []byte, s string, fv uint64, set *vars.EscapeSet) []byte {
+    e := newEscaper(fv, set)
+    dst = append(dst, '"')
+    return append(e.escape(dst, s, false), '"')
+}
+
+// EscapeText appends the JSON text src to dst, with the string literals escaped
+// as QuoteEscaped does. The escape sequences in src are kept as they are.
+func EscapeText(dst []byte, src []byte, fv uint64, set *vars.EscapeSet) []byte {
+    e := newEscaper(fv, set)
+    for {
+        n := bytes.IndexByte(src, '"')
+        if n < 0 {
+            return append(dst, src...)
+        }
+        dst = append(dst, src[:n + 1]...)
+        src = src[n + 1:]
+
+        /* find the end of the string literal */
+        i := literalEnd(src)
+        if i >= len(src) {
+            return e.escape(dst, rt.Mem2Str(src), true)
+        }
+
+        /* escape the string literal */
+        dst = append(e.escape(dst, rt.Mem2Str(src[:i]), true), '"')
+        src = src[i + 1:]
+    }
+}
+
+// literalEnd returns the position of the closing quote of the string literal,
+// which src begins right after the opening quote, or len(src) if not found.
+func literalEnd(src []byte) int {
+    i := 0
+    for i < len(src) && src[i] != '"' {
+        if src[i] == '\\' {
+            i++
+        }
+        i++
+    }
+    if i > len(src) {
+        return len(src)
+    }
+    return i
+}
+
+// escape appends the string literal s without the quotes to dst, which keeps the
+// escape sequences in s if raw is true.
+func (self *escaper) escape(dst []byte, s string, raw bool) []byte {
+    p := 0
+    for i := 0; ; {
+        i = self.seek(s, i)
+        if i >= len(s) {
+            break
+        }
+        c := s[i]
+
+        /* the escape sequences of JSON text */
+        if raw && c == '\\' {
+            if i + 1 < len(s) && s[i + 1] == 'u' {
+                i += 6
+            } else {
+                i += 2
+            }
+            continue
+        }
+
+        /* the ASCII characters */
+        if c < utf8.RuneSelf {
+            dst = append(dst, s[p:i]...)
+            switch {
+                case c == '"' || c == '\\' : dst = append(dst, '\\', c)
+                case c == '\n'              : dst = append(dst, `\n`...)
+                case c == '\r'              : dst = append(dst, `\r`...)
+                case c == '\t'              : dst = append(dst, `\t`...)
+                case c == '/' && self.slash : dst = append(dst, `\/`...)
+                default                     : dst = appendEscapedRune(dst, rune(c))
+            }
+            i++
+            p = i
+            continue
+        }
+
+        /* the non-ASCII characters */
+        r, n := utf8.DecodeRuneInString(s[i:])
+        if r == utf8.RuneError && n == 1 {
+            if self.ascii || self.fix {
+                dst = append(dst, s[p:i]...)
+                dst = append(dst, `\ufffd`...)
+                p = i + 1
+            }
+        } else if self.escapeRune(r) {
+            dst = append(dst, s[p:i]...)
+            dst = appendEscapedRune(dst, r)
+            p = i + n
+        }
+        i += n
+    }
+    if p < len(s) {
+        dst = append(dst, s[p:]...)
+    }
+    return dst
+}
+
+// seek returns the position of the first candidate in s starting from i.
+func (self *escaper) seek(s string, i int) int {
+    if self.nw <= len(self.words) {
+        i = self.skip(rt.Str2Mem(s), i)
+    }
+    if self.set == nil {
+        for i < len(s) && !self.tab[s[i]] {
+            i++
+        }
+        return i
+    }
+    for i < len(s) {
+        if c := s[i]; self.tab[c] || self.set.HasByte(c) || (self.hi && c >= utf8.RuneSelf) {
+            break
+        }
+        i++
+    }
+    return i
+}
+
+// skip returns the position of the first word of b which may contain any candidate,
+// starting from i. The remaining bytes less than a word are not checked.
+func (self *escaper) skip(b []byte, i int) int {
+    hm := uint64(0)
+    if self.high {
+        hm = _SWAR_hi
+    }
+
+    /* the unrolled matches of the words */
+    p := &self.words
+    p0, p1, p2, p3 := p[0], p[1], p[2], p[3]
+    p4, p5, p6, p7 := p[4], p[5], p[6], p[7]
+
+    /* check 8 bytes at a time */
+    for ; i + 8 <= len(b); i += 8 {
+        w := binary.LittleEndian.Uint64(b[i:])
+        x0, x1, x2, x3 := w ^ p0, w ^ p1, w ^ p2, w ^ p3
+        m := (w - _SWAR_sp) & ^w | w & hm |
+            (x0 - _SWAR_lo) & ^x0 | (x1 - _SWAR_lo) & ^x1 |
+            (x2 - _SWAR_lo) & ^x2 | (x3 - _SWAR_lo) & ^x3
+        if self.nw > 4 {
+            x4, x5, x6, x7 := w ^ p4, w ^ p5, w ^ p6, w ^ p7
+            m |= (x4 - _SWAR_lo) & ^x4 | (x5 - _SWAR_lo) & ^x5 |
+                (x6 - _SWAR_lo) & ^x6 | (x7 - _SWAR_lo) & ^x7
+        }
+        if m & _SWAR_hi != 0 {
+            break
+        }
+    }
+    return i
+}
+
+func (self *escaper) escapeRune(r rune) bool {
+    return self.ascii ||
+        (self.html && (r == '\u2028' || r == '\u2029')) ||
+        (self.set != nil && self.set.HasRune(r))
+}
+
+func appendEscapedRune(dst []byte, r rune) []byte {
+    if r >= 0x10000 {
+        r1, r2 := utf16.EncodeRune(r)
+        dst = appendEscapedRune(dst, r1)
+        return appendEscapedRune(dst, r2)
+    }
+    return append(dst, '\\', 'u',
+        _HexChars[(r >> 12) & 0xf],
+        _HexChars[(r >> 8) & 0xf],
+        _HexChars[(r >> 4) & 0xf],
+        _HexChars[r & 0xf],
+    )
+}
+
+// the modes of EncodeString
+const (
+    StrPlain  = iota // a string
+    StrDouble        // a string quoted twice, for the "string" option
+    StrNumber        // a json.Number
+)
+
+// EncodeString appends the string s of mode to buf, for the options which the
+// native quoter does not support, see Escaped.
+func EncodeString(buf *[]byte, s string, sb *vars.Stack, fv uint64, mode int) (err error) {
+    if fv & (1 << BitCanonical) == 0 {
+        switch mode {
+            case StrPlain  : *buf = QuoteEscaped(*buf, s, fv, sb.EscapeSet())
+            case StrNumber : *buf = append(*buf, s...)
+            default        : *buf = QuoteEscaped(*buf, rt.Mem2Str(QuoteEscaped(nil, s, 0, nil)), fv, sb.EscapeSet())
+        }
+        return nil
+    }
+
+    /* the canonical form */
+    fix := fv & (1 << BitValidateString) != 0
+    switch mode {
+        case StrPlain  : *buf, err = QuoteCanonical(*buf, s, fix)
+        case StrNumber : *buf, err = NumberES6(*buf, s)
+        default        : {
+            var str []byte
+            if str, err = QuoteCanonical(nil, s, fix); err == nil {
+                *buf = appendCanonical(*buf, rt.Mem2Str(str))
+            }
+        }
+    }
+    return
+}
+
+// EncodeText appends the JSON text to buf, with its string literals escaped for
+// the options which the native quoter does not support, see Escaped.
+func EncodeText(buf *[]byte, text string, sb *vars.Stack, fv uint64) (err error) {
+    if fv & (1 << BitCanonical) == 0 {
+        *buf = EscapeText(*buf, rt.Str2Mem(text), fv, sb.EscapeSet())
+        return nil
+    }
+    *buf, err = canonicalize(*buf, rt.Str2Mem(text), fv & (1 << BitValidateString) != 0)
+    return
+}
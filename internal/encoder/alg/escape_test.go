@@ -0,0 +1,159 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `strings`
+    `testing`
+
+    `github.com/bytedance/sonic/internal/encoder/vars`
+)
+
+func escapeFlags(ascii bool, slash bool) uint64 {
+    fv := uint64(0)
+    if ascii {
+        fv |= 1 << BitEscapeASCII
+    }
+    if slash {
+        fv |= 1 << BitEscapeSlash
+    }
+    return fv
+}
+
+func TestEscapeText(t *testing.T) {
+    var cases = []struct {
+        src   string
+        ascii bool
+        slash bool
+        chars string
+        exp   string
+    }{
+        {`{"a/b":["é/\"\\", 1, true]}`, false, false, "", `{"a/b":["é/\"\\", 1, true]}`},
+        {`{"a/b":["é/\"\\", 1, true]}`, true, false, "", `{"a/b":["\u00e9/\"\\", 1, true]}`},
+        {`{"a/b":["é/\"\\", 1, true]}`, false, true, "", `{"a\/b":["é\/\"\\", 1, true]}`},
+        {`["😀中", "\u00e9a"]`, true, false, "", `["\ud83d\ude00\u4e2d", "\u00e9a"]`},
+        {"[\"a\xffb\"]", true, false, "", `["a\ufffdb"]`},
+        {"[\"a\xffb\"]", false, false, "", "[\"a\xffb\"]"},
+        {`{"true":"a'<é中"}`, false, false, "'<中et\"\\\x01", `{"\u0074ru\u0065":"a\u0027\u003cé\u4e2d"}`},
+        {`"/abc/"`, false, true, "/", `"\/abc\/"`},
+        {`"/abc/"`, false, false, "/", `"\u002fabc\u002f"`},
+        {`"unterminated /`, false, true, "", `"unterminated \/`},
+        {`"\`, false, true, "", `"\`},
+        {`"abcdefghijklmnopqrstuvwxyz"`, false, false, "abcdefghijklmnop", `"` +
+            `\u0061\u0062\u0063\u0064\u0065\u0066\u0067\u0068\u0069\u006a\u006b\u006c\u006d\u006e\u006f\u0070` +
+            `qrstuvwxyz"`},
+    }
+    for _, c := range cases {
+        var set *vars.EscapeSet
+        if c.chars != "" {
+            set = vars.NewEscapeSet(c.chars)
+        }
+        if out := string(EscapeText(nil, []byte(c.src), escapeFlags(c.ascii, c.slash), set)); out != c.exp {
+            t.Fatalf("escape %q:\nexpect %s\nactual %s", c.src, c.exp, out)
+        }
+    }
+}
+
+func TestQuoteEscaped(t *testing.T) {
+    var cases = []struct {
+        src   string
+        fv    uint64
+        chars string
+        exp   string
+    }{
+        {"a/\"\\\n\x01é", 0, "", `"a/\"\\\n\u0001é"`},
+        {"a/é😀", escapeFlags(true, true), "", `"a\/\u00e9\ud83d\ude00"`},
+        {"<a&b>\u2028", 1 << BitEscapeHTML, "", `"\u003ca\u0026b\u003e\u2028"`},
+        {"a\xffb", 1 << BitValidateString, "", `"a\ufffdb"`},
+        {"a\xffb", 0, "", "\"a\xffb\""},
+        {"it's é中", 0, "'中", `"it\u0027s é\u4e2d"`},
+    }
+    for _, c := range cases {
+        var set *vars.EscapeSet
+        if c.chars != "" {
+            set = vars.NewEscapeSet(c.chars)
+        }
+        if out := string(QuoteEscaped(nil, c.src, c.fv, set)); out != c.exp {
+            t.Fatalf("quote %q:\nexpect %s\nactual %s", c.src, c.exp, out)
+        }
+    }
+}
+
+func TestQuoteEscaped_Words(t *testing.T) {
+    var cases = []struct {
+        char  string
+        fv    uint64
+        chars string
+        exp   string
+    }{
+        {"\"", 0, "", `\"`},
+        {"\x1f", 0, "", `\u001f`},
+        {"/", escapeFlags(false, true), "", `\/`},
+        {"é", escapeFlags(true, false), "", `\u00e9`},
+        {"\u2028", 1 << BitEscapeHTML, "", `\u2028`},
+        {"&", 1 << BitEscapeHTML, "'", `\u0026`},
+        {"'", 0, "'", `\u0027`},
+        {"z", 0, "stuvwxyz", `\u007a`},
+        {"z", 0, "opqrstuvwxyz", `\u007a`},
+        {"中", 0, "中", `\u4e2d`},
+    }
+    for _, c := range cases {
+        var set *vars.EscapeSet
+        if c.chars != "" {
+            set = vars.NewEscapeSet(c.chars)
+        }
+
+        /* put the character at every position of the words */
+        for i := 0; i <= 20; i++ {
+            pad := strings.Repeat("a", i)
+            exp := `"` + pad + c.exp + pad + `"`
+            if out := string(QuoteEscaped(nil, pad + c.char + pad, c.fv, set)); out != exp {
+                t.Fatalf("quote %q at %d:\nexpect %s\nactual %s", c.char, i, exp, out)
+            }
+        }
+    }
+}
+
+func TestEscapeText_Long(t *testing.T) {
+    s := strings.Repeat("abcdefg/", 64)
+    src := `["` + s + `é` + s + `"]`
+    exp := `["` + strings.Replace(s, "/", `\/`, -1) + `\u00e9` + strings.Replace(s, "/", `\/`, -1) + `"]`
+    if out := string(EscapeText([]byte("x"), []byte(src), escapeFlags(true, true), nil)); out != "x" + exp {
+        t.Fatalf("expect %s\nactual %s", exp, out)
+    }
+}
+
+func BenchmarkQuoteEscaped_ASCII(b *testing.B) {
+    src := strings.Repeat("hello, world ", 64) + strings.Repeat("你好", 16)
+    dst := make([]byte, 0, len(src) * 2)
+    b.SetBytes(int64(len(src)))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        dst = QuoteEscaped(dst[:0], src, 1 << BitEscapeASCII, nil)
+    }
+}
+
+func BenchmarkQuoteEscaped_Clean(b *testing.B) {
+    src := strings.Repeat("hello, world ", 64)
+    set := vars.NewEscapeSet("'")
+    dst := make([]byte, 0, len(src) * 2)
+    b.SetBytes(int64(len(src)))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        dst = QuoteEscaped(dst[:0], src, escapeFlags(false, true), set)
+    }
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `math`
+    `strconv`
+    `unsafe`
+
+    `github.com/bytedance/sonic/internal/encoder/vars`
+)
+
+// EncodeFloat encodes the float32 (bits is 32) or float64 at p with ff, or as
+// ECMAScript does in the canonical form, regardless of ff.
+func EncodeFloat(buf *[]byte, p unsafe.Pointer, ff *vars.FloatFormat, bits int, fv uint64) error {
+    var v float64
+    if bits == 32 {
+        v = float64(*(*float32)(p))
+    } else {
+        v = *(*float64)(p)
+    }
+
+    /* check for NaN and infinities */
+    if math.IsNaN(v) || math.IsInf(v, 0) {
+        if fv&(1<<BitEncodeNullForInfOrNan) != 0 {
+            *buf = append(*buf, 'n', 'u', 'l', 'l')
+            return nil
+        }
+        return vars.ERR_nan_or_infinite
+    }
+
+    if fv & (1 << BitCanonical) != 0 {
+        *buf = FloatES6(*buf, v, bits)
+    } else {
+        *buf = FormatFloat(*buf, v, bits, ff)
+    }
+    return nil
+}
+
+// FormatFloat appends the finite v formatted with ff to buf, as a float32 if bits
+// is 32, or a float64 otherwise.
+func FormatFloat(buf []byte, v float64, bits int, ff *vars.FloatFormat) []byte {
+    if v == 0 {
+        if math.Signbit(v) && ff.NegativeZero {
+            buf = append(buf, '-')
+        }
+        v = 0
+    }
+
+    /* fixed number of decimals */
+    if ff.Fixed {
+        if ff.Exponent == vars.FloatExponentAlways {
+            return formatExp(buf, v, ff.Decimals, bits, false)
+        } else {
+            return strconv.AppendFloat(buf, v, 'f', ff.Decimals, bits)
+        }
+    }
+
+    /* digits after the decimal point in the exponent notation */
+    prec := -1
+    if ff.Precision > 0 {
+        prec = ff.Precision - 1
+    }
+
+    switch ff.Exponent {
+        case vars.FloatExponentAlways : return formatExp(buf, v, prec, bits, true)
+        case vars.FloatExponentNever  : return formatPlain(buf, v, prec, bits)
+    }
+
+    /* the shortest representation */
+    if prec < 0 {
+        if bits == 32 {
+            return F32toa(buf, float32(v))
+        } else {
+            return F64toa(buf, v)
+        }
+    }
+
+    /* use the exponent notation for the same range as the default encoding */
+    n := len(buf)
+    buf = formatExp(buf, v, prec, bits, true)
+    if exp := decimalExp(buf[n:]); exp < -6 || exp >= 21 {
+        return buf
+    } else {
+        return expToPlain(buf, n)
+    }
+}
+
+func formatPlain(buf []byte, v float64, prec int, bits int) []byte {
+    if prec < 0 {
+        return strconv.AppendFloat(buf, v, 'f', -1, bits)
+    }
+
+    /* round to the significant digits first */
+    n := len(buf)
+    buf = formatExp(buf, v, prec, bits, true)
+    return expToPlain(buf, n)
+}
+
+// expToPlain rewrites the float in buf[n:], which is formatted by formatExp with
+// trimming, without the exponent notation.
+func expToPlain(buf []byte, n int) []byte {
+    var tmp [32]byte
+    var dig [32]byte
+
+    /* take the sign, the digits and the exponent */
+    s := append(tmp[:0], buf[n:]...)
+    buf = buf[:n]
+    if s[0] == '-' {
+        buf = append(buf, '-')
+        s = s[1:]
+    }
+    exp := decimalExp(s)
+    d := append(dig[:0], s[0])
+    for _, c := range s[1:] {
+        if c == 'e' {
+            break
+        } else if c != '.' {
+            d = append(d, c)
+        }
+    }
+
+    /* place the decimal point */
+    switch {
+        case exp >= len(d) - 1 : {
+            buf = append(buf, d...)
+            for i := len(d) - 1; i < exp; i++ {
+                buf = append(buf, '0')
+            }
+        }
+        case exp >= 0: {
+            buf = append(buf, d[:exp + 1]...)
+            buf = append(buf, '.')
+            buf = append(buf, d[exp + 1:]...)
+        }
+        default: {
+            buf = append(buf, '0', '.')
+            for i := exp + 1; i < 0; i++ {
+                buf = append(buf, '0')
+            }
+            buf = append(buf, d...)
+        }
+    }
+    return buf
+}
+
+// formatExp formats v in the exponent notation like "1.5e+21" or "1e-7", and removes
+// the trailing zeros of the mantissa if trim is true.
+func formatExp(buf []byte, v float64, prec int, bits int, trim bool) []byte {
+    buf = strconv.AppendFloat(buf, v, 'e', prec, bits)
+
+    /* locate the exponent */
+    i := len(buf) - 1
+    for buf[i] != 'e' {
+        i--
+    }
+
+    /* remove the trailing zeros of the mantissa */
+    m := i
+    if trim && prec > 0 {
+        for buf[m - 1] == '0' {
+            m--
+        }
+        if buf[m - 1] == '.' {
+            m--
+        }
+    }
+
+    /* remove the leading zero of the 2-digit exponent */
+    e := len(buf)
+    if e - i == 4 && buf[i + 2] == '0' {
+        buf[i + 2] = buf[i + 3]
+        e--
+    }
+
+    /* move the exponent after the mantissa */
+    return append(buf[:m], buf[i:e]...)
+}
+
+// decimalExp returns the exponent of the float formatted in the exponent notation.
+func decimalExp(s []byte) int {
+    i := len(s) - 1
+    for s[i] != 'e' {
+        i--
+    }
+
+    /* parse the exponent */
+    exp := 0
+    for _, c := range s[i + 2:] {
+        exp = exp * 10 + int(c - '0')
+    }
+    if s[i + 1] == '-' {
+        exp = -exp
+    }
+    return exp
+}
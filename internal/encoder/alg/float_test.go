@@ -0,0 +1,83 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `math`
+    `testing`
+
+    `github.com/bytedance/sonic/internal/encoder/vars`
+)
+
+func TestFormatFloat(t *testing.T) {
+    var (
+        fixed2   = vars.FloatFormat{Fixed: true, Decimals: 2}
+        fixed0   = vars.FloatFormat{Fixed: true}
+        fixedExp = vars.FloatFormat{Fixed: true, Decimals: 2, Exponent: vars.FloatExponentAlways}
+        prec3    = vars.FloatFormat{Precision: 3}
+        prec3Exp = vars.FloatFormat{Precision: 3, Exponent: vars.FloatExponentAlways}
+        prec3Non = vars.FloatFormat{Precision: 3, Exponent: vars.FloatExponentNever}
+        exp      = vars.FloatFormat{Exponent: vars.FloatExponentAlways}
+        noExp    = vars.FloatFormat{Exponent: vars.FloatExponentNever}
+        negZero  = vars.FloatFormat{NegativeZero: true}
+    )
+    var cases = []struct {
+        v    float64
+        bits int
+        ff   vars.FloatFormat
+        exp  string
+    }{
+        {1.005, 64, fixed2, "1.00"},
+        {1.5, 64, fixed2, "1.50"},
+        {-12.345, 64, fixed2, "-12.35"},
+        {1e21, 64, fixed2, "1000000000000000000000.00"},
+        {2.5, 64, fixed0, "2"},
+        {1234.5, 64, fixedExp, "1.23e+3"},
+        {0, 64, fixedExp, "0.00e+0"},
+        {math.Copysign(0, -1), 64, fixed2, "0.00"},
+        {123456, 64, prec3, "123000"},
+        {0.000123456, 64, prec3, "0.000123"},
+        {1.5e-7, 64, prec3, "1.5e-7"},
+        {9.999, 64, prec3, "10"},
+        {1.23456e25, 64, prec3, "1.23e+25"},
+        {123456, 64, prec3Exp, "1.23e+5"},
+        {100, 64, prec3Exp, "1e+2"},
+        {1.23456e25, 64, prec3Non, "12300000000000000000000000"},
+        {1.5e-7, 64, prec3Non, "0.00000015"},
+        {1.5, 64, exp, "1.5e+0"},
+        {1e100, 64, exp, "1e+100"},
+        {1e21, 64, noExp, "1000000000000000000000"},
+        {1.5e-7, 64, noExp, "0.00000015"},
+        {math.Copysign(0, -1), 64, negZero, "-0"},
+        {math.Copysign(0, -1), 64, fixed2, "0.00"},
+        {-1.5, 64, negZero, "-1.5"},
+        {float64(float32(0.1)), 32, noExp, "0.1"},
+        {float64(float32(0.1)), 32, fixed2, "0.10"},
+        {float64(float32(16777216.0)), 32, exp, "1.6777216e+7"},
+    }
+    for _, c := range cases {
+        ff := c.ff
+        if out := string(FormatFloat([]byte("x"), c.v, c.bits, &ff)); out != "x" + c.exp {
+            t.Fatalf("format %v with %+v: expect %s, actual %s", c.v, c.ff, c.exp, out[1:])
+        }
+    }
+
+    ff := vars.FloatFormat{Fixed: true, Decimals: 2, NegativeZero: true}
+    if out := string(FormatFloat(nil, math.Copysign(0, -1), 64, &ff)); out != "-0.00" {
+        t.Fatalf("expect -0.00, actual %s", out)
+    }
+}
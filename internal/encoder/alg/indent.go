@@ -0,0 +1,126 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `github.com/bytedance/sonic/internal/encoder/vars`
+    `github.com/bytedance/sonic/internal/rt`
+)
+
+// IndentText appends the valid JSON text src to dst, indented as json.Indent does:
+// each element and member begins on a new line with prefix and indent repeated by
+// its depth, and the first line does not begin with prefix, so that src can be
+// indented at the current line of dst by passing its prefix.
+func IndentText(dst []byte, src []byte, prefix string, indent string) []byte {
+    return appendIndented(dst, src, nil, prefix, indent)
+}
+
+// EncodeIndentedText is like IndentText, but also escapes the string literals of
+// text as QuoteEscaped does, or writes its canonical form if BitCanonical is set.
+// The text must be valid JSON, except in the canonical form, which checks it.
+func EncodeIndentedText(buf *[]byte, text []byte, fv uint64, set *vars.EscapeSet, prefix string, indent string) (err error) {
+    if fv & (1 << BitCanonical) != 0 {
+        c := canonicalizer{src: text, fix: fv & (1 << BitValidateString) != 0, ind: true, prefix: prefix, indent: indent}
+        *buf, err = c.finish(*buf)
+        return
+    }
+    if fv & (1 << BitEscapeASCII | 1 << BitEscapeSlash | 1 << BitEscapeHTML | 1 << BitValidateString) == 0 && set == nil {
+        *buf = appendIndented(*buf, text, nil, prefix, indent)
+        return nil
+    }
+    e := newEscaper(fv, set)
+    *buf = appendIndented(*buf, text, &e, prefix, indent)
+    return nil
+}
+
+// appendIndented indents src into dst, with the string literals escaped by e if
+// it is not nil. The insignificant whitespaces of src are dropped.
+func appendIndented(dst []byte, src []byte, e *escaper, prefix string, indent string) []byte {
+    depth := 0
+    for i := 0; i < len(src); i++ {
+        switch c := src[i]; c {
+            case ' ', '\t', '\n', '\r':
+                continue
+
+            /* copy the string literal */
+            case '"':
+                n := literalEnd(src[i + 1:])
+                lit := src[i + 1:i + 1 + n]
+                if dst = append(dst, '"'); e != nil {
+                    dst = e.escape(dst, rt.Mem2Str(lit), true)
+                } else {
+                    dst = append(dst, lit...)
+                }
+                dst = append(dst, '"')
+                i += n + 1
+
+            /* the empty arrays and objects are kept as they are */
+            case '[', '{':
+                j := skipBlank(src, i + 1)
+                if dst = append(dst, c); j < len(src) && (src[j] == ']' || src[j] == '}') {
+                    dst = append(dst, src[j])
+                    i = j
+                    continue
+                }
+                depth++
+                dst = appendNewline(dst, prefix, indent, depth)
+
+            case ']', '}':
+                depth--
+                dst = append(appendNewline(dst, prefix, indent, depth), c)
+
+            case ',':
+                dst = appendNewline(append(dst, ','), prefix, indent, depth)
+
+            case ':':
+                dst = append(dst, ':', ' ')
+
+            /* copy the number or literal as a whole */
+            default:
+                j := i + 1
+                for j < len(src) && !isDelimiter(src[j]) {
+                    j++
+                }
+                dst = append(dst, src[i:j]...)
+                i = j - 1
+        }
+    }
+    return dst
+}
+
+func appendNewline(dst []byte, prefix string, indent string, depth int) []byte {
+    dst = append(dst, '\n')
+    dst = append(dst, prefix...)
+    for i := 0; i < depth; i++ {
+        dst = append(dst, indent...)
+    }
+    return dst
+}
+
+func skipBlank(src []byte, i int) int {
+    for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r') {
+        i++
+    }
+    return i
+}
+
+func isDelimiter(c byte) bool {
+    switch c {
+        case ' ', '\t', '\n', '\r', '"', '[', ']', '{', '}', ',', ':' : return true
+        default                                                       : return false
+    }
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `bytes`
+    `encoding/json`
+    `testing`
+)
+
+func TestIndentText(t *testing.T) {
+    var cases = []string{
+        `1`,
+        ` "a:b,[c]" `,
+        `[]`,
+        `[ ]`,
+        `{ }`,
+        `[1, -2.5e3, true, null, "x\"]"]`,
+        `{"a" : {"b":[ {}, [], {"c":[1,{"d":"}"}]}]}, "e" : "\\"}`,
+        "{\n\t\"a\": [\n\t\t1\n\t]\n}\n",
+    }
+    for _, src := range cases {
+        for _, ind := range [][2]string{{"", "  "}, {">", "\t"}, {"", ""}} {
+            var exp bytes.Buffer
+            if err := json.Indent(&exp, bytes.TrimSpace([]byte(src)), ind[0], ind[1]); err != nil {
+                t.Fatal(err)
+            }
+            if out := string(IndentText([]byte("x"), []byte(src), ind[0], ind[1])); out != "x" + exp.String() {
+                t.Fatalf("indent %q with %q:\nexpect %s\nactual %s", src, ind, "x" + exp.String(), out)
+            }
+        }
+    }
+}
+
+func TestEncodeIndentedText(t *testing.T) {
+    var cases = []struct {
+        src string
+        fv  uint64
+        exp string
+    }{
+        {`{"b/":["<é>"], "a":{}}`, 1 << BitEscapeASCII | 1 << BitEscapeSlash, "{\n>  \"b\\/\": [\n>    \"<\\u00e9>\"\n>  ],\n>  \"a\": {}\n>}"},
+        {`{"b":["<é>"]}`, 1 << BitEscapeHTML, "{\n>  \"b\": [\n>    \"\\u003cé\\u003e\"\n>  ]\n>}"},
+        {`{"b":[1.0E2, []], "a":{"d":1,"c":2}}`, 1 << BitCanonical, "{\n>  \"a\": {\n>    \"c\": 2,\n>    \"d\": 1\n>  },\n>  \"b\": [\n>    100,\n>    []\n>  ]\n>}"},
+        {`[]`, 1 << BitCanonical, `[]`},
+    }
+    for _, c := range cases {
+        var buf []byte
+        if err := EncodeIndentedText(&buf, []byte(c.src), c.fv, nil, ">", "  "); err != nil {
+            t.Fatal(err)
+        }
+        if string(buf) != c.exp {
+            t.Fatalf("indent %q:\nexpect %s\nactual %s", c.src, c.exp, buf)
+        }
+    }
+
+    /* the canonical form checks the text */
+    var buf []byte
+    if err := EncodeIndentedText(&buf, []byte(`{"a":1,"a":2}`), 1 << BitCanonical, nil, "", " "); err == nil {
+        t.Fatal("expect an error for the duplicate keys")
+    }
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `unsafe`
+)
+
+// MaxSafeInteger is the largest integer that JavaScript numbers can represent
+// exactly, which is 2^53 - 1.
+const MaxSafeInteger = 1 << 53 - 1
+
+// EncodeInt64 encodes the int64 at p, as a string if BitInt64AsString is set, or
+// BitLargeInt64AsString is set and v is out of [-MaxSafeInteger, MaxSafeInteger].
+// The unquoted large v is rounded to a double in the canonical form.
+func EncodeInt64(buf *[]byte, p unsafe.Pointer, fv uint64) {
+    v := *(*int64)(p)
+    large := v > MaxSafeInteger || v < -MaxSafeInteger
+    if quoteInt64(fv, large) {
+        *buf = append(I64toa(append(*buf, '"'), v), '"')
+    } else if large && fv & (1 << BitCanonical) != 0 {
+        *buf = F64toES6(*buf, float64(v))
+    } else {
+        *buf = I64toa(*buf, v)
+    }
+}
+
+// EncodeUint64 encodes the uint64 at p, as a string if BitInt64AsString is set, or
+// BitLargeInt64AsString is set and v is greater than MaxSafeInteger. The unquoted
+// large v is rounded to a double in the canonical form.
+func EncodeUint64(buf *[]byte, p unsafe.Pointer, fv uint64) {
+    v := *(*uint64)(p)
+    large := v > MaxSafeInteger
+    if quoteInt64(fv, large) {
+        *buf = append(U64toa(append(*buf, '"'), v), '"')
+    } else if large && fv & (1 << BitCanonical) != 0 {
+        *buf = F64toES6(*buf, float64(v))
+    } else {
+        *buf = U64toa(*buf, v)
+    }
+}
+
+func quoteInt64(fv uint64, large bool) bool {
+    return fv & (1 << BitInt64AsString) != 0 || (large && fv & (1 << BitLargeInt64AsString) != 0)
+}
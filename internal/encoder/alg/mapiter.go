@@ -19,6 +19,7 @@ package alg
 import (
 	"encoding"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 	"unsafe"
@@ -37,6 +38,7 @@ type MapIterator struct {
     It rt.GoMapIterator     // must be the first field
     kv rt.GoSlice           // slice of _MapPair
     ki int
+    tm bool                 // the non-string keys are encoded by MarshalText
 }
 
 var (
@@ -85,6 +87,11 @@ func (self *MapIterator) append(t *rt.GoType, k unsafe.Pointer, v unsafe.Pointer
     p := self.add()
     p.v = v
 
+    /* check for the text marshalers, which precede the integers like encoding/json */
+    if self.tm {
+        return self.appendConcrete(p, t, k)
+    }
+
     /* check for strings */
     if tk := t.Kind(); tk != reflect.String {
         return self.appendGeneric(p, t, tk, k)
@@ -170,7 +177,7 @@ func IteratorStart(t *rt.GoMapType, m *rt.GoMap, fv uint64) (*MapIterator, error
     rt.Mapiterinit(t, m, &it.It)
 
     /* check for key-sorting, empty map don't need sorting */
-    if m.Count == 0 || (fv & (1<<BitSortMapKeys)) == 0 {
+    if m.Count == 0 || (fv & (1<<BitSortMapKeys | 1<<BitCanonical)) == 0 {
         it.ki = -1
         return it, nil
     }
@@ -181,6 +188,8 @@ func IteratorStart(t *rt.GoMapType, m *rt.GoMap, fv uint64) (*MapIterator, error
     }
 
     /* dump all the key-value pairs */
+    kt := t.Key.Kind()
+    it.tm = kt != reflect.String && kt != reflect.Interface && t.Key.Pack().Implements(vars.EncodingTextMarshalerType)
     for ; it.It.K != nil; rt.Mapiternext(&it.It) {
         if err := it.append(t.Key, it.It.K, it.It.V); err != nil {
             IteratorStop(it)
@@ -189,8 +198,13 @@ func IteratorStart(t *rt.GoMapType, m *rt.GoMap, fv uint64) (*MapIterator, error
     }
 
     /* sort the keys, map with only 1 item don't need sorting */
-    if it.ki = 1; m.Count > 1 {
+    if it.ki = 1; m.Count > 1 && (fv & (1<<BitCanonical)) == 0 {
         radixQsort(it.data(), 0, maxDepth(it.kv.Len))
+    } else if m.Count > 1 {
+        if err := sortCanonical(it.data()); err != nil {
+            IteratorStop(it)
+            return nil, err
+        }
     }
 
     /* load the first pair into iterator */
@@ -199,6 +213,26 @@ func IteratorStart(t *rt.GoMapType, m *rt.GoMap, fv uint64) (*MapIterator, error
     return it, nil
 }
 
+// canonicalPairs sorts the pairs by the UTF-16 code units of keys, and the pairs
+// are swapped in the same way as radixQsort.
+type canonicalPairs []_MapPair
+
+func (self canonicalPairs) Len() int           { return len(self) }
+func (self canonicalPairs) Less(i, j int) bool { return LessUTF16(self[i].k, self[j].k) }
+func (self canonicalPairs) Swap(i, j int)      { swap(self, i, j) }
+
+// sortCanonical sorts the keys for the canonical form, which must be unique, since
+// the text of different keys may be the same.
+func sortCanonical(kvs []_MapPair) error {
+    sort.Sort(canonicalPairs(kvs))
+    for i := 1; i < len(kvs); i++ {
+        if kvs[i].k == kvs[i - 1].k {
+            return vars.Error_duplicate_key(kvs[i].k)
+        }
+    }
+    return nil
+}
+
 func asText(v unsafe.Pointer) (string, error) {
 	text := rt.AssertI2I(rt.UnpackType(vars.EncodingTextMarshalerType), *(*rt.GoIface)(v))
 	r, e := (*(*encoding.TextMarshaler)(unsafe.Pointer(&text))).MarshalText()
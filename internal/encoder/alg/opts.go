@@ -16,6 +16,10 @@
 
 package alg
 
+import (
+    `github.com/bytedance/sonic/internal/encoder/vars`
+)
+
 const (
     BitSortMapKeys          = iota
     BitEscapeHTML          
@@ -26,6 +30,68 @@ const (
     BitNoValidateJSONMarshaler
     BitNoEncoderNewline 
     BitEncodeNullForInfOrNan 
+    BitRedactSensitive
+    BitCanonical
+    BitEscapeASCII
+    BitEscapeSlash
+    BitInt64AsString
+    BitLargeInt64AsString
+    BitDetectCycles
 	
     BitPointerValue = 63
 )
+
+// Escaped tells if the strings must be quoted by EncodeString in Go, for the
+// options which the native quoter does not support, including the extra
+// characters of set.
+func Escaped(fv uint64, set *vars.EscapeSet) bool {
+    return fv & (1 << BitCanonical | 1 << BitEscapeASCII | 1 << BitEscapeSlash) != 0 || set != nil
+}
+
+// EscapedIndent is like Escaped, but is also true for the HTML escaping and the
+// UTF-8 correction if ind has a prefix or indent which is not blank, since their
+// post-pass over the finished output would alter it.
+func EscapedIndent(fv uint64, set *vars.EscapeSet, ind *vars.Indent) bool {
+    if Escaped(fv, set) {
+        return true
+    }
+    if ind == nil || fv & (1 << BitEscapeHTML | 1 << BitValidateString) == 0 {
+        return false
+    }
+    return !isBlank(ind.Prefix) || !isBlank(ind.Indent)
+}
+
+func isBlank(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if c := s[i]; c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+            return false
+        }
+    }
+    return true
+}
+
+// variantBits are the flags which select the variants of the programs.
+const variantBits = 1 << BitRedactSensitive | 1 << BitCanonical | 1 << BitEscapeASCII | 1 << BitEscapeSlash
+
+// DefaultVariant tells if the values are encoded by the default programs, which is
+// true if there is no context and none of the flags selects a variant.
+func DefaultVariant(sb *vars.Stack, fv uint64) bool {
+    return fv & variantBits == 0 && !sb.HasContext()
+}
+
+// ProgramVariant returns the variant of the programs which encode the values with
+// the flags fv and the context of sb.
+func ProgramVariant(sb *vars.Stack, fv uint64) vars.Variant {
+    ret := vars.Variant{
+        Redact    : fv & (1 << BitRedactSensitive) != 0,
+        Float     : sb.FloatFormat(),
+        Indent    : sb.Indent() != nil,
+        Depth     : sb.MaxDepth() > 0,
+        Escape    : EscapedIndent(fv, sb.EscapeSet(), sb.Indent()),
+        Canonical : fv & (1 << BitCanonical) != 0,
+    }
+    if ret.Redact {
+        ret.Redactor = sb.Redactor()
+    }
+    return ret
+}
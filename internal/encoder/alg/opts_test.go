@@ -0,0 +1,50 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `testing`
+
+    `github.com/bytedance/sonic/internal/encoder/vars`
+)
+
+func TestDefaultVariant(t *testing.T) {
+    var cases = []struct {
+        fv  uint64
+        cx  *vars.Context
+        def bool
+    }{
+        {0, nil, true},
+        {1 << BitSortMapKeys | 1 << BitEscapeHTML | 1 << BitPointerValue, nil, true},
+        {0, &vars.Context{}, true},
+        {1 << BitRedactSensitive, nil, false},
+        {1 << BitEscapeSlash, nil, false},
+        {0, &vars.Context{MaxSize: 1}, false},
+        {0, &vars.Context{Indent: &vars.Indent{}}, false},
+    }
+    sb := vars.NewStack()
+    defer vars.FreeStack(sb)
+    for _, c := range cases {
+        sb.SetContext(c.cx)
+        if ret := DefaultVariant(sb, c.fv); ret != c.def {
+            t.Fatalf("DefaultVariant(%#x, %+v) = %v", c.fv, c.cx, ret)
+        }
+        if c.def && !ProgramVariant(sb, c.fv).IsDefault() {
+            t.Fatalf("ProgramVariant(%#x, %+v) is not default", c.fv, c.cx)
+        }
+    }
+}
@@ -42,6 +42,54 @@ func Compact(p *[]byte, v []byte) error {
 	return nil
 }
 
+// IsValidNumber tells if s is a valid JSON number literal.
+func IsValidNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	/* the integer part, without leading zeros */
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		i = skipDigits(s, i + 1)
+	default:
+		return false
+	}
+
+	/* the fraction part */
+	if i < len(s) && s[i] == '.' {
+		if j := skipDigits(s, i + 1); j == i + 1 {
+			return false
+		} else {
+			i = j
+		}
+	}
+
+	/* the exponent part */
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if j := skipDigits(s, i); j == i {
+			return false
+		} else {
+			i = j
+		}
+	}
+	return i == len(s)
+}
+
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
 func EncodeNil(rb *[]byte) error {
 	*rb = append(*rb, 'n', 'u', 'l', 'l')
 	return nil
@@ -63,7 +111,27 @@ func EncodeNil(rb *[]byte) error {
 // 	}
 // }
 
-func EncodeJsonMarshaler(buf *[]byte, val json.Marshaler, opt uint64) error {
+// IndentMarshaler is implemented by the types which can marshal themselves into the
+// indented JSON directly, such as ast.Node. The output follows json.Indent, that is,
+// only the lines after the first one begin with prefix.
+type IndentMarshaler interface {
+	MarshalIndent(prefix, indent string) ([]byte, error)
+}
+
+func EncodeJsonMarshaler(buf *[]byte, val json.Marshaler, opt uint64, sb *vars.Stack) error {
+	if ind := sb.Indent(); ind != nil {
+		return encodeIndentMarshaler(buf, val, opt, sb, ind.Indent)
+	}
+	if opt&(1<<BitCanonical) != 0 {
+		return encodeCanonicalMarshaler(buf, val, opt)
+	}
+	if Escaped(opt, sb.EscapeSet()) {
+		return encodeEscapedMarshaler(buf, val, opt, sb)
+	}
+	return encodeMarshaler(buf, val, opt)
+}
+
+func encodeMarshaler(buf *[]byte, val json.Marshaler, opt uint64) error {
 	if ret, err := val.MarshalJSON(); err != nil {
 		return err
 	} else {
@@ -80,7 +148,87 @@ func EncodeJsonMarshaler(buf *[]byte, val json.Marshaler, opt uint64) error {
 	}
 }
 
-func EncodeTextMarshaler(buf *[]byte, val encoding.TextMarshaler, opt uint64) error {
+// encodeIndentMarshaler indents the output of val at the current line while copying
+// it, with the string literals escaped or in the canonical form for the options.
+func encodeIndentMarshaler(buf *[]byte, val json.Marshaler, opt uint64, sb *vars.Stack, indent string) error {
+	var err error
+	var ret []byte
+	prefix := sb.LinePrefix()
+	escaped := EscapedIndent(opt, sb.EscapeSet(), sb.Indent())
+
+	/* marshal into the indented JSON directly if possible */
+	if im, ok := val.(IndentMarshaler); ok && opt&(1<<BitCanonical) == 0 {
+		if ret, err = im.MarshalIndent(prefix, indent); err != nil {
+			return err
+		}
+		if opt&(1<<BitNoValidateJSONMarshaler) == 0 {
+			if ok, s := Valid(ret); !ok {
+				return vars.Error_marshaler(ret, s)
+			}
+		}
+		if escaped {
+			*buf = EscapeText(*buf, ret, opt, sb.EscapeSet())
+		} else {
+			*buf = append(*buf, ret...)
+		}
+		return nil
+	}
+
+	/* the output is always validated, since it is indented token by token */
+	if ret, err = val.MarshalJSON(); err != nil {
+		return err
+	}
+	if opt&(1<<BitCanonical) == 0 {
+		if ok, s := Valid(ret); !ok {
+			return vars.Error_marshaler(ret, s)
+		}
+	}
+	if !escaped {
+		*buf = IndentText(*buf, ret, prefix, indent)
+		return nil
+	}
+	return EncodeIndentedText(buf, ret, opt, sb.EscapeSet(), prefix, indent)
+}
+
+// encodeEscapedMarshaler escapes the string literals in the output of val while
+// copying it into buf.
+func encodeEscapedMarshaler(buf *[]byte, val json.Marshaler, opt uint64, sb *vars.Stack) error {
+	ret, err := val.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if opt&(1<<BitCompactMarshaler) != 0 {
+		var out []byte
+		if err = Compact(&out, ret); err != nil {
+			return err
+		}
+		ret = out
+	} else if opt&(1<<BitNoValidateJSONMarshaler) == 0 {
+		if ok, s := Valid(ret); !ok {
+			return vars.Error_marshaler(ret, s)
+		}
+	}
+	*buf = EscapeText(*buf, ret, opt, sb.EscapeSet())
+	return nil
+}
+
+// encodeCanonicalMarshaler canonicalizes the output of val while copying it, which
+// also validates it.
+func encodeCanonicalMarshaler(buf *[]byte, val json.Marshaler, opt uint64) error {
+	ret, err := val.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	*buf, err = canonicalize(*buf, ret, opt&(1<<BitValidateString) != 0)
+	return err
+}
+
+// EncodeNewline appends the line break and the indentation of the current depth.
+func EncodeNewline(buf *[]byte, sb *vars.Stack) {
+	*buf = sb.Newline(*buf)
+}
+
+func EncodeTextMarshaler(buf *[]byte, val encoding.TextMarshaler, opt uint64, sb *vars.Stack) error {
 	if ret, err := val.MarshalText(); err != nil {
 		return err
 	} else {
@@ -88,6 +236,9 @@ func EncodeTextMarshaler(buf *[]byte, val encoding.TextMarshaler, opt uint64) er
 			*buf = append(*buf, ret...)
 			return nil
 		}
+		if EscapedIndent(opt, sb.EscapeSet(), sb.Indent()) {
+			return EncodeString(buf, rt.Mem2Str(ret), sb, opt, StrPlain)
+		}
 		*buf = Quote(*buf, rt.Mem2Str(ret), false)
 		return nil
 	}
@@ -128,15 +128,13 @@ func HtmlEscape(dst []byte, src []byte) []byte {
 }
 
 func F64toa(buf []byte, v float64) ([]byte) {
-	bs := bytes.NewBuffer(buf)
-	_ = json.NewEncoder(bs).Encode(v)
-	return bs.Bytes()
+	bs, _ := json.Marshal(v)
+	return append(buf, bs...)
 }
 
 func F32toa(buf []byte, v float32) ([]byte) {
-	bs := bytes.NewBuffer(buf)
-	_ = json.NewEncoder(bs).Encode(v)
-	return bs.Bytes()
+	bs, _ := json.Marshal(v)
+	return append(buf, bs...)
 }
 
 func I64toa(buf []byte, v int64) ([]byte) {
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alg
+
+import (
+    `testing`
+)
+
+func TestFtoa(t *testing.T) {
+    var cases = []struct {
+        v   float64
+        exp string
+    }{
+        {0, "0"},
+        {1.5, "1.5"},
+        {-2.5e-7, "-2.5e-7"},
+        {1e21, "1e+21"},
+    }
+    for _, c := range cases {
+        if out := string(F64toa([]byte("x"), c.v)); out != "x" + c.exp {
+            t.Fatalf("F64toa(%v): %q", c.v, out)
+        }
+        if out := string(F32toa([]byte("x"), float32(c.v))); out != "x" + c.exp {
+            t.Fatalf("F32toa(%v): %q", c.v, out)
+        }
+    }
+}
@@ -18,8 +18,10 @@ package encoder
 
 import (
 	"reflect"
+	"sort"
 	"unsafe"
 
+	"github.com/bytedance/sonic/internal/encoder/alg"
 	"github.com/bytedance/sonic/internal/encoder/ir"
 	"github.com/bytedance/sonic/internal/encoder/vars"
 	"github.com/bytedance/sonic/internal/encoder/vm"
@@ -30,21 +32,31 @@ import (
 
 func ForceUseVM() {
 	vm.SetCompiler(makeEncoderVM)
+	vm.SetNested(vm.EncodeTypedPointer)
 	pretouchType = pretouchTypeVM
 	encodeTypedPointer = vm.EncodeTypedPointer
+	makeEncoder = makeEncoderVM
+	programEncoder = programEncoderVM
 	vars.UseVM = true
 }
 
 var encodeTypedPointer func(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64) error
 
 func makeEncoderVM(vt *rt.GoType, ex ...interface{}) (interface{}, error) {
-	pp, err := NewCompiler().Compile(vt.Pack(), ex[0].(bool))
+	pp, err := newCompiler(ex...).Compile(vt.Pack(), ex[0].(bool))
 	if err != nil {
 		return nil, err
 	}
 	return &pp, nil
 }
 
+func programEncoderVM(prog interface{}) vars.Encoder {
+	pp := prog.(*ir.Program)
+	return func(rb *[]byte, vp unsafe.Pointer, sb *vars.Stack, fv uint64) error {
+		return vm.Execute(rb, vp, sb, fv, pp)
+	}
+}
+
 var pretouchType func(_vt reflect.Type, opts option.CompileOptions, v uint8) (map[reflect.Type]uint8, error)
 
 func pretouchTypeVM(_vt reflect.Type, opts option.CompileOptions, v uint8) (map[reflect.Type]uint8, error) {
@@ -85,8 +97,21 @@ type Compiler struct {
 	pv   bool
 	tab  map[reflect.Type]bool
 	rec  map[reflect.Type]uint8
+	proj resolver.FieldTree
+	rdx  bool
+	rf   vars.Redactor
+	cf   *vars.FloatFormat
+	ff   *vars.FloatFormat
+	qs   bool
+	ind  bool
+	dep  bool
+	esc  bool
+	can  bool
 }
 
+// the default replacement of the sensitive fields
+const _RedactMask = `"***"`
+
 func NewCompiler() *Compiler {
 	return &Compiler{
 		opts: option.DefaultCompileOptions(),
@@ -95,6 +120,27 @@ func NewCompiler() *Compiler {
 	}
 }
 
+func newCompiler(ex ...interface{}) *Compiler {
+	ret := NewCompiler()
+	if len(ex) > 1 {
+		ret.variant(ex[1].(vars.Variant))
+	}
+	return ret
+}
+
+func (self *Compiler) variant(v vars.Variant) *Compiler {
+	if v.Redact {
+		self.redact(v.Redactor)
+	}
+	self.floatFormat(v.Float)
+	self.ind = v.Indent
+	self.dep = v.Depth
+	self.esc = v.Escape
+	self.can = v.Canonical
+	self.proj = v.Fields
+	return self
+}
+
 func (self *Compiler) apply(opts option.CompileOptions) *Compiler {
 	self.opts = opts
 	if self.opts.RecursiveDepth > 0 {
@@ -103,6 +149,18 @@ func (self *Compiler) apply(opts option.CompileOptions) *Compiler {
 	return self
 }
 
+func (self *Compiler) redact(fn vars.Redactor) *Compiler {
+	self.rdx = true
+	self.rf = fn
+	return self
+}
+
+func (self *Compiler) floatFormat(ff *vars.FloatFormat) *Compiler {
+	self.cf = ff
+	self.ff = ff
+	return self
+}
+
 func (self *Compiler) rescue(ep *error) {
 	if val := recover(); val != nil {
 		if err, ok := val.(error); ok {
@@ -120,7 +178,7 @@ func (self *Compiler) Compile(vt reflect.Type, pv bool) (ret ir.Program, err err
 }
 
 func (self *Compiler) compileOne(p *ir.Program, sp int, vt reflect.Type, pv bool) {
-	if self.tab[vt] {
+	if self.tab[vt] && self.proj == nil {
 		p.Vp(ir.OP_recurse, vt, pv)
 	} else {
 		self.compileRec(p, sp, vt, pv)
@@ -165,13 +223,16 @@ func (self *Compiler) compileRec(p *ir.Program, sp int, vt reflect.Type, pv bool
 	}
 
 	/* enter the recursion, and compile the type */
+	rc := self.tab[vt]
 	self.pv = pv
 	self.tab[vt] = true
 	self.compileOps(p, sp, vt)
 
-	/* exit the recursion */
+	/* exit the recursion, projected types may be entered more than once */
 	self.pv = pr
-	delete(self.tab, vt)
+	if !rc {
+		delete(self.tab, vt)
+	}
 }
 
 func (self *Compiler) compileOps(p *ir.Program, sp int, vt reflect.Type) {
@@ -179,7 +240,7 @@ func (self *Compiler) compileOps(p *ir.Program, sp int, vt reflect.Type) {
 	case reflect.Bool:
 		p.Add(ir.OP_bool)
 	case reflect.Int:
-		p.Add(ir.OP_int())
+		self.compileInt(p, ir.OP_int())
 	case reflect.Int8:
 		p.Add(ir.OP_i8)
 	case reflect.Int16:
@@ -187,9 +248,9 @@ func (self *Compiler) compileOps(p *ir.Program, sp int, vt reflect.Type) {
 	case reflect.Int32:
 		p.Add(ir.OP_i32)
 	case reflect.Int64:
-		p.Add(ir.OP_i64)
+		self.compileInt(p, ir.OP_i64)
 	case reflect.Uint:
-		p.Add(ir.OP_uint())
+		self.compileInt(p, ir.OP_uint())
 	case reflect.Uint8:
 		p.Add(ir.OP_u8)
 	case reflect.Uint16:
@@ -197,13 +258,13 @@ func (self *Compiler) compileOps(p *ir.Program, sp int, vt reflect.Type) {
 	case reflect.Uint32:
 		p.Add(ir.OP_u32)
 	case reflect.Uint64:
-		p.Add(ir.OP_u64)
+		self.compileInt(p, ir.OP_u64)
 	case reflect.Uintptr:
-		p.Add(ir.OP_uintptr())
+		self.compileUintptr(p)
 	case reflect.Float32:
-		p.Add(ir.OP_f32)
+		self.compileFloat(p, ir.OP_f32, 32)
 	case reflect.Float64:
-		p.Add(ir.OP_f64)
+		self.compileFloat(p, ir.OP_f64, 64)
 	case reflect.String:
 		self.compileString(p, vt)
 	case reflect.Array:
@@ -211,7 +272,7 @@ func (self *Compiler) compileOps(p *ir.Program, sp int, vt reflect.Type) {
 	case reflect.Interface:
 		self.compileInterface(p, vt)
 	case reflect.Map:
-		self.compileMap(p, sp, vt)
+		self.compileUnprojected(p, sp, vt, self.compileMap)
 	case reflect.Ptr:
 		self.compilePtr(p, sp, vt.Elem())
 	case reflect.Slice:
@@ -223,6 +284,13 @@ func (self *Compiler) compileOps(p *ir.Program, sp int, vt reflect.Type) {
 	}
 }
 
+func (self *Compiler) compileUnprojected(p *ir.Program, sp int, vt reflect.Type, fn func(*ir.Program, int, reflect.Type)) {
+	pj := self.proj
+	self.proj = nil
+	fn(p, sp, vt)
+	self.proj = pj
+}
+
 func (self *Compiler) compileNil(p *ir.Program, sp int, vt reflect.Type, nil_op ir.Op, fn func(*ir.Program, int, reflect.Type)) {
 	x := p.PC()
 	p.Add(ir.OP_is_nil)
@@ -260,32 +328,53 @@ func (self *Compiler) compileMapBody(p *ir.Program, sp int, vt reflect.Type) {
 	p.Add(ir.OP_save)
 	i := p.PC()
 	p.Add(ir.OP_map_check_key)
-	u := p.PC()
-	p.Add(ir.OP_map_write_key)
-	self.compileMapBodyKey(p, vt.Key())
-	p.Pin(u)
-	p.Int(ir.OP_byte, ':')
+	self.compileIndent(p, 1)
+	self.compileNewline(p)
+	self.compileMapKey(p, vt.Key())
+	self.compileColon(p)
 	p.Add(ir.OP_map_value_next)
 	self.compileOne(p, sp+2, vt.Elem(), false)
 	j := p.PC()
 	p.Add(ir.OP_map_check_key)
 	p.Int(ir.OP_byte, ',')
-	v := p.PC()
-	p.Add(ir.OP_map_write_key)
-	self.compileMapBodyKey(p, vt.Key())
-	p.Pin(v)
-	p.Int(ir.OP_byte, ':')
+	self.compileNewline(p)
+	self.compileMapKey(p, vt.Key())
+	self.compileColon(p)
 	p.Add(ir.OP_map_value_next)
 	self.compileOne(p, sp+2, vt.Elem(), false)
 	p.Int(ir.OP_goto, j)
-	p.Pin(i)
 	p.Pin(j)
+	self.compileIndent(p, -1)
+	self.compileNewline(p)
+	p.Pin(i)
 	p.Add(ir.OP_map_stop)
 	p.Add(ir.OP_drop_2)
 	p.Pin(e)
 	p.Int(ir.OP_byte, '}')
 }
 
+// compileMapKey writes the key of the current pair, which is already converted
+// into a string if the keys are sorted.
+func (self *Compiler) compileMapKey(p *ir.Program, vk reflect.Type) {
+	if !self.esc {
+		u := p.PC()
+		p.Add(ir.OP_map_write_key)
+		self.compileMapBodyKey(p, vk)
+		p.Pin(u)
+		return
+	}
+
+	/* the sorted keys are quoted in Go as well */
+	u := p.PC()
+	p.Add(ir.OP_map_sorted_key)
+	self.compileMapBodyKey(p, vk)
+	e := p.PC()
+	p.Add(ir.OP_goto)
+	p.Pin(u)
+	p.Int(ir.OP_esc_str, alg.StrPlain)
+	p.Pin(e)
+}
+
 func (self *Compiler) compileMapBodyKey(p *ir.Program, vk reflect.Type) {
 	if !vk.Implements(vars.EncodingTextMarshalerType) {
 		self.compileMapBodyTextKey(p, vk)
@@ -373,14 +462,19 @@ func (self *Compiler) compileSliceArray(p *ir.Program, sp int, vt reflect.Type)
 	p.Add(ir.OP_slice_len)
 	i := p.PC()
 	p.Rtt(ir.OP_slice_next, vt)
+	self.compileIndent(p, 1)
+	self.compileNewline(p)
 	self.compileOne(p, sp+1, vt, true)
 	j := p.PC()
 	p.Rtt(ir.OP_slice_next, vt)
 	p.Int(ir.OP_byte, ',')
+	self.compileNewline(p)
 	self.compileOne(p, sp+1, vt, true)
 	p.Int(ir.OP_goto, j)
-	p.Pin(i)
 	p.Pin(j)
+	self.compileIndent(p, -1)
+	self.compileNewline(p)
+	p.Pin(i)
 	p.Add(ir.OP_drop)
 	p.Pin(e)
 	p.Int(ir.OP_byte, ']')
@@ -393,6 +487,8 @@ func (self *Compiler) compileArray(p *ir.Program, sp int, vt reflect.Type, nb in
 
 	/* first item */
 	if nb != 0 {
+		self.compileIndent(p, 1)
+		self.compileNewline(p)
 		self.compileOne(p, sp+1, vt, self.pv)
 		p.Add(ir.OP_load)
 	}
@@ -400,26 +496,100 @@ func (self *Compiler) compileArray(p *ir.Program, sp int, vt reflect.Type, nb in
 	/* remaining items */
 	for i := 1; i < nb; i++ {
 		p.Int(ir.OP_byte, ',')
+		self.compileNewline(p)
 		p.Int(ir.OP_index, i*int(vt.Size()))
 		self.compileOne(p, sp+1, vt, self.pv)
 		p.Add(ir.OP_load)
 	}
 
 	/* end of array */
+	if nb != 0 {
+		self.compileIndent(p, -1)
+		self.compileNewline(p)
+	}
 	p.Add(ir.OP_drop)
 	p.Int(ir.OP_byte, ']')
 }
 
-func (self *Compiler) compileString(p *ir.Program, vt reflect.Type) {
-	if vt != vars.JsonNumberType {
-		p.Add(ir.OP_str)
+func (self *Compiler) compileIndent(p *ir.Program, d int) {
+	if self.ind || self.dep {
+		p.Int(ir.OP_indent, d)
+	}
+}
+
+func (self *Compiler) compileNewline(p *ir.Program) {
+	if self.ind {
+		p.Add(ir.OP_newline)
+	}
+}
+
+func (self *Compiler) compileColon(p *ir.Program) {
+	if self.ind {
+		p.Str(ir.OP_text, ": ")
+	} else {
+		p.Int(ir.OP_byte, ':')
+	}
+}
+
+func (self *Compiler) colon() string {
+	if self.ind {
+		return ": "
+	}
+	return ":"
+}
+
+// compileInt emits the 64-bit integers as the ones which may be quoted by the
+// Int64AsString options, unless they are already quoted by the "string" tag.
+func (self *Compiler) compileInt(p *ir.Program, op ir.Op) {
+	switch {
+	case self.qs:
+		p.Add(op)
+	case op == ir.OP_i64:
+		p.Add(ir.OP_i64_q)
+	case op == ir.OP_u64:
+		p.Add(ir.OP_u64_q)
+	default:
+		p.Add(op)
+	}
+}
+
+// compileUintptr emits the uintptrs as the 64-bit integers in the canonical form,
+// which may be out of the range of doubles.
+func (self *Compiler) compileUintptr(p *ir.Program) {
+	if self.can {
+		self.compileInt(p, ir.OP_uintptr())
+	} else {
+		p.Add(ir.OP_uintptr())
+	}
+}
+
+func (self *Compiler) compileFloat(p *ir.Program, op ir.Op, bits int) {
+	if self.can {
+		p.Float(ir.OP_float, bits, nil)
+	} else if self.ff == nil {
+		p.Add(op)
 	} else {
+		p.Float(ir.OP_float, bits, self.ff)
+	}
+}
+
+func (self *Compiler) compileString(p *ir.Program, vt reflect.Type) {
+	switch {
+	case vt == vars.JsonNumberType && self.can:
+		p.Int(ir.OP_esc_str, alg.StrNumber)
+	case vt == vars.JsonNumberType:
 		p.Add(ir.OP_number)
+	case self.esc:
+		p.Int(ir.OP_esc_str, alg.StrPlain)
+	default:
+		p.Add(ir.OP_str)
 	}
 }
 
 func (self *Compiler) compileStruct(p *ir.Program, sp int, vt reflect.Type) {
-	if sp >= self.opts.MaxInlineDepth || p.PC() >= vars.MAX_ILBUF || (sp > 0 && vt.NumField() >= vars.MAX_FIELDS) {
+	if self.proj != nil {
+		self.compileStructBody(p, sp, vt)
+	} else if sp >= self.opts.MaxInlineDepth || p.PC() >= vars.MAX_ILBUF || (sp > 0 && vt.NumField() >= vars.MAX_FIELDS) {
 		p.Vp(ir.OP_recurse, vt, self.pv)
 		if self.opts.RecursiveDepth > 0 {
 			if self.pv {
@@ -439,11 +609,31 @@ func (self *Compiler) compileStructBody(p *ir.Program, sp int, vt reflect.Type)
 	p.Add(ir.OP_save)
 	p.Add(ir.OP_cond_set)
 
+	/* the float format of the fields do not apply to the sub-fields */
+	pf := self.ff
+	self.ff = self.cf
+
 	/* compile each field */
-	for _, fv := range resolver.ResolveStruct(vt) {
+	pj := self.proj
+	for _, fv := range self.structFields(vt) {
 		var s []int
 		var o resolver.Offset
 
+		/* skip the fields not selected by the projection */
+		if pj != nil {
+			if _, ok := pj[fv.Name]; !ok {
+				continue
+			}
+		}
+
+		/* replace or omit the sensitive fields */
+		var mask string
+		if self.rdx && (fv.Opts&resolver.F_sensitive) != 0 {
+			if mask = self.redactField(vt, fv.Name); mask == "" {
+				continue
+			}
+		}
+
 		/* "omitempty" for arrays */
 		if fv.Type.Kind() == reflect.Array {
 			if fv.Type.Len() == 0 && (fv.Opts&resolver.F_omitempty) != 0 {
@@ -466,18 +656,46 @@ func (self *Compiler) compileStructBody(p *ir.Program, sp int, vt reflect.Type)
 			self.compileStructFieldZero(p, fv.Type)
 		}
 
-		/* add the comma if not the first element */
+		/* add the comma if not the first element, or enter the object */
 		i := p.PC()
 		p.Add(ir.OP_cond_testc)
 		p.Int(ir.OP_byte, ',')
-		p.Pin(i)
+		if self.ind || self.dep {
+			j := p.PC()
+			p.Add(ir.OP_goto)
+			p.Pin(i)
+			p.Int(ir.OP_indent, 1)
+			p.Pin(j)
+		} else {
+			p.Pin(i)
+		}
+		self.compileNewline(p)
 
 		/* compile the key and value */
 		ft := fv.Type
-		p.Str(ir.OP_text, Quote(fv.Name)+":")
+		if self.esc {
+			p.Str(ir.OP_esc_text, Quote(fv.Name))
+			self.compileColon(p)
+		} else {
+			p.Str(ir.OP_text, Quote(fv.Name)+self.colon())
+		}
+
+		/* narrow the projection to the sub-fields of this field */
+		if pj != nil {
+			self.proj = pj[fv.Name]
+		}
 
-		/* check for "stringnize" option */
-		if (fv.Opts & resolver.F_stringize) == 0 {
+		/* apply the float format of the field */
+		if fv.Format != "" {
+			self.ff = self.fieldFloatFormat(vt, &fv)
+		}
+
+		/* check for the mask and "stringnize" option */
+		if mask != "" && self.esc {
+			p.Str(ir.OP_esc_text, mask)
+		} else if mask != "" {
+			p.Str(ir.OP_text, mask)
+		} else if (fv.Opts & resolver.F_stringize) == 0 {
 			self.compileOne(p, sp+1, ft, self.pv)
 		} else {
 			self.compileStructFieldStr(p, sp+1, ft)
@@ -486,13 +704,60 @@ func (self *Compiler) compileStructBody(p *ir.Program, sp int, vt reflect.Type)
 		/* patch the skipping jumps and reload the struct pointer */
 		p.Rel(s)
 		p.Add(ir.OP_load)
+		self.ff = self.cf
 	}
 
-	/* end of object */
+	/* end of object, leave it only if any of the fields was written */
+	self.ff = pf
+	self.proj = pj
+	if self.ind || self.dep {
+		i := p.PC()
+		p.Add(ir.OP_cond_testc)
+		p.Int(ir.OP_indent, -1)
+		self.compileNewline(p)
+		p.Pin(i)
+	}
 	p.Add(ir.OP_drop)
 	p.Int(ir.OP_byte, '}')
 }
 
+// structFields returns the fields of vt, sorted by the UTF-16 code units of their
+// names in the canonical form.
+func (self *Compiler) structFields(vt reflect.Type) []resolver.FieldMeta {
+	fvs := resolver.ResolveStruct(vt)
+	if self.can {
+		fvs = append([]resolver.FieldMeta(nil), fvs...)
+		sort.SliceStable(fvs, func(i, j int) bool {
+			return alg.LessUTF16(fvs[i].Name, fvs[j].Name)
+		})
+	}
+	return fvs
+}
+
+func (self *Compiler) redactField(vt reflect.Type, name string) string {
+	if self.rf == nil {
+		return _RedactMask
+	}
+
+	/* the replacement must be a valid JSON value */
+	ret := self.rf(vt, name)
+	if len(ret) == 0 {
+		return ""
+	} else if ok, _ := alg.Valid(ret); !ok {
+		panic(vars.Error_redactor(vt, name, ret))
+	} else {
+		return string(ret)
+	}
+}
+
+func (self *Compiler) fieldFloatFormat(vt reflect.Type, fv *resolver.FieldMeta) *vars.FloatFormat {
+	ff, err := vars.ParseFloatFormat(fv.Format)
+	if err != nil {
+		panic(vars.Error_float_format(vt, fv.Name, err))
+	}
+	return vars.InternFloatFormat(ff)
+}
+
 func (self *Compiler) compileStructFieldStr(p *ir.Program, sp int, vt reflect.Type) {
 	// NOTICE: according to encoding/json, Marshaler type has higher priority than string option
 	// see issue: 
@@ -558,7 +823,9 @@ func (self *Compiler) compileStructFieldStr(p *ir.Program, sp int, vt reflect.Ty
 	}
 
 	/* special case of a double-quoted string */
-	if ft != vars.JsonNumberType && ft.Kind() == reflect.String {
+	if ft != vars.JsonNumberType && ft.Kind() == reflect.String && self.esc {
+		p.Int(ir.OP_esc_str, alg.StrDouble)
+	} else if ft != vars.JsonNumberType && ft.Kind() == reflect.String {
 		p.Add(ir.OP_quote)
 	} else {
 		self.compileStructFieldQuoted(p, sp, vt)
@@ -621,7 +888,9 @@ func (self *Compiler) compileStructFieldZero(p *ir.Program, vt reflect.Type) {
 
 func (self *Compiler) compileStructFieldQuoted(p *ir.Program, sp int, vt reflect.Type) {
 	p.Int(ir.OP_byte, '"')
+	self.qs = true
 	self.compileOne(p, sp, vt, self.pv)
+	self.qs = false
 	p.Int(ir.OP_byte, '"')
 }
 
@@ -19,6 +19,10 @@
 
 package encoder
 
-func encodeIntoCheckRace(buf *[]byte, val interface{}, opts Options) error {
-	return encodeInto(buf, val, opts)
+import (
+	"github.com/bytedance/sonic/internal/encoder/vars"
+)
+
+func encodeIntoCheckRace(buf *[]byte, val interface{}, opts Options, ctx *vars.Context) error {
+	return encodeInto(buf, val, opts, ctx)
 }
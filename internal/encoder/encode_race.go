@@ -22,6 +22,7 @@ package encoder
 import (
     `encoding/json`
 
+    `github.com/bytedance/sonic/internal/encoder/vars`
     `github.com/bytedance/sonic/internal/rt`
 )
 
@@ -38,8 +39,8 @@ func helpDetectDataRace(val interface{}) {
     out, _ = json.Marshal(val)
 }
 
-func encodeIntoCheckRace(buf *[]byte, val interface{}, opts Options) error {
-	err := encodeInto(buf, val, opts)
+func encodeIntoCheckRace(buf *[]byte, val interface{}, opts Options, ctx *vars.Context) error {
+	err := encodeInto(buf, val, opts, ctx)
     /* put last to make the panic from sonic will always be caught at first */
     helpDetectDataRace(val)
     return err
@@ -17,8 +17,6 @@
 package encoder
 
 import (
-	"bytes"
-	"encoding/json"
 	"reflect"
 	"runtime"
 	"unsafe"
@@ -73,21 +71,96 @@ const (
 
     // Encode Infinity or Nan float into `null`, instead of returning an error.
     EncodeNullForInfOrNan Options = 1 << alg.BitEncodeNullForInfOrNan
+
+    // Canonical indicates that the output JSON should be in the canonical form of
+    // RFC 8785 (JCS), see Canonicalize. It overrides SortMapKeys and the escaping options.
+    // WARNING: This hurts performance A LOT, USE WITH CARE.
+    Canonical Options = 1 << alg.BitCanonical
+
+    // RedactSensitive indicates that the struct fields tagged with `json:",sensitive"`
+    // should be replaced by `"***"`, or by the output of the Redactor of the Encoder.
+    RedactSensitive Options = 1 << alg.BitRedactSensitive
+
+    // EscapeASCII indicates that all the non-ASCII characters in the strings should be
+    // escaped as \uXXXX (with surrogate pairs for the supplementary characters), and the
+    // invalid UTF-8 bytes as \ufffd, so that the output JSON is pure ASCII.
+    EscapeASCII Options = 1 << alg.BitEscapeASCII
+
+    // EscapeSlash indicates that the '/' in the strings should be escaped as `\/`.
+    EscapeSlash Options = 1 << alg.BitEscapeSlash
+
+    // Int64AsString indicates that the 64-bit integers (int64, uint64, and int, uint
+    // on 64-bit platforms) should be encoded as JSON strings, for the JavaScript
+    // consumers which lose the precision above 2^53. The ones in the fields with
+    // the "string" tag are not quoted twice.
+    Int64AsString Options = 1 << alg.BitInt64AsString
+
+    // LargeInt64AsString is like Int64AsString, but only for the integers out of the
+    // safe range of JavaScript, [-(2^53-1), 2^53-1]. It is ignored if Int64AsString is set.
+    LargeInt64AsString Options = 1 << alg.BitLargeInt64AsString
+
+    // DetectCycles indicates that the encoder should track the pointers of the deeply
+    // nested values, and fail with an error naming the types on the cycle if a value
+    // contains itself, instead of failing for the too deep nesting.
+    DetectCycles Options = 1 << alg.BitDetectCycles
 )
 
+// Redactor returns the JSON text replacing the value of the sensitive field name
+// of struct vt, or nil to omit the field.
+//
+// It is called only once for each field when compiling, and the compiled programs
+// are cached for every Redactor, so it should be a long-lived function.
+type Redactor = vars.Redactor
+
+// FloatFormat controls how the floats are encoded. The zero value is the shortest
+// representation that round-trips, which is the default.
+//
+// The format of a struct field can also be set by the options in its json tag, which
+// replace the format of the Encoder entirely:
+//   - "decimals=N": exactly N digits after the decimal point;
+//   - "precision=N": at most N significant digits;
+//   - "exponent" or "noexponent": always or never use the exponent notation;
+//   - "negzero": emit -0 as `-0` instead of `0`.
+// For example, `json:"price,decimals=2"`. The options apply to the floats in the field
+// value, such as the elements of a []float64, but not to the fields of the nested structs.
+type FloatFormat = vars.FloatFormat
+
+// FloatExponent controls the exponent notation of the floats.
+type FloatExponent = vars.FloatExponent
+
+const (
+    // FloatExponentAuto uses the exponent notation only for the very large or
+    // very small floats (< 1e-6 or >= 1e21), the same as the default encoding.
+    FloatExponentAuto = vars.FloatExponentAuto
+
+    // FloatExponentNever never uses the exponent notation.
+    FloatExponentNever = vars.FloatExponentNever
+
+    // FloatExponentAlways always uses the exponent notation.
+    FloatExponentAlways = vars.FloatExponentAlways
+)
+
+// LimitError is returned when the output exceeds the limits of the Encoder, see
+// Encoder.SetMaxDepth and Encoder.SetMaxSize.
+type LimitError = vars.LimitError
+
 // Encoder represents a specific set of encoder configurations.
 type Encoder struct {
     Opts Options
-    prefix string
-    indent string
+    ctx vars.Context
 }
 
 // Encode returns the JSON encoding of v.
 func (self *Encoder) Encode(v interface{}) ([]byte, error) {
-    if self.indent != "" || self.prefix != "" { 
-        return EncodeIndented(v, self.prefix, self.indent, self.Opts)
-    }
-    return Encode(v, self.Opts)
+    return encode(v, self.Opts, &self.ctx)
+}
+
+// EncodeIndented is like Encode but indents the output with prefix and indent,
+// even if both of them are empty, regardless of SetIndent.
+func (self *Encoder) EncodeIndented(v interface{}, prefix string, indent string) ([]byte, error) {
+    ctx := self.ctx
+    ctx.Indent = &vars.Indent{Prefix: prefix, Indent: indent}
+    return encode(v, self.Opts, &ctx)
 }
 
 // SortKeys enables the SortMapKeys option.
@@ -151,12 +224,113 @@ func (self *Encoder) SetNoQuoteTextMarshaler(f bool) {
     }
 }
 
+// SetRedactSensitive specifies if option RedactSensitive opens
+func (self *Encoder) SetRedactSensitive(f bool) {
+    if f {
+        self.Opts |= RedactSensitive
+    } else {
+        self.Opts &= ^RedactSensitive
+    }
+}
+
+// SetRedactor sets the Redactor for the sensitive fields, which takes effect
+// only if option RedactSensitive opens.
+func (self *Encoder) SetRedactor(fn Redactor) {
+    self.ctx.Redactor = fn
+}
+
+// SetFloatFormat sets the format of all the floats. The programs are compiled and
+// cached for every distinct format. It fails and leaves the format unchanged if
+// ff is invalid, see FloatFormat.
+func (self *Encoder) SetFloatFormat(ff FloatFormat) error {
+    if err := vars.ValidateFloatFormat(ff); err != nil {
+        return err
+    }
+    self.ctx.FloatFormat = vars.InternFloatFormat(ff)
+    return nil
+}
+
+// SetEscapeASCII specifies if option EscapeASCII opens
+func (self *Encoder) SetEscapeASCII(f bool) {
+    if f {
+        self.Opts |= EscapeASCII
+    } else {
+        self.Opts &= ^EscapeASCII
+    }
+}
+
+// SetEscapeSlash specifies if option EscapeSlash opens
+func (self *Encoder) SetEscapeSlash(f bool) {
+    if f {
+        self.Opts |= EscapeSlash
+    } else {
+        self.Opts &= ^EscapeSlash
+    }
+}
+
+// SetInt64AsString specifies if option Int64AsString opens
+func (self *Encoder) SetInt64AsString(f bool) {
+    if f {
+        self.Opts |= Int64AsString
+    } else {
+        self.Opts &= ^Int64AsString
+    }
+}
+
+// SetLargeInt64AsString specifies if option LargeInt64AsString opens
+func (self *Encoder) SetLargeInt64AsString(f bool) {
+    if f {
+        self.Opts |= LargeInt64AsString
+    } else {
+        self.Opts &= ^LargeInt64AsString
+    }
+}
+
+// SetDetectCycles specifies if option DetectCycles opens
+func (self *Encoder) SetDetectCycles(f bool) {
+    if f {
+        self.Opts |= DetectCycles
+    } else {
+        self.Opts &= ^DetectCycles
+    }
+}
+
+// SetMaxDepth limits the nesting depth of the output, that is, the number of the arrays
+// and objects enclosing any value, 0 for unlimited. The encoding is aborted with a
+// *LimitError once the limit is exceeded.
+func (self *Encoder) SetMaxDepth(n int) {
+    self.ctx.MaxDepth = n
+}
+
+// SetMaxSize limits the length in bytes of each encoded output, 0 for unlimited. The
+// encoding is aborted with a *LimitError once the limit is exceeded, instead of
+// growing the buffer without bound.
+func (self *Encoder) SetMaxSize(n int) {
+    self.ctx.MaxSize = n
+}
+
+// SetEscapeChars sets the extra characters to be escaped as \uXXXX in the strings.
+// The characters which are always escaped by JSON are ignored, and SetEscapeChars("")
+// clears the set.
+func (self *Encoder) SetEscapeChars(chars string) {
+    if chars == "" {
+        self.ctx.Escapes = nil
+    } else {
+        self.ctx.Escapes = vars.NewEscapeSet(chars)
+    }
+}
+
 // SetIndent instructs the encoder to format each subsequent encoded
 // value as if indented by the package-level function EncodeIndent().
 // Calling SetIndent("", "") disables indentation.
+//
+// The indented output is written directly by the encoder, in a single pass.
 func (enc *Encoder) SetIndent(prefix, indent string) {
-    enc.prefix = prefix
-    enc.indent = indent
+    if prefix == "" && indent == "" {
+        enc.ctx.Indent = nil
+    } else {
+        enc.ctx.Indent = &vars.Indent{Prefix: prefix, Indent: indent}
+    }
 }
 
 // Quote returns the JSON-quoted version of s.
@@ -168,10 +342,14 @@ func Quote(s string) string {
 
 // Encode returns the JSON encoding of val, encoded with opts.
 func Encode(val interface{}, opts Options) ([]byte, error) {
+    return encode(val, opts, nil)
+}
+
+func encode(val interface{}, opts Options, ctx *vars.Context) ([]byte, error) {
     var ret []byte
 
     buf := vars.NewBytes()
-    err := encodeIntoCheckRace(buf, val, opts)
+    err := encodeIntoCheckRace(buf, val, opts, ctx)
 
     /* check for errors */
     if err != nil {
@@ -179,9 +357,17 @@ func Encode(val interface{}, opts Options) ([]byte, error) {
         return nil, err
     }
 
-    /* htmlescape or correct UTF-8 if opts enable */
+    /* htmlescape, correct UTF-8 or canonicalize if opts enable */
     old := buf
-    *buf = encodeFinish(*old, opts)
+    out, err := encodeFinish(*old, opts, ctx)
+    if err == nil {
+        err = checkSize(len(out), ctx)
+    }
+    if err != nil {
+        vars.FreeBytes(buf)
+        return nil, err
+    }
+    *buf = out
     pbuf := ((*rt.GoSlice)(unsafe.Pointer(buf))).Ptr
     pold := ((*rt.GoSlice)(unsafe.Pointer(old))).Ptr
 
@@ -207,18 +393,46 @@ func Encode(val interface{}, opts Options) ([]byte, error) {
 // EncodeInto is like Encode but uses a user-supplied buffer instead of allocating
 // a new one.
 func EncodeInto(buf *[]byte, val interface{}, opts Options) error {
-    err := encodeIntoCheckRace(buf, val, opts)
+    return encodeIntoWith(buf, val, opts, nil)
+}
+
+func encodeIntoWith(buf *[]byte, val interface{}, opts Options, ctx *vars.Context) error {
+    base := len(*buf)
+    err := encodeIntoCheckRace(buf, val, opts, ctx)
     if err != nil {
         return err
     }
-    *buf = encodeFinish(*buf, opts)
-    return err
+    if *buf, err = encodeFinish(*buf, opts, ctx); err != nil {
+        return err
+    }
+    return checkSize(len(*buf) - base, ctx)
+}
+
+// checkSize fails if the length n of the finished output exceeds the limit, since
+// the escaping options may grow the output after encoding.
+func checkSize(n int, ctx *vars.Context) error {
+    if ctx != nil && ctx.MaxSize > 0 && n > ctx.MaxSize {
+        return vars.Error_max_size(ctx.MaxSize)
+    }
+    return nil
 }
 
-func encodeInto(buf *[]byte, val interface{}, opts Options) error {
+func encodeInto(buf *[]byte, val interface{}, opts Options, ctx *vars.Context) error {
     stk := vars.NewStack()
+    stk.SetContext(ctx)
+    stk.LimitSize(len(*buf))
     efv := rt.UnpackEface(val)
-    err := encodeTypedPointer(buf, efv.Type, &efv.Value, stk, uint64(opts))
+
+    /* only the top-level value is projected */
+    var err error
+    if ctx != nil && ctx.Fields != nil {
+        err = encodeProjected(buf, efv.Type, &efv.Value, stk, uint64(opts), ctx.Fields)
+    } else {
+        err = encodeTypedPointer(buf, efv.Type, &efv.Value, stk, uint64(opts))
+    }
+    if err == nil {
+        err = stk.CheckSize(len(*buf))
+    }
 
     /* return the stack into pool */
     if err != nil {
@@ -232,16 +446,28 @@ func encodeInto(buf *[]byte, val interface{}, opts Options) error {
     return err
 }
 
-func encodeFinish(buf []byte, opts Options) []byte {
+// encodeFinish escapes HTML and corrects UTF-8 in the finished output if opts enable,
+// unless the strings are already escaped while being quoted.
+func encodeFinish(buf []byte, opts Options, ctx *vars.Context) ([]byte, error) {
+    if alg.EscapedIndent(uint64(opts), ctx.EscapeSet(), ctx.Indentation()) {
+        return buf, nil
+    }
     if opts & EscapeHTML != 0 {
         buf = HTMLEscape(nil, buf)
     }
     if (opts & ValidateString != 0) && !utf8.Validate(buf) {
         buf = utf8.CorrectWith(nil, buf, `\ufffd`)
     }
-    return buf
+    return buf, nil
 }
 
+// Canonicalize returns the canonical form of the JSON src, which is defined by
+// RFC 8785 (JSON Canonicalization Scheme): no whitespace, object keys sorted by
+// their UTF-16 code units, minimal string escapes, and numbers serialized as
+// ECMAScript does. The src must be a single valid I-JSON value.
+func Canonicalize(src []byte) ([]byte, error) {
+    return alg.Canonicalize(make([]byte, 0, len(src)), src)
+}
 
 // HTMLEscape appends to dst the JSON-encoded src with <, >, &, U+2028 and U+2029
 // characters inside string literals changed to \u003c, \u003e, \u0026, \u2028, \u2029
@@ -257,42 +483,7 @@ func HTMLEscape(dst []byte, src []byte) []byte {
 // Each JSON element in the output will begin on a new line beginning with prefix
 // followed by one or more copies of indent according to the indentation nesting.
 func EncodeIndented(val interface{}, prefix string, indent string, opts Options) ([]byte, error) {
-    var err error
-    var buf *bytes.Buffer
-
-    /* encode into the buffer */
-    out := vars.NewBytes()
-    err = EncodeInto(out, val, opts)
-
-    /* check for errors */
-    if err != nil {
-        vars.FreeBytes(out)
-        return nil, err
-    }
-
-    /* indent the JSON */
-    buf = vars.NewBuffer()
-    err = json.Indent(buf, *out, prefix, indent)
-    vars.FreeBytes(out)
-
-    /* check for errors */
-    if err != nil {
-        vars.FreeBuffer(buf)
-        return nil, err
-    }
-
-    /* copy to the result buffer */
-    var ret []byte
-    if rt.CanSizeResue(cap(buf.Bytes())) {
-        ret = make([]byte, buf.Len())
-        copy(ret, buf.Bytes())
-        /* return the buffers into pool */
-        vars.FreeBuffer(buf)
-    } else {
-        ret = buf.Bytes()
-    }
-    
-    return ret, nil
+    return encode(val, opts, &vars.Context{Indent: &vars.Indent{Prefix: prefix, Indent: indent}})
 }
 
 // Pretouch compiles vt ahead-of-time to avoid JIT compilation on-the-fly, in
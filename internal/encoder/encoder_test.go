@@ -292,6 +292,18 @@ func TestTextMarshalTextKey_SortKeys(t *testing.T) {
     ret, err = Encode(v3, SortMapKeys)
     require.NoError(t, err)
     require.Equal(t, `{"a":"a","b":"b","c":"c"}`, string(ret))
+
+    /* the integer keys with MarshalText are not encoded as the integers */
+    v4 := map[textInt]string{2: "a", 1: "b", 0: "c"}
+    ret, err = Encode(v4, SortMapKeys)
+    require.NoError(t, err)
+    require.Equal(t, `{"ka":"c","kb":"b","kc":"a"}`, string(ret))
+}
+
+type textInt int
+
+func (self textInt) MarshalText() ([]byte, error) {
+    return []byte{'k', byte('a' + self)}, nil
 }
 
 func TestEncoder_Marshal_EscapeHTML(t *testing.T) {
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package encoder
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type floatItem struct {
+	Ratio float32 `json:"ratio"`
+}
+
+func TestEncoder_FloatFormatTag(t *testing.T) {
+	rate := 0.000012345
+	cases := []struct {
+		name string
+		val  interface{}
+		exp  string
+	}{
+		{"decimals", struct {
+			A float64 `json:"a,decimals=2"`
+		}{10}, `{"a":10.00}`},
+		{"slice", struct {
+			A []float64 `json:"a,decimals=2"`
+		}{[]float64{1.005, 2.5}}, `{"a":[1.00,2.50]}`},
+		{"pointer", struct {
+			A *float64 `json:"a,precision=3,noexponent"`
+		}{&rate}, `{"a":0.0000123}`},
+		{"string", struct {
+			A float64 `json:"a,string,decimals=1"`
+		}{0.25}, `{"a":"0.2"}`},
+		{"negzero", struct {
+			A float64 `json:"a,negzero"`
+		}{math.Copysign(0, -1)}, `{"a":-0}`},
+		{"plain", struct {
+			A float64 `json:"a"`
+		}{1e21}, `{"a":1e+21}`},
+		{"nested struct", struct {
+			A floatItem `json:"a,decimals=2"`
+		}{floatItem{0.5}}, `{"a":{"ratio":0.5}}`},
+		{"interface", struct {
+			A interface{} `json:"a,decimals=2"`
+		}{3.14159}, `{"a":3.14159}`},
+		{"map", struct {
+			A map[string]float32 `json:"a"`
+		}{map[string]float32{"x": 0.125}}, `{"a":{"x":0.125}}`},
+	}
+	for _, c := range cases {
+		out, err := Encode(c.val, 0)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, c.exp, string(out), c.name)
+	}
+}
+
+func TestEncoder_SetFloatFormat(t *testing.T) {
+	enc := Encoder{}
+	require.NoError(t, enc.SetFloatFormat(FloatFormat{Precision: 2, Exponent: FloatExponentAlways}))
+	cases := []struct {
+		name string
+		val  interface{}
+		exp  string
+	}{
+		{"tagged", struct {
+			A float64 `json:"a,decimals=2"`
+		}{10}, `{"a":10.00}`},
+		{"plain", floatItem{0.5}, `{"ratio":5e-1}`},
+		{"interface", []interface{}{3.14159}, `[3.1e+0]`},
+		{"map", map[string]float32{"x": 0.125}, `{"x":1.2e-1}`},
+	}
+	for _, c := range cases {
+		out, err := enc.Encode(c.val)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, c.exp, string(out), c.name)
+	}
+
+	/* the default format is not affected */
+	out, err := Encode(floatItem{Ratio: 0.5}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ratio":0.5}`, string(out))
+
+	/* NaN and infinities */
+	_, err = enc.Encode([]float64{math.Inf(1)})
+	require.Error(t, err)
+	enc.Opts |= EncodeNullForInfOrNan
+	out, err = enc.Encode([]float64{math.NaN(), 1})
+	require.NoError(t, err)
+	assert.Equal(t, `[null,1e+0]`, string(out))
+}
+
+func TestEncoder_FloatFormatInvalid(t *testing.T) {
+	_, err := Encode(struct {
+		A float64 `json:"a,decimals=x"`
+	}{}, 0)
+	require.Error(t, err)
+}
+
+func TestEncoder_SetFloatFormatInvalid(t *testing.T) {
+	for _, ff := range []FloatFormat{
+		{Decimals: 2},
+		{Fixed: true, Decimals: -1},
+		{Fixed: true, Decimals: 768},
+		{Fixed: true, Decimals: 2, Precision: 3},
+		{Precision: -1},
+		{Precision: 768},
+		{Exponent: FloatExponentAlways + 1},
+	} {
+		enc := Encoder{}
+		require.Error(t, enc.SetFloatFormat(ff), "%+v", ff)
+		assert.Nil(t, enc.ctx.FloatFormat, "%+v", ff)
+	}
+	enc := Encoder{}
+	require.NoError(t, enc.SetFloatFormat(FloatFormat{Fixed: true, Decimals: 767, Exponent: FloatExponentNever}))
+}
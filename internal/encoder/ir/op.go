@@ -80,6 +80,14 @@ const (
 	OP_marshal_text_p
 	OP_cond_set
 	OP_cond_testc
+	OP_float
+	OP_i64_q
+	OP_u64_q
+	OP_indent
+	OP_newline
+	OP_esc_str
+	OP_esc_text
+	OP_map_sorted_key
 )
 
 const (
@@ -141,6 +149,14 @@ var OpNames = [256]string{
 	OP_marshal_text_p: "marshal_text_p",
 	OP_cond_set:       "cond_set",
 	OP_cond_testc:     "cond_testc",
+	OP_float:          "float",
+	OP_i64_q:          "i64_q",
+	OP_u64_q:          "u64_q",
+	OP_indent:         "indent",
+	OP_newline:        "newline",
+	OP_esc_str:        "esc_str",
+	OP_esc_text:       "esc_text",
+	OP_map_sorted_key: "map_sorted_key",
 }
 
 func (self Op) String() string {
@@ -251,6 +267,14 @@ func NewInsVp(op Op, vt reflect.Type, pv bool) Instr {
 	}
 }
 
+func NewInsFloat(op Op, bits int, ff *vars.FloatFormat) Instr {
+	return Instr{
+		o: op,
+		u: bits,
+		p: unsafe.Pointer(ff),
+	}
+}
+
 func (self Instr) Op() Op {
 	return Op(self.o)
 }
@@ -294,6 +318,10 @@ func (self Instr) Vp2() (vt *rt.GoType, pv bool) {
 	return (*rt.GoType)(self.p), self.u == 1
 }
 
+func (self Instr) Vff() *vars.FloatFormat {
+	return (*vars.FloatFormat)(self.p)
+}
+
 func (self Instr) I64() int64 {
 	return int64(self.Vi())
 }
@@ -326,6 +354,8 @@ func (self Instr) isBranch() bool {
 		fallthrough
 	case OP_map_write_key:
 		fallthrough
+	case OP_map_sorted_key:
+		fallthrough
 	case OP_slice_next:
 		fallthrough
 	case OP_cond_testc:
@@ -340,8 +370,14 @@ func (self Instr) Disassemble() string {
 	case OP_byte:
 		return fmt.Sprintf("%-18s%s", self.Op().String(), strconv.QuoteRune(rune(self.Vi())))
 	case OP_text:
+		fallthrough
+	case OP_esc_text:
 		return fmt.Sprintf("%-18s%s", self.Op().String(), strconv.Quote(self.Vs()))
 	case OP_index:
+		fallthrough
+	case OP_esc_str:
+		fallthrough
+	case OP_indent:
 		return fmt.Sprintf("%-18s%d", self.Op().String(), self.Vi())
 	case OP_recurse:
 		fallthrough
@@ -377,9 +413,13 @@ func (self Instr) Disassemble() string {
 	case OP_map_check_key:
 		fallthrough
 	case OP_map_write_key:
+		fallthrough
+	case OP_map_sorted_key:
 		return fmt.Sprintf("%-18sL_%d", self.Op().String(), self.Vi())
 	case OP_slice_next:
 		return fmt.Sprintf("%-18sL_%d, %s", self.Op().String(), self.Vi(), self.Vt())
+	case OP_float:
+		return fmt.Sprintf("%-18s%d, %+v", self.Op().String(), self.Vi(), self.Vff())
 	default:
 		return fmt.Sprintf("%#v", self) 
 	}
@@ -438,6 +478,10 @@ func (self *Program) Vp(op Op, vt reflect.Type, pv bool) {
 	*self = append(*self, NewInsVp(op, vt, pv))
 }
 
+func (self *Program) Float(op Op, bits int, ff *vars.FloatFormat) {
+	*self = append(*self, NewInsFloat(op, bits, ff))
+}
+
 func (self *Program) Vtab(op Op, vt reflect.Type, itab *rt.GoItab) {
 	*self = append(*self, NewInsVtab(op, vt, itab))
 }
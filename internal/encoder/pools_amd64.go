@@ -22,6 +22,7 @@ import (
 	"unsafe"
 
 	"github.com/bytedance/sonic/internal/encoder/vars"
+	"github.com/bytedance/sonic/internal/encoder/vm"
 	"github.com/bytedance/sonic/internal/encoder/x86"
 	"github.com/bytedance/sonic/internal/rt"
 	"github.com/bytedance/sonic/option"
@@ -30,8 +31,11 @@ import (
 
 func ForceUseJit() {
 	x86.SetCompiler(makeEncoderX86)
+	vm.SetNested(x86.EncodeTypedPointer)
 	pretouchType = pretouchTypeX86
 	encodeTypedPointer = x86.EncodeTypedPointer
+	makeEncoder = makeEncoderX86
+	programEncoder = programEncoderX86
 	vars.UseVM = false
 }
 
@@ -71,7 +75,7 @@ func _Encoder_Shadow(rb *[]byte, vp unsafe.Pointer, sb *vars.Stack, fv uint64) (
 }
 
 func makeEncoderX86(vt *rt.GoType, ex ...interface{}) (interface{}, error) {
-	pp, err := NewCompiler().Compile(vt.Pack(), ex[0].(bool))
+	pp, err := newCompiler(ex...).Compile(vt.Pack(), ex[0].(bool))
 	if err != nil {
 		return nil, err
 	} 
@@ -80,6 +84,10 @@ func makeEncoderX86(vt *rt.GoType, ex ...interface{}) (interface{}, error) {
 	return as.Load(), nil
 }
 
+func programEncoderX86(prog interface{}) vars.Encoder {
+	return prog.(vars.Encoder)
+}
+
 func pretouchTypeX86(_vt reflect.Type, opts option.CompileOptions, v uint8) (map[reflect.Type]uint8, error) {
 	/* compile function */
 	compiler := NewCompiler().apply(opts)
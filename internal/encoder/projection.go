@@ -0,0 +1,194 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package encoder
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/bytedance/sonic/internal/caching"
+	"github.com/bytedance/sonic/internal/encoder/alg"
+	"github.com/bytedance/sonic/internal/encoder/vars"
+	"github.com/bytedance/sonic/internal/resolver"
+	"github.com/bytedance/sonic/internal/rt"
+)
+
+type projectionKey struct {
+	vt     *rt.GoType
+	pv     bool
+	fields string
+	rdx    bool
+	fn     unsafe.Pointer
+	ff     *vars.FloatFormat
+	ind    bool
+	dep    bool
+	esc    bool
+	can    bool
+}
+
+const (
+	_MaxProjectionPaths = 4096 // the most paths of fields cached
+	_MaxProjections     = 1024 // the most projected encoders of the VM cached
+)
+
+var (
+	projectionPaths = caching.NewBoundedCache(_MaxProjectionPaths)
+	projectionCache = caching.NewBoundedCache(_MaxProjections)
+	jitProjections  sync.Map
+	jitCount        int
+	jitMutex        sync.Mutex
+)
+
+// maxJitProjections is the most projected programs loaded by the JIT, since the loaded
+// programs are never freed. The other projections are run by the VM.
+var maxJitProjections = 1024
+
+var (
+	makeEncoder    func(*rt.GoType, ... interface{}) (interface{}, error)
+	programEncoder func(prog interface{}) vars.Encoder
+)
+
+// findOrCompileProjection returns the encoder of vt which only emits the struct fields
+// selected by fields, compiled for the same context as the unprojected programs.
+func findOrCompileProjection(vt *rt.GoType, fields []string, sb *vars.Stack, fv uint64) (vars.Encoder, error) {
+	pv := (fv & (1 << alg.BitPointerValue)) != 0
+	v := alg.ProgramVariant(sb, fv)
+	if !v.Redact {
+		v.Redactor = nil
+	}
+
+	/* look up with the paths, to avoid building the field tree */
+	key := projectionKey{vt, pv, resolver.FieldPathsKey(fields), v.Redact, vars.RedactorKey(v.Redactor), v.Float, v.Indent, v.Depth, v.Escape, v.Canonical}
+	if enc, ok := projectionPaths.Load(key); ok {
+		return enc.(vars.Encoder), nil
+	}
+
+	/* build the field tree, without the names matching no field */
+	ft, err := resolver.NewFieldTree(fields)
+	if err != nil {
+		return nil, err
+	}
+	v.Fields = ft.Normalize(vt.Pack())
+
+	/* the same tree may come from different paths */
+	tk := key
+	tk.fields = v.Fields.Key()
+	enc, err := compileProjection(tk, vt, pv, v)
+	if err != nil {
+		return nil, err
+	}
+	return projectionPaths.LoadOrStore(key, enc).(vars.Encoder), nil
+}
+
+func compileProjection(key projectionKey, vt *rt.GoType, pv bool, v vars.Variant) (vars.Encoder, error) {
+	if !vars.UseVM {
+		if enc, ok := jitProjections.Load(key); ok {
+			return enc.(vars.Encoder), nil
+		}
+		if enc, err := compileJitProjection(key, vt, pv, v); enc != nil || err != nil {
+			return enc, err
+		}
+	}
+
+	/* the projections of the VM are evicted when there are too many */
+	if enc, ok := projectionCache.Load(key); ok {
+		return enc.(vars.Encoder), nil
+	}
+	prog, err := makeEncoderVM(vt, pv, v)
+	if err != nil {
+		return nil, err
+	}
+	return projectionCache.LoadOrStore(key, programEncoderVM(prog)).(vars.Encoder), nil
+}
+
+// compileJitProjection compiles the projection with the JIT,
+// it returns nil if there is no more room for the programs.
+func compileJitProjection(key projectionKey, vt *rt.GoType, pv bool, v vars.Variant) (vars.Encoder, error) {
+	jitMutex.Lock()
+	defer jitMutex.Unlock()
+	if enc, ok := jitProjections.Load(key); ok {
+		return enc.(vars.Encoder), nil
+	}
+	if jitCount >= maxJitProjections {
+		return nil, nil
+	}
+
+	/* compile and load the program */
+	prog, err := makeEncoder(vt, pv, v)
+	if err != nil {
+		return nil, err
+	}
+	enc := programEncoder(prog)
+	jitProjections.Store(key, enc)
+	jitCount++
+	return enc, nil
+}
+
+// EncodeWithFields is like Encode but only emits the struct fields selected by fields.
+//
+// Each path of fields is a dot-separated list of JSON field names (such as "owner.email"),
+// which selects the named field of the struct and applies the remaining names to the
+// field value. Pointers, slices and arrays are transparent to the paths, so "items.id"
+// selects the "id" field of every element of "items". The values of maps, interfaces and
+// marshalers are always emitted as a whole, and the names that do not match any field are
+// ignored.
+//
+// The projected programs are cached for every type and set of fields, up to a limit.
+func EncodeWithFields(val interface{}, fields []string, opts Options) ([]byte, error) {
+	return encode(val, opts, &vars.Context{Fields: selectFields(fields)})
+}
+
+// EncodeWithFields is like Encode but only emits the struct fields selected by fields,
+// see the package-level EncodeWithFields.
+func (self *Encoder) EncodeWithFields(v interface{}, fields []string) ([]byte, error) {
+	ctx := self.ctx
+	ctx.Fields = selectFields(fields)
+	return encode(v, self.Opts, &ctx)
+}
+
+// selectFields returns the non-nil paths, since nil disables the projection.
+func selectFields(fields []string) []string {
+	if fields == nil {
+		return []string{}
+	}
+	return fields
+}
+
+func encodeProjected(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64, fields []string) (err error) {
+	if vt == nil {
+		return alg.EncodeNil(buf)
+	}
+	fn, err := findOrCompileProjection(vt, fields, sb, fv)
+	if err != nil {
+		return err
+	}
+
+	/* check for cycles like the unprojected programs */
+	if (fv & (1 << alg.BitDetectCycles)) != 0 {
+		if err = sb.Enter(vt, *vp); err != nil {
+			return err
+		}
+		defer sb.Leave()
+	}
+
+	/* call the encoder */
+	if vt.Indirect() {
+		return fn(buf, *vp, sb, fv)
+	} else {
+		return fn(buf, unsafe.Pointer(vp), sb, fv)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package encoder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type projItem struct {
+	ID    int               `json:"id"`
+	Name  string            `json:"name,omitempty"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+type projTree struct {
+	Name     string      `json:"name"`
+	Children []*projTree `json:"children"`
+}
+
+func TestEncodeWithFields(t *testing.T) {
+	tree := projTree{"a", []*projTree{{"b", []*projTree{{"c", nil}}}}}
+	var cases = []struct {
+		name   string
+		val    interface{}
+		fields []string
+		expect string
+	}{
+		{"top", projItem{ID: 1, Name: "x"}, []string{"name"}, `{"name":"x"}`},
+		{"order", projItem{ID: 1, Name: "x"}, []string{"name", "id"}, `{"id":1,"name":"x"}`},
+		{"nested", struct{ Item *projItem `json:"item"` }{&projItem{ID: 1}}, []string{"item.id"}, `{"item":{"id":1}}`},
+		{"whole", struct{ Item projItem `json:"item"` }{projItem{ID: 1}}, []string{"item", "item.id"}, `{"item":{"id":1,"attrs":null}}`},
+		{"slice", []projItem{{ID: 1}, {ID: 2}}, []string{"id"}, `[{"id":1},{"id":2}]`},
+		{"array", [1]projItem{{ID: 1}}, []string{"id"}, `[{"id":1}]`},
+		{"map", projItem{Attrs: map[string]string{"k": "v"}}, []string{"attrs.x"}, `{"attrs":{"k":"v"}}`},
+		{"interface", struct{ V interface{} }{projItem{ID: 1}}, []string{"V.name"}, `{"V":{"id":1,"attrs":null}}`},
+		{"recursive", tree, []string{"children.children.name"}, `{"children":[{"children":[{"name":"c"}]}]}`},
+		{"unknown", projItem{ID: 1}, []string{"nope", "id.nope"}, `{"id":1}`},
+		{"nil pointer", (*projItem)(nil), []string{"id"}, `null`},
+		{"nil", nil, []string{"id"}, `null`},
+		{"empty", projItem{ID: 1}, nil, `{}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := EncodeWithFields(c.val, c.fields, 0)
+			require.NoError(t, err)
+			assert.Equal(t, c.expect, string(out))
+		})
+	}
+}
+
+func TestEncodeWithFields_Context(t *testing.T) {
+	type sample struct {
+		ID     int     `json:"id"`
+		Secret string  `json:"secret,sensitive"`
+		Price  float64 `json:"price"`
+	}
+	v := sample{1, "x", 1.5}
+
+	enc := Encoder{Opts: RedactSensitive}
+	enc.SetRedactor(func(vt reflect.Type, name string) []byte { return []byte(`"-"`) })
+	require.NoError(t, enc.SetFloatFormat(FloatFormat{Fixed: true, Decimals: 2}))
+	out, err := enc.EncodeWithFields(v, []string{"secret", "price"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"secret":"-","price":1.50}`, string(out))
+
+	enc = Encoder{}
+	enc.SetIndent("", " ")
+	out, err = enc.EncodeWithFields(v, []string{"id"})
+	require.NoError(t, err)
+	assert.Equal(t, "{\n \"id\": 1\n}", string(out))
+
+	enc = Encoder{}
+	enc.SetMaxDepth(1)
+	_, err = enc.EncodeWithFields([]sample{v}, []string{"id"})
+	assert.Error(t, err)
+}
+
+func TestEncodeWithFields_Limit(t *testing.T) {
+	old := maxJitProjections
+	maxJitProjections = 0
+	defer func() { maxJitProjections = old }()
+
+	/* the projections beyond the limit are run by the VM, and the nested values by the JIT */
+	v := struct {
+		Item  projItem    `json:"item"`
+		Items []*projTree `json:"items"`
+		Any   interface{} `json:"any"`
+	}{projItem{ID: 1, Name: "x"}, []*projTree{{"a", nil}}, []projItem{{ID: 2}}}
+	n := jitCount
+	out, err := EncodeWithFields(v, []string{"item.name", "items", "any", "unknown"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `{"item":{"name":"x"},"items":[{"name":"a","children":null}],"any":[{"id":2,"attrs":null}]}`, string(out))
+	assert.Equal(t, n, jitCount)
+
+	/* the names matching no field share the same encoder */
+	m := projectionCache.Len()
+	out, err = EncodeWithFields(v, []string{"any", "items", "item.name", "nothing.at.all"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `{"item":{"name":"x"},"items":[{"name":"a","children":null}],"any":[{"id":2,"attrs":null}]}`, string(out))
+	assert.Equal(t, m, projectionCache.Len())
+}
+
+func TestEncodeWithFields_InvalidPath(t *testing.T) {
+	for _, fields := range [][]string{{"a..b"}, {""}} {
+		_, err := EncodeWithFields(projItem{}, fields, 0)
+		assert.Error(t, err)
+	}
+}
+
+func BenchmarkEncodeWithFields(b *testing.B) {
+	v := []projItem{{ID: 1, Name: "x"}, {ID: 2, Attrs: map[string]string{"k": "v"}}}
+	fields := []string{"id", "attrs"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = EncodeWithFields(v, fields, 0)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package encoder
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type redactCred struct {
+	User     string `json:"user"`
+	Password string `json:"password,sensitive"`
+	Token    string `json:"token,omitempty,sensitive"`
+}
+
+func redactByName(vt reflect.Type, name string) []byte {
+	switch name {
+	case "password":
+		return nil
+	case "key":
+		return []byte(`0`)
+	default:
+		return []byte(`"` + vt.Name() + `.` + name + `"`)
+	}
+}
+
+func TestEncoder_RedactSensitive(t *testing.T) {
+	type node struct {
+		Key  int   `json:"key,sensitive"`
+		Next *node `json:"next,omitempty"`
+	}
+	var cases = []struct {
+		name   string
+		val    interface{}
+		opts   Options
+		fn     Redactor
+		expect string
+	}{
+		{"off", redactCred{"a", "p", "t"}, 0, nil, `{"user":"a","password":"p","token":"t"}`},
+		{"mask", redactCred{"a", "p", "t"}, RedactSensitive, nil, `{"user":"a","password":"***","token":"***"}`},
+		{"omitempty", redactCred{User: "a"}, RedactSensitive, nil, `{"user":"a","password":"***"}`},
+		{"nested", []interface{}{&redactCred{Password: "p"}}, RedactSensitive, nil, `[{"user":"","password":"***"}]`},
+		{"recursive", node{1, &node{Key: 2}}, RedactSensitive, nil, `{"key":"***","next":{"key":"***"}}`},
+		{"redactor", redactCred{"a", "p", "t"}, RedactSensitive, redactByName, `{"user":"a","token":"redactCred.token"}`},
+		{"redactor off", redactCred{"a", "p", "t"}, 0, redactByName, `{"user":"a","password":"p","token":"t"}`},
+		{"redactor value", node{Key: 1}, RedactSensitive, redactByName, `{"key":0}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			enc := Encoder{Opts: c.opts}
+			enc.SetRedactor(c.fn)
+			out, err := enc.Encode(c.val)
+			require.NoError(t, err)
+			assert.Equal(t, c.expect, string(out))
+		})
+	}
+}
+
+func TestEncoder_RedactorIndentAndStream(t *testing.T) {
+	enc := Encoder{Opts: RedactSensitive}
+	enc.SetRedactor(redactByName)
+	enc.SetIndent("", " ")
+	out, err := enc.Encode(redactCred{User: "a", Password: "p"})
+	require.NoError(t, err)
+	assert.Equal(t, "{\n \"user\": \"a\"\n}", string(out))
+
+	w := bytes.NewBuffer(nil)
+	senc := NewStreamEncoder(w)
+	senc.SetRedactSensitive(true)
+	senc.SetRedactor(redactByName)
+	require.NoError(t, senc.Encode(redactCred{User: "a", Token: "t"}))
+	assert.Equal(t, "{\"user\":\"a\",\"token\":\"redactCred.token\"}\n", w.String())
+}
+
+func TestEncoder_RedactorInvalid(t *testing.T) {
+	enc := Encoder{Opts: RedactSensitive}
+	enc.SetRedactor(func(vt reflect.Type, name string) []byte {
+		return []byte(`{`)
+	})
+	_, err := enc.Encode(redactCred{})
+	require.Error(t, err)
+}
+
+func TestEncodeWithFields_RedactSensitive(t *testing.T) {
+	out, err := EncodeWithFields([]redactCred{{"a", "p", "t"}}, []string{"password", "token"}, RedactSensitive)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"password":"***","token":"***"}]`, string(out))
+}
@@ -17,7 +17,6 @@
 package encoder
 
 import (
-	"encoding/json"
 	"io"
 
 	"github.com/bytedance/sonic/internal/encoder/vars"
@@ -39,53 +38,28 @@ func NewStreamEncoder(w io.Writer) *StreamEncoder {
 // Encode encodes interface{} as JSON to io.Writer
 func (enc *StreamEncoder) Encode(val interface{}) (err error) {
     out := vars.NewBytes()
+    defer vars.FreeBytes(out)
 
-    /* encode into the buffer */
-    err = EncodeInto(out, val, enc.Opts)
+    /* encode into the buffer, indented if SetIndent is called */
+    err = encodeIntoWith(out, val, enc.Opts, &enc.ctx)
     if err != nil {
-        goto free_bytes
+        return err
     }
 
-    if enc.indent != "" || enc.prefix != "" {
-        /* indent the JSON */
-        buf := vars.NewBuffer()
-        err = json.Indent(buf, *out, enc.prefix, enc.indent)
-        if err != nil {
-            vars.FreeBuffer(buf)
-            goto free_bytes
-        }
-
-        // according to standard library, terminate each value with a newline...
-        if enc.Opts & NoEncoderNewline == 0 {
-            buf.WriteByte('\n')
-        }
+    // according to standard library, terminate each value with a newline...
+    if enc.Opts & NoEncoderNewline == 0 {
+        *out = append(*out, '\n')
+    }
 
-        /* copy into io.Writer */
-        _, err = io.Copy(enc.w, buf)
+    /* copy into io.Writer */
+    var n int
+    buf := *out
+    for len(buf) > 0 {
+        n, err = enc.w.Write(buf)
+        buf = buf[n:]
         if err != nil {
-            vars.FreeBuffer(buf)
-            goto free_bytes
-        }
-
-    } else {
-        /* copy into io.Writer */
-        var n int
-        buf := *out
-        for len(buf) > 0 {
-            n, err = enc.w.Write(buf)
-            buf = buf[n:]
-            if err != nil {
-                goto free_bytes
-            }
-        }
-
-        // according to standard library, terminate each value with a newline...
-        if enc.Opts & NoEncoderNewline == 0 {
-            enc.w.Write([]byte{'\n'})
+            return err
         }
     }
-
-free_bytes:
-    vars.FreeBytes(out)
-    return err
+    return nil
 }
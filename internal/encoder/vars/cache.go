@@ -17,8 +17,10 @@
 package vars
 
 import (
+	"sync"
 	"unsafe"
 
+	"github.com/bytedance/sonic/internal/resolver"
 	"github.com/bytedance/sonic/internal/rt"
 )
 
@@ -45,4 +47,66 @@ func GetProgram(vt *rt.GoType) (interface{}) {
 
 func ComputeProgram(vt *rt.GoType, compute func(*rt.GoType, ... interface{}) (interface{}, error), pv bool) (interface{}, error) {
 	return programCache.Compute(vt, compute, pv)
-}
\ No newline at end of file
+}
+
+// Variant describes how the program of a type differs from the default one.
+type Variant struct {
+	Redact    bool          // redact the sensitive fields with Redactor
+	Redactor  Redactor
+	Float     *FloatFormat  // the interned format of the floats, nil for the default
+	Indent    bool          // write the indented output
+	Depth     bool          // track the nesting depth of the output
+	Escape    bool          // quote the strings in Go, for the escaping options
+	Canonical bool          // write the canonical form of RFC 8785
+	Fields    resolver.FieldTree // only emit the selected struct fields, nil for all
+}
+
+// IsDefault tells if v is the default program of the type.
+func (v Variant) IsDefault() bool {
+	return !v.Redact && v.Float == nil && !v.Indent && !v.Depth && !v.Escape && !v.Canonical && v.Fields == nil
+}
+
+type variantKey struct {
+	vt  *rt.GoType
+	rdx bool
+	fn  unsafe.Pointer
+	ff  *FloatFormat
+	ind bool
+	dep bool
+	esc bool
+	can bool
+}
+
+var variantCache sync.Map
+
+// FindOrCompileVariant is like FindOrCompile, but for the programs described by v.
+// The compiler is called with v as the extra argument.
+//
+// The projections are never cached here, since their fields come from the users,
+// the encoder keeps them in the caches of bounded size.
+func FindOrCompileVariant(vt *rt.GoType, pv bool, v Variant, compiler func(*rt.GoType, ... interface{}) (interface{}, error)) (interface{}, error) {
+	if v.Fields != nil {
+		return compiler(vt, pv, v)
+	}
+	if !v.Redact {
+		v.Redactor = nil
+	}
+	key := variantKey{vt, v.Redact, RedactorKey(v.Redactor), v.Float, v.Indent, v.Depth, v.Escape, v.Canonical}
+	if val, ok := variantCache.Load(key); ok {
+		return val, nil
+	}
+
+	/* compile the program, and keep the first one if there are races */
+	ret, err := compiler(vt, pv, v)
+	if err != nil {
+		return nil, err
+	}
+	val, _ := variantCache.LoadOrStore(key, ret)
+	return val, nil
+}
+
+// RedactorKey returns the identity of fn, which is also referenced by the key
+// to keep the closure alive.
+func RedactorKey(fn Redactor) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&fn))
+}
@@ -26,6 +26,9 @@ const (
 	StackSize = unsafe.Sizeof(Stack{})
 	StateSize  = int64(unsafe.Sizeof(State{}))
 	StackLimit = MaxStack * StateSize
+	StackDepth = int64(unsafe.Offsetof(Stack{}.depth))
+	StackMaxD  = int64(unsafe.Offsetof(Stack{}.maxd))
+	StackMaxN  = int64(unsafe.Offsetof(Stack{}.maxn))
 )
 
 const (
@@ -33,6 +36,10 @@ const (
 	MAX_FIELDS = 50     // cutoff at 50 fields struct
 )
 
+// CycleDepth is the nesting level of the encoded values after which the pointers
+// are tracked to detect the cycles, since the shallow values can not be cyclic.
+const CycleDepth = 100
+
 var (
 	DebugSyncGC   = os.Getenv("SONIC_SYNC_GC") != ""
 	DebugAsyncGC  = os.Getenv("SONIC_NO_ASYNC_GC") == ""
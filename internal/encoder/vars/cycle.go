@@ -0,0 +1,137 @@
+/**
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/bytedance/sonic/internal/rt"
+)
+
+// cyclePath returns the types on the cycle from the i-th tracked value back to
+// itself. Only the interfaces and the recursive types are tracked, so the containers
+// between two tracked values are found by walking the value of the former.
+func (s *Stack) cyclePath(i int) []reflect.Type {
+	ret := []reflect.Type{s.seen[i].vt.Pack()}
+	for j := i; j < len(s.seen); j++ {
+		dst := s.seen[i]
+		if j+1 < len(s.seen) {
+			dst = s.seen[j+1]
+		}
+		ret = append(ret, findHops(s.seen[j], dst)...)
+		ret = append(ret, dst.vt.Pack())
+	}
+	return ret
+}
+
+// hopWalker finds the path from a tracked value to the next one.
+type hopWalker struct {
+	dst  visit
+	path []reflect.Type
+	done map[unsafe.Pointer]bool
+}
+
+// findHops returns the types of the containers from the tracked value src to dst,
+// or nil if dst is not found.
+func findHops(src visit, dst visit) []reflect.Type {
+	w := hopWalker{dst: dst, done: map[unsafe.Pointer]bool{}}
+
+	/* vp is the address of the value, or the value itself if it is a pointer */
+	vp := src.vp
+	if !src.vt.Indirect() {
+		vp = unsafe.Pointer(&src.vp)
+	}
+	w.walk(src.vt.Pack(), vp, 0, true)
+	return w.path
+}
+
+// walk tells if dst is reachable from the value of type vt at p, which is recorded
+// into the path unless it is named by its parent, like the element of a pointer.
+// The interfaces are not walked through, since the values in them are tracked.
+func (w *hopWalker) walk(vt reflect.Type, p unsafe.Pointer, depth int, named bool) bool {
+	gt := rt.UnpackType(vt)
+	if depth > 0 && gt == w.dst.vt {
+		if gt.Indirect() && p == w.dst.vp || !gt.Indirect() && *(*unsafe.Pointer)(p) == w.dst.vp {
+			return true
+		}
+	}
+	if vt.Kind() == reflect.Interface {
+		return w.walkInterface(vt, p)
+	}
+	if gt.PtrData == 0 || depth > CycleDepth {
+		return false
+	}
+	if !named {
+		w.path = append(w.path, vt)
+	}
+
+	found := false
+	switch vt.Kind() {
+	case reflect.Ptr:
+		if ep := *(*unsafe.Pointer)(p); ep != nil && !w.done[ep] {
+			w.done[ep] = true
+			found = w.walk(vt.Elem(), ep, depth+1, true)
+		}
+	case reflect.Struct:
+		for i := 0; !found && i < vt.NumField(); i++ {
+			f := vt.Field(i)
+			found = w.walk(f.Type, rt.Add(p, f.Offset), depth+1, false)
+		}
+	case reflect.Array:
+		et := vt.Elem()
+		for i := 0; !found && i < vt.Len(); i++ {
+			found = w.walk(et, rt.Add(p, uintptr(i)*et.Size()), depth+1, false)
+		}
+	case reflect.Slice:
+		sl := (*rt.GoSlice)(p)
+		if sl.Ptr != nil && !w.done[sl.Ptr] {
+			w.done[sl.Ptr] = true
+			et := vt.Elem()
+			for i := 0; !found && i < sl.Len; i++ {
+				found = w.walk(et, rt.Add(sl.Ptr, uintptr(i)*et.Size()), depth+1, false)
+			}
+		}
+	case reflect.Map:
+		if mp := *(*unsafe.Pointer)(p); mp != nil && !w.done[mp] {
+			w.done[mp] = true
+			it := reflect.NewAt(vt, p).Elem().MapRange()
+			for !found && it.Next() {
+				ev := reflect.New(vt.Elem())
+				ev.Elem().Set(it.Value())
+				found = w.walk(vt.Elem(), unsafe.Pointer(ev.Pointer()), depth+1, false)
+			}
+		}
+	}
+	if !found && !named {
+		w.path = w.path[:len(w.path)-1]
+	}
+	return found
+}
+
+// walkInterface tells if the interface at p holds dst.
+func (w *hopWalker) walkInterface(vt reflect.Type, p unsafe.Pointer) bool {
+	var et *rt.GoType
+	var ep unsafe.Pointer
+	if vt.NumMethod() == 0 {
+		ef := (*rt.GoEface)(p)
+		et, ep = ef.Type, ef.Value
+	} else if it := (*rt.GoIface)(p); it.Itab != nil {
+		et, ep = it.Itab.Vt, it.Value
+	}
+	return et != nil && et == w.dst.vt && ep == w.dst.vp
+}
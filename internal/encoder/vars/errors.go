@@ -21,6 +21,7 @@ import (
     `fmt`
     `reflect`
     `strconv`
+    `strings`
     `unsafe`
 
     `github.com/bytedance/sonic/internal/rt`
@@ -47,10 +48,62 @@ func Error_number(number json.Number) error {
     }
 }
 
+func Error_invalid_utf8(str string) error {
+    return &json.UnsupportedValueError {
+        Str   : "invalid UTF-8 string in the canonical form: " + strconv.Quote(str),
+        Value : reflect.ValueOf(str),
+    }
+}
+
+func Error_duplicate_key(key string) error {
+    return &json.UnsupportedValueError {
+        Str   : "duplicate object key in the canonical form: " + strconv.Quote(key),
+        Value : reflect.ValueOf(key),
+    }
+}
+
+// LimitError is returned when the output of encoder exceeds the limits.
+type LimitError struct {
+    Limit string // "depth" or "size"
+    Max   int
+}
+
+func (self *LimitError) Error() string {
+    return fmt.Sprintf("encoder: output exceeds the max %s of %d", self.Limit, self.Max)
+}
+
+func Error_max_depth(max int) error {
+    return &LimitError{Limit: "depth", Max: max}
+}
+
+func Error_max_size(max int) error {
+    return &LimitError{Limit: "size", Max: max}
+}
+
+func Error_cycle(path []reflect.Type) error {
+    names := make([]string, len(path))
+    for i, vt := range path {
+        names[i] = vt.String()
+    }
+    str := strings.Join(names, " -> ")
+    return &json.UnsupportedValueError {
+        Str   : "encountered a cycle via " + str,
+        Value : reflect.ValueOf(str),
+    }
+}
+
 func Error_marshaler(ret []byte, pos int) error {
     return fmt.Errorf("invalid Marshaler output json syntax at %d: %q", pos, ret)
 }
 
+func Error_redactor(vt reflect.Type, name string, ret []byte) error {
+    return fmt.Errorf("invalid Redactor output json syntax for field %q of %s: %q", name, vt, ret)
+}
+
+func Error_float_format(vt reflect.Type, name string, err error) error {
+    return fmt.Errorf("invalid float format for field %q of %s: %v", name, vt, err)
+}
+
 const (
     PanicNilPointerOfNonEmptyString int = 1 + iota
 )
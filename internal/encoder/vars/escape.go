@@ -0,0 +1,69 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+    `sort`
+)
+
+// EscapeSet is a set of the characters to be escaped in the JSON strings.
+type EscapeSet struct {
+    table [128]bool
+    bytes []byte
+    runes []rune
+}
+
+// NewEscapeSet returns the set of the characters in chars, except the ones which
+// are always escaped by JSON: '"', '\\' and the control characters.
+func NewEscapeSet(chars string) *EscapeSet {
+    ret := new(EscapeSet)
+    for _, r := range chars {
+        if r >= 0x80 {
+            ret.runes = append(ret.runes, r)
+        } else if r >= 0x20 && r != '"' && r != '\\' && !ret.table[r] {
+            ret.table[r] = true
+            ret.bytes = append(ret.bytes, byte(r))
+        }
+    }
+    sort.Slice(ret.runes, func(i, j int) bool {
+        return ret.runes[i] < ret.runes[j]
+    })
+    return ret
+}
+
+// Bytes returns the ASCII characters in the set.
+func (self *EscapeSet) Bytes() []byte {
+    return self.bytes
+}
+
+// HasByte reports whether the ASCII character c is in the set.
+func (self *EscapeSet) HasByte(c byte) bool {
+    return c < 0x80 && self.table[c]
+}
+
+// HasRune reports whether the non-ASCII character r is in the set.
+func (self *EscapeSet) HasRune(r rune) bool {
+    i := sort.Search(len(self.runes), func(i int) bool {
+        return self.runes[i] >= r
+    })
+    return i < len(self.runes) && self.runes[i] == r
+}
+
+// NonASCII reports whether there are any non-ASCII characters in the set.
+func (self *EscapeSet) NonASCII() bool {
+    return len(self.runes) != 0
+}
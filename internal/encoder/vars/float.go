@@ -0,0 +1,155 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+    `fmt`
+    `strconv`
+    `strings`
+    `sync`
+)
+
+// FloatExponent controls the exponent notation of the floats.
+type FloatExponent uint8
+
+const (
+    // FloatExponentAuto uses the exponent notation only for the very large or
+    // very small floats (< 1e-6 or >= 1e21), the same as the default encoding.
+    FloatExponentAuto FloatExponent = iota
+
+    // FloatExponentNever never uses the exponent notation.
+    FloatExponentNever
+
+    // FloatExponentAlways always uses the exponent notation.
+    FloatExponentAlways
+)
+
+// FloatFormat controls how the floats are encoded. The zero value is the
+// shortest representation that round-trips, which is the default.
+type FloatFormat struct {
+    // Fixed indicates to emit exactly Decimals digits after the decimal point.
+    Fixed bool
+
+    // Decimals is the number of digits after the decimal point if Fixed is true,
+    // which must be 0 otherwise.
+    Decimals int
+
+    // Precision is the maximum number of significant digits if it is positive,
+    // trailing zeros are removed. It must be 0 if Fixed is true.
+    Precision int
+
+    // Exponent controls the exponent notation.
+    Exponent FloatExponent
+
+    // NegativeZero indicates to emit -0 as `-0` instead of `0`.
+    NegativeZero bool
+}
+
+// the float format options in struct tags
+const (
+    _OptDecimals   = "decimals="
+    _OptPrecision  = "precision="
+    _OptExponent   = "exponent"
+    _OptNoExponent = "noexponent"
+    _OptNegZero    = "negzero"
+)
+
+// ParseFloatFormat parses the comma-separated float format options in spec, which
+// are "decimals=N", "precision=N", "exponent", "noexponent" and "negzero".
+func ParseFloatFormat(spec string) (ff FloatFormat, err error) {
+    for _, opt := range strings.Split(spec, ",") {
+        switch {
+            case opt == _OptExponent   : ff.Exponent = FloatExponentAlways
+            case opt == _OptNoExponent : ff.Exponent = FloatExponentNever
+            case opt == _OptNegZero    : ff.NegativeZero = true
+            case strings.HasPrefix(opt, _OptDecimals): {
+                n, err := parseDigits(opt[len(_OptDecimals):])
+                if err != nil {
+                    return ff, fmt.Errorf("invalid float format option %q: %v", opt, err)
+                }
+                ff.Fixed, ff.Decimals, ff.Precision = true, n, 0
+            }
+            case strings.HasPrefix(opt, _OptPrecision): {
+                n, err := parseDigits(opt[len(_OptPrecision):])
+                if err == nil && n == 0 {
+                    err = fmt.Errorf("precision must be positive")
+                }
+                if err != nil {
+                    return ff, fmt.Errorf("invalid float format option %q: %v", opt, err)
+                }
+                ff.Fixed, ff.Decimals, ff.Precision = false, 0, n
+            }
+            default: {
+                return ff, fmt.Errorf("unknown float format option %q", opt)
+            }
+        }
+    }
+    return ff, nil
+}
+
+// the max digits of the floats, which is enough for any float64
+const _MaxFloatDigits = 767
+
+func parseDigits(s string) (int, error) {
+    n, err := strconv.Atoi(s)
+    if err != nil {
+        return 0, err
+    }
+    if n < 0 || n > _MaxFloatDigits {
+        return 0, fmt.Errorf("out of range [0, %d]", _MaxFloatDigits)
+    }
+    return n, nil
+}
+
+// ValidateFloatFormat checks ff by the same rules as ParseFloatFormat: Decimals and
+// Precision are in [0, 767], Decimals is set only if Fixed is true, Precision only
+// if it is false, and Exponent is one of the FloatExponent constants.
+func ValidateFloatFormat(ff FloatFormat) error {
+    if ff.Decimals < 0 || ff.Decimals > _MaxFloatDigits {
+        return fmt.Errorf("invalid float format: Decimals %d out of range [0, %d]", ff.Decimals, _MaxFloatDigits)
+    }
+    if ff.Precision < 0 || ff.Precision > _MaxFloatDigits {
+        return fmt.Errorf("invalid float format: Precision %d out of range [0, %d]", ff.Precision, _MaxFloatDigits)
+    }
+    if !ff.Fixed && ff.Decimals != 0 {
+        return fmt.Errorf("invalid float format: Decimals %d without Fixed", ff.Decimals)
+    }
+    if ff.Fixed && ff.Precision != 0 {
+        return fmt.Errorf("invalid float format: Precision %d with Fixed", ff.Precision)
+    }
+    if ff.Exponent > FloatExponentAlways {
+        return fmt.Errorf("invalid float format: unknown Exponent %d", ff.Exponent)
+    }
+    return nil
+}
+
+var floatFormats sync.Map
+
+// InternFloatFormat returns the unique and never freed pointer to ff, or nil if
+// ff is the default format, so that it can be referenced by the compiled programs
+// and compared by the pointer. ff must be valid, see ValidateFloatFormat, which
+// also bounds the number of the distinct formats.
+func InternFloatFormat(ff FloatFormat) *FloatFormat {
+    if ff == (FloatFormat{}) {
+        return nil
+    }
+    if ret, ok := floatFormats.Load(ff); ok {
+        return ret.(*FloatFormat)
+    }
+    ret, _ := floatFormats.LoadOrStore(ff, &ff)
+    return ret.(*FloatFormat)
+}
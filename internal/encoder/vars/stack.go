@@ -18,6 +18,7 @@ package vars
 
 import (
 	"bytes"
+	"reflect"
 	"sync"
 	"unsafe"
 
@@ -34,8 +35,49 @@ type State struct {
 }
 
 type Stack struct {
-	sp uintptr
-	sb [MaxStack]State
+	sp    uintptr
+	sb    [MaxStack]State
+	cx    *Context
+	depth int
+	level int
+	seen  []visit
+	maxd  int // max nesting depth, 0 for unlimited
+	maxn  int // max length of the buffer, 0 for unlimited
+}
+
+// visit is a value being encoded, which is tracked for the cycle detection.
+type visit struct {
+	vt *rt.GoType
+	vp unsafe.Pointer
+}
+
+// Redactor returns the JSON text replacing the value of the sensitive field name
+// of struct vt, or nil to omit the field.
+type Redactor func(vt reflect.Type, name string) []byte
+
+// Context holds the per-call configurations, which can not be passed by the flags.
+type Context struct {
+	Redactor    Redactor
+	Escapes     *EscapeSet
+	FloatFormat *FloatFormat
+	Indent      *Indent
+	MaxDepth    int
+	MaxSize     int
+
+	// Fields selects the struct fields of the top-level value, nil for all
+	Fields      []string
+}
+
+// IsEmpty tells if cx is nil or holds no configuration.
+func (cx *Context) IsEmpty() bool {
+	return cx == nil || cx.Redactor == nil && cx.Escapes == nil && cx.FloatFormat == nil &&
+		cx.Indent == nil && cx.MaxDepth <= 0 && cx.MaxSize <= 0 && cx.Fields == nil
+}
+
+// Indent holds the prefix and the indent of the indented output, see json.Indent.
+type Indent struct {
+	Prefix string
+	Indent string
 }
 
 var (
@@ -61,6 +103,10 @@ func NewBytes() *[]byte {
 func NewStack() *Stack {
 	ret :=  stackPool.Get().(*Stack)
 	ret.sp = 0
+	ret.depth = 0
+	ret.level = 0
+	ret.maxd = 0
+	ret.maxn = 0
 	return ret
 }
 
@@ -68,6 +114,149 @@ func ResetStack(p *Stack) {
 	rt.MemclrNoHeapPointers(unsafe.Pointer(p), StackSize)
 }
 
+func (s *Stack) SetContext(cx *Context) {
+	if cx.IsEmpty() {
+		cx = nil
+	}
+	s.cx = cx
+	if cx != nil && cx.MaxDepth > 0 {
+		s.maxd = cx.MaxDepth
+	}
+}
+
+// LimitSize limits the length of the output buffer to base + MaxSize of the context,
+// where base is the length of the buffer before encoding.
+func (s *Stack) LimitSize(base int) {
+	if s.cx != nil && s.cx.MaxSize > 0 {
+		s.maxn = base + s.cx.MaxSize
+	}
+}
+
+// HasContext tells if there is any per-call configuration.
+func (s *Stack) HasContext() bool {
+	return s.cx != nil
+}
+
+// LimitsSize tells if the length of the output buffer is limited.
+func (s *Stack) LimitsSize() bool {
+	return s.maxn > 0
+}
+
+// MaxDepth returns the max nesting depth of the output, or 0 if it is unlimited.
+func (s *Stack) MaxDepth() int {
+	if s.cx == nil {
+		return 0
+	}
+	return s.cx.MaxDepth
+}
+
+// CheckSize fails if the length of the output buffer n exceeds the limit.
+func (s *Stack) CheckSize(n int) error {
+	if s.maxn > 0 && n > s.maxn {
+		return s.SizeError()
+	}
+	return nil
+}
+
+// SizeError returns the error of the output exceeding the limit of length.
+func (s *Stack) SizeError() error {
+	return Error_max_size(s.cx.MaxSize)
+}
+
+func (s *Stack) Redactor() Redactor {
+	if s.cx == nil {
+		return nil
+	}
+	return s.cx.Redactor
+}
+
+// FloatFormat returns the interned format of the floats, or nil for the default.
+func (s *Stack) FloatFormat() *FloatFormat {
+	if s.cx == nil {
+		return nil
+	}
+	return s.cx.FloatFormat
+}
+
+// EscapeSet returns the extra characters to be escaped, or nil if there is none.
+func (s *Stack) EscapeSet() *EscapeSet {
+	return s.cx.EscapeSet()
+}
+
+// Indent returns the indentation of the output, or nil if it is compact.
+func (s *Stack) Indent() *Indent {
+	return s.cx.Indentation()
+}
+
+// AddDepth changes the nesting depth of the output by d, and fails if it exceeds the limit.
+func (s *Stack) AddDepth(d int) error {
+	if s.depth += d; s.maxd > 0 && s.depth > s.maxd {
+		return Error_max_depth(s.maxd)
+	}
+	return nil
+}
+
+// Newline appends the line break and the indentation of the current depth to buf.
+func (s *Stack) Newline(buf []byte) []byte {
+	ind := s.cx.Indent
+	buf = append(buf, '\n')
+	buf = append(buf, ind.Prefix...)
+	for i := 0; i < s.depth; i++ {
+		buf = append(buf, ind.Indent...)
+	}
+	return buf
+}
+
+// LinePrefix returns the prefix of the lines at the current depth, which can be
+// used to indent the nested JSON texts at the current line.
+func (s *Stack) LinePrefix() string {
+	return string(s.Newline(nil)[1:])
+}
+
+// Enter marks the value vp of type vt as being encoded, and fails if it is being
+// encoded already, which means it contains itself. The values are tracked only
+// after CycleDepth levels of nesting.
+func (s *Stack) Enter(vt *rt.GoType, vp unsafe.Pointer) error {
+	if s.level++; s.level <= CycleDepth {
+		return nil
+	}
+
+	/* the nil and zero-sized values can not contain themselves */
+	for i := len(s.seen) - 1; vp != nil && vt.Size != 0 && i >= 0; i-- {
+		if v := s.seen[i]; v.vp == vp && v.vt == vt {
+			s.level--
+			return Error_cycle(s.cyclePath(i))
+		}
+	}
+	s.seen = append(s.seen, visit{vt: vt, vp: vp})
+	return nil
+}
+
+// Leave marks the last entered value as encoded.
+func (s *Stack) Leave() {
+	if s.level--; s.level >= CycleDepth {
+		n := len(s.seen) - 1
+		s.seen[n] = visit{}
+		s.seen = s.seen[:n]
+	}
+}
+
+// Indentation returns the indentation of the output, or nil if it is compact.
+func (cx *Context) Indentation() *Indent {
+	if cx == nil {
+		return nil
+	}
+	return cx.Indent
+}
+
+// EscapeSet returns the extra characters to be escaped, or nil if there is none.
+func (cx *Context) EscapeSet() *EscapeSet {
+	if cx == nil {
+		return nil
+	}
+	return cx.Escapes
+}
+
 func (s *Stack) Top() *State {
 	return (*State)(rt.Add(unsafe.Pointer(&s.sb[0]), s.sp))
 }
@@ -127,6 +316,12 @@ func FreeBytes(p *[]byte) {
 
 func FreeStack(p *Stack) {
 	p.sp = 0
+	p.cx = nil
+	p.depth = 0
+	p.level = 0
+	p.seen = p.seen[:0]
+	p.maxd = 0
+	p.maxn = 0
 	stackPool.Put(p)
 }
 
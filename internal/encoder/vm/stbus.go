@@ -29,8 +29,10 @@ import (
 func EncodeTypedPointer(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64) error {
 	if vt == nil {
 		return alg.EncodeNil(buf)
-	} else if pp, err := vars.FindOrCompile(vt, (fv&(1<<alg.BitPointerValue)) != 0, compiler); err != nil {
+	} else if pp, err := findOrCompile(vt, sb, fv); err != nil {
 		return err
+	} else if (fv & (1<<alg.BitDetectCycles)) != 0 {
+		return encodeChecked(buf, vt, vp, sb, fv, pp.(*ir.Program))
 	} else if vt.Indirect() {
 		return Execute(buf, *vp, sb, fv, pp.(*ir.Program))
 	} else {
@@ -38,6 +40,43 @@ func EncodeTypedPointer(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars
 	}
 }
 
+// encodeChecked is like EncodeTypedPointer, but fails if the value contains itself.
+func encodeChecked(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64, pp *ir.Program) (err error) {
+	if err = sb.Enter(vt, *vp); err != nil {
+		return err
+	}
+	if vt.Indirect() {
+		err = Execute(buf, *vp, sb, fv, pp)
+	} else {
+		err = Execute(buf, unsafe.Pointer(vp), sb, fv, pp)
+	}
+	sb.Leave()
+	return err
+}
+
+func findOrCompile(vt *rt.GoType, sb *vars.Stack, fv uint64) (interface{}, error) {
+	pv := (fv&(1<<alg.BitPointerValue)) != 0
+	if alg.DefaultVariant(sb, fv) {
+		return vars.FindOrCompile(vt, pv, compiler)
+	} else if v := alg.ProgramVariant(sb, fv); v.IsDefault() {
+		return vars.FindOrCompile(vt, pv, compiler)
+	} else {
+		return vars.FindOrCompileVariant(vt, pv, v, compiler)
+	}
+}
+
+// nested encodes the values the programs refer to. The values are encoded by the JIT,
+// if the VM only runs a few programs for it.
+var nested func(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64) error
+
+func init() {
+	nested = EncodeTypedPointer
+}
+
+func SetNested(fn func(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64) error) {
+	nested = fn
+}
+
 var compiler func(*rt.GoType, ... interface{}) (interface{}, error)
 
 func SetCompiler(c func(*rt.GoType, ... interface{}) (interface{}, error)) {
@@ -60,11 +60,22 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 	var f uint64
 
 	var pro = &(*prog)[0]
+	var bc = cap(buf)
+	var limited = s.LimitsSize()
 	for pc := 0; pc < pl; {
 		ins := (*ir.Instr)(rt.Add(unsafe.Pointer(pro), ir.OpSize*uintptr(pc)))
 		pc++
 		op := ins.Op()
 
+		/* the output may not grow beyond the limit, which is checked only when
+		 * the buffer has been reallocated, like _more_space of the JIT */
+		if limited && cap(buf) != bc {
+			if err := s.CheckSize(len(buf)); err != nil {
+				return err
+			}
+			bc = cap(buf)
+		}
+
 		switch op {
 		case ir.OP_goto:
 			pc = ins.Vi()
@@ -99,12 +110,12 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 			}
 			*b = buf
 			if vt.Indirect() {
-				if err := EncodeTypedPointer(b, vt, (*unsafe.Pointer)(rt.NoEscape(unsafe.Pointer(&p))), s, f); err != nil {
+				if err := nested(b, vt, (*unsafe.Pointer)(rt.NoEscape(unsafe.Pointer(&p))), s, f); err != nil {
 					return err
 				}
 			} else {
 				vp := (*unsafe.Pointer)(p)
-				if err := EncodeTypedPointer(b, vt, vp, s, f); err != nil {
+				if err := nested(b, vt, vp, s, f); err != nil {
 					return err
 				}
 			}
@@ -174,6 +185,20 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 				return vars.ERR_nan_or_infinite
 			}
 			buf = alg.F64toa(buf, v)
+		case ir.OP_i64_q:
+			alg.EncodeInt64(&buf, p, flags)
+		case ir.OP_u64_q:
+			alg.EncodeUint64(&buf, p, flags)
+		case ir.OP_indent:
+			if err := s.AddDepth(ins.Vi()); err != nil {
+				return err
+			}
+		case ir.OP_newline:
+			buf = s.Newline(buf)
+		case ir.OP_float:
+			if err := alg.EncodeFloat(&buf, p, ins.Vff(), ins.Vi(), flags); err != nil {
+				return err
+			}
 		case ir.OP_bin:
 			v := *(*[]byte)(p)
 			buf = base64.EncodeBase64(buf, v)
@@ -191,13 +216,13 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 			}
 		case ir.OP_eface:
 			*b = buf
-			if err := EncodeTypedPointer(b, *(**rt.GoType)(p), (*unsafe.Pointer)(rt.Add(p, 8)), s, flags); err != nil {
+			if err := nested(b, *(**rt.GoType)(p), (*unsafe.Pointer)(rt.Add(p, 8)), s, flags); err != nil {
 				return err
 			}
 			buf = *b
 		case ir.OP_iface:
 			*b = buf
-			if err := EncodeTypedPointer(b,  (*(**rt.GoItab)(p)).Vt, (*unsafe.Pointer)(rt.Add(p, 8)), s, flags); err != nil {
+			if err := nested(b, (*(**rt.GoItab)(p)).Vt, (*unsafe.Pointer)(rt.Add(p, 8)), s, flags); err != nil {
 				return err
 			}
 			buf = *b
@@ -243,13 +268,13 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 				case reflect.Ptr, reflect.Map : it = convT2I(p, true, itab)
 				default                       : it = convT2I(p, !vt.Indirect(), itab)
 			}
-			if err := alg.EncodeTextMarshaler(&buf, *(*encoding.TextMarshaler)(unsafe.Pointer(&it)), (flags)); err != nil {
+			if err := alg.EncodeTextMarshaler(&buf, *(*encoding.TextMarshaler)(unsafe.Pointer(&it)), (flags), s); err != nil {
 				return err
 			}
 		case ir.OP_marshal_text_p:
 			_, itab := ins.Vtab()
 			it := convT2I(p, false, itab)
-			if err := alg.EncodeTextMarshaler(&buf, *(*encoding.TextMarshaler)(unsafe.Pointer(&it)), (flags)); err != nil {
+			if err := alg.EncodeTextMarshaler(&buf, *(*encoding.TextMarshaler)(unsafe.Pointer(&it)), (flags), s); err != nil {
 				return err
 			}
 		case ir.OP_map_write_key:
@@ -259,6 +284,19 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 				pc = ins.Vi()
 				continue
 			}
+		case ir.OP_map_sorted_key:
+			if has_opts(flags, alg.BitSortMapKeys) || has_opts(flags, alg.BitCanonical) {
+				pc = ins.Vi()
+				continue
+			}
+		case ir.OP_esc_str:
+			if err := alg.EncodeString(&buf, *(*string)(p), s, flags, ins.Vi()); err != nil {
+				return err
+			}
+		case ir.OP_esc_text:
+			if err := alg.EncodeText(&buf, ins.Vs(), s, flags); err != nil {
+				return err
+			}
 		case ir.OP_slice_len:
 			v := (*rt.GoSlice)(p)
 			x = v.Len
@@ -329,13 +367,13 @@ func Execute(b *[]byte, p unsafe.Pointer, s *vars.Stack, flags uint64, prog *ir.
 				case reflect.Ptr, reflect.Map : it = convT2I(p, true, itab)
 				default                       : it = convT2I(p, !vt.Indirect(), itab)
 			}
-			if err := alg.EncodeJsonMarshaler(&buf, *(*json.Marshaler)(unsafe.Pointer(&it)), (flags)); err != nil {
+			if err := alg.EncodeJsonMarshaler(&buf, *(*json.Marshaler)(unsafe.Pointer(&it)), (flags), s); err != nil {
 				return err
 			}
 		case ir.OP_marshal_p:
 			_, itab := ins.Vtab()
 			it := convT2I(p, false, itab)
-			if err := alg.EncodeJsonMarshaler(&buf, *(*json.Marshaler)(unsafe.Pointer(&it)), (flags)); err != nil {
+			if err := alg.EncodeJsonMarshaler(&buf, *(*json.Marshaler)(unsafe.Pointer(&it)), (flags), s); err != nil {
 				return err
 			}
 		default:
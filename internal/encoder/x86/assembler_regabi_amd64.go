@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"sync"
 	"unsafe"
 
 	"github.com/bytedance/sonic/internal/cpu"
@@ -112,6 +113,8 @@ const (
 const (
 	_LB_error                 = "_error"
 	_LB_error_too_deep        = "_error_too_deep"
+	_LB_error_max_depth       = "_error_max_depth"
+	_LB_error_max_size        = "_error_max_size"
 	_LB_error_invalid_number  = "_error_invalid_number"
 	_LB_error_nan_or_infinite = "_error_nan_or_infinite"
 	_LB_panic                 = "_panic"
@@ -256,6 +259,7 @@ var _OpFuncTab = [256]func(*Assembler, *ir.Instr){
 	ir.OP_map_stop:       (*Assembler)._asm_OP_map_stop,
 	ir.OP_map_check_key:  (*Assembler)._asm_OP_map_check_key,
 	ir.OP_map_write_key:  (*Assembler)._asm_OP_map_write_key,
+	ir.OP_map_sorted_key: (*Assembler)._asm_OP_map_sorted_key,
 	ir.OP_map_value_next: (*Assembler)._asm_OP_map_value_next,
 	ir.OP_slice_len:      (*Assembler)._asm_OP_slice_len,
 	ir.OP_slice_next:     (*Assembler)._asm_OP_slice_next,
@@ -265,6 +269,13 @@ var _OpFuncTab = [256]func(*Assembler, *ir.Instr){
 	ir.OP_marshal_text_p: (*Assembler)._asm_OP_marshal_text_p,
 	ir.OP_cond_set:       (*Assembler)._asm_OP_cond_set,
 	ir.OP_cond_testc:     (*Assembler)._asm_OP_cond_testc,
+	ir.OP_float:          (*Assembler)._asm_OP_float,
+	ir.OP_i64_q:          (*Assembler)._asm_OP_i64_q,
+	ir.OP_u64_q:          (*Assembler)._asm_OP_u64_q,
+	ir.OP_indent:         (*Assembler)._asm_OP_indent,
+	ir.OP_newline:        (*Assembler)._asm_OP_newline,
+	ir.OP_esc_str:        (*Assembler)._asm_OP_esc_str,
+	ir.OP_esc_text:       (*Assembler)._asm_OP_esc_text,
 }
 
 func (self *Assembler) instr(v *ir.Instr) {
@@ -286,6 +297,8 @@ func (self *Assembler) instrs() {
 func (self *Assembler) builtins() {
 	self.more_space()
 	self.error_too_deep()
+	self.error_max_depth()
+	self.error_max_size()
 	self.error_invalid_number()
 	self.error_nan_or_infinite()
 	self.go_panic()
@@ -363,6 +376,24 @@ func (self *Assembler) store_int(nd int, fn obj.Addr, ins string) {
 	self.Emit("ADDQ", _AX, _RL)            // ADDQ   AX, RL
 }
 
+func (self *Assembler) store_int_q(nd int, fn obj.Addr, slow obj.Addr) {
+	self.Emit("BTQ", jit.Imm(alg.BitInt64AsString), _ARG_fv)      // BTQ     ${BitInt64AsString}, fv
+	self.Sjmp("JC", "_int_q_{n}")                                  // JC      _int_q_{n}
+	self.Emit("BTQ", jit.Imm(alg.BitLargeInt64AsString), _ARG_fv) // BTQ     ${BitLargeInt64AsString}, fv
+	self.Sjmp("JC", "_int_q_{n}")                                  // JC      _int_q_{n}
+	self.Emit("BTQ", jit.Imm(alg.BitCanonical), _ARG_fv)          // BTQ     ${BitCanonical}, fv
+	self.Sjmp("JC", "_int_q_{n}")                                  // JC      _int_q_{n}
+	self.store_int(nd, fn, "MOVQ")                                 // STORE   $nd, $fn
+	self.Sjmp("JMP", "_int_q_end_{n}")                             // JMP     _int_q_end_{n}
+	self.Link("_int_q_{n}")                                        // _int_q_{n}:
+	self.prep_buffer_AX()                                          // MOVE    {buf}, AX
+	self.Emit("MOVQ", _SP_p, _BX)                                  // MOVQ    SP.p, BX
+	self.Emit("MOVQ", _ARG_fv, _CX)                                // MOVQ    ARG.fv, CX
+	self.call_go(slow)                                             // CALL_GO $slow
+	self.load_buffer_AX()                                          // LOAD    {buf}, AX
+	self.Link("_int_q_end_{n}")                                    // _int_q_end_{n}:
+}
+
 func (self *Assembler) store_str(s string) {
 	i := 0
 	m := rt.Str2Mem(s)
@@ -465,6 +496,20 @@ func (self *Assembler) add_long(ch uint32, n int64) {
 	self.Emit("ADDQ", jit.Imm(n), _RL)                             // ADDQ $n, RL
 }
 
+var (
+	textPool  []string
+	textMutex sync.Mutex
+)
+
+// pinText keeps a text referenced by the generated code alive, since
+// the loaded programs are never unloaded.
+func pinText(ss string) string {
+	textMutex.Lock()
+	textPool = append(textPool, ss)
+	textMutex.Unlock()
+	return ss
+}
+
 func (self *Assembler) add_text(ss string) {
 	self.store_str(ss)                              // TEXT $ss
 	self.Emit("ADDQ", jit.Imm(int64(len(ss))), _RL) // ADDQ ${len(ss)}, RL
@@ -563,6 +608,7 @@ func (self *Assembler) call_marshaler_i(fn obj.Addr, it *rt.GoType) {
 	self.Emit("MOVQ", _AX, _BX)               // MOVQ   AX, BX
 	self.prep_buffer_AX()
 	self.Emit("MOVQ", _ARG_fv, _DI) // MOVQ   ARG.fv, DI
+	self.Emit("MOVQ", _ST, _SI)     // MOVQ   ST, SI
 	self.call_go(fn)                // CALL    $fn
 	self.Emit("TESTQ", _ET, _ET)    // TESTQ ET, ET
 	self.Sjmp("JNZ", _LB_error)     // JNZ   _error
@@ -588,6 +634,7 @@ func (self *Assembler) call_marshaler_v(fn obj.Addr, it *rt.GoType, vt reflect.T
 
 	/* call the encoder, and perform error checks */
 	self.Emit("MOVQ", _ARG_fv, _DI) // MOVQ   ARG.fv, DI
+	self.Emit("MOVQ", _ST, _SI)     // MOVQ   ST, SI
 	self.call_go(fn)                // CALL  $fn
 	self.Emit("TESTQ", _ET, _ET)    // TESTQ ET, ET
 	self.Sjmp("JNZ", _LB_error)     // JNZ   _error
@@ -607,6 +654,12 @@ var (
 // AX must saving n
 func (self *Assembler) more_space() {
 	self.Link(_LB_more_space)
+	self.Emit("MOVQ", jit.Ptr(_ST, vars.StackMaxN), _CX) // MOVQ maxn(ST), CX
+	self.Emit("TESTQ", _CX, _CX)                         // TESTQ CX, CX
+	self.Sjmp("JZ", "_more_space_grow")                  // JZ   _more_space_grow
+	self.Emit("CMPQ", _AX, _CX)                          // CMPQ AX, CX
+	self.Sjmp("JG", _LB_error_max_size)                  // JG   _error_max_size
+	self.Link("_more_space_grow")                        // _more_space_grow:
 	self.Emit("MOVQ", _RP, _BX)        // MOVQ DI, BX
 	self.Emit("MOVQ", _RL, _CX)        // MOVQ SI, CX
 	self.Emit("MOVQ", _RC, _DI)        // MOVQ DX, DI
@@ -635,6 +688,20 @@ func (self *Assembler) error_too_deep() {
 	self.Sjmp("JMP", _LB_error)                           // JMP  _error
 }
 
+func (self *Assembler) error_max_depth() {
+	self.Link(_LB_error_max_depth)
+	self.Emit("MOVQ", jit.Ptr(_ST, vars.StackMaxD), _AX) // MOVQ    maxd(ST), AX
+	self.call_go(_F_error_depth)                         // CALL_GO error_depth
+	self.Sjmp("JMP", _LB_error)                          // JMP     _error
+}
+
+func (self *Assembler) error_max_size() {
+	self.Link(_LB_error_max_size)
+	self.Emit("MOVQ", _ST, _AX)  // MOVQ    ST, AX
+	self.call_go(_F_error_size)  // CALL_GO error_size
+	self.Sjmp("JMP", _LB_error)  // JMP     _error
+}
+
 func (self *Assembler) error_invalid_number() {
 	self.Link(_LB_error_invalid_number)
 	self.Emit("MOVQ", jit.Ptr(_SP_p, 0), _AX) // MOVQ    0(SP), AX
@@ -762,6 +829,8 @@ var (
 var (
 	_F_memmove       = jit.Func(rt.Memmove)
 	_F_error_number  = jit.Func(vars.Error_number)
+	_F_error_depth   = jit.Func(vars.Error_max_depth)
+	_F_error_size    = jit.Func((*vars.Stack).SizeError)
 	_F_isValidNumber = jit.Func(rt.IsValidNumber)
 )
 
@@ -775,6 +844,12 @@ var (
 	_F_encodeTypedPointer  obj.Addr
 	_F_encodeJsonMarshaler obj.Addr
 	_F_encodeTextMarshaler obj.Addr
+	_F_encodeFloat         obj.Addr
+	_F_encodeInt64         obj.Addr
+	_F_encodeUint64        obj.Addr
+	_F_encodeNewline       obj.Addr
+	_F_encodeString        obj.Addr
+	_F_encodeText          obj.Addr
 )
 
 const (
@@ -784,7 +859,13 @@ const (
 func init() {
 	_F_encodeJsonMarshaler = jit.Func(alg.EncodeJsonMarshaler)
 	_F_encodeTextMarshaler = jit.Func(alg.EncodeTextMarshaler)
+	_F_encodeFloat         = jit.Func(alg.EncodeFloat)
+	_F_encodeInt64         = jit.Func(alg.EncodeInt64)
+	_F_encodeUint64        = jit.Func(alg.EncodeUint64)
+	_F_encodeNewline       = jit.Func(alg.EncodeNewline)
 	_F_encodeTypedPointer  = jit.Func(EncodeTypedPointer)
+	_F_encodeString        = jit.Func(alg.EncodeString)
+	_F_encodeText          = jit.Func(alg.EncodeText)
 }
 
 func (self *Assembler) _asm_OP_null(_ *ir.Instr) {
@@ -864,6 +945,14 @@ func (self *Assembler) _asm_OP_u64(_ *ir.Instr) {
 	self.store_int(20, _F_u64toa, "MOVQ")
 }
 
+func (self *Assembler) _asm_OP_i64_q(_ *ir.Instr) {
+	self.store_int_q(21, _F_i64toa, _F_encodeInt64)
+}
+
+func (self *Assembler) _asm_OP_u64_q(_ *ir.Instr) {
+	self.store_int_q(20, _F_u64toa, _F_encodeUint64)
+}
+
 func (self *Assembler) _asm_OP_f32(_ *ir.Instr) {
 	self.check_size(32)
 	self.Emit("MOVL", jit.Ptr(_SP_p, 0), _AX)  // MOVL     (SP.p), AX
@@ -903,6 +992,65 @@ func (self *Assembler) _asm_OP_f64(_ *ir.Instr) {
 	self.Link("_encode_f64_end_{n}")
 }
 
+func (self *Assembler) _asm_OP_float(p *ir.Instr) {
+	self.prep_buffer_AX()                                                 // MOVE    {buf}, AX
+	self.Emit("MOVQ", _SP_p, _BX)                                         // MOVQ    SP.p, BX
+	self.Emit("MOVQ", jit.Imm(int64(uintptr(unsafe.Pointer(p.Vff())))), _CX) // MOVQ    $ff, CX
+	self.Emit("MOVQ", jit.Imm(int64(p.Vi())), _DI)                        // MOVQ    $bits, DI
+	self.Emit("MOVQ", _ARG_fv, _SI)                                       // MOVQ    ARG.fv, SI
+	self.call_go(_F_encodeFloat)                                          // CALL_GO encodeFloat
+	self.Emit("TESTQ", _ET, _ET)                                          // TESTQ   ET, ET
+	self.Sjmp("JNZ", _LB_error)                                           // JNZ     _error
+	self.load_buffer_AX()
+}
+
+func (self *Assembler) _asm_OP_esc_str(p *ir.Instr) {
+	self.prep_buffer_AX()                          // MOVE    {buf}, AX
+	self.Emit("MOVQ", jit.Ptr(_SP_p, 0), _BX)      // MOVQ    (SP.p), BX
+	self.Emit("MOVQ", jit.Ptr(_SP_p, 8), _CX)      // MOVQ    8(SP.p), CX
+	self.Emit("MOVQ", _ST, _DI)                    // MOVQ    ST, DI
+	self.Emit("MOVQ", _ARG_fv, _SI)                // MOVQ    ARG.fv, SI
+	self.Emit("MOVQ", jit.Imm(int64(p.Vi())), _R8) // MOVQ    ${p.Vi()}, R8
+	self.call_go(_F_encodeString)                  // CALL_GO encodeString
+	self.Emit("TESTQ", _ET, _ET)                   // TESTQ   ET, ET
+	self.Sjmp("JNZ", _LB_error)                    // JNZ     _error
+	self.load_buffer_AX()
+}
+
+func (self *Assembler) _asm_OP_esc_text(p *ir.Instr) {
+	sv := pinText(p.Vs())
+	self.prep_buffer_AX()                                           // MOVE    {buf}, AX
+	self.Emit("MOVQ", jit.Imm(int64(uintptr(rt.StrPtr(sv)))), _BX) // MOVQ    ${p.Vs()}, BX
+	self.Emit("MOVQ", jit.Imm(int64(len(sv))), _CX)                // MOVQ    ${len(p.Vs())}, CX
+	self.Emit("MOVQ", _ST, _DI)                                     // MOVQ    ST, DI
+	self.Emit("MOVQ", _ARG_fv, _SI)                                 // MOVQ    ARG.fv, SI
+	self.call_go(_F_encodeText)                                     // CALL_GO encodeText
+	self.Emit("TESTQ", _ET, _ET)                                    // TESTQ   ET, ET
+	self.Sjmp("JNZ", _LB_error)                                     // JNZ     _error
+	self.load_buffer_AX()
+}
+
+func (self *Assembler) _asm_OP_indent(p *ir.Instr) {
+	self.Emit("MOVQ", jit.Ptr(_ST, vars.StackDepth), _AX) // MOVQ    depth(ST), AX
+	self.Emit("ADDQ", jit.Imm(int64(p.Vi())), _AX)        // ADDQ    ${p.Vi()}, AX
+	self.Emit("MOVQ", _AX, jit.Ptr(_ST, vars.StackDepth)) // MOVQ    AX, depth(ST)
+	if p.Vi() > 0 {
+		self.Emit("MOVQ", jit.Ptr(_ST, vars.StackMaxD), _CX) // MOVQ    maxd(ST), CX
+		self.Emit("TESTQ", _CX, _CX)                         // TESTQ   CX, CX
+		self.Sjmp("JZ", "_indent_end_{n}")                   // JZ      _indent_end_{n}
+		self.Emit("CMPQ", _AX, _CX)                          // CMPQ    AX, CX
+		self.Sjmp("JG", _LB_error_max_depth)                 // JG      _error_max_depth
+		self.Link("_indent_end_{n}")                         // _indent_end_{n}:
+	}
+}
+
+func (self *Assembler) _asm_OP_newline(_ *ir.Instr) {
+	self.prep_buffer_AX()            // MOVE    {buf}, AX
+	self.Emit("MOVQ", _ST, _BX)      // MOVQ    ST, BX
+	self.call_go(_F_encodeNewline)   // CALL_GO encodeNewline
+	self.load_buffer_AX()            // LOAD    {buf}, AX
+}
+
 func (self *Assembler) _asm_OP_str(_ *ir.Instr) {
 	self.encode_string(false)
 }
@@ -1133,6 +1281,13 @@ func (self *Assembler) _asm_OP_map_write_key(p *ir.Instr) {
 	self.Link("_unordered_key_{n}")                    // _unordered_key_{n}:
 }
 
+func (self *Assembler) _asm_OP_map_sorted_key(p *ir.Instr) {
+	self.Emit("BTQ", jit.Imm(alg.BitSortMapKeys), _ARG_fv) // BTQ ${SortMapKeys}, fv
+	self.Xjmp("JC", p.Vi())                            // JC  ${p.Vi()}
+	self.Emit("BTQ", jit.Imm(alg.BitCanonical), _ARG_fv)   // BTQ ${Canonical}, fv
+	self.Xjmp("JC", p.Vi())                            // JC  ${p.Vi()}
+}
+
 func (self *Assembler) _asm_OP_map_value_next(_ *ir.Instr) {
 	self.Emit("MOVQ", jit.Ptr(_SP_q, 8), _SP_p) // MOVQ    8(SP.q), SP.p
 	self.Emit("MOVQ", _SP_q, _AX)               // MOVQ    SP.q, AX
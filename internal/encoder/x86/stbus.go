@@ -30,6 +30,17 @@ import (
 //go:linkname _subr__b64encode github.com/cloudwego/base64x._subr__b64encode
 var _subr__b64encode uintptr
 
+func findOrCompile(vt *rt.GoType, sb *vars.Stack, fv uint64) (interface{}, error) {
+	pv := (fv&(1<<alg.BitPointerValue)) != 0
+	if alg.DefaultVariant(sb, fv) {
+		return vars.FindOrCompile(vt, pv, compiler)
+	} else if v := alg.ProgramVariant(sb, fv); v.IsDefault() {
+		return vars.FindOrCompile(vt, pv, compiler)
+	} else {
+		return vars.FindOrCompileVariant(vt, pv, v, compiler)
+	}
+}
+
 var compiler func(*rt.GoType, ... interface{}) (interface{}, error)
 
 func SetCompiler(c func(*rt.GoType, ... interface{}) (interface{}, error)) {
@@ -43,8 +54,10 @@ func ptoenc(p loader.Function) vars.Encoder {
 func EncodeTypedPointer(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64) error {
 	if vt == nil {
 		return alg.EncodeNil(buf)
-	} else if fn, err := vars.FindOrCompile(vt, (fv&(1<<alg.BitPointerValue)) != 0, compiler); err != nil {
+	} else if fn, err := findOrCompile(vt, sb, fv); err != nil {
 		return err
+	} else if (fv & (1<<alg.BitDetectCycles)) != 0 {
+		return encodeChecked(buf, vt, vp, sb, fv, fn.(vars.Encoder))
 	} else if vt.Indirect() {
 		return	fn.(vars.Encoder)(buf, *vp, sb, fv)
 	} else {
@@ -52,3 +65,17 @@ func EncodeTypedPointer(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars
 	}
 }
 
+// encodeChecked is like EncodeTypedPointer, but fails if the value contains itself.
+func encodeChecked(buf *[]byte, vt *rt.GoType, vp *unsafe.Pointer, sb *vars.Stack, fv uint64, fn vars.Encoder) (err error) {
+	if err = sb.Enter(vt, *vp); err != nil {
+		return err
+	}
+	if vt.Indirect() {
+		err = fn(buf, *vp, sb, fv)
+	} else {
+		err = fn(buf, unsafe.Pointer(vp), sb, fv)
+	}
+	sb.Leave()
+	return err
+}
+
@@ -195,6 +195,7 @@ func useSSE() {
     S_vunsigned   = sse.S_vunsigned
     S_skip_one    = sse.S_skip_one
     __SkipOne     = sse.F_skip_one
+    S_skip_one_fast = sse.S_skip_one_fast
     __SkipOneFast = sse.F_skip_one_fast
     S_skip_array  = sse.S_skip_array
     S_skip_object = sse.S_skip_object
@@ -232,6 +233,7 @@ func useAVX2() {
     S_vunsigned   = avx2.S_vunsigned
     S_skip_one    = avx2.S_skip_one
     __SkipOne     = avx2.F_skip_one
+    S_skip_one_fast = avx2.S_skip_one_fast
     __SkipOneFast = avx2.F_skip_one_fast
     S_skip_array  = avx2.S_skip_array
     S_skip_object = avx2.S_skip_object
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+    `encoding/binary`
+    `fmt`
+    `reflect`
+    `sort`
+    `strconv`
+    `strings`
+    `unsafe`
+)
+
+// FieldTree is the set of selected JSON field names of a struct, each name
+// maps to the selection of its sub-fields, and a nil sub-tree selects the
+// whole value of the field.
+type FieldTree map[string]FieldTree
+
+// NewFieldTree builds the tree from dot-separated paths of JSON field names,
+// such as "owner.email".
+func NewFieldTree(paths []string) (FieldTree, error) {
+    ret := FieldTree{}
+    for _, path := range paths {
+        if err := ret.add(path); err != nil {
+            return nil, err
+        }
+    }
+    return ret, nil
+}
+
+func (self FieldTree) add(path string) error {
+    ft := self
+    ks := strings.Split(path, ".")
+
+    /* walk down the tree, creating the missing levels */
+    for i, k := range ks {
+        if k == "" {
+            return fmt.Errorf("invalid field path: %q", path)
+        }
+
+        /* the last name selects the whole field */
+        if i == len(ks) - 1 {
+            ft[k] = nil
+            return nil
+        }
+
+        /* check if the whole field has already been selected */
+        st, ok := ft[k]
+        if ok && st == nil {
+            return nil
+        }
+
+        /* create the sub-tree if not exists */
+        if !ok {
+            st = FieldTree{}
+            ft[k] = st
+        }
+        ft = st
+    }
+    return nil
+}
+
+// Normalize returns the tree of the names matching the fields of vt, looking
+// through the pointers, slices and arrays. The sub-trees of the fields which
+// are not structs are dropped, since these fields are always selected as a whole.
+func (self FieldTree) Normalize(vt reflect.Type) FieldTree {
+    if ret := self.normalize(vt); ret != nil {
+        return ret
+    }
+    return FieldTree{}
+}
+
+func (self FieldTree) normalize(vt reflect.Type) FieldTree {
+    for vt.Kind() == reflect.Ptr || vt.Kind() == reflect.Slice || vt.Kind() == reflect.Array {
+        vt = vt.Elem()
+    }
+
+    /* only the structs have fields to select */
+    if vt.Kind() != reflect.Struct {
+        return nil
+    }
+
+    /* keep the names of the fields, and normalize their sub-trees */
+    ret := FieldTree{}
+    for _, fm := range ResolveStruct(vt) {
+        if st, ok := self[fm.Name]; !ok {
+            continue
+        } else if st != nil {
+            ret[fm.Name] = st.normalize(fm.Type)
+        } else {
+            ret[fm.Name] = nil
+        }
+    }
+    return ret
+}
+
+// Key returns a canonical representation of the tree, which does not depend
+// on the order or redundancy of the original paths.
+func (self FieldTree) Key() string {
+    return string(self.appendKey(nil))
+}
+
+func (self FieldTree) appendKey(buf []byte) []byte {
+    ks := make([]string, 0, len(self))
+    for k := range self {
+        ks = append(ks, k)
+    }
+    sort.Strings(ks)
+
+    /* dump every name with its sub-tree */
+    buf = append(buf, '{')
+    for i, k := range ks {
+        if i != 0 {
+            buf = append(buf, ',')
+        }
+        buf = strconv.AppendQuote(buf, k)
+        if st := self[k]; st != nil {
+            buf = st.appendKey(buf)
+        }
+    }
+    return append(buf, '}')
+}
+
+// FieldPathsKey joins the length-prefixed paths, it is used to look up the
+// compiled programs without building the field tree.
+func FieldPathsKey(paths []string) string {
+    n := 0
+    for _, f := range paths {
+        n += len(f) + binary.MaxVarintLen64
+    }
+
+    /* dump every path with its length */
+    i := 0
+    buf := make([]byte, n)
+    for _, f := range paths {
+        i += binary.PutUvarint(buf[i:], uint64(len(f)))
+        i += copy(buf[i:], f)
+    }
+
+    /* the buffer is never modified after this */
+    buf = buf[:i]
+    return *(*string)(unsafe.Pointer(&buf))
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+    `reflect`
+    `testing`
+)
+
+func TestFieldTree_Key(t *testing.T) {
+    a, err := NewFieldTree([]string{"b.c", "a", "b.d", "a.x"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    b, err := NewFieldTree([]string{"a", "b.d", "b.c"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if a.Key() != b.Key() {
+        t.Fatalf("keys mismatch: %s != %s", a.Key(), b.Key())
+    }
+    if k := a.Key(); k != `{"a","b"{"c","d"}}` {
+        t.Fatalf("invalid key: %s", k)
+    }
+}
+
+func TestFieldTree_Invalid(t *testing.T) {
+    for _, p := range []string{"", ".", "a.", ".a", "a..b"} {
+        if _, err := NewFieldTree([]string{p}); err == nil {
+            t.Fatalf("path %q should be invalid", p)
+        }
+    }
+}
+
+func TestFieldPathsKey(t *testing.T) {
+    if FieldPathsKey(nil) == FieldPathsKey([]string{""}) {
+        t.Fatal("empty path should not collide with no path")
+    }
+    if FieldPathsKey([]string{"ab"}) == FieldPathsKey([]string{"a", "b"}) {
+        t.Fatal("joined paths should not collide")
+    }
+}
+
+type normB struct {
+    C int            `json:"c"`
+    D []*normB       `json:"d"`
+}
+
+type normA struct {
+    A  int            `json:"a"`
+    B  *normB         `json:"b"`
+    M  map[string]int `json:"m"`
+    X  int            `json:"-"`
+}
+
+func TestFieldTree_Normalize(t *testing.T) {
+    ft, err := NewFieldTree([]string{"a", "b.c", "b.d.c", "b.d.x", "b.y", "m.k", "X", "z.w"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    nt := ft.Normalize(reflect.TypeOf([]*normA{}))
+    if k := nt.Key(); k != `{"a","b"{"c","d"{"c"}},"m"}` {
+        t.Fatalf("invalid key: %s", k)
+    }
+
+    /* the names matching no field share the same tree */
+    ft, err = NewFieldTree([]string{"a", "q"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if k := ft.Normalize(reflect.TypeOf(normA{})).Key(); k != `{"a"}` {
+        t.Fatalf("invalid key: %s", k)
+    }
+    if k := ft.Normalize(reflect.TypeOf(0)).Key(); k != `{}` {
+        t.Fatalf("invalid key: %s", k)
+    }
+}
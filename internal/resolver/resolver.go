@@ -29,6 +29,7 @@ type OffsetType int
 const (
     F_omitempty FieldOpts = 1 << iota
     F_stringize
+    F_sensitive
 )
 
 const (
@@ -47,6 +48,9 @@ type FieldMeta struct {
     Path []Offset
     Opts FieldOpts
     Type reflect.Type
+
+    // Format is the comma-separated float format options in the tag
+    Format string
 }
 
 func (self *FieldMeta) String() string {
@@ -72,6 +76,16 @@ func (self *FieldMeta) String() string {
         opts = append(opts, "omitempty")
     }
 
+    /* check for "sensitive" */
+    if (self.Opts & F_sensitive) != 0 {
+        opts = append(opts, "sensitive")
+    }
+
+    /* check for the float format */
+    if self.Format != "" {
+        opts = append(opts, self.Format)
+    }
+
     /* format the field */
     return fmt.Sprintf(
         "{Field \"%s\" @ %s, opts=%s, type=%s}",
@@ -151,6 +165,12 @@ func resolveFields(vt reflect.Type) []FieldMeta {
             })
         }
 
+        /* check for the options which are not recognized by encoding/json */
+        sensitive, format := extraOptions(vt.FieldByIndex(fv.index).Tag)
+        if sensitive {
+            opts |= F_sensitive
+        }
+
         /* get the index to the last offset */
         idx := len(path) - 1
         fvt := path[idx].Type
@@ -167,6 +187,7 @@ func resolveFields(vt reflect.Type) []FieldMeta {
             Opts: opts,
             Path: path,
             Name: fv.name,
+            Format: format,
         })
     }
 
@@ -179,6 +200,40 @@ func resolveFields(vt reflect.Type) []FieldMeta {
     return ret
 }
 
+// extraOptions returns the "sensitive" option and the float format options in tag.
+func extraOptions(tag reflect.StructTag) (sensitive bool, format string) {
+    opts := tag.Get("json")
+    if i := strings.IndexByte(opts, ','); i < 0 {
+        return false, ""
+    } else {
+        opts = opts[i + 1:]
+    }
+
+    /* search in the tag options */
+    var fmts []string
+    for opts != "" {
+        var opt string
+        if i := strings.IndexByte(opts, ','); i < 0 {
+            opt, opts = opts, ""
+        } else {
+            opt, opts = opts[:i], opts[i + 1:]
+        }
+        if opt == "sensitive" {
+            sensitive = true
+        } else if isFloatFormat(opt) {
+            fmts = append(fmts, opt)
+        }
+    }
+    return sensitive, strings.Join(fmts, ",")
+}
+
+func isFloatFormat(opt string) bool {
+    switch opt {
+        case "exponent", "noexponent", "negzero" : return true
+        default                                  : return strings.HasPrefix(opt, "decimals=") || strings.HasPrefix(opt, "precision=")
+    }
+}
+
 var (
     fieldLock  = sync.RWMutex{}
     fieldCache = map[reflect.Type][]FieldMeta{}
@@ -17,6 +17,8 @@
 package resolver
 
 import (
+    `bytes`
+    `encoding/json`
     `reflect`
     `testing`
 )
@@ -69,3 +71,81 @@ func TestResolver_ResolveStruct(t *testing.T) {
         println(fv.String())
     }
 }
+
+type baq struct {
+    Z  int    `json:",omitempty"`
+    Q  string `json:"q,string"`
+    P  *int   `json:"-"`
+    R  int    `json:"-,"`
+    S  int    `json:"s!#"`
+    ba *bay
+}
+
+type bap struct {
+    baq
+    Foo
+    bas
+}
+
+func TestResolver_StdFields(t *testing.T) {
+    for _, v := range []interface{}{
+        Foo{PackageError: &PackageError{}},
+        bap{baq: baq{Z: 1}, Foo: Foo{PackageError: &PackageError{}}},
+    } {
+        buf, err := json.Marshal(v)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        /* collect the keys emitted by encoding/json */
+        var keys []string
+        dec := json.NewDecoder(bytes.NewReader(buf))
+        dec.Token()
+        for dec.More() {
+            key, _ := dec.Token()
+            keys = append(keys, key.(string))
+            var skip json.RawMessage
+            if err := dec.Decode(&skip); err != nil {
+                t.Fatal(err)
+            }
+        }
+
+        /* the resolved fields must be the same */
+        var names []string
+        for _, fm := range ResolveStruct(reflect.TypeOf(v)) {
+            names = append(names, fm.Name)
+        }
+        if !reflect.DeepEqual(keys, names) {
+            t.Fatalf("mismatched fields of %T: %q != %q", v, names, keys)
+        }
+    }
+}
+
+func TestResolver_Sensitive(t *testing.T) {
+    type secret struct {
+        A string `json:"a,sensitive"`
+        B string `json:"b,omitempty,sensitive"`
+        C string `json:"sensitive"`
+        D string `json:",sensitive"`
+    }
+    fm := ResolveStruct(reflect.TypeOf(secret{}))
+    for i, sensitive := range []bool{true, true, false, true} {
+        if ((fm[i].Opts & F_sensitive) != 0) != sensitive {
+            t.Fatalf("invalid sensitive option: %s", fm[i].String())
+        }
+    }
+}
+
+func TestResolver_FloatFormat(t *testing.T) {
+    type price struct {
+        A float64 `json:"a,decimals=2"`
+        B float64 `json:"b,omitempty,precision=6,noexponent,sensitive"`
+        C float64 `json:"c,string"`
+    }
+    fm := ResolveStruct(reflect.TypeOf(price{}))
+    for i, format := range []string{"decimals=2", "precision=6,noexponent", ""} {
+        if fm[i].Format != format {
+            t.Fatalf("invalid float format: %s", fm[i].String())
+        }
+    }
+}
@@ -1,4 +1,4 @@
-// +build !go1.21
+// +build amd64,go1.17,!go1.21 arm64,go1.20,!go1.21
 
 /*
  * Copyright 2021 ByteDance Inc.
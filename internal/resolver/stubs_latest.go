@@ -1,4 +1,4 @@
-// +build go1.21
+// +build amd64,go1.21,!go1.24 arm64,go1.21,!go1.24
 
 /*
  * Copyright 2021 ByteDance Inc.
@@ -0,0 +1,221 @@
+// +build !amd64,!arm64 go1.24 !go1.17 arm64,!go1.20
+
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+    `reflect`
+    `sort`
+    `strings`
+    `unicode`
+)
+
+type StdField struct {
+    name      string
+    tag       bool
+    index     []int
+    typ       reflect.Type
+    omitEmpty bool
+    quoted    bool
+}
+
+type StdStructFields struct {
+    list []StdField
+}
+
+// typeFields returns the fields encoding/json recognizes for struct t, with the same
+// rules of the embedded and tagged fields. The fields of encoding/json are private
+// and unreachable in the compat build, so the rules are followed by reflection here.
+func typeFields(t reflect.Type) StdStructFields {
+    var fields []StdField
+    var count, nextCount map[reflect.Type]int
+
+    /* the embedded structs to explore at the current and the next level */
+    current := []StdField{}
+    next := []StdField{{typ: t}}
+    visited := map[reflect.Type]bool{}
+
+    /* walk the embedded structs level by level */
+    for len(next) > 0 {
+        current, next = next, current[:0]
+        count, nextCount = nextCount, map[reflect.Type]int{}
+
+        for _, f := range current {
+            if visited[f.typ] {
+                continue
+            }
+            visited[f.typ] = true
+
+            /* scan f.typ for the fields to include */
+            for i := 0; i < f.typ.NumField(); i++ {
+                sf := f.typ.Field(i)
+                if sf.Anonymous {
+                    et := sf.Type
+                    if et.Kind() == reflect.Ptr {
+                        et = et.Elem()
+                    }
+                    if sf.PkgPath != "" && et.Kind() != reflect.Struct {
+                        continue
+                    }
+                } else if sf.PkgPath != "" {
+                    continue
+                }
+
+                /* check for the "-" tag */
+                tag := sf.Tag.Get("json")
+                if tag == "-" {
+                    continue
+                }
+                name, opts := parseTag(tag)
+                if !isValidTag(name) {
+                    name = ""
+                }
+                index := make([]int, len(f.index) + 1)
+                copy(index, f.index)
+                index[len(f.index)] = i
+
+                /* follow the unnamed pointer */
+                ft := sf.Type
+                if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+                    ft = ft.Elem()
+                }
+
+                /* record the field */
+                if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+                    tagged := name != ""
+                    if name == "" {
+                        name = sf.Name
+                    }
+                    fields = append(fields, StdField {
+                        name      : name,
+                        tag       : tagged,
+                        index     : index,
+                        typ       : ft,
+                        omitEmpty : hasOption(opts, "omitempty"),
+                        quoted    : hasOption(opts, "string") && isQuotable(ft),
+                    })
+
+                    /* the duplicated embedded structs annihilate their fields */
+                    if count[f.typ] > 1 {
+                        fields = append(fields, fields[len(fields) - 1])
+                    }
+                    continue
+                }
+
+                /* explore the embedded struct in the next level */
+                if nextCount[ft]++; nextCount[ft] == 1 {
+                    next = append(next, StdField{name: ft.Name(), index: index, typ: ft})
+                }
+            }
+        }
+    }
+
+    /* sort by the name, then by the depth, the tagged ones, and the index */
+    sort.Slice(fields, func(i, j int) bool {
+        x, y := &fields[i], &fields[j]
+        if x.name != y.name {
+            return x.name < y.name
+        }
+        if len(x.index) != len(y.index) {
+            return len(x.index) < len(y.index)
+        }
+        if x.tag != y.tag {
+            return x.tag
+        }
+        return lessIndex(x.index, y.index)
+    })
+
+    /* keep only the dominant field of each name */
+    out := fields[:0]
+    for n, i := 0, 0; i < len(fields); i += n {
+        for n = 1; i + n < len(fields) && fields[i + n].name == fields[i].name; n++ {}
+        if n == 1 || len(fields[i].index) != len(fields[i + 1].index) || fields[i].tag != fields[i + 1].tag {
+            out = append(out, fields[i])
+        }
+    }
+
+    /* restore the declaration order */
+    sort.Slice(out, func(i, j int) bool { return lessIndex(out[i].index, out[j].index) })
+    return StdStructFields{list: out}
+}
+
+func lessIndex(x []int, y []int) bool {
+    for i := 0; i < len(x) && i < len(y); i++ {
+        if x[i] != y[i] {
+            return x[i] < y[i]
+        }
+    }
+    return len(x) < len(y)
+}
+
+func parseTag(tag string) (string, string) {
+    if i := strings.IndexByte(tag, ','); i >= 0 {
+        return tag[:i], tag[i + 1:]
+    }
+    return tag, ""
+}
+
+func hasOption(opts string, name string) bool {
+    for opts != "" {
+        var opt string
+        if i := strings.IndexByte(opts, ','); i < 0 {
+            opt, opts = opts, ""
+        } else {
+            opt, opts = opts[:i], opts[i + 1:]
+        }
+        if opt == name {
+            return true
+        }
+    }
+    return false
+}
+
+// isQuotable tells if the "string" option applies to the values of vt.
+func isQuotable(vt reflect.Type) bool {
+    switch vt.Kind() {
+        case reflect.Bool    ,
+             reflect.Int     ,
+             reflect.Int8    ,
+             reflect.Int16   ,
+             reflect.Int32   ,
+             reflect.Int64   ,
+             reflect.Uint    ,
+             reflect.Uint8   ,
+             reflect.Uint16  ,
+             reflect.Uint32  ,
+             reflect.Uint64  ,
+             reflect.Uintptr ,
+             reflect.Float32 ,
+             reflect.Float64 ,
+             reflect.String  : return true
+        default              : return false
+    }
+}
+
+func isValidTag(s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, c := range s {
+        switch {
+            case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c) : break
+            case !unicode.IsLetter(c) && !unicode.IsDigit(c)              : return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,449 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jsonwriter writes JSON token by token with the native kernels,
+// and checks the structure of the written tokens.
+//
+//  w := jsonwriter.NewBuffer(nil)
+//  w.BeginObject()
+//  w.Key("a")
+//  w.Int64(1)
+//  w.EndObject()
+//  if err := w.Close(); err != nil { ... }
+//  out := w.Bytes() // {"a":1}
+//
+// Writer also implements ast.Visitor, thus ast.Preorder(src, w, nil) re-formats src.
+package jsonwriter
+
+import (
+    `encoding/json`
+    `errors`
+    `fmt`
+    `io`
+    `math`
+
+    `github.com/bytedance/sonic/ast`
+    `github.com/bytedance/sonic/internal/encoder/alg`
+)
+
+// ErrInvalidToken is returned when a token is written where it is not allowed,
+// such as a value without key in an object, or unbalanced brackets.
+var ErrInvalidToken = errors.New("jsonwriter: invalid token")
+
+// _FLUSH_SIZE is the size of buffer to write out if the Writer writes to an io.Writer.
+const _FLUSH_SIZE = 16 * 1024
+
+var (
+    _ ast.Visitor            = (*Writer)(nil)
+    _ ast.StringChunkVisitor = (*Writer)(nil)
+)
+
+// Writer writes a JSON value token by token.
+//
+// The first error is kept, and all the following calls return it.
+type Writer struct {
+    buf      []byte
+    w        io.Writer
+    err      error
+    stack    []level
+    done     bool
+    inString bool
+    prefix   string
+    indent   string
+}
+
+type level struct {
+    object   bool
+    empty    bool
+    expected bool  // a key is expected in object
+}
+
+// New returns a Writer writing to w. The output is buffered, call Close or Flush at last.
+func New(w io.Writer) *Writer {
+    return &Writer{w: w, buf: make([]byte, 0, _FLUSH_SIZE)}
+}
+
+// NewBuffer returns a Writer appending to buf, and the output can be got by Bytes.
+func NewBuffer(buf []byte) *Writer {
+    return &Writer{buf: buf}
+}
+
+// SetIndent makes the Writer write each element of objects and arrays in a new line,
+// which begins with prefix followed by copies of indent according to the nesting.
+func (self *Writer) SetIndent(prefix string, indent string) {
+    self.prefix, self.indent = prefix, indent
+}
+
+// Bytes returns the output of Writer created by NewBuffer.
+func (self *Writer) Bytes() []byte {
+    return self.buf
+}
+
+// Reset discards the buffered output and the state, so that the Writer can write another value.
+func (self *Writer) Reset() {
+    self.buf = self.buf[:0]
+    self.err = nil
+    self.stack = self.stack[:0]
+    self.done = false
+    self.inString = false
+}
+
+// Flush writes the buffered output to the underlying io.Writer.
+func (self *Writer) Flush() error {
+    if self.err != nil {
+        return self.err
+    }
+    if self.w == nil || len(self.buf) == 0 {
+        return nil
+    }
+    if _, err := self.w.Write(self.buf); err != nil {
+        self.err = err
+        return err
+    }
+    self.buf = self.buf[:0]
+    return nil
+}
+
+// Close checks that a complete JSON value has been written, then flushes the output.
+// It doesn't close the underlying io.Writer.
+func (self *Writer) Close() error {
+    if self.err == nil && !self.done {
+        self.fail("incomplete value")
+    }
+    return self.Flush()
+}
+
+func (self *Writer) fail(msg string) error {
+    if self.err == nil {
+        self.err = fmt.Errorf("%w: %s", ErrInvalidToken, msg)
+    }
+    return self.err
+}
+
+func (self *Writer) newline(depth int) {
+    if self.prefix == "" && self.indent == "" {
+        return
+    }
+    self.buf = append(self.buf, '\n')
+    self.buf = append(self.buf, self.prefix...)
+    for i := 0; i < depth; i++ {
+        self.buf = append(self.buf, self.indent...)
+    }
+}
+
+// beforeValue checks if a value can be written, and writes the separator before it.
+func (self *Writer) beforeValue() error {
+    if self.err != nil {
+        return self.err
+    }
+    if self.inString {
+        return self.fail("unterminated string")
+    }
+    if len(self.stack) == 0 {
+        if self.done {
+            return self.fail("multiple top-level values")
+        }
+        return nil
+    }
+
+    top := &self.stack[len(self.stack) - 1]
+    if top.object {
+        if top.expected {
+            return self.fail("missing key in object")
+        }
+        return nil
+    }
+    if !top.empty {
+        self.buf = append(self.buf, ',')
+    }
+    self.newline(len(self.stack))
+    return nil
+}
+
+// afterValue updates the state after a value was written.
+func (self *Writer) afterValue() error {
+    if len(self.stack) == 0 {
+        self.done = true
+    } else {
+        top := &self.stack[len(self.stack) - 1]
+        top.empty = false
+        top.expected = top.object
+    }
+    if self.w != nil && len(self.buf) >= _FLUSH_SIZE {
+        return self.Flush()
+    }
+    return nil
+}
+
+// BeginObject writes the beginning of an object.
+func (self *Writer) BeginObject() error {
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = append(self.buf, '{')
+    self.stack = append(self.stack, level{object: true, empty: true, expected: true})
+    return nil
+}
+
+// EndObject writes the ending of the current object.
+func (self *Writer) EndObject() error {
+    return self.end(true)
+}
+
+// BeginArray writes the beginning of an array.
+func (self *Writer) BeginArray() error {
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = append(self.buf, '[')
+    self.stack = append(self.stack, level{empty: true})
+    return nil
+}
+
+// EndArray writes the ending of the current array.
+func (self *Writer) EndArray() error {
+    return self.end(false)
+}
+
+func (self *Writer) end(object bool) error {
+    if self.err != nil {
+        return self.err
+    }
+    if self.inString {
+        return self.fail("unterminated string")
+    }
+    if len(self.stack) == 0 || self.stack[len(self.stack) - 1].object != object {
+        return self.fail("unbalanced brackets")
+    }
+    top := self.stack[len(self.stack) - 1]
+    if object && !top.expected {
+        return self.fail("missing value in object")
+    }
+    self.stack = self.stack[:len(self.stack) - 1]
+    if !top.empty {
+        self.newline(len(self.stack))
+    }
+    if object {
+        self.buf = append(self.buf, '}')
+    } else {
+        self.buf = append(self.buf, ']')
+    }
+    return self.afterValue()
+}
+
+// Key writes the key of a member in the current object.
+func (self *Writer) Key(key string) error {
+    if self.err != nil {
+        return self.err
+    }
+    if len(self.stack) == 0 || !self.stack[len(self.stack) - 1].object {
+        return self.fail("key outside object")
+    }
+    top := &self.stack[len(self.stack) - 1]
+    if !top.expected {
+        return self.fail("missing value in object")
+    }
+    if !top.empty {
+        self.buf = append(self.buf, ',')
+    }
+    self.newline(len(self.stack))
+    self.buf = alg.Quote(self.buf, key, false)
+    self.buf = append(self.buf, ':')
+    if self.indent != "" || self.prefix != "" {
+        self.buf = append(self.buf, ' ')
+    }
+    top.expected = false
+    return nil
+}
+
+// Null writes a null.
+func (self *Writer) Null() error {
+    return self.literal("null")
+}
+
+// Bool writes a boolean.
+func (self *Writer) Bool(v bool) error {
+    if v {
+        return self.literal("true")
+    }
+    return self.literal("false")
+}
+
+// String writes a string, which is escaped as the encoder does by default.
+func (self *Writer) String(v string) error {
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = alg.Quote(self.buf, v, false)
+    return self.afterValue()
+}
+
+// Int64 writes an integer.
+func (self *Writer) Int64(v int64) error {
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = alg.I64toa(self.buf, v)
+    return self.afterValue()
+}
+
+// Uint64 writes an unsigned integer.
+func (self *Writer) Uint64(v uint64) error {
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = alg.U64toa(self.buf, v)
+    return self.afterValue()
+}
+
+// Float64 writes a float, in the shortest form as the encoder does. NaN and Inf are not allowed.
+func (self *Writer) Float64(v float64) error {
+    if math.IsNaN(v) || math.IsInf(v, 0) {
+        if self.err == nil {
+            self.err = fmt.Errorf("jsonwriter: unsupported value: %v", v)
+        }
+        return self.err
+    }
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = alg.F64toa(self.buf, v)
+    return self.afterValue()
+}
+
+// Number writes a number literal as is, which must be valid.
+func (self *Writer) Number(v json.Number) error {
+    if v == "" || (v[0] != '-' && (v[0] < '0' || v[0] > '9')) {
+        return self.fail("invalid number " + string(v))
+    }
+    if ok, _ := alg.Valid([]byte(v)); !ok {
+        return self.fail("invalid number " + string(v))
+    }
+    return self.literal(string(v))
+}
+
+// Raw writes a pre-encoded JSON value, which must be valid. The spaces around it are trimmed.
+func (self *Writer) Raw(raw []byte) error {
+    if ok, _ := alg.Valid(raw); !ok {
+        return self.fail("invalid raw JSON")
+    }
+    s, e := 0, len(raw)
+    for s < e && isSpace(raw[s]) {
+        s++
+    }
+    for e > s && isSpace(raw[e - 1]) {
+        e--
+    }
+    return self.literal(string(raw[s:e]))
+}
+
+func isSpace(c byte) bool {
+    return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func (self *Writer) literal(v string) error {
+    if err := self.beforeValue(); err != nil {
+        return err
+    }
+    self.buf = append(self.buf, v...)
+    return self.afterValue()
+}
+
+// StringChunk writes a chunk of string, and last reports if it is the last chunk of the string.
+// The chunks are joined as one string, and no other token can be written between them.
+func (self *Writer) StringChunk(chunk string, last bool) error {
+    if !self.inString {
+        if err := self.beforeValue(); err != nil {
+            return err
+        }
+        self.buf = append(self.buf, '"')
+        self.inString = true
+    } else if self.err != nil {
+        return self.err
+    }
+
+    /* strip the quotes around the quoted chunk */
+    n := len(self.buf)
+    self.buf = alg.Quote(self.buf, chunk, false)
+    self.buf = append(self.buf[:n], self.buf[n + 1:len(self.buf) - 1]...)
+    if !last {
+        return nil
+    }
+    self.buf = append(self.buf, '"')
+    self.inString = false
+    return self.afterValue()
+}
+
+// OnNull implements ast.Visitor.
+func (self *Writer) OnNull() error {
+    return self.Null()
+}
+
+// OnBool implements ast.Visitor.
+func (self *Writer) OnBool(v bool) error {
+    return self.Bool(v)
+}
+
+// OnString implements ast.Visitor.
+func (self *Writer) OnString(v string) error {
+    return self.String(v)
+}
+
+// OnStringChunk implements ast.StringChunkVisitor.
+func (self *Writer) OnStringChunk(chunk string, last bool) error {
+    return self.StringChunk(chunk, last)
+}
+
+// OnInt64 implements ast.Visitor, and writes the number literal as is if given.
+func (self *Writer) OnInt64(v int64, n json.Number) error {
+    if n == "" {
+        return self.Int64(v)
+    }
+    return self.literal(string(n))
+}
+
+// OnFloat64 implements ast.Visitor, and writes the number literal as is if given.
+func (self *Writer) OnFloat64(v float64, n json.Number) error {
+    if n == "" {
+        return self.Float64(v)
+    }
+    return self.literal(string(n))
+}
+
+// OnObjectBegin implements ast.Visitor.
+func (self *Writer) OnObjectBegin(capacity int) error {
+    return self.BeginObject()
+}
+
+// OnObjectKey implements ast.Visitor.
+func (self *Writer) OnObjectKey(key string) error {
+    return self.Key(key)
+}
+
+// OnObjectEnd implements ast.Visitor.
+func (self *Writer) OnObjectEnd() error {
+    return self.EndObject()
+}
+
+// OnArrayBegin implements ast.Visitor.
+func (self *Writer) OnArrayBegin(capacity int) error {
+    return self.BeginArray()
+}
+
+// OnArrayEnd implements ast.Visitor.
+func (self *Writer) OnArrayEnd() error {
+    return self.EndArray()
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonwriter
+
+import (
+    `bytes`
+    `encoding/json`
+    `errors`
+    `math`
+    `strings`
+    `testing`
+
+    `github.com/bytedance/sonic/ast`
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestWriter_Tokens(t *testing.T) {
+    w := NewBuffer(nil)
+    w.BeginObject()
+    w.Key("a")
+    w.BeginArray()
+    w.Int64(-1)
+    w.Uint64(math.MaxUint64)
+    w.Float64(1.5)
+    w.Bool(true)
+    w.Null()
+    w.String("x\"\n<")
+    w.Number("1e3")
+    w.Raw([]byte(` {"b" : []} `))
+    w.BeginArray()
+    w.EndArray()
+    w.EndArray()
+    w.Key("")
+    w.BeginObject()
+    w.EndObject()
+    w.EndObject()
+    require.NoError(t, w.Close())
+    exp := `{"a":[-1,18446744073709551615,1.5,true,null,"x\"\n<",1e3,{"b" : []},[]],"":{}}`
+    assert.Equal(t, exp, string(w.Bytes()))
+    assert.True(t, json.Valid(w.Bytes()))
+
+    /* reused for another value */
+    w.Reset()
+    require.NoError(t, w.String("s"))
+    require.NoError(t, w.Close())
+    assert.Equal(t, `"s"`, string(w.Bytes()))
+}
+
+func TestWriter_Indent(t *testing.T) {
+    w := NewBuffer(nil)
+    w.SetIndent(">", "  ")
+    w.BeginObject()
+    w.Key("a")
+    w.BeginArray()
+    w.Int64(1)
+    w.BeginObject()
+    w.EndObject()
+    w.EndArray()
+    w.Key("b")
+    w.BeginArray()
+    w.EndArray()
+    require.NoError(t, w.EndObject())
+    exp := "{\n>  \"a\": [\n>    1,\n>    {}\n>  ],\n>  \"b\": []\n>}"
+    assert.Equal(t, exp, string(w.Bytes()))
+
+    /* the same as json.Indent */
+    var out bytes.Buffer
+    require.NoError(t, json.Indent(&out, []byte(`{"a":[1,{}],"b":[]}`), ">", "  "))
+    assert.Equal(t, out.String(), exp)
+}
+
+func TestWriter_Errors(t *testing.T) {
+    cases := []struct {
+        name  string
+        write func(w *Writer) error
+    }{
+        {"value without key", func(w *Writer) error { w.BeginObject(); return w.Int64(1) }},
+        {"key without value", func(w *Writer) error { w.BeginObject(); w.Key("a"); return w.EndObject() }},
+        {"key twice", func(w *Writer) error { w.BeginObject(); w.Key("a"); return w.Key("b") }},
+        {"key in array", func(w *Writer) error { w.BeginArray(); return w.Key("a") }},
+        {"unbalanced", func(w *Writer) error { w.BeginArray(); return w.EndObject() }},
+        {"extra end", func(w *Writer) error { w.Null(); return w.EndArray() }},
+        {"multiple values", func(w *Writer) error { w.Null(); return w.Null() }},
+        {"incomplete", func(w *Writer) error { w.BeginArray(); return w.Close() }},
+        {"empty", func(w *Writer) error { return w.Close() }},
+        {"invalid number", func(w *Writer) error { return w.Number("0x1") }},
+        {"invalid raw", func(w *Writer) error { return w.Raw([]byte(`{"a"}`)) }},
+        {"unterminated string", func(w *Writer) error { w.StringChunk("a", false); return w.EndArray() }},
+    }
+    for _, c := range cases {
+        w := NewBuffer(nil)
+        err := c.write(w)
+        assert.True(t, errors.Is(err, ErrInvalidToken), c.name)
+
+        /* the error is kept */
+        assert.Equal(t, err, w.Null(), c.name)
+    }
+
+    w := NewBuffer(nil)
+    assert.Error(t, w.Float64(math.NaN()))
+    assert.Error(t, w.Close())
+}
+
+func TestWriter_Stream(t *testing.T) {
+    var out bytes.Buffer
+    w := New(&out)
+    w.BeginArray()
+    for i := 0; i < 10000; i++ {
+        w.StringChunk("a\t", false)
+        w.StringChunk("中", true)
+    }
+    w.EndArray()
+
+    /* the output is flushed when the buffer is full */
+    assert.True(t, out.Len() > 0)
+    require.NoError(t, w.Close())
+    var v []string
+    require.NoError(t, json.Unmarshal(out.Bytes(), &v))
+    assert.Equal(t, 10000, len(v))
+    assert.Equal(t, "a\t中", v[0])
+}
+
+func TestWriter_Visitor(t *testing.T) {
+    src := `{ "a" : [ 1, -2.5e3, 12345678901234567890, "é\n" ], "b": { "c": null, "d": [true, false, {}] } }`
+    w := NewBuffer(nil)
+    require.NoError(t, ast.Preorder(src, w, nil))
+    require.NoError(t, w.Close())
+    assert.Equal(t, `{"a":[1,-2.5e3,12345678901234567890,"é\n"],"b":{"c":null,"d":[true,false,{}]}}`, string(w.Bytes()))
+
+    /* the streaming re-formatter */
+    var out bytes.Buffer
+    w = New(&out)
+    w.SetIndent("", "\t")
+    require.NoError(t, ast.PreorderReader(strings.NewReader(src), w, &ast.VisitorOptions{BufferSize: 64}))
+    require.NoError(t, w.Close())
+    var exp bytes.Buffer
+    require.NoError(t, json.Indent(&exp, []byte(src), "", "\t"))
+    assert.Equal(t, exp.String(), out.String())
+
+    /* the invalid input */
+    w = NewBuffer(nil)
+    assert.Error(t, ast.Preorder(`[1, }`, w, nil))
+}
@@ -0,0 +1,654 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+    `encoding/json`
+    `fmt`
+    `math/big`
+    `net/url`
+    `regexp`
+    `strconv`
+    `strings`
+)
+
+const (
+    typeNull = 1 << iota
+    typeBoolean
+    typeObject
+    typeArray
+    typeNumber
+    typeString
+    typeInteger
+)
+
+var typeNames = []string{"null", "boolean", "object", "array", "number", "string", "integer"}
+
+// node is a compiled schema.
+type node struct {
+    path    string     // JSON Pointer of the schema in the document
+    never   bool       // the `false` schema
+    asserts bool       // the schema has keywords looking into the instance
+    res     *resource  // the schema resource containing the schema
+    closure []*node    // the schemas applied in place, including itself
+
+    /* core */
+    ref       *node
+    refStr    string
+    dynRef    *node
+    dynRefStr string
+    dynName   string   // the anchor which $dynamicRef looks up in the dynamic scope
+    dynAnchor string
+
+    /* validation */
+    types         int
+    enum          []interface{}
+    hasEnum       bool
+    constant      interface{}
+    hasConst      bool
+    multipleOf    *number
+    maximum       *number
+    exclusiveMax  *number
+    minimum       *number
+    exclusiveMin  *number
+    maxLength     int
+    minLength     int
+    pattern       *regexp.Regexp
+    maxItems      int
+    minItems      int
+    uniqueItems   bool
+    maxContains   int
+    minContains   int
+    maxProperties int
+    minProperties int
+    required      []string
+    depRequired   []dependency
+
+    /* applicator */
+    allOf         []*node
+    anyOf         []*node
+    oneOf         []*node
+    not           *node
+    ifs           *node
+    then          *node
+    els           *node
+    depSchemas    []dependency
+    prefixItems   []*node
+    items         *node
+    contains      *node
+    properties    map[string]*node
+    patternProps  []patternProperty
+    additional    *node
+    propertyNames *node
+    unevalItems   *node
+    unevalProps   *node
+}
+
+// number is a number keyword, with its literal for the messages.
+type number struct {
+    rat *big.Rat
+    lit string
+}
+
+// resource is the root schema or a schema having $id.
+type resource struct {
+    anchors map[string]*node   // the schemas by their $dynamicAnchor
+}
+
+type dependency struct {
+    key      string
+    required []string
+    schema   *node
+}
+
+type patternProperty struct {
+    re     *regexp.Regexp
+    schema *node
+}
+
+var unsupportedKeywords = []string{"$recursiveRef", "$recursiveAnchor"}
+
+// assertingKeywords are the keywords looking into the instance, the others are
+// annotations or the applicators in place, whose schemas are validated separately.
+var assertingKeywords = []string{
+    "type", "enum", "const", "multipleOf", "maximum", "exclusiveMaximum", "minimum", "exclusiveMinimum",
+    "maxLength", "minLength", "pattern", "maxItems", "minItems", "uniqueItems", "maxContains", "minContains",
+    "maxProperties", "minProperties", "required", "dependentRequired", "prefixItems", "items", "contains",
+    "properties", "patternProperties", "additionalProperties", "propertyNames", "unevaluatedItems", "unevaluatedProperties",
+}
+
+type compiler struct {
+    doc       interface{}
+    nodes     map[string]*node      // the compiled schemas by their paths
+    ids       map[string]string     // the paths of the schemas by their absolute $id
+    anchors   map[string]*node
+    bases     map[*node]string      // the base URIs of the schemas
+    resources map[string]*resource  // the schema resources by their base URIs
+    refs      []*node
+    dynRefs   []*node
+}
+
+func newCompiler(doc interface{}) *compiler {
+    return &compiler{
+        doc       : doc,
+        nodes     : make(map[string]*node),
+        ids       : make(map[string]string),
+        anchors   : make(map[string]*node),
+        bases     : make(map[*node]string),
+        resources : make(map[string]*resource),
+    }
+}
+
+func compileError(path string, format string, args ...interface{}) error {
+    return fmt.Errorf("invalid JSON Schema at %q: %s", path, fmt.Sprintf(format, args...))
+}
+
+func (self *compiler) compileRoot() (*node, error) {
+    root, err := self.compile(self.doc, "", "")
+    if err != nil {
+        return nil, err
+    }
+
+    /* resolve the references, which may compile more schemas */
+    for i := 0; i < len(self.refs) || i < len(self.dynRefs); i++ {
+        if i < len(self.refs) {
+            n := self.refs[i]
+            if n.ref, err = self.resolve(n, n.refStr, self.bases[n]); err != nil {
+                return nil, err
+            }
+        }
+        if i < len(self.dynRefs) {
+            n := self.dynRefs[i]
+            if n.dynRef, err = self.resolve(n, n.dynRefStr, self.bases[n]); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    /* the $dynamicRef is dynamic only if its initial target has the same $dynamicAnchor */
+    for _, n := range self.dynRefs {
+        _, frag, _ := resolveURI(self.bases[n], n.dynRefStr)
+        if frag != "" && frag == n.dynRef.dynAnchor {
+            n.dynName = frag
+        }
+    }
+    for _, n := range self.nodes {
+        n.closure = self.closure(n, nil, make(map[*node]bool))
+    }
+    return root, nil
+}
+
+// closure appends to ret the schemas applied in place to the instance of n, which
+// are n itself and the schemas of its references and in-place applicators.
+func (self *compiler) closure(n *node, ret []*node, visited map[*node]bool) []*node {
+    if visited[n] || n.never {
+        return ret
+    }
+    visited[n] = true
+    if n.asserts {
+        ret = append(ret, n)
+    }
+    subs := []*node{n.ref, n.dynRef, n.not, n.ifs, n.then, n.els}
+    subs = append(subs, n.allOf...)
+    subs = append(subs, n.anyOf...)
+    subs = append(subs, n.oneOf...)
+    for _, dep := range n.depSchemas {
+        subs = append(subs, dep.schema)
+    }
+    if n.dynName != "" {
+        for _, r := range self.resources {
+            subs = append(subs, r.anchors[n.dynName])
+        }
+    }
+    for _, sub := range subs {
+        if sub != nil {
+            ret = self.closure(sub, ret, visited)
+        }
+    }
+    return ret
+}
+
+func (self *compiler) compile(v interface{}, path string, base string) (*node, error) {
+    if n, ok := self.nodes[path]; ok {
+        return n, nil
+    }
+    n := &node{path: path, maxLength: -1, maxItems: -1, maxContains: -1, minContains: 1, maxProperties: -1}
+    self.nodes[path] = n
+
+    s, ok := v.(map[string]interface{})
+    if !ok {
+        if b, ok := v.(bool); ok {
+            n.never = !b
+            return n, nil
+        }
+        return nil, compileError(path, "schema must be an object or a boolean")
+    }
+    for _, k := range unsupportedKeywords {
+        if _, ok := s[k]; ok {
+            return nil, compileError(path, "unsupported keyword %q", k)
+        }
+    }
+    for _, k := range assertingKeywords {
+        if _, ok := s[k]; ok {
+            n.asserts = true
+            break
+        }
+    }
+    if err := self.compileCore(n, s, base); err != nil {
+        return nil, err
+    }
+    if err := self.compileValidation(n, s); err != nil {
+        return nil, err
+    }
+    return n, self.compileApplicator(n, s, self.bases[n])
+}
+
+func (self *compiler) compileCore(n *node, s map[string]interface{}, base string) error {
+    if v, ok := s["$id"]; ok {
+        id, ok := v.(string)
+        if !ok {
+            return compileError(n.path, "$id must be a string")
+        }
+        u, _, err := resolveURI(base, id)
+        if err != nil {
+            return compileError(n.path, "invalid $id: %v", err)
+        }
+        base = u
+        self.ids[base] = n.path
+    }
+    self.bases[n] = base
+    if n.res = self.resources[base]; n.res == nil {
+        n.res = &resource{anchors: make(map[string]*node)}
+        self.resources[base] = n.res
+    }
+
+    if v, ok := s["$anchor"]; ok {
+        a, ok := v.(string)
+        if !ok {
+            return compileError(n.path, "$anchor must be a string")
+        }
+        self.anchors[base + "#" + a] = n
+    }
+    if v, ok := s["$dynamicAnchor"]; ok {
+        a, ok := v.(string)
+        if !ok {
+            return compileError(n.path, "$dynamicAnchor must be a string")
+        }
+        self.anchors[base + "#" + a] = n
+        n.res.anchors[a] = n
+        n.dynAnchor = a
+    }
+    if v, ok := s["$ref"]; ok {
+        ref, ok := v.(string)
+        if !ok {
+            return compileError(n.path, "$ref must be a string")
+        }
+        n.refStr = ref
+        self.refs = append(self.refs, n)
+    }
+    if v, ok := s["$dynamicRef"]; ok {
+        ref, ok := v.(string)
+        if !ok {
+            return compileError(n.path, "$dynamicRef must be a string")
+        }
+        n.dynRefStr = ref
+        self.dynRefs = append(self.dynRefs, n)
+    }
+
+    /* the definitions are compiled for their $id and $anchor */
+    for _, kw := range []string{"$defs", "definitions"} {
+        if err := self.compileMap(s, kw, n.path, base, func(string, *node) {}); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// resolve resolves the reference ref of n with the base URI.
+func (self *compiler) resolve(n *node, ref string, base string) (*node, error) {
+    uri, frag, err := resolveURI(base, ref)
+    if err != nil {
+        return nil, compileError(n.path, "invalid reference: %v", err)
+    }
+    if frag != "" && frag[0] != '/' {
+        if a, ok := self.anchors[uri + "#" + frag]; ok {
+            return a, nil
+        }
+        return nil, compileError(n.path, "unknown anchor of reference %q", ref)
+    }
+
+    /* locate the schema by the pointer relative to the resource */
+    path, ok := self.ids[uri]
+    if !ok && uri != "" {
+        return nil, compileError(n.path, "unsupported remote reference %q", ref)
+    }
+    path += frag
+    v, ok := lookupPointer(self.doc, path)
+    if !ok {
+        return nil, compileError(n.path, "unresolved reference %q", ref)
+    }
+    return self.compile(v, path, uri)
+}
+
+// resolveURI resolves ref against base, and returns the URI without fragment and the fragment.
+func resolveURI(base string, ref string) (string, string, error) {
+    r, err := url.Parse(ref)
+    if err != nil {
+        return "", "", err
+    }
+    if base != "" {
+        b, err := url.Parse(base)
+        if err != nil {
+            return "", "", err
+        }
+        r = b.ResolveReference(r)
+    }
+    frag := r.Fragment
+    r.Fragment, r.RawFragment = "", ""
+    return r.String(), frag, nil
+}
+
+// lookupPointer returns the value at the JSON Pointer path in doc.
+func lookupPointer(doc interface{}, path string) (interface{}, bool) {
+    if path == "" {
+        return doc, true
+    }
+    var ok bool
+    for _, tok := range strings.Split(path[1:], "/") {
+        tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+        switch v := doc.(type) {
+            case map[string]interface{}:
+                if doc, ok = v[tok]; !ok {
+                    return nil, false
+                }
+            case []interface{}:
+                i, err := strconv.Atoi(tok)
+                if err != nil || i < 0 || i >= len(v) {
+                    return nil, false
+                }
+                doc = v[i]
+            default:
+                return nil, false
+        }
+    }
+    return doc, true
+}
+
+func (self *compiler) compileValidation(n *node, s map[string]interface{}) error {
+    var err error
+    if v, ok := s["type"]; ok {
+        if n.types, err = compileTypes(v); err != nil {
+            return compileError(n.path, "%v", err)
+        }
+    }
+    if v, ok := s["enum"]; ok {
+        if n.enum, ok = v.([]interface{}); !ok {
+            return compileError(n.path, "enum must be an array")
+        }
+        n.hasEnum = true
+    }
+    if v, ok := s["const"]; ok {
+        n.constant, n.hasConst = v, true
+    }
+
+    /* numbers */
+    for _, kw := range []struct {
+        name string
+        dst  **number
+    }{
+        {"multipleOf", &n.multipleOf},
+        {"maximum", &n.maximum},
+        {"exclusiveMaximum", &n.exclusiveMax},
+        {"minimum", &n.minimum},
+        {"exclusiveMinimum", &n.exclusiveMin},
+    } {
+        if v, ok := s[kw.name]; ok {
+            if *kw.dst, ok = toNumber(v); !ok {
+                return compileError(n.path, "%s must be a number", kw.name)
+            }
+        }
+    }
+    if n.multipleOf != nil && n.multipleOf.rat.Sign() <= 0 {
+        return compileError(n.path, "multipleOf must be greater than 0")
+    }
+
+    /* counts */
+    for _, kw := range []struct {
+        name string
+        dst  *int
+    }{
+        {"maxLength", &n.maxLength},
+        {"minLength", &n.minLength},
+        {"maxItems", &n.maxItems},
+        {"minItems", &n.minItems},
+        {"maxContains", &n.maxContains},
+        {"minContains", &n.minContains},
+        {"maxProperties", &n.maxProperties},
+        {"minProperties", &n.minProperties},
+    } {
+        if v, ok := s[kw.name]; ok {
+            if *kw.dst, ok = toCount(v); !ok {
+                return compileError(n.path, "%s must be a non-negative integer", kw.name)
+            }
+        }
+    }
+
+    if v, ok := s["pattern"]; ok {
+        if n.pattern, err = compilePattern(v); err != nil {
+            return compileError(n.path, "pattern %v", err)
+        }
+    }
+    if v, ok := s["uniqueItems"]; ok {
+        if n.uniqueItems, ok = v.(bool); !ok {
+            return compileError(n.path, "uniqueItems must be a boolean")
+        }
+    }
+    if v, ok := s["required"]; ok {
+        if n.required, ok = toStrings(v); !ok {
+            return compileError(n.path, "required must be an array of strings")
+        }
+    }
+    if v, ok := s["dependentRequired"]; ok {
+        m, ok := v.(map[string]interface{})
+        if !ok {
+            return compileError(n.path, "dependentRequired must be an object")
+        }
+        for _, k := range sortedKeys(m) {
+            names, ok := toStrings(m[k])
+            if !ok {
+                return compileError(n.path, "dependentRequired must be an object of string arrays")
+            }
+            n.depRequired = append(n.depRequired, dependency{key: k, required: names})
+        }
+    }
+    return nil
+}
+
+func (self *compiler) compileApplicator(n *node, s map[string]interface{}, base string) error {
+    var err error
+    for _, kw := range []struct {
+        name string
+        dst  *[]*node
+    }{
+        {"allOf", &n.allOf},
+        {"anyOf", &n.anyOf},
+        {"oneOf", &n.oneOf},
+        {"prefixItems", &n.prefixItems},
+    } {
+        if *kw.dst, err = self.compileList(s, kw.name, n.path, base); err != nil {
+            return err
+        }
+    }
+    for _, kw := range []struct {
+        name string
+        dst  **node
+    }{
+        {"not", &n.not},
+        {"if", &n.ifs},
+        {"then", &n.then},
+        {"else", &n.els},
+        {"items", &n.items},
+        {"contains", &n.contains},
+        {"additionalProperties", &n.additional},
+        {"propertyNames", &n.propertyNames},
+        {"unevaluatedItems", &n.unevalItems},
+        {"unevaluatedProperties", &n.unevalProps},
+    } {
+        if v, ok := s[kw.name]; ok {
+            if *kw.dst, err = self.compile(v, n.path + "/" + kw.name, base); err != nil {
+                return err
+            }
+        }
+    }
+
+    err = self.compileMap(s, "properties", n.path, base, func(k string, p *node) {
+        if n.properties == nil {
+            n.properties = make(map[string]*node)
+        }
+        n.properties[k] = p
+    })
+    if err != nil {
+        return err
+    }
+    err = self.compileMap(s, "dependentSchemas", n.path, base, func(k string, p *node) {
+        n.depSchemas = append(n.depSchemas, dependency{key: k, schema: p})
+    })
+    if err != nil {
+        return err
+    }
+    return self.compileMap(s, "patternProperties", n.path, base, func(k string, p *node) {
+        n.patternProps = append(n.patternProps, patternProperty{re: regexp.MustCompile(k), schema: p})
+    })
+}
+
+func (self *compiler) compileList(s map[string]interface{}, kw string, path string, base string) ([]*node, error) {
+    v, ok := s[kw]
+    if !ok {
+        return nil, nil
+    }
+    list, ok := v.([]interface{})
+    if !ok || len(list) == 0 {
+        return nil, compileError(path, "%s must be a non-empty array", kw)
+    }
+    ret := make([]*node, len(list))
+    for i, sub := range list {
+        var err error
+        if ret[i], err = self.compile(sub, path + "/" + kw + "/" + strconv.Itoa(i), base); err != nil {
+            return nil, err
+        }
+    }
+    return ret, nil
+}
+
+// compileMap compiles the schemas in the object of kw in their key order.
+func (self *compiler) compileMap(s map[string]interface{}, kw string, path string, base string, add func(string, *node)) error {
+    v, ok := s[kw]
+    if !ok {
+        return nil
+    }
+    m, ok := v.(map[string]interface{})
+    if !ok {
+        return compileError(path, "%s must be an object", kw)
+    }
+    for _, k := range sortedKeys(m) {
+        if kw == "patternProperties" {
+            if _, err := compilePattern(k); err != nil {
+                return compileError(path, "patternProperties %v", err)
+            }
+        }
+        sub, err := self.compile(m[k], path + "/" + kw + "/" + escapePointerToken(k), base)
+        if err != nil {
+            return err
+        }
+        add(k, sub)
+    }
+    return nil
+}
+
+func compileTypes(v interface{}) (int, error) {
+    names, ok := toStrings(v)
+    if !ok {
+        if s, ok := v.(string); ok {
+            names = []string{s}
+        } else {
+            return 0, fmt.Errorf("type must be a string or an array of strings")
+        }
+    }
+    ret := 0
+    next:
+    for _, name := range names {
+        for i, t := range typeNames {
+            if name == t {
+                ret |= 1 << i
+                continue next
+            }
+        }
+        return 0, fmt.Errorf("unknown type %q", name)
+    }
+    return ret, nil
+}
+
+func compilePattern(v interface{}) (*regexp.Regexp, error) {
+    s, ok := v.(string)
+    if !ok {
+        return nil, fmt.Errorf("must be a string")
+    }
+    re, err := regexp.Compile(s)
+    if err != nil {
+        return nil, fmt.Errorf("is invalid: %v", err)
+    }
+    return re, nil
+}
+
+func toRat(v interface{}) (*big.Rat, bool) {
+    num, ok := v.(json.Number)
+    if !ok {
+        return nil, false
+    }
+    r := parseNumber(num)
+    return r, r != nil
+}
+
+func toNumber(v interface{}) (*number, bool) {
+    r, ok := toRat(v)
+    if !ok {
+        return nil, false
+    }
+    return &number{rat: r, lit: string(v.(json.Number))}, true
+}
+
+func toCount(v interface{}) (int, bool) {
+    r, ok := toRat(v)
+    if !ok || !r.IsInt() || r.Sign() < 0 || !r.Num().IsInt64() {
+        return 0, false
+    }
+    return int(r.Num().Int64()), true
+}
+
+func toStrings(v interface{}) ([]string, bool) {
+    list, ok := v.([]interface{})
+    if !ok {
+        return nil, false
+    }
+    ret := make([]string, len(list))
+    for i, s := range list {
+        if ret[i], ok = s.(string); !ok {
+            return nil, false
+        }
+    }
+    return ret, true
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+    `encoding/json`
+    `strings`
+
+    `github.com/bytedance/sonic/ast`
+    `github.com/bytedance/sonic/internal/native/types`
+    `github.com/bytedance/sonic/internal/rt`
+)
+
+// demand is the schemas applied to a value, which decide how much of it is scanned.
+type demand struct {
+    nodes []*node   // the schemas looking into the value
+    whole bool      // the whole value is compared by const, enum or uniqueItems
+}
+
+func newDemand(s *node) demand {
+    d := demand{}
+    d.add(s)
+    return d
+}
+
+func (self *demand) add(s *node) {
+    if s == nil || self.whole {
+        return
+    }
+    next:
+    for _, n := range s.closure {
+        if n.hasConst || n.hasEnum {
+            *self = demand{whole: true}
+            return
+        }
+        for _, m := range self.nodes {
+            if m == n {
+                continue next
+            }
+        }
+        self.nodes = append(self.nodes, n)
+    }
+}
+
+func (self demand) needed() bool {
+    return self.whole || len(self.nodes) != 0
+}
+
+// member returns the demand of the member of key.
+func (self demand) member(key string) demand {
+    ret := demand{whole: self.whole}
+    for _, s := range self.nodes {
+        matched := false
+        if p, ok := s.properties[key]; ok {
+            ret.add(p)
+            matched = true
+        }
+        for _, pp := range s.patternProps {
+            if pp.re.MatchString(key) {
+                ret.add(pp.schema)
+                matched = true
+            }
+        }
+        if !matched {
+            ret.add(s.additional)
+        }
+        ret.add(s.unevalProps)
+    }
+    return ret
+}
+
+// item returns the demand of the i-th item.
+func (self demand) item(i int) demand {
+    ret := demand{whole: self.whole}
+    for _, s := range self.nodes {
+        if s.uniqueItems {
+            return demand{whole: true}
+        }
+        if i < len(s.prefixItems) {
+            ret.add(s.prefixItems[i])
+        } else {
+            ret.add(s.items)
+        }
+        ret.add(s.contains)
+        ret.add(s.unevalItems)
+    }
+    return ret
+}
+
+// prefix returns the number of the leading items which may have their own demands.
+func (self demand) prefix() int {
+    n := 0
+    for _, s := range self.nodes {
+        if len(s.prefixItems) > n {
+            n = len(s.prefixItems)
+        }
+    }
+    return n
+}
+
+// scanner scans the validated JSON in a single pass. The values demanded by the schemas
+// are parsed into the instances, while the others are checked and skipped by skip,
+// and kept as the raw nodes.
+type scanner struct {
+    s     string
+    p     int
+    depth int
+}
+
+func (self *scanner) error(code types.ParsingError) error {
+    if code == types.ERR_EOF || self.p > len(self.s) {
+        self.p = len(self.s)
+    }
+    return ast.SyntaxError{Pos: self.p, Src: self.s, Code: code}
+}
+
+func (self *scanner) blank() {
+    for self.p < len(self.s) && (types.SPACE_MASK & (1 << self.s[self.p])) != 0 {
+        self.p++
+    }
+}
+
+// expect skips the blanks and the char c.
+func (self *scanner) expect(c byte) error {
+    if self.blank(); self.p >= len(self.s) {
+        return self.error(types.ERR_EOF)
+    }
+    if self.s[self.p] != c {
+        return self.error(types.ERR_INVALID_CHAR)
+    }
+    self.p++
+    return nil
+}
+
+// scan scans the whole document, which is the value of d.
+func (self *scanner) scan(d demand) (instance, error) {
+    v, err := self.value(d)
+    if err != nil {
+        return v, err
+    }
+    if self.blank(); self.p < len(self.s) {
+        return v, self.error(types.ERR_INVALID_CHAR)
+    }
+    return v, nil
+}
+
+func (self *scanner) value(d demand) (instance, error) {
+    if self.blank(); self.p >= len(self.s) {
+        return instance{}, self.error(types.ERR_EOF)
+    }
+    if d.needed() {
+        switch self.s[self.p] {
+            case '{' : return self.object(d)
+            case '[' : return self.array(d)
+        }
+    }
+
+    start, err := self.skip()
+    if err != nil {
+        return instance{}, err
+    }
+    lit := self.s[start:self.p]
+    if !d.needed() {
+        node := ast.NewRaw(lit)
+        return instance{node: &node}, nil
+    }
+    switch lit[0] {
+        case 'n' : return instance{typ: ast.V_NULL}, nil
+        case 't' : return instance{typ: ast.V_TRUE}, nil
+        case 'f' : return instance{typ: ast.V_FALSE}, nil
+        case '"':
+            str, err := self.unquote(start)
+            return instance{typ: ast.V_STRING, str: str}, err
+        default:
+            return instance{typ: ast.V_NUMBER, lit: lit}, nil
+    }
+}
+
+// unquote unquotes the checked string from start to the current position.
+func (self *scanner) unquote(start int) (string, error) {
+    lit := self.s[start:self.p]
+    if strings.IndexByte(lit, '\\') < 0 {
+        return lit[1:len(lit) - 1], nil
+    }
+    var str string
+    if err := json.Unmarshal(rt.Str2Mem(lit), &str); err != nil {
+        self.p = start
+        return "", self.error(types.ERR_INVALID_ESCAPE)
+    }
+    return str, nil
+}
+
+func (self *scanner) object(d demand) (instance, error) {
+    v := instance{typ: ast.V_OBJECT, loaded: true}
+    if self.depth++; self.depth > types.MAX_RECURSE {
+        return v, self.error(types.ERR_RECURSE_EXCEED_MAX)
+    }
+    self.p++
+    if self.blank(); self.p < len(self.s) && self.s[self.p] == '}' {
+        self.p++
+        self.depth--
+        return v, nil
+    }
+    for {
+        if self.blank(); self.p < len(self.s) && self.s[self.p] != '"' {
+            return v, self.error(types.ERR_INVALID_CHAR)
+        }
+        start, err := self.skip()
+        if err != nil {
+            return v, err
+        }
+        key, err := self.unquote(start)
+        if err != nil {
+            return v, err
+        }
+        if err := self.expect(':'); err != nil {
+            return v, err
+        }
+        elem, err := self.value(d.member(key))
+        if err != nil {
+            return v, err
+        }
+        v.keys = append(v.keys, key)
+        v.elems = append(v.elems, elem)
+
+        if err := self.next('}'); err != nil || self.s[self.p - 1] == '}' {
+            self.depth--
+            return v, err
+        }
+    }
+}
+
+func (self *scanner) array(d demand) (instance, error) {
+    v := instance{typ: ast.V_ARRAY, loaded: true}
+    if self.depth++; self.depth > types.MAX_RECURSE {
+        return v, self.error(types.ERR_RECURSE_EXCEED_MAX)
+    }
+    self.p++
+    if self.blank(); self.p < len(self.s) && self.s[self.p] == ']' {
+        self.p++
+        self.depth--
+        return v, nil
+    }
+
+    /* the items after the prefixes share the same demand */
+    var rest demand
+    n := d.prefix()
+    for i := 0; ; i++ {
+        var sub demand
+        if i < n {
+            sub = d.item(i)
+        } else {
+            if i == n {
+                rest = d.item(i)
+            }
+            sub = rest
+        }
+        elem, err := self.value(sub)
+        if err != nil {
+            return v, err
+        }
+        v.elems = append(v.elems, elem)
+
+        if err := self.next(']'); err != nil || self.s[self.p - 1] == ']' {
+            self.depth--
+            return v, err
+        }
+    }
+}
+
+// next skips the blanks and the comma or the end of the container.
+func (self *scanner) next(end byte) error {
+    if self.blank(); self.p >= len(self.s) {
+        return self.error(types.ERR_EOF)
+    }
+    if c := self.s[self.p]; c != ',' && c != end {
+        return self.error(types.ERR_INVALID_CHAR)
+    }
+    self.p++
+    return nil
+}
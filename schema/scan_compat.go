@@ -0,0 +1,45 @@
+// +build !amd64,!arm64 go1.24 !go1.17 arm64,!go1.20
+
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+    `encoding/json`
+    `strings`
+
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// skip checks and skips the next value by encoding/json, and returns its start.
+func (self *scanner) skip() (int, error) {
+    if self.blank(); self.p >= len(self.s) {
+        return 0, self.error(types.ERR_EOF)
+    }
+    start := self.p
+    dec := json.NewDecoder(strings.NewReader(self.s[start:]))
+    var raw json.RawMessage
+    if err := dec.Decode(&raw); err != nil {
+        if e, ok := err.(*json.SyntaxError); ok {
+            self.p = start + int(e.Offset) - 1
+            return 0, self.error(types.ERR_INVALID_CHAR)
+        }
+        return 0, self.error(types.ERR_EOF)
+    }
+    self.p = start + int(dec.InputOffset())
+    return start, nil
+}
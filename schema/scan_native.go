@@ -0,0 +1,122 @@
+//go:build (amd64 && go1.17 && !go1.24) || (arm64 && go1.20 && !go1.24)
+// +build amd64,go1.17,!go1.24 arm64,go1.20,!go1.24
+
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+    `strings`
+
+    `github.com/bytedance/sonic/internal/native`
+    `github.com/bytedance/sonic/internal/native/types`
+)
+
+// skip checks and skips the next value by the native validator, and returns its start.
+func (self *scanner) skip() (int, error) {
+    m := types.NewStateMachine()
+    ret := native.ValidateOne(&self.s, &self.p, m, types.F_VALIDATE_STRING)
+    types.FreeStateMachine(m)
+    if ret < 0 {
+        self.p--
+        return 0, self.error(types.ParsingError(-ret))
+    }
+    return ret, self.check(ret)
+}
+
+// check checks the escape sequences and the minus signs of the value from start to
+// the current position, which are not checked by the native validator.
+func (self *scanner) check(start int) error {
+    s := self.s[:self.p]
+    if err := self.checkEscapes(s, start); err != nil {
+        return err
+    }
+    return self.checkMinus(s, start)
+}
+
+func (self *scanner) checkEscapes(s string, start int) error {
+    for i := start; ; {
+        j := strings.IndexByte(s[i:], '\\')
+        if j < 0 {
+            return nil
+        }
+        if i += j + 1; i < len(s) && s[i] == 'u' {
+            for k := i + 1; k < i + 5; k++ {
+                if k >= len(s) || !isHex(s[k]) {
+                    self.p = k
+                    return self.error(types.ERR_INVALID_UNICODE)
+                }
+            }
+            i += 5
+            continue
+        }
+        if i >= len(s) || strings.IndexByte(`"\/bfnrt`, s[i]) < 0 {
+            self.p = i
+            return self.error(types.ERR_INVALID_ESCAPE)
+        }
+        i++
+    }
+}
+
+// checkMinus checks that the minus signs without digits following are in the strings.
+func (self *scanner) checkMinus(s string, start int) error {
+    q := start // the strings before q are passed
+    for i := start; ; i++ {
+        j := strings.IndexByte(s[i:], '-')
+        if j < 0 {
+            return nil
+        }
+        if i += j; i + 1 < len(s) && s[i + 1] >= '0' && s[i + 1] <= '9' {
+            continue
+        }
+        for {
+            b := strings.IndexByte(s[q:i], '"')
+            if b < 0 {
+                self.p = i
+                return self.error(types.ERR_INVALID_NUMBER_FMT)
+            }
+            e := closingQuote(s, q + b + 1)
+            if q = e + 1; e > i {
+                i = e
+                break
+            }
+        }
+    }
+}
+
+// closingQuote returns the position of the quote closing the string from p.
+func closingQuote(s string, p int) int {
+    for {
+        j := strings.IndexByte(s[p:], '"')
+        if j < 0 {
+            return len(s)
+        }
+        p += j
+        n := 0
+        for k := p - 1; s[k] == '\\'; k-- {
+            n++
+        }
+        if n % 2 == 0 {
+            return p
+        }
+        p++
+    }
+}
+
+func isHex(c byte) bool {
+    return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
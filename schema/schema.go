@@ -0,0 +1,134 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package schema validates JSON documents against JSON Schema (draft 2020-12).
+//
+// The schemas are validated against the input JSON instead of the unmarshaled Go values,
+// thus the absent members and the null members are distinguished.
+//
+// Supported keywords are all the ones of the core, applicator, unevaluated and validation vocabularies.
+// The references must be local to the schema document.
+// The "format" keyword is an annotation and not asserted, and "pattern" uses the RE2 syntax of Go.
+package schema
+
+import (
+    `fmt`
+    `strings`
+
+    `github.com/bytedance/sonic/ast`
+    `github.com/bytedance/sonic/internal/rt`
+)
+
+// Schema is a compiled JSON Schema, which is safe to validate concurrently.
+type Schema struct {
+    root *node
+}
+
+// Compile compiles the JSON Schema document src.
+func Compile(src []byte) (*Schema, error) {
+    return CompileString(string(src))
+}
+
+// CompileString is same with Compile except src is string.
+func CompileString(src string) (*Schema, error) {
+    doc := ast.NewRaw(src)
+    v, err := doc.InterfaceUseNumber()
+    if err != nil {
+        return nil, err
+    }
+    c := newCompiler(v)
+    root, err := c.compileRoot()
+    if err != nil {
+        return nil, err
+    }
+    return &Schema{root: root}, nil
+}
+
+// MustCompile is like Compile but panics if the schema can't be compiled.
+func MustCompile(src string) *Schema {
+    s, err := CompileString(src)
+    if err != nil {
+        panic(err)
+    }
+    return s
+}
+
+// Validate validates the JSON src against the schema.
+//
+// The src is scanned and its syntax is checked in a single pass, which parses only
+// the values constrained by the schema, and skips the others by the native validator.
+// Like sonic.Valid, the invalid UTF-8 in the strings is not checked.
+//
+// It returns Errors including all the violations, or the ast.SyntaxError of src.
+func (self *Schema) Validate(src []byte) error {
+    sc := scanner{s: rt.Mem2Str(src)}
+    v, err := sc.scan(newDemand(self.root))
+    if err != nil {
+        return err
+    }
+    return self.validate(&v)
+}
+
+// ValidateString is same with Validate except src is string.
+func (self *Schema) ValidateString(src string) error {
+    return self.Validate(rt.Str2Mem(src))
+}
+
+// ValidateNode validates the node against the schema.
+// The lazy children of the node get loaded if they are constrained by the schema.
+//
+// It returns Errors including all the violations, or the error of loading the node.
+func (self *Schema) ValidateNode(node *ast.Node) error {
+    return self.validate(newInstance(node))
+}
+
+func (self *Schema) validate(inst *instance) error {
+    v := validator{}
+    if err := v.validate(self.root, inst, nil); err != nil {
+        return err
+    }
+    if len(v.errs) != 0 {
+        return v.errs
+    }
+    return nil
+}
+
+// Error is a violation of the schema.
+type Error struct {
+    // InstancePath is the JSON Pointer of the invalid value in the validated JSON
+    InstancePath string
+
+    // SchemaPath is the JSON Pointer of the violated keyword in the schema document
+    SchemaPath   string
+
+    // Message describes the violation
+    Message      string
+}
+
+func (self Error) Error() string {
+    return fmt.Sprintf("%q: %s (schema: %q)", self.InstancePath, self.Message, self.SchemaPath)
+}
+
+// Errors is all the violations of the schema, in the order of validation.
+type Errors []Error
+
+func (self Errors) Error() string {
+    msgs := make([]string, len(self))
+    for i, e := range self {
+        msgs[i] = e.Error()
+    }
+    return "JSON Schema violations: " + strings.Join(msgs, "; ")
+}
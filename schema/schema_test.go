@@ -0,0 +1,231 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+    `encoding/json`
+    `testing`
+
+    `github.com/bytedance/sonic/ast`
+    `github.com/bytedance/sonic/internal/native/types`
+    `github.com/stretchr/testify/assert`
+    `github.com/stretchr/testify/require`
+)
+
+func TestValidate_Keywords(t *testing.T) {
+    tests := []struct {
+        schema  string
+        valid   []string
+        invalid []string
+    }{
+        {`true`, []string{`1`, `null`, `{"a":[]}`}, nil},
+        {`false`, nil, []string{`1`, `null`}},
+        {`{"type":"integer"}`, []string{`1`, `1.0`, `-2e3`}, []string{`1.5`, `"1"`, `null`}},
+        {`{"type":["string","null"]}`, []string{`"a"`, `null`}, []string{`1`, `true`}},
+        {`{"type":"boolean"}`, []string{`true`, `false`}, []string{`0`}},
+        {`{"enum":[1,"a",{"b":[null]}]}`, []string{`1.0`, `"a"`, `{"b":[null]}`}, []string{`2`, `{"b":[]}`}},
+        {`{"const":{"a":1,"b":2}}`, []string{`{"b":2,"a":1}`}, []string{`{"a":1}`}},
+        {`{"multipleOf":0.1}`, []string{`0.3`, `10`}, []string{`0.35`}},
+        {`{"minimum":1,"exclusiveMaximum":3}`, []string{`1`, `2.99`}, []string{`0.9`, `3`}},
+        {`{"maximum":1e2,"exclusiveMinimum":-1}`, []string{`100`, `-0.5`, `"x"`}, []string{`100.1`, `-1`}},
+        {`{"minimum":1e-400}`, []string{`1e-300`}, []string{`0`}},
+        {`{"minLength":2,"maxLength":3}`, []string{`"ab"`, `"日本語"`}, []string{`"a"`, `"abcd"`}},
+        {`{"pattern":"^a+$"}`, []string{`"aa"`, `1`}, []string{`"ab"`}},
+        {`{"minItems":1,"maxItems":2,"uniqueItems":true}`, []string{`[1]`, `[1,"1"]`}, []string{`[]`, `[1,2,3]`, `[1,1.0]`}},
+        {`{"prefixItems":[{"type":"string"}],"items":{"type":"integer"}}`, []string{`["a",1,2]`, `[]`}, []string{`[1]`, `["a","b"]`}},
+        {`{"prefixItems":[true],"items":false}`, []string{`[1]`}, []string{`[1,2]`}},
+        {`{"contains":{"type":"null"}}`, []string{`[1,null]`}, []string{`[]`, `[1]`}},
+        {`{"contains":{"type":"null"},"minContains":2,"maxContains":3}`, []string{`[null,1,null]`}, []string{`[null]`, `[null,null,null,null]`}},
+        {`{"contains":{"type":"null"},"minContains":0}`, []string{`[]`}, nil},
+        {`{"required":["a"],"minProperties":1,"maxProperties":2}`, []string{`{"a":null}`, `{"a":1,"b":2}`}, []string{`{}`, `{"b":1}`, `{"a":1,"b":2,"c":3}`}},
+        {`{"properties":{"a":{"type":"null"}},"additionalProperties":false}`, []string{`{}`, `{"a":null}`}, []string{`{"a":1}`, `{"b":1}`}},
+        {`{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":{"type":"integer"}}`, []string{`{"x-a":"s","b":1}`}, []string{`{"x-a":1}`, `{"b":"s"}`}},
+        {`{"propertyNames":{"maxLength":2}}`, []string{`{"ab":1}`}, []string{`{"abc":1}`}},
+        {`{"dependentRequired":{"a":["b"]}}`, []string{`{"b":1}`, `{"a":1,"b":2}`}, []string{`{"a":1}`}},
+        {`{"dependentSchemas":{"a":{"required":["b"]}}}`, []string{`{}`, `{"a":1,"b":2}`}, []string{`{"a":1}`}},
+        {`{"allOf":[{"minimum":1},{"maximum":2}]}`, []string{`1.5`}, []string{`0`, `3`}},
+        {`{"anyOf":[{"type":"string"},{"minimum":1}]}`, []string{`"a"`, `2`}, []string{`0`}},
+        {`{"oneOf":[{"type":"integer"},{"minimum":1}]}`, []string{`0`, `1.5`}, []string{`2`, `0.5`}},
+        {`{"not":{"type":"null"}}`, []string{`1`}, []string{`null`}},
+        {`{"if":{"type":"string"},"then":{"minLength":1},"else":{"type":"null"}}`, []string{`"a"`, `null`}, []string{`""`, `1`}},
+        {`{"$defs":{"pos":{"minimum":0}},"items":{"$ref":"#/$defs/pos"}}`, []string{`[0,1]`}, []string{`[-1]`}},
+        {`{"$defs":{"a/b":{"$anchor":"s","type":"string"}},"properties":{"x":{"$ref":"#/$defs/a~1b"},"y":{"$ref":"#s"}}}`, []string{`{"x":"","y":""}`}, []string{`{"x":1}`, `{"y":1}`}},
+        {`{"type":"object","properties":{"next":{"$ref":"#"}},"required":["v"]}`, []string{`{"v":1,"next":{"v":2}}`}, []string{`{"v":1,"next":{}}`}},
+        {`{"$id":"http://x.com/root.json","$defs":{"i":{"$id":"item.json","type":"integer"}},"items":{"$ref":"item.json"}}`, []string{`[1]`}, []string{`["a"]`}},
+        {`{"properties":{"a":true},"allOf":[{"properties":{"b":true}}],"unevaluatedProperties":false}`, []string{`{"a":1,"b":1}`}, []string{`{"c":1}`}},
+        {`{"anyOf":[{"required":["a"],"properties":{"a":true}},{"required":["b"],"properties":{"b":true}}],"unevaluatedProperties":false}`, []string{`{"a":1,"b":1}`}, []string{`{"a":1,"c":1}`}},
+        {`{"not":{"not":{"properties":{"a":true}}},"unevaluatedProperties":false}`, []string{`{}`}, []string{`{"a":1}`}},
+        {`{"if":{"properties":{"a":{"const":1}}},"then":{"properties":{"b":true}},"unevaluatedProperties":{"type":"null"}}`, []string{`{"a":1,"b":1}`, `{"a":null}`}, []string{`{"a":2}`, `{"a":1,"c":1}`}},
+        {`{"patternProperties":{"^x":true},"additionalProperties":{"type":"string"},"unevaluatedProperties":false}`, []string{`{"x":1,"y":"s"}`}, []string{`{"y":1}`}},
+        {`{"prefixItems":[true],"contains":{"type":"string"},"unevaluatedItems":false}`, []string{`[1,"a"]`}, []string{`[1,2]`, `[]`}},
+        {`{"allOf":[{"unevaluatedItems":true}],"unevaluatedItems":false}`, []string{`[1,2]`}, nil},
+        {`{"$ref":"#/$defs/a","unevaluatedItems":{"type":"integer"},"$defs":{"a":{"prefixItems":[{"type":"string"}]}}}`, []string{`["a",1]`}, []string{`["a","b"]`}},
+        {`{
+            "$id": "http://x.com/strict-tree", "$dynamicAnchor": "node", "$ref": "tree", "unevaluatedProperties": false,
+            "$defs": {"tree": {
+                "$id": "http://x.com/tree", "$dynamicAnchor": "node", "type": "object",
+                "properties": {"data": true, "children": {"type": "array", "items": {"$dynamicRef": "#node"}}}
+            }}
+        }`, []string{`{"children":[{"data":1}]}`}, []string{`{"children":[{"daat":1}]}`, `{"children":[1]}`}},
+        {`{
+            "$id": "http://x.com/list", "$ref": "#/$defs/base", "$defs": {
+                "base": {"$dynamicRef": "#item"},
+                "item": {"$dynamicAnchor": "item", "type": "array"}
+            }
+        }`, []string{`[]`}, []string{`1`}},
+        {`{"$ref":"#/$defs/a","$defs":{"a":{"$dynamicRef":"#/$defs/b"},"b":{"type":"null"}}}`, []string{`null`}, []string{`1`}},
+    }
+    for _, tt := range tests {
+        s, err := CompileString(tt.schema)
+        require.NoError(t, err, tt.schema)
+        for _, src := range tt.valid {
+            assert.NoError(t, s.ValidateString(src), "%s: %s", tt.schema, src)
+        }
+        for _, src := range tt.invalid {
+            assert.IsType(t, Errors{}, s.ValidateString(src), "%s: %s", tt.schema, src)
+        }
+    }
+}
+
+func TestValidate_Errors(t *testing.T) {
+    s := MustCompile(`{
+        "type": "object",
+        "required": ["id", "tags"],
+        "properties": {
+            "id": {"type": "integer"},
+            "name": {"type": "string"},
+            "tags": {"type": "array", "items": {"type": "string"}},
+            "a/b": {"const": 1}
+        }
+    }`)
+
+    /* all the violations are reported */
+    err := s.ValidateString(`{"id": "1", "name": null, "tags": ["a", 2, 3], "a/b": 2}`)
+    require.IsType(t, Errors{}, err)
+    assert.Equal(t, Errors{
+        {InstancePath: "/id", SchemaPath: "/properties/id/type", Message: "expected integer, but got string"},
+        {InstancePath: "/name", SchemaPath: "/properties/name/type", Message: "expected string, but got null"},
+        {InstancePath: "/tags/1", SchemaPath: "/properties/tags/items/type", Message: "expected string, but got number"},
+        {InstancePath: "/tags/2", SchemaPath: "/properties/tags/items/type", Message: "expected string, but got number"},
+        {InstancePath: "/a~1b", SchemaPath: "/properties/a~1b/const", Message: "value must be the constant"},
+    }, err)
+
+    /* the numbers are reported by their literals */
+    err = MustCompile(`{"minimum":0,"multipleOf":0.5}`).ValidateString(`-1.25e0`)
+    assert.Equal(t, Errors{
+        {InstancePath: "", SchemaPath: "/multipleOf", Message: "-1.25e0 is not a multiple of 0.5"},
+        {InstancePath: "", SchemaPath: "/minimum", Message: "-1.25e0 is less than 0"},
+    }, err)
+
+    /* the absent members are different from the null ones */
+    err = s.ValidateString(`{"tags": []}`)
+    assert.Equal(t, Errors{{InstancePath: "", SchemaPath: "/required", Message: `missing property "id"`}}, err)
+    assert.Equal(t, `JSON Schema violations: "": missing property "id" (schema: "/required")`, err.Error())
+
+    /* the invalid JSON */
+    for _, c := range []struct {
+        src  string
+        code types.ParsingError
+        pos  int
+    }{
+        {`{"id": 1, "tags": [}`, types.ERR_INVALID_CHAR, 19},
+        {`{"id": 1, "tags": []} x`, types.ERR_INVALID_CHAR, 22},
+        {`{"id": 1, "tags": [1`, types.ERR_EOF, 20},
+    } {
+        err = s.ValidateString(c.src)
+        require.IsType(t, ast.SyntaxError{}, err, c.src)
+        assert.Equal(t, c.code, err.(ast.SyntaxError).Code, c.src)
+        assert.Equal(t, c.pos, err.(ast.SyntaxError).Pos, c.src)
+    }
+    for _, src := range []string{"{\"id\": \"\x01\"}", `{"id": 01}`, `{"id": -}`, `{"id": "\u00"}`, `{"x": [-], "id": 1}`,
+        `{"x": {"a":"\q"}, "id": 1}`, `{"x": ["\u12"], "id": 1}`, `{"x": "a" "b", "id": 1}`, `{"x": [1,], "id": 1}`} {
+        assert.IsType(t, ast.SyntaxError{}, s.ValidateString(src), src)
+    }
+
+    /* the values skipped are still checked */
+    err = s.ValidateString(`{"id": 1, "tags": [], "x": {"a-b": ["-\"-", "\\-", -1, "\u002d"]}}`)
+    assert.NoError(t, err)
+}
+
+func TestValidate_Scan(t *testing.T) {
+    s := MustCompile(`{
+        "properties": {"a": {"$ref": "#/$defs/a"}, "b": {}, "c": {"enum": [[1, {"d": 2}]]}},
+        "$defs": {"a": {"items": {"type": ["integer", "array"]}, "prefixItems": [{"properties": {"e": {"type": "string"}}}]}}
+    }`)
+    sc := scanner{s: `{"a": [{"e": "x", "f": [1]}, 2, [3]], "b": {"g": 1}, "c": [1, {"d": 2}], "h": [4]}`}
+    v, err := sc.scan(newDemand(s.root))
+    require.NoError(t, err)
+    require.Equal(t, []string{"a", "b", "c", "h"}, v.keys)
+
+    /* only the values constrained are parsed */
+    a := v.elems[0]
+    require.Nil(t, a.node)
+    require.Len(t, a.elems, 3)
+    assert.Nil(t, a.elems[0].node)
+    assert.Equal(t, []string{"e", "f"}, a.elems[0].keys)
+    assert.NotNil(t, a.elems[0].elems[1].node)
+    assert.Equal(t, "2", a.elems[1].lit)
+    assert.Equal(t, ast.V_ARRAY, a.elems[2].typ)
+    assert.NotNil(t, a.elems[2].elems[0].node)
+    assert.NotNil(t, v.elems[1].node)
+    assert.NotNil(t, v.elems[3].node)
+
+    /* the whole values are parsed for enum */
+    c, err := v.elems[2].value()
+    require.NoError(t, err)
+    assert.Equal(t, []interface{}{json.Number("1"), map[string]interface{}{"d": json.Number("2")}}, c)
+    assert.NoError(t, s.validate(&v))
+}
+
+func TestValidateNode(t *testing.T) {
+    s := MustCompile(`{"properties":{"a":{"type":"integer"}},"required":["a"]}`)
+    node := ast.NewRaw(`{"a":1,"b":{"c":[1,2,3]}}`)
+    require.NoError(t, s.ValidateNode(&node))
+
+    /* the members not constrained are not parsed */
+    assert.True(t, node.Get("b").IsRaw())
+
+    node = ast.NewObject([]ast.Pair{ast.NewPair("a", ast.NewString("x"))})
+    err := s.ValidateNode(&node)
+    assert.Equal(t, Errors{{InstancePath: "/a", SchemaPath: "/properties/a/type", Message: "expected integer, but got string"}}, err)
+
+    node = ast.NewAny(map[string]interface{}{"a": 1})
+    require.NoError(t, s.ValidateNode(&node))
+}
+
+func TestCompile_Errors(t *testing.T) {
+    for _, src := range []string{
+        `1`,
+        `{"type":"int"}`,
+        `{"minLength":-1}`,
+        `{"multipleOf":0}`,
+        `{"pattern":"("}`,
+        `{"allOf":[]}`,
+        `{"properties":{"a":1}}`,
+        `{"$ref":"#/$defs/x"}`,
+        `{"$ref":"#x"}`,
+        `{"$ref":"http://x.com/s.json"}`,
+        `{"$dynamicRef":"#/$defs/x"}`,
+        `{"$recursiveRef":"#"}`,
+        `{"unevaluatedProperties":1}`,
+        `{"a":`,
+    } {
+        _, err := CompileString(src)
+        assert.Error(t, err, src)
+    }
+    assert.Panics(t, func() { MustCompile(`{"type":1}`) })
+}
@@ -0,0 +1,778 @@
+/*
+ * Copyright 2024 ByteDance Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+    `encoding/json`
+    `fmt`
+    `math`
+    `math/big`
+    `sort`
+    `strconv`
+    `strings`
+    `unicode/utf8`
+
+    `github.com/bytedance/sonic/ast`
+)
+
+// instance is a value of the validated JSON, whose children are loaded once on demand.
+// The instances scanned from the raw JSON have no node, except the skipped raw values.
+type instance struct {
+    node   *ast.Node
+    typ    int
+    str    string
+    lit    string
+    num    *big.Rat
+    val    interface{}
+    hasVal bool
+    loaded bool
+    elems  []instance
+    keys   []string
+    index  map[string]int
+}
+
+func newInstance(node *ast.Node) *instance {
+    return &instance{node: node}
+}
+
+func (self *instance) init() error {
+    if self.typ != ast.V_NONE {
+        return nil
+    }
+    if err := self.node.Check(); err != nil {
+        return err
+    }
+
+    /* the Go values are validated as their JSON */
+    if self.typ = self.node.TypeSafe(); self.typ == ast.V_ANY {
+        raw, err := self.node.Raw()
+        if err != nil {
+            return err
+        }
+        n := ast.NewRaw(raw)
+        self.node = &n
+        self.typ = n.TypeSafe()
+    }
+    if self.typ == ast.V_NONE {
+        return ast.ErrNotExist
+    }
+    return nil
+}
+
+func (self *instance) typeName() string {
+    switch self.typ {
+        case ast.V_NULL                : return "null"
+        case ast.V_TRUE, ast.V_FALSE   : return "boolean"
+        case ast.V_OBJECT              : return "object"
+        case ast.V_ARRAY               : return "array"
+        case ast.V_STRING              : return "string"
+        default                        : return "number"
+    }
+}
+
+// number returns the value of number, and keeps its literal in lit.
+func (self *instance) number() (*big.Rat, error) {
+    if self.num == nil {
+        if self.node != nil {
+            num, err := self.node.Number()
+            if err != nil {
+                return nil, err
+            }
+            self.lit = string(num)
+        }
+        if self.num = parseNumber(json.Number(self.lit)); self.num == nil {
+            return nil, fmt.Errorf("invalid number %q", self.lit)
+        }
+    }
+    return self.num, nil
+}
+
+func (self *instance) string() (string, error) {
+    if self.node == nil {
+        return self.str, nil
+    }
+    return self.node.String()
+}
+
+func (self *instance) value() (interface{}, error) {
+    if self.hasVal {
+        return self.val, nil
+    }
+    var err error
+    if self.node != nil {
+        self.val, err = self.node.InterfaceUseNumber()
+    } else {
+        self.val, err = self.scannedValue()
+    }
+    if err != nil {
+        return nil, err
+    }
+    self.hasVal = true
+    return self.val, nil
+}
+
+func (self *instance) scannedValue() (interface{}, error) {
+    switch self.typ {
+        case ast.V_NULL   : return nil, nil
+        case ast.V_TRUE   : return true, nil
+        case ast.V_FALSE  : return false, nil
+        case ast.V_STRING : return self.str, nil
+        case ast.V_NUMBER : return json.Number(self.lit), nil
+        case ast.V_ARRAY:
+            ret := make([]interface{}, len(self.elems))
+            for i := range self.elems {
+                v, err := self.elems[i].value()
+                if err != nil {
+                    return nil, err
+                }
+                ret[i] = v
+            }
+            return ret, nil
+        default:
+            ret := make(map[string]interface{}, len(self.keys))
+            for i, k := range self.keys {
+                v, err := self.elems[i].value()
+                if err != nil {
+                    return nil, err
+                }
+                ret[k] = v
+            }
+            return ret, nil
+    }
+}
+
+// load loads the elements of array or the members of object,
+// the nodes of them stay raw until they are validated.
+func (self *instance) load() error {
+    if self.loaded {
+        return nil
+    }
+    err := self.node.ForEach(func(path ast.Sequence, node *ast.Node) bool {
+        self.elems = append(self.elems, instance{node: node})
+        if path.Key != nil {
+            self.keys = append(self.keys, *path.Key)
+        }
+        return true
+    })
+    if err != nil {
+        return err
+    }
+    self.loaded = true
+    return nil
+}
+
+// has reports if the object has the member of key.
+func (self *instance) has(key string) bool {
+    if self.index == nil {
+        self.index = make(map[string]int, len(self.keys))
+        for i := len(self.keys) - 1; i >= 0; i-- {
+            self.index[self.keys[i]] = i
+        }
+    }
+    _, ok := self.index[key]
+    return ok
+}
+
+// annotations are the members and items of an instance evaluated by the schemas applied
+// in place, which are collected for unevaluatedProperties and unevaluatedItems.
+type annotations struct {
+    props []bool
+    items []bool
+}
+
+func (self *annotations) evaluate(list *[]bool, n int, i int) {
+    if *list == nil {
+        *list = make([]bool, n)
+    }
+    (*list)[i] = true
+}
+
+func (self *annotations) merge(other *annotations) {
+    for _, p := range []struct{ dst *[]bool; src []bool }{{&self.props, other.props}, {&self.items, other.items}} {
+        for i, ok := range p.src {
+            if ok {
+                self.evaluate(p.dst, len(p.src), i)
+            }
+        }
+    }
+}
+
+type validator struct {
+    path  []byte
+    errs  Errors
+    scope []*resource  // the dynamic scope of the schema resources, from the outermost one
+}
+
+func (self *validator) fail(s *node, kw string, format string, args ...interface{}) {
+    sp := s.path
+    if kw != "" {
+        sp += "/" + kw
+    }
+    self.errs = append(self.errs, Error{
+        InstancePath : string(self.path),
+        SchemaPath   : sp,
+        Message      : fmt.Sprintf(format, args...),
+    })
+}
+
+func (self *validator) push(key string) int {
+    n := len(self.path)
+    self.path = append(append(self.path, '/'), escapePointerToken(key)...)
+    return n
+}
+
+func (self *validator) pushIndex(i int) int {
+    n := len(self.path)
+    self.path = strconv.AppendInt(append(self.path, '/'), int64(i), 10)
+    return n
+}
+
+// try validates v against s, and reports if it is valid without recording the violations.
+// The annotations of v are merged into ev only if v is valid.
+func (self *validator) try(s *node, v *instance, ev *annotations) (bool, error) {
+    var sub *annotations
+    if ev != nil {
+        sub = new(annotations)
+    }
+    n := len(self.errs)
+    err := self.validate(s, v, sub)
+    ok := len(self.errs) == n
+    self.errs = self.errs[:n]
+    if ok && ev != nil {
+        ev.merge(sub)
+    }
+    return ok, err
+}
+
+// validate validates v against s, and records the violations.
+// The members and items evaluated are collected into ev if it is not nil.
+// The returned error is the error of loading v.
+func (self *validator) validate(s *node, v *instance, ev *annotations) error {
+    if s.never {
+        self.fail(s, "", "value is not allowed")
+        return nil
+    }
+    if err := v.init(); err != nil {
+        return err
+    }
+
+    /* enter the schema resource */
+    if n := len(self.scope); n == 0 || self.scope[n - 1] != s.res {
+        self.scope = append(self.scope, s.res)
+        defer func() { self.scope = self.scope[:n] }()
+    }
+    if ev == nil && (s.unevalItems != nil || s.unevalProps != nil) {
+        ev = new(annotations)
+    }
+
+    if s.ref != nil {
+        if err := self.validate(s.ref, v, ev); err != nil {
+            return err
+        }
+    }
+    if s.dynRef != nil {
+        if err := self.validate(self.dynamicTarget(s), v, ev); err != nil {
+            return err
+        }
+    }
+    if err := self.validateValue(s, v); err != nil {
+        return err
+    }
+
+    var err error
+    switch v.typ {
+        case ast.V_NUMBER : err = self.validateNumber(s, v)
+        case ast.V_STRING : err = self.validateString(s, v)
+        case ast.V_ARRAY  : err = self.validateArray(s, v, ev)
+        case ast.V_OBJECT : err = self.validateObject(s, v, ev)
+    }
+    if err != nil {
+        return err
+    }
+    if err = self.validateApplicators(s, v, ev); err != nil {
+        return err
+    }
+    return self.validateUnevaluated(s, v, ev)
+}
+
+// dynamicTarget returns the schema of the $dynamicRef of s, which is the outermost
+// schema having the $dynamicAnchor in the dynamic scope.
+func (self *validator) dynamicTarget(s *node) *node {
+    if s.dynName != "" {
+        for _, r := range self.scope {
+            if a := r.anchors[s.dynName]; a != nil {
+                return a
+            }
+        }
+    }
+    return s.dynRef
+}
+
+func (self *validator) validateValue(s *node, v *instance) error {
+    if s.types != 0 {
+        ok := false
+        switch v.typ {
+            case ast.V_NULL              : ok = s.types & typeNull != 0
+            case ast.V_TRUE, ast.V_FALSE : ok = s.types & typeBoolean != 0
+            case ast.V_OBJECT            : ok = s.types & typeObject != 0
+            case ast.V_ARRAY             : ok = s.types & typeArray != 0
+            case ast.V_STRING            : ok = s.types & typeString != 0
+            case ast.V_NUMBER:
+                ok = s.types & typeNumber != 0
+                if !ok && s.types & typeInteger != 0 {
+                    num, err := v.number()
+                    if err != nil {
+                        return err
+                    }
+                    ok = num.IsInt()
+                }
+        }
+        if !ok {
+            self.fail(s, "type", "expected %s, but got %s", typesString(s.types), v.typeName())
+        }
+    }
+    if !s.hasConst && !s.hasEnum {
+        return nil
+    }
+
+    val, err := v.value()
+    if err != nil {
+        return err
+    }
+    if s.hasConst && !equalValues(val, s.constant) {
+        self.fail(s, "const", "value must be the constant")
+    }
+    if s.hasEnum {
+        for _, e := range s.enum {
+            if equalValues(val, e) {
+                return nil
+            }
+        }
+        self.fail(s, "enum", "value must be one of the enumerated values")
+    }
+    return nil
+}
+
+func (self *validator) validateNumber(s *node, v *instance) error {
+    if s.multipleOf == nil && s.maximum == nil && s.exclusiveMax == nil && s.minimum == nil && s.exclusiveMin == nil {
+        return nil
+    }
+    num, err := v.number()
+    if err != nil {
+        return err
+    }
+    if s.multipleOf != nil && !new(big.Rat).Quo(num, s.multipleOf.rat).IsInt() {
+        self.fail(s, "multipleOf", "%s is not a multiple of %s", v.lit, s.multipleOf.lit)
+    }
+    if s.maximum != nil && num.Cmp(s.maximum.rat) > 0 {
+        self.fail(s, "maximum", "%s is greater than %s", v.lit, s.maximum.lit)
+    }
+    if s.exclusiveMax != nil && num.Cmp(s.exclusiveMax.rat) >= 0 {
+        self.fail(s, "exclusiveMaximum", "%s is not less than %s", v.lit, s.exclusiveMax.lit)
+    }
+    if s.minimum != nil && num.Cmp(s.minimum.rat) < 0 {
+        self.fail(s, "minimum", "%s is less than %s", v.lit, s.minimum.lit)
+    }
+    if s.exclusiveMin != nil && num.Cmp(s.exclusiveMin.rat) <= 0 {
+        self.fail(s, "exclusiveMinimum", "%s is not greater than %s", v.lit, s.exclusiveMin.lit)
+    }
+    return nil
+}
+
+func (self *validator) validateString(s *node, v *instance) error {
+    if s.maxLength < 0 && s.minLength == 0 && s.pattern == nil {
+        return nil
+    }
+    str, err := v.string()
+    if err != nil {
+        return err
+    }
+    if n := utf8.RuneCountInString(str); s.maxLength >= 0 && n > s.maxLength {
+        self.fail(s, "maxLength", "length %d is greater than %d", n, s.maxLength)
+    } else if n < s.minLength {
+        self.fail(s, "minLength", "length %d is less than %d", n, s.minLength)
+    }
+    if s.pattern != nil && !s.pattern.MatchString(str) {
+        self.fail(s, "pattern", "%q does not match pattern %q", str, s.pattern.String())
+    }
+    return nil
+}
+
+func (self *validator) validateArray(s *node, v *instance, ev *annotations) error {
+    if s.maxItems < 0 && s.minItems == 0 && !s.uniqueItems && s.prefixItems == nil && s.items == nil && s.contains == nil {
+        return nil
+    }
+    if err := v.load(); err != nil {
+        return err
+    }
+    n := len(v.elems)
+    if s.maxItems >= 0 && n > s.maxItems {
+        self.fail(s, "maxItems", "%d items are more than %d", n, s.maxItems)
+    } else if n < s.minItems {
+        self.fail(s, "minItems", "%d items are less than %d", n, s.minItems)
+    }
+
+    if s.uniqueItems {
+        if err := self.validateUnique(s, v); err != nil {
+            return err
+        }
+    }
+
+    /* the items */
+    for i := range v.elems {
+        var item *node
+        if i < len(s.prefixItems) {
+            item = s.prefixItems[i]
+        } else if item = s.items; item == nil {
+            break
+        }
+        p := self.pushIndex(i)
+        if err := self.validate(item, &v.elems[i], nil); err != nil {
+            return err
+        }
+        self.path = self.path[:p]
+        if ev != nil {
+            ev.evaluate(&ev.items, len(v.elems), i)
+        }
+    }
+    if s.contains == nil {
+        return nil
+    }
+
+    /* the contained items */
+    matched := 0
+    for i := range v.elems {
+        p := self.pushIndex(i)
+        ok, err := self.try(s.contains, &v.elems[i], nil)
+        if err != nil {
+            return err
+        }
+        self.path = self.path[:p]
+        if ok {
+            matched++
+            if ev != nil {
+                ev.evaluate(&ev.items, len(v.elems), i)
+            }
+        }
+    }
+    if matched < s.minContains {
+        kw := "contains"
+        if matched > 0 {
+            kw = "minContains"
+        }
+        self.fail(s, kw, "%d items match the contains schema, less than %d", matched, s.minContains)
+    }
+    if s.maxContains >= 0 && matched > s.maxContains {
+        self.fail(s, "maxContains", "%d items match the contains schema, more than %d", matched, s.maxContains)
+    }
+    return nil
+}
+
+func (self *validator) validateUnique(s *node, v *instance) error {
+    for i := range v.elems {
+        a, err := v.elems[i].value()
+        if err != nil {
+            return err
+        }
+        for j := 0; j < i; j++ {
+            b, _ := v.elems[j].value()
+            if equalValues(a, b) {
+                self.fail(s, "uniqueItems", "items at %d and %d are equal", j, i)
+                return nil
+            }
+        }
+    }
+    return nil
+}
+
+func (self *validator) validateObject(s *node, v *instance, ev *annotations) error {
+    if s.maxProperties < 0 && s.minProperties == 0 && s.required == nil && s.depRequired == nil && s.depSchemas == nil &&
+       s.properties == nil && s.patternProps == nil && s.additional == nil && s.propertyNames == nil {
+        return nil
+    }
+    if err := v.load(); err != nil {
+        return err
+    }
+    n := len(v.keys)
+    if s.maxProperties >= 0 && n > s.maxProperties {
+        self.fail(s, "maxProperties", "%d properties are more than %d", n, s.maxProperties)
+    } else if n < s.minProperties {
+        self.fail(s, "minProperties", "%d properties are less than %d", n, s.minProperties)
+    }
+    for _, name := range s.required {
+        if !v.has(name) {
+            self.fail(s, "required", "missing property %q", name)
+        }
+    }
+    for _, dep := range s.depRequired {
+        if !v.has(dep.key) {
+            continue
+        }
+        for _, name := range dep.required {
+            if !v.has(name) {
+                self.fail(s, "dependentRequired/" + escapePointerToken(dep.key), "missing property %q required by %q", name, dep.key)
+            }
+        }
+    }
+    for _, dep := range s.depSchemas {
+        if !v.has(dep.key) {
+            continue
+        }
+        if err := self.validate(dep.schema, v, ev); err != nil {
+            return err
+        }
+    }
+
+    /* the members */
+    for i, key := range v.keys {
+        p := self.push(key)
+        evaluated, err := self.validateMember(s, key, &v.elems[i])
+        if err != nil {
+            return err
+        }
+        self.path = self.path[:p]
+        if evaluated && ev != nil {
+            ev.evaluate(&ev.props, len(v.keys), i)
+        }
+    }
+    return nil
+}
+
+// validateMember validates the member of key, and reports if any schema is applied to it.
+func (self *validator) validateMember(s *node, key string, v *instance) (bool, error) {
+    if s.propertyNames != nil {
+        if err := self.validate(s.propertyNames, &instance{typ: ast.V_STRING, str: key}, nil); err != nil {
+            return false, err
+        }
+    }
+
+    matched := false
+    if p, ok := s.properties[key]; ok {
+        matched = true
+        if err := self.validate(p, v, nil); err != nil {
+            return false, err
+        }
+    }
+    for _, pp := range s.patternProps {
+        if !pp.re.MatchString(key) {
+            continue
+        }
+        matched = true
+        if err := self.validate(pp.schema, v, nil); err != nil {
+            return false, err
+        }
+    }
+    if !matched && s.additional != nil {
+        return true, self.validate(s.additional, v, nil)
+    }
+    return matched, nil
+}
+
+func (self *validator) validateApplicators(s *node, v *instance, ev *annotations) error {
+    for _, sub := range s.allOf {
+        if err := self.validate(sub, v, ev); err != nil {
+            return err
+        }
+    }
+
+    /* all the schemas are tried for their annotations */
+    if s.anyOf != nil {
+        matched := false
+        for _, sub := range s.anyOf {
+            ok, err := self.try(sub, v, ev)
+            if err != nil {
+                return err
+            }
+            if matched = matched || ok; matched && ev == nil {
+                break
+            }
+        }
+        if !matched {
+            self.fail(s, "anyOf", "value does not match any of the schemas")
+        }
+    }
+
+    if s.oneOf != nil {
+        matched := 0
+        for _, sub := range s.oneOf {
+            ok, err := self.try(sub, v, ev)
+            if err != nil {
+                return err
+            }
+            if ok {
+                matched++
+            }
+        }
+        if matched != 1 {
+            self.fail(s, "oneOf", "value matches %d of the schemas, expected exactly one", matched)
+        }
+    }
+
+    if s.not != nil {
+        ok, err := self.try(s.not, v, nil)
+        if err != nil {
+            return err
+        }
+        if ok {
+            self.fail(s, "not", "value must not match the schema")
+        }
+    }
+
+    if s.ifs == nil {
+        return nil
+    }
+    ok, err := self.try(s.ifs, v, ev)
+    if err != nil {
+        return err
+    }
+    if ok && s.then != nil {
+        return self.validate(s.then, v, ev)
+    } else if !ok && s.els != nil {
+        return self.validate(s.els, v, ev)
+    }
+    return nil
+}
+
+// validateUnevaluated validates the items and members which are not evaluated by
+// the other keywords of s and the schemas applied in place.
+func (self *validator) validateUnevaluated(s *node, v *instance, ev *annotations) error {
+    var list *[]bool
+    var sub *node
+    switch {
+        case v.typ == ast.V_ARRAY && s.unevalItems != nil  : list, sub = &ev.items, s.unevalItems
+        case v.typ == ast.V_OBJECT && s.unevalProps != nil : list, sub = &ev.props, s.unevalProps
+        default                                            : return nil
+    }
+    if err := v.load(); err != nil {
+        return err
+    }
+    for i := range v.elems {
+        if *list != nil && (*list)[i] {
+            continue
+        }
+        var p int
+        if v.typ == ast.V_ARRAY {
+            p = self.pushIndex(i)
+        } else {
+            p = self.push(v.keys[i])
+        }
+        if err := self.validate(sub, &v.elems[i], nil); err != nil {
+            return err
+        }
+        self.path = self.path[:p]
+        ev.evaluate(list, len(v.elems), i)
+    }
+    return nil
+}
+
+// equalValues reports whether the JSON values are equal, the numbers are compared by their values.
+func equalValues(a interface{}, b interface{}) bool {
+    switch x := a.(type) {
+        case json.Number:
+            y, ok := b.(json.Number)
+            if !ok {
+                return false
+            }
+            if x == y {
+                return true
+            }
+            rx, ry := parseNumber(x), parseNumber(y)
+            return rx != nil && ry != nil && rx.Cmp(ry) == 0
+        case string:
+            y, ok := b.(string)
+            return ok && x == y
+        case bool:
+            y, ok := b.(bool)
+            return ok && x == y
+        case nil:
+            return b == nil
+        case []interface{}:
+            y, ok := b.([]interface{})
+            if !ok || len(x) != len(y) {
+                return false
+            }
+            for i := range x {
+                if !equalValues(x[i], y[i]) {
+                    return false
+                }
+            }
+            return true
+        case map[string]interface{}:
+            y, ok := b.(map[string]interface{})
+            if !ok || len(x) != len(y) {
+                return false
+            }
+            for k, xv := range x {
+                if yv, ok := y[k]; !ok || !equalValues(xv, yv) {
+                    return false
+                }
+            }
+            return true
+        default:
+            return false
+    }
+}
+
+// maxExponent limits the exponents of the numbers computed exactly,
+// the numbers having larger exponents are approximated by float64.
+const maxExponent = 1024
+
+func parseNumber(num json.Number) *big.Rat {
+    s := string(num)
+    if i := strings.IndexAny(s, "eE"); i >= 0 {
+        if e, err := strconv.Atoi(s[i + 1:]); err != nil || e > maxExponent || e < -maxExponent {
+            f, err := strconv.ParseFloat(s, 64)
+            if err != nil && !math.IsInf(f, 0) {
+                return nil
+            }
+            f = math.Max(-math.MaxFloat64, math.Min(f, math.MaxFloat64))
+            return new(big.Rat).SetFloat64(f)
+        }
+    }
+    r, ok := new(big.Rat).SetString(s)
+    if !ok {
+        return nil
+    }
+    return r
+}
+
+func typesString(types int) string {
+    var names []string
+    for i, name := range typeNames {
+        if types & (1 << i) != 0 {
+            names = append(names, name)
+        }
+    }
+    return strings.Join(names, " or ")
+}
+
+// escapePointerToken escapes '~' and '/' of the JSON Pointer token.
+func escapePointerToken(key string) string {
+    if strings.IndexAny(key, "~/") < 0 {
+        return key
+    }
+    return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
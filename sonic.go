@@ -36,6 +36,8 @@ type frozenConfig struct {
     Config
     encoderOpts encoder.Options
     decoderOpts decoder.Options
+    encoder     *encoder.Encoder // the shared encoder with the custom states, or nil
+    encoderErr  error            // the error of the invalid encoder options
 }
 
 // Froze convert the Config to API
@@ -70,6 +72,28 @@ func (cfg Config) Froze() API {
     if cfg.EncodeNullForInfOrNan {
         api.encoderOpts |= encoder.EncodeNullForInfOrNan
     }
+    if cfg.RedactSensitive {
+        api.encoderOpts |= encoder.RedactSensitive
+    }
+    if cfg.Canonical {
+        api.encoderOpts |= encoder.Canonical
+    }
+    if cfg.EscapeASCII {
+        api.encoderOpts |= encoder.EscapeASCII
+    }
+    if cfg.EscapeSlash {
+        api.encoderOpts |= encoder.EscapeSlash
+    }
+    if cfg.Int64AsString {
+        api.encoderOpts |= encoder.Int64AsString
+    }
+    if cfg.LargeInt64AsString {
+        api.encoderOpts |= encoder.LargeInt64AsString
+    }
+    if cfg.DetectCycles {
+        api.encoderOpts |= encoder.DetectCycles
+    }
+    api.encoder, api.encoderErr = api.newEncoder()
 
     // configure decoder options:
     if cfg.NoValidateJSONSkip {
@@ -90,22 +114,60 @@ func (cfg Config) Froze() API {
     if cfg.ValidateString {
         api.decoderOpts |= decoder.OptionValidateString
     }
+    if cfg.Int64AsString || cfg.LargeInt64AsString {
+        api.decoderOpts |= decoder.OptionInt64FromString
+    }
     return api
 }
 
+// newEncoder returns the encoder with the custom Redactor, EscapeChars, FloatFormat
+// or limits, or nil if it is not needed. It is built only once by Froze, and shared
+// by all the calls since Encoder.Encode does not modify it.
+func (cfg frozenConfig) newEncoder() (*encoder.Encoder, error) {
+    if (!cfg.RedactSensitive || cfg.Redactor == nil) && cfg.EscapeChars == "" && cfg.FloatFormat == (encoder.FloatFormat{}) &&
+        cfg.EncoderMaxDepth <= 0 && cfg.EncoderMaxSize <= 0 {
+        return nil, nil
+    }
+    enc := &encoder.Encoder{Opts: cfg.encoderOpts}
+    if err := cfg.setupEncoder(enc); err != nil {
+        return nil, err
+    }
+    return enc, nil
+}
+
+func (cfg frozenConfig) setupEncoder(enc *encoder.Encoder) error {
+    enc.SetRedactor(cfg.Redactor)
+    enc.SetEscapeChars(cfg.EscapeChars)
+    enc.SetMaxDepth(cfg.EncoderMaxDepth)
+    enc.SetMaxSize(cfg.EncoderMaxSize)
+    return enc.SetFloatFormat(cfg.FloatFormat)
+}
+
 // Marshal is implemented by sonic
 func (cfg frozenConfig) Marshal(val interface{}) ([]byte, error) {
+    if cfg.encoderErr != nil {
+        return nil, cfg.encoderErr
+    }
+    if enc := cfg.encoder; enc != nil {
+        return enc.Encode(val)
+    }
     return encoder.Encode(val, cfg.encoderOpts)
 }
 
 // MarshalToString is implemented by sonic
 func (cfg frozenConfig) MarshalToString(val interface{}) (string, error) {
-    buf, err := encoder.Encode(val, cfg.encoderOpts)
+    buf, err := cfg.Marshal(val)
     return rt.Mem2Str(buf), err
 }
 
 // MarshalIndent is implemented by sonic
 func (cfg frozenConfig) MarshalIndent(val interface{}, prefix, indent string) ([]byte, error) {
+    if cfg.encoderErr != nil {
+        return nil, cfg.encoderErr
+    }
+    if enc := cfg.encoder; enc != nil {
+        return enc.EncodeIndented(val, prefix, indent)
+    }
     return encoder.EncodeIndented(val, prefix, indent, cfg.encoderOpts)
 }
 
@@ -130,7 +192,13 @@ func (cfg frozenConfig) Unmarshal(buf []byte, val interface{}) error {
 
 // NewEncoder is implemented by sonic
 func (cfg frozenConfig) NewEncoder(writer io.Writer) Encoder {
+    if cfg.encoderErr != nil {
+        return invalidEncoder{cfg.encoderErr}
+    }
     enc := encoder.NewStreamEncoder(writer)
+    if cfg.encoder != nil {
+        enc.Encoder = *cfg.encoder
+    }
     enc.Opts = cfg.encoderOpts
     return enc
 }